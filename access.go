@@ -2,6 +2,8 @@ package macaroon
 
 import (
 	"time"
+
+	"github.com/superfly/macaroon/access"
 )
 
 // Access represents the user's attempt to access some resource. Different
@@ -16,3 +18,45 @@ type Access interface {
 	// Callback for validating the structure
 	Validate() error
 }
+
+// CompositeAccess combines several Access implementations into one, so
+// products layering caveats on top of another product's (e.g. flyio's) can add
+// their own request context without forking its Access. The action and time
+// come from the primary Access. Caveats find the other parts with [access.As].
+// Create one with [Compose].
+type CompositeAccess struct {
+	primary Access
+	parts   []any
+}
+
+var _ access.Composite = (*CompositeAccess)(nil)
+
+// Compose creates a CompositeAccess from a primary Access and other parts.
+// Parts may implement Access, in which case they're validated along with the
+// primary, or just the extensions defined in the access package.
+func Compose(primary Access, parts ...any) *CompositeAccess {
+	return &CompositeAccess{primary: primary, parts: parts}
+}
+
+func (c *CompositeAccess) GetAction() Action { return c.primary.GetAction() }
+func (c *CompositeAccess) Now() time.Time    { return c.primary.Now() }
+
+// Validate validates the primary Access and any other parts implementing
+// Access.
+func (c *CompositeAccess) Validate() error {
+	err := c.primary.Validate()
+
+	for _, part := range c.parts {
+		if a, ok := part.(Access); ok {
+			err = appendErrs(err, a.Validate())
+		}
+	}
+
+	return err
+}
+
+// Parts implements [access.Composite], returning the primary Access followed
+// by the other parts.
+func (c *CompositeAccess) Parts() []any {
+	return append([]any{c.primary}, c.parts...)
+}
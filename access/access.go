@@ -0,0 +1,253 @@
+// Package access defines optional extensions to [macaroon.Access].
+//
+// The core Access interface only describes the action being attempted and
+// the current time. Caveats that need more context about a request (where it
+// came from, who it is for, what it contains) probe the Access for one of the
+// small interfaces defined here rather than type-switching on a concrete,
+// product-specific Access implementation.
+//
+// This package deliberately doesn't import the macaroon package, so that the
+// core library and product packages alike can depend on it.
+//
+// [macaroon.Access]: https://pkg.go.dev/github.com/superfly/macaroon#Access
+package access
+
+import (
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// RemoteAddr is implemented by Accesses that know the network address the
+// request originated from.
+type RemoteAddr interface {
+	GetRemoteAddr() netip.Addr
+}
+
+// Audience is implemented by Accesses that know which service the request is
+// being made to.
+type Audience interface {
+	GetAudience() string
+}
+
+// RequestHash is implemented by Accesses that can provide a digest of the
+// request being authorized (e.g. a hash of the HTTP method, path and body).
+type RequestHash interface {
+	GetRequestHash() []byte
+}
+
+// UserID is implemented by Accesses that know the ID of the user making the
+// request.
+type UserID interface {
+	GetUserID() uint64
+}
+
+// Usage is implemented by Accesses that can report the usage of metered
+// resources (e.g. machines created, GB-hours), typically by querying a usage
+// service. GetUsage returns the resource's current usage and the amount the
+// request would add to it.
+type Usage interface {
+	GetUsage(resource string) (current, requested uint64, err error)
+}
+
+// EstimatedCost is implemented by Accesses that can estimate the monetary
+// cost of the request being authorized. The amount is in the currency's
+// minor unit (e.g. cents) and the currency is an ISO 4217 code.
+type EstimatedCost interface {
+	GetEstimatedCost() (amount uint64, currency string)
+}
+
+// StrongAuth is implemented by Accesses that know when the session making the
+// request last completed strong authentication (e.g. MFA) and with which
+// method. ok is false if it never has.
+type StrongAuth interface {
+	GetStrongAuth() (method string, at time.Time, ok bool)
+}
+
+// WebAuthn is implemented by Accesses for requests that may carry a WebAuthn
+// assertion. VerifyWebAuthnAssertion checks that the request carries a valid
+// assertion, made with the specified credential, over a fresh challenge
+// issued by the service.
+type WebAuthn interface {
+	VerifyWebAuthnAssertion(credentialID []byte) error
+}
+
+// ResourceKey is implemented by Accesses that can identify the resource being
+// accessed with a single opaque key (e.g. "app:123"), for caveats that match
+// resources by key rather than by type. ok is false if the access doesn't
+// involve a specific resource.
+type ResourceKey interface {
+	GetResourceKey() (key string, ok bool)
+}
+
+// ChannelBinding is implemented by Accesses for requests made over a TLS
+// connection, returning the connection's RFC 9266 tls-exporter channel
+// binding (see macaroon.TLSChannelBinding).
+type ChannelBinding interface {
+	GetChannelBinding() []byte
+}
+
+// Verifier is implemented by Accesses that know which instance of the service
+// is verifying the token, identified by its hostname and machine ID. Either
+// may be empty if unknown.
+type Verifier interface {
+	GetVerifier() (hostname, machineID string)
+}
+
+// Labels is implemented by Accesses that know the labels (key=value tags) of
+// the resource being accessed, for caveats that match resources by label
+// rather than by ID. ok is false if the access doesn't involve a specific
+// resource.
+type Labels interface {
+	GetLabels() (labels map[string]string, ok bool)
+}
+
+// Composite is implemented by Accesses composed of several smaller Access
+// implementations (e.g. a product's Access alongside one providing request
+// metadata). Parts returns the parts, which are searched by [As].
+type Composite interface {
+	Parts() []any
+}
+
+// As finds the first part of the access implementing T: either the access
+// itself or, for a [Composite], one of its parts (recursively). Caveats should
+// probe Accesses with As rather than with type assertions, so they work with
+// composite Accesses.
+func As[T any](a any) (T, bool) {
+	if t, ok := a.(T); ok {
+		return t, true
+	}
+
+	if c, ok := a.(Composite); ok {
+		for _, part := range c.Parts() {
+			if t, ok := As[T](part); ok {
+				return t, true
+			}
+		}
+	}
+
+	var zero T
+	return zero, false
+}
+
+// Capability identifies one of the optional Access extensions defined in this
+// package.
+type Capability uint8
+
+const (
+	CapRemoteAddr Capability = iota + 1
+	CapAudience
+	CapRequestHash
+	CapUserID
+	CapUsage
+	CapEstimatedCost
+	CapStrongAuth
+	CapWebAuthn
+	CapResourceKey
+	CapChannelBinding
+	CapVerifier
+	CapLabels
+)
+
+// AllCapabilities lists every Capability known to this package.
+var AllCapabilities = []Capability{
+	CapRemoteAddr,
+	CapAudience,
+	CapRequestHash,
+	CapUserID,
+	CapUsage,
+	CapEstimatedCost,
+	CapStrongAuth,
+	CapWebAuthn,
+	CapResourceKey,
+	CapChannelBinding,
+	CapVerifier,
+	CapLabels,
+}
+
+func (c Capability) String() string {
+	switch c {
+	case CapRemoteAddr:
+		return "remote-addr"
+	case CapAudience:
+		return "audience"
+	case CapRequestHash:
+		return "request-hash"
+	case CapUserID:
+		return "user-id"
+	case CapUsage:
+		return "usage"
+	case CapEstimatedCost:
+		return "estimated-cost"
+	case CapStrongAuth:
+		return "strong-auth"
+	case CapWebAuthn:
+		return "webauthn"
+	case CapResourceKey:
+		return "resource-key"
+	case CapChannelBinding:
+		return "channel-binding"
+	case CapVerifier:
+		return "verifier"
+	case CapLabels:
+		return "labels"
+	default:
+		return fmt.Sprintf("capability(%d)", uint8(c))
+	}
+}
+
+// Supports checks whether the access (or one of its parts, see [As])
+// implements the interface corresponding to the specified capability.
+func Supports(a any, c Capability) bool {
+	switch c {
+	case CapRemoteAddr:
+		_, ok := As[RemoteAddr](a)
+		return ok
+	case CapAudience:
+		_, ok := As[Audience](a)
+		return ok
+	case CapRequestHash:
+		_, ok := As[RequestHash](a)
+		return ok
+	case CapUserID:
+		_, ok := As[UserID](a)
+		return ok
+	case CapUsage:
+		_, ok := As[Usage](a)
+		return ok
+	case CapEstimatedCost:
+		_, ok := As[EstimatedCost](a)
+		return ok
+	case CapStrongAuth:
+		_, ok := As[StrongAuth](a)
+		return ok
+	case CapWebAuthn:
+		_, ok := As[WebAuthn](a)
+		return ok
+	case CapResourceKey:
+		_, ok := As[ResourceKey](a)
+		return ok
+	case CapChannelBinding:
+		_, ok := As[ChannelBinding](a)
+		return ok
+	case CapVerifier:
+		_, ok := As[Verifier](a)
+		return ok
+	case CapLabels:
+		_, ok := As[Labels](a)
+		return ok
+	default:
+		return false
+	}
+}
+
+// Capabilities returns all the capabilities supported by the access.
+func Capabilities(a any) []Capability {
+	var ret []Capability
+	for _, c := range AllCapabilities {
+		if Supports(a, c) {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
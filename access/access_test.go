@@ -0,0 +1,49 @@
+package access
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+type remoteAddrAccess struct{ addr netip.Addr }
+
+func (a *remoteAddrAccess) GetRemoteAddr() netip.Addr { return a.addr }
+
+type fullAccess struct{ remoteAddrAccess }
+
+func (a *fullAccess) GetAudience() string    { return "aud" }
+func (a *fullAccess) GetRequestHash() []byte { return []byte{1, 2, 3} }
+func (a *fullAccess) GetUserID() uint64      { return 123 }
+func (a *fullAccess) GetUsage(string) (uint64, uint64, error) {
+	return 0, 0, nil
+}
+func (a *fullAccess) GetEstimatedCost() (uint64, string) { return 0, "USD" }
+func (a *fullAccess) GetStrongAuth() (string, time.Time, bool) {
+	return "", time.Time{}, false
+}
+func (a *fullAccess) VerifyWebAuthnAssertion([]byte) error { return nil }
+func (a *fullAccess) GetResourceKey() (string, bool)       { return "app:1", true }
+func (a *fullAccess) GetChannelBinding() []byte            { return []byte{1} }
+func (a *fullAccess) GetVerifier() (string, string)        { return "host", "machine" }
+func (a *fullAccess) GetLabels() (map[string]string, bool) { return nil, true }
+
+func TestSupports(t *testing.T) {
+	ra := &remoteAddrAccess{netip.MustParseAddr("127.0.0.1")}
+
+	assert.True(t, Supports(ra, CapRemoteAddr))
+	assert.False(t, Supports(ra, CapAudience))
+	assert.False(t, Supports(ra, CapRequestHash))
+	assert.False(t, Supports(ra, CapUserID))
+	assert.False(t, Supports(ra, Capability(0)))
+	assert.Equal(t, []Capability{CapRemoteAddr}, Capabilities(ra))
+
+	fa := &fullAccess{}
+	assert.Equal(t, AllCapabilities, Capabilities(fa))
+	assert.Zero(t, Capabilities(struct{}{}))
+
+	assert.Equal(t, "remote-addr", CapRemoteAddr.String())
+	assert.Equal(t, "capability(99)", Capability(99).String())
+}
@@ -0,0 +1,47 @@
+package macaroon
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon/access"
+)
+
+type usagePart struct {
+	usage map[string][2]uint64
+}
+
+func (p *usagePart) GetUsage(resource string) (uint64, uint64, error) {
+	u := p.usage[resource]
+	return u[0], u[1], nil
+}
+
+type invalidAccess struct{ testAccess }
+
+func (a *invalidAccess) Validate() error { return ErrInvalidAccess }
+
+func TestCompose(t *testing.T) {
+	primary := &testAccess{action: ActionWrite, parentResource: ptr(uint64(1))}
+	cs := NewCaveatSet(cavParent(ActionWrite, 1), &Quota{Resource: "machines", Limit: 10})
+
+	// the primary access alone can't satisfy the quota
+	assert.True(t, errors.Is(cs.Validate(primary), ErrInvalidAccess))
+
+	a := Compose(primary, &usagePart{map[string][2]uint64{"machines": {1, 1}}})
+	assert.Equal(t, ActionWrite, a.GetAction())
+	assert.NoError(t, cs.Validate(a))
+
+	a = Compose(primary, &usagePart{map[string][2]uint64{"machines": {10, 1}}})
+	assert.Error(t, cs.Validate(a))
+
+	// nested composites are searched
+	_, ok := access.As[access.Usage](Compose(primary, Compose(&testAccess{}, &usagePart{})))
+	assert.True(t, ok)
+	_, ok = access.As[access.UserID](a)
+	assert.False(t, ok)
+	assert.True(t, access.Supports(a, access.CapUsage))
+
+	// parts implementing Access are validated
+	assert.True(t, errors.Is(Compose(primary, &invalidAccess{}).Validate(), ErrInvalidAccess))
+}
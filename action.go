@@ -1,6 +1,10 @@
 package macaroon
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
 
 // Action is an RWX-style bitmap of actions that can be taken on a resource
 // (eg org, app, machine). An Action can describe the permission limitations
@@ -42,57 +46,131 @@ const (
 )
 
 const (
+	// ActionAll is the built-in actions. It's fixed when tokens are minted,
+	// so grants of ActionAll don't include actions registered later (see
+	// [RegisterAction]). To be explicit about whether a grant should include
+	// future actions, use [ActionAllCurrent] or [ActionAllCurrentAndFuture].
 	ActionAll  = ActionRead | ActionWrite | ActionCreate | ActionDelete | ActionControl
 	ActionNone = Action(0)
+
+	// ActionAllCurrentAndFuture is every action bit, including those of
+	// actions registered after a token granting it was minted. Its string
+	// form is "*".
+	ActionAllCurrentAndFuture = Action(0xffff)
 )
 
-func ActionFromString(ms string) Action {
+// ActionAllCurrent returns the currently registered actions, including
+// registered actions beyond the built-in ones. Grants of the returned Action
+// are a snapshot: they don't include actions registered later.
+func ActionAllCurrent() Action {
 	var ret Action
+	for _, ra := range actions {
+		ret |= ra.action
+	}
+	return ret
+}
 
-	if ms == "*" {
-		ret = 0xffff
-		return ret
+// registeredAction describes an action bit. See [RegisterAction].
+type registeredAction struct {
+	action Action
+	name   string
+	code   rune
+}
+
+// actions are the registered actions, ordered by bit.
+var actions []registeredAction
+
+func init() {
+	RegisterAction("read", 'r', ActionRead)
+	RegisterAction("write", 'w', ActionWrite)
+	RegisterAction("create", 'c', ActionCreate)
+	RegisterAction("delete", 'd', ActionDelete)
+	RegisterAction("control", 'C', ActionControl)
+}
+
+// RegisterAction registers an additional action bit, beyond the built-in
+// actions, for use by a particular resource type (e.g. creating volume
+// snapshots). The code is the character used for the action in its string
+// form. Action bits and codes are part of the token format, so they must be
+// chosen in coordination with other users of this library.
+//
+// Note that grants of ActionAll or ActionAllCurrent don't include actions
+// registered after they were minted. Grants of ActionAllCurrentAndFuture
+// ("*") do.
+func RegisterAction(name string, code rune, a Action) {
+	switch {
+	case a == ActionNone || a&(a-1) != 0:
+		panic(fmt.Sprintf("action %s must be a single bit", name))
+	case code == '*':
+		panic("action code * is reserved")
 	}
 
-	for _, mc := range ms {
-		switch mc {
-		case 'r':
-			ret |= ActionRead
-		case 'w':
-			ret |= ActionWrite
-		case 'c':
-			ret |= ActionCreate
-		case 'd':
-			ret |= ActionDelete
-		case 'C':
-			ret |= ActionControl
+	for _, ra := range actions {
+		switch {
+		case ra.action == a:
+			panic(fmt.Sprintf("action %s conflicts with %s", name, ra.name))
+		case ra.code == code:
+			panic(fmt.Sprintf("action code %c for %s conflicts with %s", code, name, ra.name))
+		case ra.name == name:
+			panic(fmt.Sprintf("duplicate action %s", name))
 		}
 	}
 
-	return ret
+	actions = append(actions, registeredAction{a, name, code})
+	sort.Slice(actions, func(i, j int) bool { return actions[i].action < actions[j].action })
 }
 
-func (a Action) String() string {
-	str := []byte{}
+// ActionByName returns the registered action with the given name.
+func ActionByName(name string) (Action, bool) {
+	for _, ra := range actions {
+		if ra.name == name {
+			return ra.action, true
+		}
+	}
+	return ActionNone, false
+}
 
-	if a&ActionRead != 0 {
-		str = append(str, 'r')
+// Names returns the names of the registered actions whose bits are set in a.
+func (a Action) Names() []string {
+	var ret []string
+	for _, ra := range actions {
+		if a&ra.action != 0 {
+			ret = append(ret, ra.name)
+		}
 	}
+	return ret
+}
 
-	if a&ActionWrite != 0 {
-		str = append(str, 'w')
+func ActionFromString(ms string) Action {
+	var ret Action
+
+	if ms == "*" {
+		return ActionAllCurrentAndFuture
 	}
 
-	if a&ActionCreate != 0 {
-		str = append(str, 'c')
+	for _, mc := range ms {
+		for _, ra := range actions {
+			if ra.code == mc {
+				ret |= ra.action
+				break
+			}
+		}
 	}
 
-	if a&ActionDelete != 0 {
-		str = append(str, 'd')
+	return ret
+}
+
+func (a Action) String() string {
+	if a == ActionAllCurrentAndFuture {
+		return "*"
 	}
 
-	if a&ActionControl != 0 {
-		str = append(str, 'C')
+	str := []rune{}
+
+	for _, ra := range actions {
+		if a&ra.action != 0 {
+			str = append(str, ra.code)
+		}
 	}
 
 	return string(str)
@@ -0,0 +1,59 @@
+package macaroon
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestActionRegistry(t *testing.T) {
+	assert.Equal(t, "rwcdC", ActionAll.String())
+	assert.Equal(t, ActionAll, ActionFromString("rwcdC"))
+	assert.Equal(t, []string{"read", "delete"}, (ActionRead | ActionDelete).Names())
+
+	a, ok := ActionByName("control")
+	assert.True(t, ok)
+	assert.Equal(t, ActionControl, a)
+
+	_, ok = ActionByName("bogus")
+	assert.False(t, ok)
+
+	assert.Panics(t, func() { RegisterAction("two-bits", 'z', 1<<14|1<<15) })
+	assert.Panics(t, func() { RegisterAction("dup-bit", 'z', ActionRead) })
+	assert.Panics(t, func() { RegisterAction("dup-code", 'r', 1<<15) })
+	assert.Panics(t, func() { RegisterAction("read", 'z', 1<<15) })
+	assert.Panics(t, func() { RegisterAction("star", '*', 1<<15) })
+}
+
+func TestActionAllFuture(t *testing.T) {
+	saved := append([]registeredAction(nil), actions...)
+	t.Cleanup(func() { actions = saved })
+
+	current := ActionAllCurrent()
+	assert.Equal(t, ActionAll, current)
+	assert.Equal(t, ActionAllCurrentAndFuture, ActionFromString("*"))
+	assert.Equal(t, "*", ActionAllCurrentAndFuture.String())
+
+	const actionNew Action = 1 << 15
+	RegisterAction("new", 'n', actionNew)
+
+	// only the future-inclusive grant covers the new action
+	assert.False(t, actionNew.IsSubsetOf(ActionAll))
+	assert.False(t, actionNew.IsSubsetOf(current))
+	assert.True(t, actionNew.IsSubsetOf(ActionAllCurrentAndFuture))
+	assert.Equal(t, ActionAll|actionNew, ActionAllCurrent())
+
+	// and survives a round trip through JSON as such
+	buf, err := json.Marshal(ActionAllCurrentAndFuture)
+	assert.NoError(t, err)
+	assert.Equal(t, `"*"`, string(buf))
+	var a Action
+	assert.NoError(t, json.Unmarshal(buf, &a))
+	assert.Equal(t, ActionAllCurrentAndFuture, a)
+
+	buf, err = json.Marshal(current)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(buf, &a))
+	assert.Equal(t, current, a)
+}
@@ -0,0 +1,36 @@
+package macaroon
+
+import "fmt"
+
+// Attenuate decodes the encoded token, adds the caveats to it and returns the
+// re-encoded token. This is the only mutation a holder who isn't the token's
+// issuer should perform, and it doesn't require any keys. See [Macaroon.Add].
+func Attenuate(encoded []byte, caveats ...Caveat) ([]byte, error) {
+	m, err := Decode(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("attenuate: %w", err)
+	}
+
+	if err := m.Add(caveats...); err != nil {
+		return nil, fmt.Errorf("attenuate: %w", err)
+	}
+
+	return m.Encode()
+}
+
+// Attenuate3P is like [Attenuate], but adds a third-party caveat for the
+// third party at loc, sharing the key ka with it. The caveats are included in
+// the ticket for the third party to check before discharging. See
+// [Macaroon.Add3P].
+func Attenuate3P(encoded []byte, ka EncryptionKey, loc string, caveats ...Caveat) ([]byte, error) {
+	m, err := Decode(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("attenuate: %w", err)
+	}
+
+	if err := m.Add3P(ka, loc, caveats...); err != nil {
+		return nil, fmt.Errorf("attenuate: %w", err)
+	}
+
+	return m.Encode()
+}
@@ -0,0 +1,42 @@
+package macaroon
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestAttenuateEncoded(t *testing.T) {
+	var (
+		key = NewSigningKey()
+		ka  = NewEncryptionKey()
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	tok, err := m.Encode()
+	assert.NoError(t, err)
+
+	tok, err = Attenuate(tok, cavParent(ActionRead, 1))
+	assert.NoError(t, err)
+
+	tok, err = Attenuate3P(tok, ka, "https://auth", cavChild(ActionRead, 2))
+	assert.NoError(t, err)
+
+	_, dcavs, dm, err := dischargeMacaroon(ka, "https://auth", tok)
+	assert.NoError(t, err)
+	assert.Equal(t, []Caveat{cavChild(ActionRead, 2)}, dcavs)
+	dtok, err := dm.Encode()
+	assert.NoError(t, err)
+
+	decoded, err := Decode(tok)
+	assert.NoError(t, err)
+	cavs, err := decoded.Verify(key, [][]byte{dtok}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []Caveat{cavParent(ActionRead, 1)}, cavs.Caveats)
+
+	_, err = Attenuate([]byte("bogus"), cavParent(ActionRead, 1))
+	assert.Error(t, err)
+	_, err = Attenuate3P([]byte("bogus"), ka, "https://auth")
+	assert.Error(t, err)
+}
@@ -0,0 +1,62 @@
+package macaroon
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimestampedAttestation is implemented by attestations recording when the
+// third party made them, e.g. when the user authenticated. Attestations that
+// don't know should return the zero time. See [WithAttestationMaxAge].
+type TimestampedAttestation interface {
+	Caveat
+	AttestedAt() time.Time
+}
+
+// WithAttestationMaxAge requires verified attestations of the specified types
+// to have been made within maxAge, as reported by [TimestampedAttestation].
+// Attestations of those types without a timestamp are rejected, so discharges
+// can't vouch for a user indefinitely. The option may be used more than once,
+// to set different ages for different types. Differences between our clock
+// and the third parties' are tolerated as configured with [WithClockSkew].
+func WithAttestationMaxAge(maxAge time.Duration, types ...CaveatType) VerifyOption {
+	return func(o *verifyOptions) {
+		if o.attestationMaxAge == nil {
+			o.attestationMaxAge = make(map[CaveatType]time.Duration, len(types))
+		}
+		for _, t := range types {
+			o.attestationMaxAge[t] = maxAge
+		}
+	}
+}
+
+// checkAttestationAge checks the ages of the verified caveats' attestations
+// against the ages passed to WithAttestationMaxAge.
+func (o *verifyOptions) checkAttestationAge(cs *CaveatSet, now time.Time) error {
+	if len(o.attestationMaxAge) == 0 {
+		return nil
+	}
+
+	for _, c := range cs.Caveats {
+		maxAge, ok := o.attestationMaxAge[c.CaveatType()]
+		if !ok || !c.IsAttestation() {
+			continue
+		}
+
+		name := CaveatTypeName(c.CaveatType())
+
+		ta, ok := c.(TimestampedAttestation)
+		if !ok || ta.AttestedAt().IsZero() {
+			return fmt.Errorf("macaroon verify: %s attestation has no timestamp", name)
+		}
+
+		switch age := now.Sub(ta.AttestedAt()); {
+		case age < -o.clockSkew:
+			return fmt.Errorf("macaroon verify: %s attestation made in the future", name)
+		case age > maxAge+o.clockSkew:
+			return fmt.Errorf("macaroon verify: %s attestation too old (made %s ago)", name, age.Truncate(time.Second))
+		}
+	}
+
+	return nil
+}
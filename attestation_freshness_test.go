@@ -0,0 +1,86 @@
+package macaroon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+type testTimestampedAttestation struct{ At int64 }
+
+func init() {
+	RegisterCaveatType("TestTimestamped", cavTestTimestamped, &testTimestampedAttestation{})
+}
+
+func (c *testTimestampedAttestation) CaveatType() CaveatType   { return cavTestTimestamped }
+func (c *testTimestampedAttestation) Prohibits(f Access) error { return nil }
+func (c *testTimestampedAttestation) IsAttestation() bool      { return true }
+
+func (c *testTimestampedAttestation) AttestedAt() time.Time {
+	if c.At == 0 {
+		return time.Time{}
+	}
+	return time.Unix(c.At, 0)
+}
+
+func TestWithAttestationMaxAge(t *testing.T) {
+	var (
+		key     = NewSigningKey()
+		ka      = NewEncryptionKey()
+		trusted = map[string]EncryptionKey{"https://auth": ka}
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add3P(ka, "https://auth"))
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	discharge := func(cavs ...Caveat) []byte {
+		t.Helper()
+		_, _, dm, err := dischargeMacaroon(ka, "https://auth", buf)
+		assert.NoError(t, err)
+		assert.NoError(t, dm.Add(cavs...))
+		dbuf, err := dm.Encode()
+		assert.NoError(t, err)
+		return dbuf
+	}
+
+	var (
+		now    = time.Now()
+		fresh  = discharge(&testTimestampedAttestation{now.Add(-time.Hour).Unix()})
+		stale  = discharge(&testTimestampedAttestation{now.Add(-48 * time.Hour).Unix()})
+		future = discharge(&testTimestampedAttestation{now.Add(time.Hour).Unix()})
+		noTS   = discharge(&testTimestampedAttestation{}, &testCaveatAttestation{"bob"})
+		maxAge = WithAttestationMaxAge(24*time.Hour, cavTestTimestamped)
+	)
+
+	_, err = m.Verify(key, [][]byte{fresh}, trusted, maxAge)
+	assert.NoError(t, err)
+
+	_, err = m.Verify(key, [][]byte{stale}, trusted, maxAge)
+	assert.Contains(t, err.Error(), "TestTimestamped attestation too old")
+	_, err = m.Verify(key, [][]byte{stale}, trusted, maxAge, WithVerifyNow(now.Add(-36*time.Hour)))
+	assert.NoError(t, err)
+
+	_, err = m.Verify(key, [][]byte{future}, trusted, maxAge)
+	assert.EqualError(t, err, "macaroon verify: TestTimestamped attestation made in the future")
+	_, err = m.Verify(key, [][]byte{future}, trusted, maxAge, WithClockSkew(2*time.Hour))
+	assert.NoError(t, err)
+
+	_, err = m.Verify(key, [][]byte{noTS}, trusted, maxAge)
+	assert.EqualError(t, err, "macaroon verify: TestTimestamped attestation has no timestamp")
+
+	// untimestamped attestation types can't be required to be fresh
+	_, err = m.Verify(key, [][]byte{noTS}, trusted, WithAttestationMaxAge(time.Hour, cavTestAttestation))
+	assert.EqualError(t, err, "macaroon verify: TestAttestation attestation has no timestamp")
+
+	// untrusted attestations are dropped before they're checked
+	_, err = m.Verify(key, [][]byte{stale}, nil, maxAge)
+	assert.NoError(t, err)
+
+	// undesignated types aren't checked
+	_, err = m.Verify(key, [][]byte{stale}, trusted)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,229 @@
+// Package bloom implements a resource caveat backed by a bloom filter, for
+// grants over tens of thousands of resources, where an exact allow-list would
+// make tokens megabytes in size.
+//
+// A bloom filter never rejects a member of the set it was built from, but
+// accepts non-members with a small probability (the false positive rate), so
+// a [Resources] caveat alone may allow access to resources outside the
+// intended set. Verifiers that can look up the authoritative set (e.g. in a
+// database, by the caveat's SetID) should confirm matches with
+// [WithExactCheck].
+//
+// Resources are identified by the keys returned by Accesses implementing
+// [access.ResourceKey].
+package bloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+
+	"github.com/superfly/macaroon"
+	"github.com/superfly/macaroon/access"
+)
+
+// maxHashes bounds the number of hash functions, and so the cost of
+// evaluating the caveat.
+const maxHashes = 32
+
+// Filter is a bloom filter over string keys.
+type Filter struct {
+	// K is the number of hash functions.
+	K uint8 `json:"k"`
+
+	// Bits is the filter's bit array.
+	Bits []byte `json:"bits"`
+}
+
+// NewFilter creates an empty filter sized to hold n keys with the specified
+// false positive rate.
+func NewFilter(n int, falsePositiveRate float64) *Filter {
+	if n < 1 {
+		n = 1
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		panic("bloom: false positive rate must be between 0 and 1")
+	}
+
+	m := math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	k := math.Round(m / float64(n) * math.Ln2)
+
+	switch {
+	case k < 1:
+		k = 1
+	case k > maxHashes:
+		k = maxHashes
+	}
+
+	return &Filter{K: uint8(k), Bits: make([]byte, (int(m)+7)/8)}
+}
+
+// Add adds keys to the filter.
+func (f *Filter) Add(keys ...string) {
+	for _, key := range keys {
+		f.each(key, func(bit uint64) bool {
+			f.Bits[bit/8] |= 1 << (bit % 8)
+			return true
+		})
+	}
+}
+
+// MayContain reports whether the key may have been added to the filter. A
+// false result is definitive; a true result may be a false positive.
+func (f *Filter) MayContain(key string) bool {
+	if len(f.Bits) == 0 || f.K == 0 || f.K > maxHashes {
+		return false
+	}
+
+	return f.each(key, func(bit uint64) bool {
+		return f.Bits[bit/8]&(1<<(bit%8)) != 0
+	})
+}
+
+// each calls fn with each of the key's bit positions, using double hashing,
+// until fn returns false.
+func (f *Filter) each(key string, fn func(bit uint64) bool) bool {
+	h := fnv.New128a()
+	h.Write([]byte(key))
+	sum := h.Sum(nil)
+
+	var (
+		h1 = binary.BigEndian.Uint64(sum[:8])
+		h2 = binary.BigEndian.Uint64(sum[8:]) | 1
+		m  = uint64(len(f.Bits)) * 8
+	)
+
+	for i := uint64(0); i < uint64(f.K); i++ {
+		if !fn((h1 + i*h2) % m) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Resources is a caveat allowing Action on resources whose keys are in a
+// bloom filter. SetID optionally identifies the authoritative set the filter
+// was built from, for use by exact checks (see [WithExactCheck]).
+type Resources struct {
+	SetID  string          `json:"set_id,omitempty"`
+	Action macaroon.Action `json:"action"`
+	Filter Filter          `json:"filter"`
+}
+
+func init() {
+	macaroon.RegisterCaveatType("BloomResources", macaroon.CavBloomResources, &Resources{})
+}
+
+// New creates a Resources caveat allowing action on the resources with the
+// specified keys, with the specified false positive rate.
+func New(setID string, action macaroon.Action, falsePositiveRate float64, keys ...string) *Resources {
+	f := NewFilter(len(keys), falsePositiveRate)
+	f.Add(keys...)
+
+	return &Resources{SetID: setID, Action: action, Filter: *f}
+}
+
+func (c *Resources) CaveatType() macaroon.CaveatType {
+	return macaroon.CavBloomResources
+}
+
+func (c *Resources) Prohibits(a macaroon.Access) error {
+	return c.ProhibitsWithContext(new(macaroon.ValidationContext), a)
+}
+
+func (c *Resources) ProhibitsWithContext(vc *macaroon.ValidationContext, a macaroon.Access) error {
+	rk, ok := access.As[access.ResourceKey](a)
+	if !ok {
+		return macaroon.ErrInvalidAccess
+	}
+
+	key, ok := rk.GetResourceKey()
+	if !ok {
+		return fmt.Errorf("%w resource key", macaroon.ErrResourceUnspecified)
+	}
+
+	if !a.GetAction().IsSubsetOf(c.Action) {
+		return &macaroon.ActionError{Requested: a.GetAction(), Permitted: c.Action, Resource: key}
+	}
+
+	if !c.Filter.MayContain(key) {
+		return fmt.Errorf("%w %s", macaroon.ErrUnauthorizedForResource, key)
+	}
+
+	if check, ok := vc.Get(exactCheckKey{}); ok {
+		member, err := check.(ExactCheck)(c.SetID, key)
+		switch {
+		case err != nil:
+			return fmt.Errorf("%w: exact check for %s: %w", macaroon.ErrUnauthorized, key, err)
+		case !member:
+			return fmt.Errorf("%w %s", macaroon.ErrUnauthorizedForResource, key)
+		}
+	}
+
+	return nil
+}
+
+func (c *Resources) IsAttestation() bool { return false }
+
+// EvaluationCost is the number of hash functions probed.
+func (c *Resources) EvaluationCost() int { return int(c.Filter.K) }
+
+const wireVersion = 1
+
+// MarshalWire implements [macaroon.WireCodec], so the filter's bits are
+// stored without per-field overhead.
+func (c *Resources) MarshalWire() ([]byte, error) {
+	buf := make([]byte, 0, 1+2*binary.MaxVarintLen64+len(c.SetID)+1+len(c.Filter.Bits))
+	buf = append(buf, wireVersion)
+	buf = binary.AppendUvarint(buf, uint64(c.Action))
+	buf = binary.AppendUvarint(buf, uint64(len(c.SetID)))
+	buf = append(buf, c.SetID...)
+	buf = append(buf, c.Filter.K)
+	buf = append(buf, c.Filter.Bits...)
+	return buf, nil
+}
+
+var errMalformed = errors.New("bloom: malformed caveat")
+
+// UnmarshalWire implements [macaroon.WireCodec].
+func (c *Resources) UnmarshalWire(buf []byte) error {
+	if len(buf) == 0 || buf[0] != wireVersion {
+		return errMalformed
+	}
+	buf = buf[1:]
+
+	action, n := binary.Uvarint(buf)
+	if n <= 0 || action > math.MaxUint16 {
+		return errMalformed
+	}
+	buf = buf[n:]
+
+	idLen, n := binary.Uvarint(buf)
+	if n <= 0 || idLen >= uint64(len(buf)-n) {
+		return errMalformed
+	}
+	buf = buf[n:]
+
+	*c = Resources{
+		SetID:  string(buf[:idLen]),
+		Action: macaroon.Action(action),
+		Filter: Filter{K: buf[idLen], Bits: append([]byte(nil), buf[idLen+1:]...)},
+	}
+
+	return nil
+}
+
+// ExactCheck reports whether key is a member of the authoritative set
+// identified by setID.
+type ExactCheck func(setID, key string) (bool, error)
+
+type exactCheckKey struct{}
+
+// WithExactCheck confirms matches by [Resources] caveats with check, ruling
+// out the filter's false positives.
+func WithExactCheck(check ExactCheck) macaroon.ValidationOption {
+	return macaroon.WithContextValue(exactCheckKey{}, check)
+}
@@ -0,0 +1,101 @@
+package bloom
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+)
+
+type testAccess struct {
+	action macaroon.Action
+	key    *string
+}
+
+func (a *testAccess) GetAction() macaroon.Action { return a.action }
+func (a *testAccess) Now() time.Time             { return time.Now() }
+func (a *testAccess) Validate() error            { return nil }
+
+func (a *testAccess) GetResourceKey() (string, bool) {
+	if a.key == nil {
+		return "", false
+	}
+	return *a.key, true
+}
+
+func keys(n int) []string {
+	ret := make([]string, n)
+	for i := range ret {
+		ret[i] = fmt.Sprintf("app:%d", i)
+	}
+	return ret
+}
+
+func TestFilter(t *testing.T) {
+	f := NewFilter(10000, 0.01)
+	f.Add(keys(10000)...)
+
+	for _, k := range keys(10000) {
+		assert.True(t, f.MayContain(k))
+	}
+
+	var fp int
+	for i := 10000; i < 20000; i++ {
+		if f.MayContain(fmt.Sprintf("app:%d", i)) {
+			fp++
+		}
+	}
+	assert.True(t, fp < 200, "false positives: %d", fp)
+
+	assert.False(t, new(Filter).MayContain("app:1"))
+}
+
+func TestResources(t *testing.T) {
+	var (
+		key     = macaroon.NewSigningKey()
+		allowed = keys(10000)
+		cav     = New("set-1", macaroon.ActionRead, 0.001, allowed...)
+		in      = "app:1"
+		out     = "app:10001"
+	)
+
+	m, err := macaroon.New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cav))
+	tok, err := m.Encode()
+	assert.NoError(t, err)
+	assert.True(t, len(tok) < 20000)
+
+	decoded, err := macaroon.Decode(tok)
+	assert.NoError(t, err)
+	cs, err := decoded.Verify(key, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []macaroon.Caveat{cav}, cs.Caveats)
+
+	assert.NoError(t, cs.Validate(&testAccess{macaroon.ActionRead, &in}))
+	assert.True(t, errors.Is(cs.Validate(&testAccess{macaroon.ActionRead, &out}), macaroon.ErrUnauthorizedForResource))
+	assert.True(t, errors.Is(cs.Validate(&testAccess{macaroon.ActionWrite, &in}), macaroon.ErrUnauthorizedForAction))
+	assert.True(t, errors.Is(cs.Validate(&testAccess{macaroon.ActionRead, nil}), macaroon.ErrResourceUnspecified))
+
+	// exact checks rule out false positives
+	var checked []string
+	v := macaroon.NewValidator(WithExactCheck(func(setID, key string) (bool, error) {
+		checked = append(checked, setID+" "+key)
+		return key != in, nil
+	}))
+	assert.True(t, errors.Is(v.Validate(cs, &testAccess{macaroon.ActionRead, &in}), macaroon.ErrUnauthorizedForResource))
+	assert.Equal(t, []string{"set-1 app:1"}, checked)
+
+	v = macaroon.NewValidator(WithExactCheck(func(setID, key string) (bool, error) {
+		return false, errors.New("db down")
+	}))
+	assert.True(t, errors.Is(v.Validate(cs, &testAccess{macaroon.ActionRead, &in}), macaroon.ErrUnauthorized))
+
+	// malformed encodings
+	for _, buf := range [][]byte{nil, {2}, {1}, {1, 1}, {1, 1, 5, 'a'}} {
+		assert.Error(t, new(Resources).UnmarshalWire(buf))
+	}
+}
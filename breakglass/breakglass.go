@@ -0,0 +1,236 @@
+// Package breakglass implements emergency-access ("break-glass") tokens.
+//
+// Break-glass tokens grant broad access for a short time, so they're wrapped
+// in extra ceremony:
+//
+//   - They're minted with [Mint], which requires a short lifetime and a
+//     reason, and adds a third-party caveat for an approval service. The
+//     ticket carries the [Request], so the approval service can show the
+//     approver who is asking and why.
+//
+//   - A second person approves the request through the approval service,
+//     which discharges the ticket with [Approve]. The discharge attests to the
+//     approver with an [Approval] caveat.
+//
+//   - Services accept break-glass tokens through a [Verifier], which checks
+//     that they were approved by a trusted approval service and are
+//     short-lived, and reports every use (or attempted use) to an audit hook.
+package breakglass
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/superfly/macaroon"
+)
+
+// DefaultMaxTTL is the longest lifetime allowed for break-glass tokens, unless
+// configured otherwise.
+const DefaultMaxTTL = time.Hour
+
+var (
+	ErrNotApproved = fmt.Errorf("%w: break-glass token not approved", macaroon.ErrUnauthorized)
+	ErrTooLong     = fmt.Errorf("%w: break-glass token lifetime too long", macaroon.ErrUnauthorized)
+)
+
+// Request marks a token as a break-glass token, recording who requested it
+// and why. It's added to the token and to the ticket of its approval caveat.
+// It plays no role in access validation.
+type Request struct {
+	Requester string `json:"requester"`
+	Reason    string `json:"reason"`
+}
+
+func init() {
+	macaroon.RegisterCaveatType("BreakGlass", macaroon.CavBreakGlass, &Request{})
+}
+
+func (c *Request) CaveatType() macaroon.CaveatType { return macaroon.CavBreakGlass }
+func (c *Request) IsAttestation() bool             { return false }
+
+func (c *Request) Prohibits(macaroon.Access) error {
+	// Request is metadata, enforced by Verifier.
+	return nil
+}
+
+// Approval is an attestation, added by the approval service to its discharge
+// tokens, stating who approved the break-glass request and when (seconds since
+// the Unix epoch).
+type Approval struct {
+	Approver string `json:"approver"`
+	At       int64  `json:"at"`
+}
+
+func init() {
+	macaroon.RegisterCaveatType("BreakGlassApproval", macaroon.CavBreakGlassApproval, &Approval{})
+}
+
+func (c *Approval) CaveatType() macaroon.CaveatType { return macaroon.CavBreakGlassApproval }
+func (c *Approval) IsAttestation() bool             { return true }
+
+func (c *Approval) Prohibits(macaroon.Access) error {
+	// attestations play no role in access validation
+	return nil
+}
+
+// AttestedAt implements [macaroon.TimestampedAttestation].
+func (c *Approval) AttestedAt() time.Time { return time.Unix(c.At, 0) }
+
+// Mint mints a break-glass token valid for ttl, which may not exceed
+// DefaultMaxTTL, and restricted by the specified caveats. The token requires
+// a discharge from the approval service at approvalLocation, keyed with ka.
+func Mint(kid []byte, loc string, key macaroon.SigningKey, ka macaroon.EncryptionKey, approvalLocation string, req Request, ttl time.Duration, cavs ...macaroon.Caveat) (*macaroon.Macaroon, error) {
+	switch {
+	case req.Requester == "":
+		return nil, errors.New("break-glass: blank requester")
+	case req.Reason == "":
+		return nil, errors.New("break-glass: blank reason")
+	case ttl <= 0 || ttl > DefaultMaxTTL:
+		return nil, fmt.Errorf("break-glass: ttl must be positive and at most %s", DefaultMaxTTL)
+	}
+
+	m, err := macaroon.New(kid, loc, key)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	if err := m.Add(append([]macaroon.Caveat{
+		&req,
+		&macaroon.ValidityWindow{NotBefore: now.Unix(), NotAfter: now.Add(ttl).Unix()},
+	}, cavs...)...); err != nil {
+		return nil, fmt.Errorf("break-glass: %w", err)
+	}
+
+	if err := m.Add3P(ka, approvalLocation, &req); err != nil {
+		return nil, fmt.Errorf("break-glass: %w", err)
+	}
+
+	return m, nil
+}
+
+// Approve is used by the approval service to discharge the ticket from a
+// break-glass token, once approver has approved the request. It returns the
+// request from the ticket, so the service can record it, and the discharge
+// token, which attests to the approval. Requesters can't approve their own
+// requests.
+func Approve(ka macaroon.EncryptionKey, approvalLocation string, ticket []byte, approver string) (*Request, *macaroon.Macaroon, error) {
+	cavs, dm, err := macaroon.DischargeCID(ka, approvalLocation, ticket)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var req *Request
+	for _, cav := range cavs {
+		r, ok := cav.(*Request)
+		if !ok {
+			return nil, nil, fmt.Errorf("break-glass: unexpected caveat in ticket: %T", cav)
+		}
+		req = r
+	}
+
+	switch {
+	case req == nil:
+		return nil, nil, errors.New("break-glass: ticket has no request")
+	case approver == "":
+		return nil, nil, errors.New("break-glass: blank approver")
+	case approver == req.Requester:
+		return nil, nil, fmt.Errorf("%w: requester can't approve their own request", macaroon.ErrUnauthorized)
+	}
+
+	if err := dm.Add(&Approval{Approver: approver, At: time.Now().Unix()}); err != nil {
+		return nil, nil, err
+	}
+
+	return req, dm, nil
+}
+
+// Event describes a verification of a break-glass token, for auditing.
+type Event struct {
+	Time      time.Time
+	TokenUUID string
+
+	// Request and Approval are from the token, if it was verified far
+	// enough to find them.
+	Request  *Request
+	Approval *Approval
+
+	// Err is why the token was rejected, or nil if it was accepted.
+	Err error
+}
+
+// Verifier verifies tokens, enforcing the break-glass requirements on
+// break-glass tokens. Other tokens are verified as usual.
+type Verifier struct {
+	// Key is the tokens' signing key.
+	Key macaroon.SigningKey
+
+	// ApprovalLocation and ApprovalKey identify the trusted approval
+	// service.
+	ApprovalLocation string
+	ApprovalKey      macaroon.EncryptionKey
+
+	// MaxTTL is the longest lifetime accepted for break-glass tokens,
+	// defaulting to DefaultMaxTTL.
+	MaxTTL time.Duration
+
+	// Audit, if set, is called for every break-glass token verified,
+	// whether it's accepted or not.
+	Audit func(Event)
+}
+
+// Verify verifies the token and its discharges. Break-glass tokens must carry
+// an approval from the approval service and a ValidityWindow no longer than
+// MaxTTL. Validation of the returned caveats is left to the caller.
+func (v *Verifier) Verify(m *macaroon.Macaroon, discharges [][]byte, opts ...macaroon.VerifyOption) (*macaroon.CaveatSet, error) {
+	isBreakGlass := len(macaroon.GetCaveats[*Request](&m.UnsafeCaveats)) != 0
+
+	trusted := map[string]macaroon.EncryptionKey{v.ApprovalLocation: v.ApprovalKey}
+	cs, err := m.Verify(v.Key, discharges, trusted, opts...)
+	if !isBreakGlass {
+		return cs, err
+	}
+
+	event := Event{Time: time.Now(), TokenUUID: m.Nonce.UUID().String()}
+	if err == nil {
+		event.Request, event.Approval, err = v.check(cs)
+	}
+	event.Err = err
+
+	if v.Audit != nil {
+		v.Audit(event)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cs, nil
+}
+
+func (v *Verifier) check(cs *macaroon.CaveatSet) (*Request, *Approval, error) {
+	var req *Request
+	if reqs := macaroon.GetCaveats[*Request](cs); len(reqs) != 0 {
+		req = reqs[0]
+	}
+
+	approvals := macaroon.GetCaveats[*Approval](cs)
+	if len(approvals) == 0 {
+		return req, nil, ErrNotApproved
+	}
+
+	maxTTL := v.MaxTTL
+	if maxTTL <= 0 {
+		maxTTL = DefaultMaxTTL
+	}
+
+	for _, vw := range macaroon.GetCaveats[*macaroon.ValidityWindow](cs) {
+		if time.Unix(vw.NotAfter, 0).Sub(time.Unix(vw.NotBefore, 0)) <= maxTTL {
+			return req, approvals[0], nil
+		}
+	}
+
+	return req, approvals[0], ErrTooLong
+}
@@ -0,0 +1,84 @@
+package breakglass
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+)
+
+func TestBreakGlass(t *testing.T) {
+	var (
+		key = macaroon.NewSigningKey()
+		ka  = macaroon.NewEncryptionKey()
+		loc = "https://approvals"
+		req = Request{Requester: "alice", Reason: "database on fire"}
+	)
+
+	_, err := Mint([]byte("kid"), "https://api", key, ka, loc, req, 2*DefaultMaxTTL)
+	assert.Error(t, err)
+	_, err = Mint([]byte("kid"), "https://api", key, ka, loc, Request{Requester: "alice"}, time.Minute)
+	assert.Error(t, err)
+
+	m, err := Mint([]byte("kid"), "https://api", key, ka, loc, req, 10*time.Minute)
+	assert.NoError(t, err)
+
+	ticket, err := m.ThirdPartyCID(loc)
+	assert.NoError(t, err)
+
+	_, _, err = Approve(ka, loc, ticket, "alice")
+	assert.True(t, errors.Is(err, macaroon.ErrUnauthorized))
+
+	got, dm, err := Approve(ka, loc, ticket, "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, &req, got)
+	dbuf, err := dm.Encode()
+	assert.NoError(t, err)
+
+	var events []Event
+	v := &Verifier{
+		Key:              key,
+		ApprovalLocation: loc,
+		ApprovalKey:      ka,
+		Audit:            func(e Event) { events = append(events, e) },
+	}
+
+	cs, err := v.Verify(m, [][]byte{dbuf})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(macaroon.GetCaveats[*Approval](cs)))
+
+	assert.Equal(t, 1, len(events))
+	assert.NoError(t, events[0].Err)
+	assert.Equal(t, &req, events[0].Request)
+	assert.Equal(t, "bob", events[0].Approval.Approver)
+	assert.Equal(t, m.Nonce.UUID().String(), events[0].TokenUUID)
+
+	// the approval must come from the trusted approval service
+	untrusted := *v
+	untrusted.ApprovalLocation = "https://elsewhere"
+	_, err = untrusted.Verify(m, [][]byte{dbuf})
+	assert.True(t, errors.Is(err, ErrNotApproved))
+	assert.Equal(t, 2, len(events))
+	assert.True(t, errors.Is(events[1].Err, ErrNotApproved))
+
+	// verifiers may require shorter lifetimes
+	strict := *v
+	strict.MaxTTL = time.Minute
+	_, err = strict.Verify(m, [][]byte{dbuf})
+	assert.True(t, errors.Is(err, ErrTooLong))
+
+	// missing discharges are audited too
+	_, err = v.Verify(m, nil)
+	assert.Error(t, err)
+	assert.Equal(t, 4, len(events))
+	assert.Error(t, events[3].Err)
+
+	// other tokens verify as usual, without audit events
+	other, err := macaroon.New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	_, err = v.Verify(other, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(events))
+}
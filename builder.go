@@ -0,0 +1,169 @@
+package macaroon
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Builder mints tokens, checking the shape of the whole token before
+// producing it. This consolidates checks that can't be made as caveats are
+// added one at a time, like requiring an expiry or limiting the encoded
+// size. Create one with [NewBuilder]:
+//
+//	m, err := NewBuilder(kid, loc, key).
+//		Caveat(orgCaveat, &ValidityWindow{NotBefore: now, NotAfter: now + 3600}).
+//		ThirdParty(authKey, authLoc).
+//		RequireExpiry(24 * time.Hour).
+//		MaxSize(4096).
+//		Build()
+//
+// Caveats are checked as they're added, but errors are only reported by
+// Build.
+type Builder struct {
+	kid []byte
+	loc string
+	key SigningKey
+
+	stages []func(m *Macaroon) error
+
+	requireExpiry bool
+	maxTTL        time.Duration
+	maxSize       int
+	checks        []func(cs *CaveatSet) error
+
+	err error
+}
+
+// NewBuilder creates a Builder for a token with the specified key ID,
+// location and key. See [New].
+func NewBuilder(kid []byte, loc string, key SigningKey) *Builder {
+	return &Builder{kid: kid, loc: loc, key: key}
+}
+
+// Caveat adds first-party caveats. Attestations and caveats rejected by the
+// deprecation policy (see [SetDeprecationPolicy]) are refused.
+func (b *Builder) Caveat(caveats ...Caveat) *Builder {
+	for _, c := range caveats {
+		switch {
+		case c == nil:
+			b.fail(errors.New("nil caveat"))
+		case c.IsAttestation():
+			b.fail(fmt.Errorf("attestation %s in non-proof token", caveatTypeToString(c.CaveatType())))
+		case isCaveat3P(c):
+			b.fail(errors.New("third-party caveats must be added with ThirdParty"))
+		default:
+			b.fail(checkDeprecation(c, time.Now()))
+		}
+	}
+
+	b.stages = append(b.stages, func(m *Macaroon) error { return m.Add(caveats...) })
+	return b
+}
+
+// ThirdParty adds a third-party caveat for the third party at loc, sealing
+// its ticket with s. See [Macaroon.Add3PWithSealer].
+func (b *Builder) ThirdParty(s Sealer, loc string, caveats ...Caveat) *Builder {
+	b.stages = append(b.stages, func(m *Macaroon) error { return m.Add3PWithSealer(s, loc, caveats...) })
+	return b
+}
+
+// RequireExpiry requires the token to have a ValidityWindow. If maxTTL is
+// non-zero, the token must also expire within maxTTL of being built.
+func (b *Builder) RequireExpiry(maxTTL time.Duration) *Builder {
+	b.requireExpiry, b.maxTTL = true, maxTTL
+	return b
+}
+
+// MaxSize limits the size of the encoded token, in bytes.
+func (b *Builder) MaxSize(n int) *Builder {
+	b.maxSize = n
+	return b
+}
+
+// Check adds a check of the token's caveats, run by Build.
+func (b *Builder) Check(check func(cs *CaveatSet) error) *Builder {
+	b.checks = append(b.checks, check)
+	return b
+}
+
+// Build checks the token's shape and mints it. It fails if any caveat was
+// refused, if the token's validity windows don't overlap, or if any of the
+// requirements configured on the Builder aren't met.
+func (b *Builder) Build() (*Macaroon, error) {
+	if b.err != nil {
+		return nil, fmt.Errorf("build: %w", b.err)
+	}
+
+	m, err := New(b.kid, b.loc, b.key)
+	if err != nil {
+		return nil, fmt.Errorf("build: %w", err)
+	}
+
+	for _, stage := range b.stages {
+		if err := stage(m); err != nil {
+			return nil, fmt.Errorf("build: %w", err)
+		}
+	}
+
+	if err := b.checkShape(&m.UnsafeCaveats, time.Now()); err != nil {
+		return nil, fmt.Errorf("build: %w", err)
+	}
+
+	if b.maxSize > 0 {
+		buf, err := m.Encode()
+		if err != nil {
+			return nil, fmt.Errorf("build: %w", err)
+		}
+		if len(buf) > b.maxSize {
+			return nil, fmt.Errorf("build: token is %d bytes, more than %d", len(buf), b.maxSize)
+		}
+	}
+
+	return m, nil
+}
+
+func (b *Builder) checkShape(cs *CaveatSet, now time.Time) error {
+	windows := GetCaveats[*ValidityWindow](cs)
+
+	if len(windows) != 0 {
+		var notBefore, notAfter int64 = windows[0].NotBefore, windows[0].NotAfter
+		for _, vw := range windows[1:] {
+			if vw.NotBefore > notBefore {
+				notBefore = vw.NotBefore
+			}
+			if vw.NotAfter < notAfter {
+				notAfter = vw.NotAfter
+			}
+		}
+
+		if notBefore >= notAfter {
+			return errors.New("validity windows don't overlap")
+		}
+
+		if b.maxTTL > 0 && time.Unix(notAfter, 0).After(now.Add(b.maxTTL)) {
+			return fmt.Errorf("token expires after maximum TTL of %s", b.maxTTL)
+		}
+	} else if b.requireExpiry {
+		return errors.New("token has no expiry")
+	}
+
+	for _, check := range b.checks {
+		if err := check(cs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *Builder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+func isCaveat3P(c Caveat) bool {
+	_, ok := c.(*Caveat3P)
+	return ok
+}
@@ -0,0 +1,59 @@
+package macaroon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestBuilder(t *testing.T) {
+	var (
+		key = NewSigningKey()
+		ka  = NewEncryptionKey()
+		now = time.Now()
+		vw  = &ValidityWindow{NotBefore: now.Unix(), NotAfter: now.Add(time.Hour).Unix()}
+	)
+
+	builder := func() *Builder { return NewBuilder([]byte("kid"), "https://api", key) }
+
+	m, err := builder().
+		Caveat(cavParent(ActionRead, 1), vw).
+		ThirdParty(ka, "https://auth").
+		RequireExpiry(2 * time.Hour).
+		MaxSize(1024).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(m.UnsafeCaveats.Caveats))
+	_, err = m.ThirdPartyCID("https://auth")
+	assert.NoError(t, err)
+
+	_, err = m.Verify(key, nil, nil)
+	assert.Error(t, err) // needs a discharge
+
+	_, err = builder().Caveat(cavParent(ActionRead, 1)).RequireExpiry(0).Build()
+	assert.EqualError(t, err, "build: token has no expiry")
+
+	_, err = builder().Caveat(vw).RequireExpiry(time.Minute).Build()
+	assert.EqualError(t, err, "build: token expires after maximum TTL of 1m0s")
+
+	_, err = builder().Caveat(vw, &ValidityWindow{NotBefore: vw.NotAfter, NotAfter: vw.NotAfter + 60}).Build()
+	assert.EqualError(t, err, "build: validity windows don't overlap")
+
+	_, err = builder().Caveat(&testCaveatAttestation{"bob"}).Build()
+	assert.EqualError(t, err, "build: attestation TestAttestation in non-proof token")
+
+	_, err = builder().Caveat(&Caveat3P{Location: "https://auth"}).Build()
+	assert.Error(t, err)
+
+	_, err = builder().Caveat(vw).MaxSize(16).Build()
+	assert.Error(t, err)
+
+	_, err = builder().Caveat(vw).Check(func(cs *CaveatSet) error {
+		if len(GetCaveats[*testCaveatParentResource](cs)) == 0 {
+			return ErrResourceUnspecified
+		}
+		return nil
+	}).Build()
+	assert.EqualError(t, err, "build: unauthorized: bad data for token verification: must specify")
+}
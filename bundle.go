@@ -0,0 +1,69 @@
+package macaroon
+
+import (
+	"fmt"
+	"time"
+)
+
+// Bundle is a permission token along with the discharge tokens for its
+// third-party caveats: everything needed to make an authorized request.
+type Bundle struct {
+	Permission []byte
+	Discharges [][]byte
+}
+
+// ParseBundle parses an Authorization header into a bundle, finding the
+// permission token for the given location. If the header contains an
+// [Envelope], its permission token is used regardless of location.
+func ParseBundle(header string, location string) (*Bundle, error) {
+	if e, err := ParseEnvelope(header); err == nil {
+		return BundleFromEnvelope(e)
+	}
+
+	permission, discharges, err := ParsePermissionAndDischargeTokens(header, location)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bundle{Permission: permission, Discharges: discharges}, nil
+}
+
+// Header formats the bundle as an HTTP Authorization header.
+func (b *Bundle) Header() string {
+	return ToAuthorizationHeader(b.Tokens()...)
+}
+
+// Tokens returns the permission token followed by the discharge tokens.
+func (b *Bundle) Tokens() [][]byte {
+	return append([][]byte{b.Permission}, b.Discharges...)
+}
+
+// Verify decodes and verifies the permission token against the bundle's
+// discharges. See [Macaroon.Verify].
+func (b *Bundle) Verify(k SigningKey, trusted3Ps map[string]EncryptionKey, opts ...VerifyOption) (*CaveatSet, error) {
+	m, err := Decode(b.Permission)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Verify(k, b.Discharges, trusted3Ps, opts...)
+}
+
+// Expiration returns the earliest expiration of the tokens in the bundle.
+// This doesn't verify the tokens.
+func (b *Bundle) Expiration() (time.Time, error) {
+	ret := maxTime
+
+	for _, tok := range b.Tokens() {
+		m, err := Decode(tok)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("bundle expiration: %w", err)
+		}
+
+		if exp := m.Expiration(); exp.Before(ret) {
+			ret = exp
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,45 @@
+package macaroon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestBundle(t *testing.T) {
+	var (
+		key     = NewSigningKey()
+		ka      = NewEncryptionKey()
+		authLoc = "https://auth"
+		expiry  = time.Now().Add(time.Hour).Truncate(time.Second)
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add3P(ka, authLoc))
+	tok, err := m.Encode()
+	assert.NoError(t, err)
+
+	_, _, dm, err := dischargeMacaroon(ka, authLoc, tok)
+	assert.NoError(t, err)
+	assert.NoError(t, dm.Add(&ValidityWindow{NotBefore: time.Now().Unix(), NotAfter: expiry.Unix()}))
+	dtok, err := dm.Encode()
+	assert.NoError(t, err)
+
+	b, err := ParseBundle(ToAuthorizationHeader(dtok, tok), "https://api")
+	assert.NoError(t, err)
+	assert.Equal(t, tok, b.Permission)
+	assert.Equal(t, [][]byte{dtok}, b.Discharges)
+
+	b2, err := ParseBundle(b.Header(), "https://api")
+	assert.NoError(t, err)
+	assert.Equal(t, b, b2)
+
+	_, err = b.Verify(key, nil)
+	assert.NoError(t, err)
+
+	exp, err := b.Expiration()
+	assert.NoError(t, err)
+	assert.Equal(t, expiry, exp)
+}
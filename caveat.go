@@ -3,6 +3,7 @@ package macaroon
 import (
 	"fmt"
 	"reflect"
+	"sort"
 )
 
 // A numeric identifier for caveat types. Values less than
@@ -32,6 +33,36 @@ const (
 	_ // fly.io reserved
 	_ // fly.io reserved
 	_ // fly.io reserved
+	CavDelegatedIssuerService
+	CavDelegatedIssuerAttestation
+	CavPredicate
+	_ // fly.io reserved
+	_ // fly.io reserved
+	CavQuota
+	CavSpendLimit
+	CavObligation
+	CavStepUp
+	CavStepUpAttestation
+	CavWebAuthn
+	CavDevicePosture
+	CavDevicePostureAttestation
+	CavMeasuredEnvironment
+	CavMeasuredEnvironmentAttestation
+	CavBloomResources
+	_ // fly.io reserved
+	CavChannelBinding
+	CavParentToken
+	CavIssuerSeal
+	CavSealedAttestation
+	CavVerifierPin
+	CavBreakGlass
+	CavBreakGlassApproval
+	CavLineage
+	_ // fly.io reserved
+	_ // fly.io reserved
+	CavLabels
+	CavElevatedAction
+	CavMultiRoot
 
 	// Globally-recognized user-registerable caveat types may be requested via
 	// pull requests to this repository. Add a meaningful name of the caveat
@@ -69,21 +100,40 @@ var (
 	t2s = map[CaveatType]string{}
 )
 
-// Register a caveat type for use with this library.
-func RegisterCaveatType(name string, typ CaveatType, zeroValue Caveat) {
+// Register a caveat type for use with this library. The name is used to
+// identify the caveat type in JSON. Aliases are alternate names accepted when
+// unmarshalling JSON, allowing caveat types to be renamed without breaking
+// stored JSON representations; the canonical name is always used when
+// marshalling. Caveat types within a reserved [CaveatTypeBlock] must be
+// registered with [CaveatTypeBlock.Register] instead.
+func RegisterCaveatType(name string, typ CaveatType, zeroValue Caveat, aliases ...string) {
+	if b := blockFor(typ); b != nil {
+		panic(fmt.Sprintf("caveat type %d is reserved for %s", typ, b.namespace))
+	}
+
+	registerCaveatType(name, typ, zeroValue, aliases...)
+}
+
+func registerCaveatType(name string, typ CaveatType, zeroValue Caveat, aliases ...string) {
 	if _, dup := t2c[typ]; dup {
 		panic("duplicate caveat type")
 	}
 	if _, dup := t2s[typ]; dup {
 		panic("duplicate caveat type")
 	}
-	if _, dup := s2t[name]; dup {
-		panic("duplicate caveat type")
+	for _, n := range append([]string{name}, aliases...) {
+		if _, dup := s2t[n]; dup {
+			panic("duplicate caveat type")
+		}
 	}
 
 	t2c[typ] = zeroValue
 	t2s[typ] = name
 	s2t[name] = typ
+
+	for _, alias := range aliases {
+		s2t[alias] = typ
+	}
 }
 
 func typeToCaveat(t CaveatType) (Caveat, error) {
@@ -99,6 +149,24 @@ func typeToCaveat(t CaveatType) (Caveat, error) {
 	return reflect.Zero(ct).Interface().(Caveat), nil
 }
 
+// RegisteredCaveats returns zero values of every registered caveat type,
+// ordered by type. Tools like code generators and other implementations of
+// this package can use it to check their lists of caveat types.
+func RegisteredCaveats() []Caveat {
+	typs := make([]CaveatType, 0, len(t2c))
+	for t := range t2c {
+		typs = append(typs, t)
+	}
+	sort.Slice(typs, func(i, j int) bool { return typs[i] < typs[j] })
+
+	ret := make([]Caveat, 0, len(typs))
+	for _, t := range typs {
+		cav, _ := typeToCaveat(t)
+		ret = append(ret, cav)
+	}
+	return ret
+}
+
 func caveatTypeFromString(s string) CaveatType {
 	if t, ok := s2t[s]; ok {
 		return t
@@ -107,6 +175,11 @@ func caveatTypeFromString(s string) CaveatType {
 	return CavUnregistered
 }
 
+// CaveatTypeName returns the name the caveat type was registered with.
+func CaveatTypeName(t CaveatType) string {
+	return caveatTypeToString(t)
+}
+
 func caveatTypeToString(t CaveatType) string {
 	if s, ok := t2s[t]; ok {
 		return s
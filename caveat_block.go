@@ -0,0 +1,109 @@
+package macaroon
+
+import "fmt"
+
+// CaveatTypeBlock is a contiguous range of user-defined caveat types reserved
+// for use by a single namespace (e.g. an internal team or application). Blocks
+// prevent numeric collisions when several parties define caveats against the
+// same library. Caveat types within a block may only be registered through
+// the block.
+//
+// Caveat types are part of the wire format, so blocks are reserved at fixed
+// offsets rather than being allocated in registration order.
+type CaveatTypeBlock struct {
+	namespace string
+	start     CaveatType
+	size      uint64
+}
+
+var blocks []*CaveatTypeBlock
+
+// ReserveCaveatTypes reserves a block of size caveat types starting at start
+// for the namespace. The block must fall within the user-defined range
+// (between CavMinUserDefined and CavMaxUserDefined), must not overlap another
+// block and must not contain any already registered caveat types. It's meant
+// to be called while initializing package variables, before any caveat types
+// in the block are registered.
+func ReserveCaveatTypes(namespace string, start CaveatType, size uint64) *CaveatTypeBlock {
+	b := &CaveatTypeBlock{namespace: namespace, start: start, size: size}
+
+	switch {
+	case namespace == "":
+		panic("blank caveat type namespace")
+	case size == 0:
+		panic("empty caveat type block")
+	case start < CavMinUserDefined || b.last() > CavMaxUserDefined || b.last() < start:
+		panic(fmt.Sprintf("caveat type block for %s outside of user-defined range", namespace))
+	}
+
+	for _, other := range blocks {
+		if other.namespace == namespace {
+			panic(fmt.Sprintf("duplicate caveat type namespace %s", namespace))
+		}
+		if b.start <= other.last() && other.start <= b.last() {
+			panic(fmt.Sprintf("caveat type block for %s overlaps %s", namespace, other.namespace))
+		}
+	}
+
+	for t := range t2c {
+		if b.Contains(t) {
+			panic(fmt.Sprintf("caveat type block for %s contains registered type %d", namespace, t))
+		}
+	}
+
+	blocks = append(blocks, b)
+	return b
+}
+
+// Namespace returns the namespace the block is reserved for.
+func (b *CaveatTypeBlock) Namespace() string {
+	return b.namespace
+}
+
+// Contains returns whether the caveat type falls within the block.
+func (b *CaveatTypeBlock) Contains(t CaveatType) bool {
+	return t >= b.start && t <= b.last()
+}
+
+// Type returns the caveat type at the specified offset within the block. It
+// panics if the offset falls outside of the block.
+func (b *CaveatTypeBlock) Type(offset uint64) CaveatType {
+	if offset >= b.size {
+		panic(fmt.Sprintf("offset %d outside of %s caveat type block", offset, b.namespace))
+	}
+	return b.start + CaveatType(offset)
+}
+
+// Register registers a caveat type at the specified offset within the block.
+// The zero value's CaveatType must match the block's type at that offset. See
+// [RegisterCaveatType].
+func (b *CaveatTypeBlock) Register(name string, offset uint64, zeroValue Caveat, aliases ...string) {
+	typ := b.Type(offset)
+	if zeroValue.CaveatType() != typ {
+		panic(fmt.Sprintf("caveat %s has type %d, expected %d", name, zeroValue.CaveatType(), typ))
+	}
+
+	registerCaveatType(name, typ, zeroValue, aliases...)
+}
+
+func (b *CaveatTypeBlock) last() CaveatType {
+	return b.start + CaveatType(b.size-1)
+}
+
+// CaveatTypeNamespace returns the namespace of the block containing the
+// caveat type, if any.
+func CaveatTypeNamespace(t CaveatType) (string, bool) {
+	if b := blockFor(t); b != nil {
+		return b.namespace, true
+	}
+	return "", false
+}
+
+func blockFor(t CaveatType) *CaveatTypeBlock {
+	for _, b := range blocks {
+		if b.Contains(t) {
+			return b
+		}
+	}
+	return nil
+}
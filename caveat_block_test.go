@@ -0,0 +1,56 @@
+package macaroon
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+type testCaveatBlockMember struct{}
+
+func (c *testCaveatBlockMember) CaveatType() CaveatType   { return CavMinUserDefined + 0x1000 + 1 }
+func (c *testCaveatBlockMember) IsAttestation() bool      { return false }
+func (c *testCaveatBlockMember) Prohibits(f Access) error { return nil }
+
+func TestCaveatTypeBlock(t *testing.T) {
+	defer func(orig []*CaveatTypeBlock) { blocks = orig }(blocks)
+
+	b := ReserveCaveatTypes("acme", CavMinUserDefined+0x1000, 16)
+	assert.Equal(t, "acme", b.Namespace())
+	assert.Equal(t, CaveatType(CavMinUserDefined+0x1000+1), b.Type(1))
+	assert.True(t, b.Contains(CavMinUserDefined+0x1000+15))
+	assert.False(t, b.Contains(CavMinUserDefined+0x1000+16))
+	assert.Panics(t, func() { b.Type(16) })
+
+	ns, ok := CaveatTypeNamespace(CavMinUserDefined + 0x1000 + 3)
+	assert.True(t, ok)
+	assert.Equal(t, "acme", ns)
+
+	_, ok = CaveatTypeNamespace(cavTestParentResource)
+	assert.False(t, ok)
+
+	// overlapping
+	assert.Panics(t, func() { ReserveCaveatTypes("other", CavMinUserDefined+0x1000+15, 16) })
+	// duplicate namespace
+	assert.Panics(t, func() { ReserveCaveatTypes("acme", CavMinUserDefined+0x2000, 16) })
+	// outside of user-defined range
+	assert.Panics(t, func() { ReserveCaveatTypes("other", CavMinUserRegisterable, 16) })
+	assert.Panics(t, func() { ReserveCaveatTypes("other", CavMaxUserDefined, 2) })
+	// contains registered types
+	assert.Panics(t, func() { ReserveCaveatTypes("other", cavTestParentResource, 2) })
+
+	// registration in the block must go through the block
+	assert.Panics(t, func() { RegisterCaveatType("BlockMember", b.Type(1), &testCaveatBlockMember{}) })
+	// with the right offset
+	assert.Panics(t, func() { b.Register("BlockMember", 2, &testCaveatBlockMember{}) })
+
+	defer func() {
+		delete(t2c, b.Type(1))
+		delete(t2s, b.Type(1))
+		delete(s2t, "BlockMember")
+	}()
+	b.Register("BlockMember", 1, &testCaveatBlockMember{})
+
+	_, err := typeToCaveat(b.Type(1))
+	assert.NoError(t, err)
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"sync"
 
 	msgpack "github.com/vmihailenco/msgpack/v5"
 )
@@ -11,6 +13,16 @@ import (
 // CaveatSet is how a set of caveats is serailized/encoded.
 type CaveatSet struct {
 	Caveats []Caveat
+
+	// original encodings of caveats that were migrated during decoding, by
+	// index in Caveats.
+	raw map[int]rawCaveat
+
+	pooled   bool
+	verified bool
+
+	// origin of each caveat, by index in Caveats. Set by Verify.
+	provenance []CaveatProvenance
 }
 
 var (
@@ -21,7 +33,7 @@ var (
 
 // Create a new CaveatSet comprised of the specified caveats.
 func NewCaveatSet(caveats ...Caveat) *CaveatSet {
-	return &CaveatSet{append([]Caveat{}, caveats...)}
+	return &CaveatSet{Caveats: append([]Caveat{}, caveats...)}
 }
 
 // Decodes a set of serialized caveats.
@@ -35,34 +47,131 @@ func DecodeCaveats(buf []byte) (*CaveatSet, error) {
 	return cavs, nil
 }
 
+// Verified returns whether the caveat set was returned by a successful
+// [Macaroon.Verify], as opposed to being decoded or constructed. Code
+// extracting identities or other attestations from caveats should check this.
+func (c *CaveatSet) Verified() bool {
+	return c.verified
+}
+
+// DecodeCaveatsFrom is like [DecodeCaveats], but reads the caveats from r. If
+// r doesn't implement [io.ByteScanner], DecodeCaveatsFrom may read past the
+// end of the caveat set.
+func DecodeCaveatsFrom(r io.Reader) (*CaveatSet, error) {
+	cavs := new(CaveatSet)
+
+	if err := decodeFrom(r, cavs); err != nil {
+		return nil, err
+	}
+
+	return cavs, nil
+}
+
+// EncodeTo writes the encoded caveat set to w.
+func (c *CaveatSet) EncodeTo(w io.Writer) error {
+	return encodeTo(w, c)
+}
+
 // Validates that the caveat set permits the specified accesses.
 func (c *CaveatSet) Validate(accesses ...Access) error {
 	return Validate(c, accesses...)
 }
 
+// Validates that the caveat set permits the specified accesses, sharing a
+// single [ValidationContext] between them. See [ValidateAll].
+func (c *CaveatSet) ValidateAll(accesses ...Access) error {
+	return ValidateAll(c, accesses...)
+}
+
 // Helper for validating concretely-typed accesses.
 func Validate[A Access](cs *CaveatSet, accesses ...A) error {
-	var merr error
+	return new(Validator).Validate(cs, toAccesses(accesses)...)
+}
+
+// ValidateAll is like [Validate], but treats the accesses as a single
+// operation. All the accesses share one [ValidationContext], allowing caveats
+// implementing [ContextualCaveat] to keep state across the accesses in the
+// call. Structurally invalid accesses are reported and skipped, as with
+// Validate.
+func ValidateAll[A Access](cs *CaveatSet, accesses ...A) error {
+	return new(Validator).ValidateAll(cs, toAccesses(accesses)...)
+}
+
+func toAccesses[A Access](accesses []A) []Access {
+	ret := make([]Access, 0, len(accesses))
 	for _, access := range accesses {
-		if ferr := access.Validate(); ferr != nil {
-			merr = appendErrs(merr, ferr)
+		ret = append(ret, access)
+	}
+	return ret
+}
+
+// minParallelCaveats is the smallest caveat set that will be evaluated
+// concurrently with WithParallelism. Below this, the overhead of coordinating
+// goroutines outweighs the gains.
+const minParallelCaveats = 32
+
+func (c *CaveatSet) validateAccess(vc *ValidationContext, access Access) error {
+	if n := vc.run.parallelism; n > 1 && len(c.Caveats) >= minParallelCaveats {
+		return c.validateAccessParallel(vc, access, n)
+	}
+
+	var merr error
+	for _, caveat := range c.Caveats {
+		if caveat.IsAttestation() {
 			continue
 		}
 
-		merr = appendErrs(merr, cs.validateAccess(access))
+		err := vc.Prohibits(caveat, access)
+		if errors.Is(err, ErrBudgetExceeded) {
+			return appendErrs(merr, err)
+		}
+
+		merr = appendErrs(merr, err)
 	}
 
 	return merr
 }
 
-func (c *CaveatSet) validateAccess(access Access) error {
-	var merr error
-	for _, caveat := range c.Caveats {
-		if caveat.IsAttestation() {
-			continue
+// validateAccessParallel is like validateAccess, but evaluates caveats using
+// up to n goroutines. Errors are aggregated in caveat order.
+func (c *CaveatSet) validateAccessParallel(vc *ValidationContext, access Access, n int) error {
+	var (
+		errs    = make([]error, len(c.Caveats))
+		indices = make(chan int)
+		wg      sync.WaitGroup
+	)
+
+	if n > len(c.Caveats) {
+		n = len(c.Caveats)
+	}
+
+	wg.Add(n)
+	for w := 0; w < n; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				errs[i] = vc.Prohibits(c.Caveats[i], access)
+			}
+		}()
+	}
+
+	for i, caveat := range c.Caveats {
+		if vc.run.exhausted() {
+			break
+		}
+		if !caveat.IsAttestation() {
+			indices <- i
 		}
+	}
+	close(indices)
+	wg.Wait()
 
-		merr = appendErrs(merr, caveat.Prohibits(access))
+	var merr error
+	for _, err := range errs {
+		merr = appendErrs(merr, err)
+		if errors.Is(err, ErrBudgetExceeded) {
+			break
+		}
 	}
 
 	return merr
@@ -94,12 +203,24 @@ func (c CaveatSet) EncodeMsgpack(enc *msgpack.Encoder) error {
 		return err
 	}
 
-	for _, cav := range c.Caveats {
+	for i, cav := range c.Caveats {
+		if raw, migrated := c.raw[i]; migrated {
+			if err := enc.EncodeUint(uint64(raw.typ)); err != nil {
+				return err
+			}
+
+			if err := enc.Encode(raw.body); err != nil {
+				return err
+			}
+
+			continue
+		}
+
 		if err := enc.EncodeUint(uint64(cav.CaveatType())); err != nil {
 			return err
 		}
 
-		if err := enc.Encode(cav); err != nil {
+		if err := encodeCaveatBody(enc, cav); err != nil {
 			return err
 		}
 	}
@@ -107,6 +228,22 @@ func (c CaveatSet) EncodeMsgpack(enc *msgpack.Encoder) error {
 	return nil
 }
 
+// caveatBytes returns the encoding of a set containing only the i'th caveat,
+// preserving the original encoding of migrated caveats. This is what gets
+// signed.
+func (c *CaveatSet) caveatBytes(i int) ([]byte, error) {
+	single := NewCaveatSet(c.Caveats[i])
+	if raw, migrated := c.raw[i]; migrated {
+		single.raw = map[int]rawCaveat{0: raw}
+	}
+
+	return single.MarshalMsgpack()
+}
+
+// maxPreallocCaveats bounds the capacity allocated up front when decoding a
+// caveat set, so a corrupt or malicious length can't exhaust memory.
+const maxPreallocCaveats = 64
+
 // Implements msgpack.CustomDecoder
 func (c *CaveatSet) DecodeMsgpack(dec *msgpack.Decoder) error {
 	aLen, err := dec.DecodeArrayLen()
@@ -120,7 +257,12 @@ func (c *CaveatSet) DecodeMsgpack(dec *msgpack.Decoder) error {
 	nCavs := aLen / 2
 
 	if c.Caveats == nil {
-		c.Caveats = make([]Caveat, 0, nCavs)
+		// don't trust the length prefix for preallocation
+		prealloc := nCavs
+		if prealloc > maxPreallocCaveats {
+			prealloc = maxPreallocCaveats
+		}
+		c.Caveats = make([]Caveat, 0, prealloc)
 	}
 
 	for i := 0; i < nCavs; i++ {
@@ -134,10 +276,32 @@ func (c *CaveatSet) DecodeMsgpack(dec *msgpack.Decoder) error {
 			return err
 		}
 
-		if err := dec.Decode(cav); err != nil {
+		if _, superseded := migrations[CaveatType(t)]; !superseded {
+			if err := decodeCaveatBody(dec, cav); err != nil {
+				return err
+			}
+
+			c.Caveats = append(c.Caveats, cav)
+			continue
+		}
+
+		body, err := dec.DecodeRaw()
+		if err != nil {
 			return err
 		}
 
+		if err := unmarshalCaveatBody(body, cav); err != nil {
+			return err
+		}
+
+		if cav, err = migrate(cav); err != nil {
+			return err
+		}
+
+		if c.raw == nil {
+			c.raw = map[int]rawCaveat{}
+		}
+		c.raw[len(c.Caveats)] = rawCaveat{CaveatType(t), body}
 		c.Caveats = append(c.Caveats, cav)
 	}
 
@@ -187,6 +351,11 @@ func (c *CaveatSet) UnmarshalJSON(b []byte) error {
 		if err := json.Unmarshal(jcavs[i].Body, &c.Caveats[i]); err != nil {
 			return err
 		}
+
+		var err error
+		if c.Caveats[i], err = migrate(c.Caveats[i]); err != nil {
+			return err
+		}
 	}
 
 	return nil
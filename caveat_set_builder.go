@@ -0,0 +1,117 @@
+package macaroon
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	singletonCaveats    = map[CaveatType]bool{}
+	incompatibleCaveats = map[[2]CaveatType]string{}
+)
+
+// RegisterSingletonCaveatType declares that caveats of the type may appear at
+// most once among a caveat set's top-level caveats, e.g. because further
+// caveats of the type would only ever narrow the first one. Tokens attenuated
+// by other parties may still carry several; the rule applies to caveat sets
+// built at once (see [CaveatSetBuilder] and [CheckCaveatRules]).
+func RegisterSingletonCaveatType(typ CaveatType) {
+	singletonCaveats[typ] = true
+}
+
+// RegisterIncompatibleCaveatTypes declares that caveats of types a and b
+// can't both appear among a caveat set's top-level caveats, e.g. because
+// they restrict mutually exclusive resources, so that no access could
+// satisfy both. The reason is included in errors.
+func RegisterIncompatibleCaveatTypes(a, b CaveatType, reason string) {
+	if a == b {
+		panic("caveat type incompatible with itself; use RegisterSingletonCaveatType")
+	}
+	incompatibleCaveats[incompatiblePair(a, b)] = reason
+}
+
+func incompatiblePair(a, b CaveatType) [2]CaveatType {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]CaveatType{a, b}
+}
+
+// CheckCaveatRules checks caveats against the registered singleton and
+// incompatibility rules (see [RegisterSingletonCaveatType] and
+// [RegisterIncompatibleCaveatTypes]), returning ErrConflictingCaveats for
+// each violation. Caveats nested within IfPresent caveats aren't checked,
+// since they apply to different accesses.
+func CheckCaveatRules(caveats ...Caveat) error {
+	var merr error
+	for i, c := range caveats {
+		if err := checkCaveatRules(caveats[:i], c); err != nil {
+			merr = appendErrs(merr, err)
+		}
+	}
+	return merr
+}
+
+func checkCaveatRules(existing []Caveat, c Caveat) error {
+	typ := c.CaveatType()
+
+	for _, e := range existing {
+		etyp := e.CaveatType()
+
+		if etyp == typ && singletonCaveats[typ] {
+			return fmt.Errorf("%w: more than one %s caveat", ErrConflictingCaveats, caveatTypeToString(typ))
+		}
+
+		if reason, ok := incompatibleCaveats[incompatiblePair(etyp, typ)]; ok {
+			return fmt.Errorf("%w: %s and %s caveats: %s", ErrConflictingCaveats, caveatTypeToString(etyp), caveatTypeToString(typ), reason)
+		}
+	}
+
+	return nil
+}
+
+// CaveatSetBuilder builds caveat sets, enforcing the registered caveat rules
+// (see [CheckCaveatRules]) as caveats are added, so that e.g. a set can't
+// carry two ValidityWindows. Errors are reported by Build:
+//
+//	cs, err := NewCaveatSetBuilder().
+//		Add(orgCaveat).
+//		Add(&ValidityWindow{NotBefore: now, NotAfter: now + 3600}).
+//		Build()
+type CaveatSetBuilder struct {
+	caveats []Caveat
+	err     error
+}
+
+// NewCaveatSetBuilder creates an empty CaveatSetBuilder.
+func NewCaveatSetBuilder() *CaveatSetBuilder {
+	return new(CaveatSetBuilder)
+}
+
+// Add adds caveats to the set, checking each against the caveats already
+// added. Caveats violating the rules aren't added.
+func (b *CaveatSetBuilder) Add(caveats ...Caveat) *CaveatSetBuilder {
+	for _, c := range caveats {
+		if c == nil {
+			b.err = appendErrs(b.err, errors.New("nil caveat"))
+			continue
+		}
+
+		if err := checkCaveatRules(b.caveats, c); err != nil {
+			b.err = appendErrs(b.err, err)
+			continue
+		}
+
+		b.caveats = append(b.caveats, c)
+	}
+	return b
+}
+
+// Build returns the caveat set, or the errors from any caveats that were
+// refused.
+func (b *CaveatSetBuilder) Build() (*CaveatSet, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return NewCaveatSet(b.caveats...), nil
+}
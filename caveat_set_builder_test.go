@@ -0,0 +1,38 @@
+package macaroon
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func init() {
+	RegisterIncompatibleCaveatTypes(cavTestWire, cavTestTimestamped, "for testing")
+}
+
+func TestCaveatSetBuilder(t *testing.T) {
+	vw := &ValidityWindow{NotBefore: 1, NotAfter: 2}
+
+	cs, err := NewCaveatSetBuilder().
+		Add(cavParent(ActionRead, 1), cavParent(ActionRead, 2)).
+		Add(vw).
+		Add(&IfPresent{Ifs: NewCaveatSet(vw), Else: ActionRead}).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(cs.Caveats))
+
+	_, err = NewCaveatSetBuilder().Add(vw, cavParent(ActionRead, 1), vw).Build()
+	assert.True(t, errors.Is(err, ErrConflictingCaveats))
+	assert.Contains(t, err.Error(), "more than one ValidityWindow caveat")
+
+	_, err = NewCaveatSetBuilder().Add(&testTimestampedAttestation{}).Add(&testCaveatWire{}).Build()
+	assert.True(t, errors.Is(err, ErrConflictingCaveats))
+	assert.Contains(t, err.Error(), "for testing")
+
+	_, err = NewCaveatSetBuilder().Add(nil).Build()
+	assert.Error(t, err)
+
+	assert.NoError(t, CheckCaveatRules(vw, cavParent(ActionRead, 1), &testCaveatWire{}))
+	assert.True(t, errors.Is(CheckCaveatRules(vw, &testCaveatWire{}, vw), ErrConflictingCaveats))
+}
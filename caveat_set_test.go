@@ -28,6 +28,7 @@ func TestCaveatSerialization(t *testing.T) {
 		&Caveat3P{Location: "123", VID: []byte("123"), CID: []byte("123")},
 		&BindToParentToken{1, 2, 3},
 		&IfPresent{Ifs: NewCaveatSet(&ValidityWindow{NotBefore: 123, NotAfter: 234}), Else: ActionDelete},
+		&Predicate{Expression: "time < 2025-01-01"},
 	)
 
 	b, err := json.Marshal(cs)
@@ -44,3 +45,33 @@ func TestCaveatSerialization(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, cs, cs2)
 }
+
+type testCaveatRenamed struct {
+	Value string `json:"value"`
+}
+
+func init() {
+	RegisterCaveatType("Renamed", cavTestRenamed, &testCaveatRenamed{}, "OriginalName", "OtherName")
+}
+
+func (c *testCaveatRenamed) CaveatType() CaveatType   { return cavTestRenamed }
+func (c *testCaveatRenamed) IsAttestation() bool      { return false }
+func (c *testCaveatRenamed) Prohibits(f Access) error { return nil }
+
+func TestCaveatAliases(t *testing.T) {
+	expected := NewCaveatSet(&testCaveatRenamed{Value: "foo"})
+
+	for _, name := range []string{"Renamed", "OriginalName", "OtherName"} {
+		cs := NewCaveatSet()
+		assert.NoError(t, json.Unmarshal([]byte(`[{"type":"`+name+`","body":{"value":"foo"}}]`), cs))
+		assert.Equal(t, expected, cs)
+	}
+
+	b, err := json.Marshal(expected)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"type":"Renamed","body":{"value":"foo"}}]`, string(b))
+
+	assert.Panics(t, func() {
+		RegisterCaveatType("Unique", CavMaxUserDefined, &testCaveatRenamed{}, "OriginalName")
+	})
+}
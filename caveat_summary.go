@@ -0,0 +1,103 @@
+package macaroon
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// CaveatSummary describes the caveats of a token to the third party that must
+// discharge one of its third-party caveats. Third parties otherwise only see
+// the caveats in their tickets, but may want to know what the token grants,
+// e.g. to show users what they're approving or to refuse to discharge tokens
+// that are too broad. Issuers create summaries with [Macaroon.Summarize3P],
+// and clients pass them to the third party alongside the ticket, which opens
+// them with [OpenCaveatSummary].
+//
+// Summaries don't include the token, so can't be used in its place. The
+// tickets of third-party caveats in the summary are removed, leaving only
+// their locations.
+type CaveatSummary struct {
+	// TokenUUID is the UUID of the token's nonce (see [Nonce.UUID]).
+	TokenUUID string
+
+	// Location is the token's location.
+	Location string
+
+	// Caveats are the token's caveats, as of the summary's creation.
+	Caveats *CaveatSet
+}
+
+type wireCaveatSummary struct {
+	_msgpack  struct{} `msgpack:",as_array"`
+	CID       []byte
+	TokenUUID string
+	Location  string
+	Caveats   *CaveatSet
+}
+
+// Summarize3P creates a [CaveatSummary] of the token's caveats for the third
+// party at the location, sealed with s, the key shared with the third party.
+// Sealing shows the third party that the summary came from the issuer, and
+// the summary is bound to the third-party caveat's ticket, so it can't be
+// presented with another token's. The summary reflects the caveats when it's
+// created; caveats added later by attenuation aren't included.
+func (m *Macaroon) Summarize3P(s Sealer, location string) ([]byte, error) {
+	cid, err := m.ThirdPartyCID(location)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("summarize caveats: %w", err)
+	case cid == nil:
+		return nil, fmt.Errorf("summarize caveats: no third-party caveat for %s", location)
+	}
+
+	cs := NewCaveatSet()
+	for _, cav := range m.UnsafeCaveats.Caveats {
+		if tp, ok := cav.(*Caveat3P); ok {
+			cav = &Caveat3P{Location: tp.Location}
+		}
+		cs.Caveats = append(cs.Caveats, cav)
+	}
+
+	pt, err := encode(wireCaveatSummary{
+		CID:       cid,
+		TokenUUID: m.Nonce.UUID().String(),
+		Location:  m.Location,
+		Caveats:   cs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("summarize caveats: %w", err)
+	}
+
+	ct, err := s.Seal(pt)
+	if err != nil {
+		return nil, fmt.Errorf("summarize caveats: %w", err)
+	}
+
+	return ct, nil
+}
+
+// OpenCaveatSummary is used by third parties to open a summary created with
+// [Macaroon.Summarize3P], using the key shared with the issuer. cid is the
+// ticket being discharged, which the summary must be bound to.
+func OpenCaveatSummary(s Sealer, summary []byte, cid []byte) (*CaveatSummary, error) {
+	pt, err := s.Unseal(summary)
+	if err != nil {
+		return nil, fmt.Errorf("open caveat summary: %w", err)
+	}
+
+	var w wireCaveatSummary
+	if err := decodeFrom(bytes.NewReader(pt), &w); err != nil {
+		return nil, fmt.Errorf("open caveat summary: %w", err)
+	}
+
+	if !bytes.Equal(w.CID, cid) {
+		return nil, errors.New("open caveat summary: summary is for a different ticket")
+	}
+
+	if w.Caveats == nil {
+		w.Caveats = NewCaveatSet()
+	}
+
+	return &CaveatSummary{TokenUUID: w.TokenUUID, Location: w.Location, Caveats: w.Caveats}, nil
+}
@@ -0,0 +1,50 @@
+package macaroon
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestCaveatSummary(t *testing.T) {
+	var (
+		key   = NewSigningKey()
+		ka    = NewEncryptionKey()
+		other = NewEncryptionKey()
+	)
+
+	m, err := New([]byte("kid"), "https://issuer", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavParent(ActionRead, 123)))
+	assert.NoError(t, m.Add3P(ka, "https://3p", cavChild(ActionRead, 234)))
+	assert.NoError(t, m.Add3P(other, "https://other"))
+
+	summary, err := m.Summarize3P(ka, "https://3p")
+	assert.NoError(t, err)
+
+	cid, err := m.ThirdPartyCID("https://3p")
+	assert.NoError(t, err)
+
+	cs, err := OpenCaveatSummary(ka, summary, cid)
+	assert.NoError(t, err)
+	assert.Equal(t, m.Nonce.UUID().String(), cs.TokenUUID)
+	assert.Equal(t, "https://issuer", cs.Location)
+	assert.Equal(t, []Caveat{
+		cavParent(ActionRead, 123),
+		&Caveat3P{Location: "https://3p"},
+		&Caveat3P{Location: "https://other"},
+	}, cs.Caveats.Caveats)
+
+	// the summary is bound to the ticket
+	otherCID, err := m.ThirdPartyCID("https://other")
+	assert.NoError(t, err)
+	_, err = OpenCaveatSummary(ka, summary, otherCID)
+	assert.Error(t, err)
+
+	// and sealed with the 3P's key
+	_, err = OpenCaveatSummary(other, summary, cid)
+	assert.Error(t, err)
+
+	_, err = m.Summarize3P(ka, "https://missing")
+	assert.Error(t, err)
+}
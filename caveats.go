@@ -49,6 +49,10 @@ func (c *IfPresent) CaveatType() CaveatType {
 }
 
 func (c *IfPresent) Prohibits(f Access) error {
+	return c.ProhibitsWithContext(newValidationContext(f), f)
+}
+
+func (c *IfPresent) ProhibitsWithContext(vc *ValidationContext, f Access) error {
 	var (
 		merr     error
 		ifBranch bool
@@ -56,14 +60,14 @@ func (c *IfPresent) Prohibits(f Access) error {
 
 	for _, cc := range c.Ifs.Caveats {
 		// set merr if any of the `Ifs` returns nil or a non-errResourceUnspecified error
-		if cErr := cc.Prohibits(f); !errors.Is(cErr, ErrResourceUnspecified) {
+		if cErr := vc.Prohibits(cc, f); !errors.Is(cErr, ErrResourceUnspecified) {
 			merr = appendErrs(merr, cErr)
 			ifBranch = true
 		}
 	}
 
 	if !ifBranch && !f.GetAction().IsSubsetOf(c.Else) {
-		return fmt.Errorf("%w access %s (%s not allowed)", ErrUnauthorizedForAction, f.GetAction(), f.GetAction().Remove(c.Else))
+		return &ActionError{Requested: f.GetAction(), Permitted: c.Else}
 	}
 
 	return merr
@@ -77,20 +81,29 @@ type ValidityWindow struct {
 	NotAfter  int64 `json:"not_after"`
 }
 
-func init() { RegisterCaveatType("ValidityWindow", CavValidityWindow, &ValidityWindow{}) }
+func init() {
+	RegisterCaveatType("ValidityWindow", CavValidityWindow, &ValidityWindow{})
+	RegisterSingletonCaveatType(CavValidityWindow)
+}
 
 func (c *ValidityWindow) CaveatType() CaveatType {
 	return CavValidityWindow
 }
 
 func (c *ValidityWindow) Prohibits(f Access) error {
+	return c.ProhibitsWithContext(newValidationContext(f), f)
+}
+
+func (c *ValidityWindow) ProhibitsWithContext(vc *ValidationContext, f Access) error {
+	now := vc.Now(f)
+
 	na := time.Unix(c.NotAfter, 0)
-	if f.Now().After(na) {
+	if now.After(na) {
 		return fmt.Errorf("%w: token only valid until %s", ErrUnauthorized, na)
 	}
 
 	nb := time.Unix(c.NotBefore, 0)
-	if f.Now().Before(nb) {
+	if now.Before(nb) {
 		return fmt.Errorf("%w: token not valid until %s", ErrUnauthorized, nb)
 	}
 
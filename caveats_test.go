@@ -192,3 +192,20 @@ func TestIfPresent(t *testing.T) {
 func ptr[T any](t T) *T {
 	return &t
 }
+
+func TestRegisteredCaveats(t *testing.T) {
+	cavs := RegisteredCaveats()
+	assert.Equal(t, len(t2c), len(cavs))
+
+	var sawVW bool
+	for i, c := range cavs {
+		if i > 0 {
+			assert.True(t, cavs[i-1].CaveatType() < c.CaveatType())
+		}
+		if vw, ok := c.(*ValidityWindow); ok {
+			sawVW = true
+			assert.Equal(t, ValidityWindow{}, *vw)
+		}
+	}
+	assert.True(t, sawVW)
+}
@@ -0,0 +1,188 @@
+package macaroon
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Challenge describes a discharge a client needs to obtain before its
+// request can be authorized: the location of the third party and the ticket
+// to present to it. Challenges are sent to clients in WWW-Authenticate
+// headers, so they can discover which third parties to contact.
+type Challenge struct {
+	Location string
+	Ticket   []byte
+}
+
+// MissingDischarges returns challenges for the third-party caveats on m that
+// aren't discharged by any of the existing discharges, ordered by location.
+func MissingDischarges(m *Macaroon, existingDischarges ...[]byte) ([]Challenge, error) {
+	cids, err := m.ThirdPartyCIDs(existingDischarges...)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]Challenge, 0, len(cids))
+	for loc, cid := range cids {
+		ret = append(ret, Challenge{Location: loc, Ticket: cid})
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Location < ret[j].Location })
+
+	return ret, nil
+}
+
+// String formats the challenge for a WWW-Authenticate header.
+func (c Challenge) String() string {
+	return fmt.Sprintf("%s location=%s, ticket=%s",
+		authorizationScheme,
+		quote(c.Location),
+		quote(base64.StdEncoding.EncodeToString(c.Ticket)),
+	)
+}
+
+// ToWWWAuthenticateHeader formats challenges as a WWW-Authenticate header
+// value.
+func ToWWWAuthenticateHeader(challenges ...Challenge) string {
+	strs := make([]string, len(challenges))
+	for i, c := range challenges {
+		strs[i] = c.String()
+	}
+
+	return strings.Join(strs, ", ")
+}
+
+// ParseWWWAuthenticate parses the FlyV1 challenges in WWW-Authenticate header
+// values. Challenges for other schemes are ignored.
+func ParseWWWAuthenticate(headers ...string) ([]Challenge, error) {
+	var ret []Challenge
+
+	for _, header := range headers {
+		challenges, err := parseChallenges(header)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ch := range challenges {
+			if !strings.EqualFold(ch.scheme, authorizationScheme) {
+				continue
+			}
+
+			loc, ok := ch.params["location"]
+			if !ok || loc == "" {
+				return nil, errors.New("parse challenge: missing location")
+			}
+
+			ticket, err := base64.StdEncoding.DecodeString(ch.params["ticket"])
+			if err != nil || len(ticket) == 0 {
+				return nil, fmt.Errorf("parse challenge: bad ticket for %s", loc)
+			}
+
+			ret = append(ret, Challenge{Location: loc, Ticket: ticket})
+		}
+	}
+
+	return ret, nil
+}
+
+type rawChallenge struct {
+	scheme string
+	params map[string]string
+}
+
+// parseChallenges parses a WWW-Authenticate header value into challenges
+// with auth-params (RFC 9110, section 11.6.1). token68 credentials aren't
+// supported.
+func parseChallenges(header string) ([]rawChallenge, error) {
+	var (
+		ret []rawChallenge
+		s   = header
+		cur *rawChallenge
+	)
+
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return ret, nil
+		}
+
+		tok := s[:tokenLen(s)]
+		if tok == "" {
+			return nil, fmt.Errorf("parse challenge: unexpected %q", s[0])
+		}
+		s = strings.TrimLeft(s[len(tok):], " \t")
+
+		if !strings.HasPrefix(s, "=") {
+			// start of a new challenge
+			ret = append(ret, rawChallenge{scheme: tok, params: map[string]string{}})
+			cur = &ret[len(ret)-1]
+			continue
+		}
+
+		if cur == nil {
+			return nil, errors.New("parse challenge: auth-param without scheme")
+		}
+
+		s = strings.TrimLeft(s[1:], " \t")
+
+		var val string
+		if strings.HasPrefix(s, `"`) {
+			end := quotedLen(s)
+			if end < 0 {
+				return nil, errors.New("parse challenge: unterminated quoted string")
+			}
+
+			val, s = unquote(s[:end]), s[end:]
+		} else {
+			val = s[:tokenLen(s)]
+			s = s[len(val):]
+		}
+
+		cur.params[strings.ToLower(tok)] = val
+	}
+}
+
+// tokenLen returns the length of the HTTP token at the start of s.
+func tokenLen(s string) int {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c >= 0x7f || strings.IndexByte(`"(),/:;<=>?@[\]{}`, c) >= 0 {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// quotedLen returns the length of the quoted string at the start of s,
+// including the quotes, or -1 if it's unterminated.
+func quotedLen(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// quote formats s as an HTTP quoted string.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// unquote removes the quotes and quoted-pair escapes from a quoted string.
+func unquote(s string) string {
+	var sb strings.Builder
+	for i := 1; i < len(s)-1; i++ {
+		if s[i] == '\\' {
+			i++
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,61 @@
+package macaroon
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestChallenges(t *testing.T) {
+	var (
+		key = NewSigningKey()
+		ka1 = NewEncryptionKey()
+		ka2 = NewEncryptionKey()
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add3P(ka1, "https://auth"))
+	assert.NoError(t, m.Add3P(ka2, `https://weird"\loc`))
+	tok, err := m.Encode()
+	assert.NoError(t, err)
+
+	challenges, err := MissingDischarges(m)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(challenges))
+	assert.Equal(t, "https://auth", challenges[0].Location)
+
+	parsed, err := ParseWWWAuthenticate(ToWWWAuthenticateHeader(challenges...))
+	assert.NoError(t, err)
+	assert.Equal(t, challenges, parsed)
+
+	// discharged caveats aren't challenged
+	_, _, dm, err := dischargeMacaroon(ka1, "https://auth", tok)
+	assert.NoError(t, err)
+	dtok, err := dm.Encode()
+	assert.NoError(t, err)
+
+	challenges, err = MissingDischarges(m, dtok)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(challenges))
+	assert.Equal(t, `https://weird"\loc`, challenges[0].Location)
+
+	// other schemes are skipped
+	parsed, err = ParseWWWAuthenticate(
+		`Basic realm="x", charset=UTF-8, `+challenges[0].String()+`, Bearer realm="y", error="invalid_token"`,
+		`Newauth realm="apps"`,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, challenges, parsed)
+
+	for _, bad := range []string{
+		`FlyV1 ticket="AAAA"`,
+		`FlyV1 location="https://auth"`,
+		`FlyV1 location="https://auth", ticket="!!"`,
+		`FlyV1 location="https://auth, ticket="AAAA`,
+		`=foo`,
+	} {
+		_, err = ParseWWWAuthenticate(bad)
+		assert.Error(t, err, bad)
+	}
+}
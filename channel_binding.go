@@ -0,0 +1,117 @@
+package macaroon
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+
+	"github.com/superfly/macaroon/access"
+)
+
+// ChannelBindingLabel is the TLS exporter label for RFC 9266 tls-exporter
+// channel bindings.
+const ChannelBindingLabel = "EXPORTER-Channel-Binding"
+
+const channelBindingLength = 32
+
+// TLSChannelBinding computes the RFC 9266 tls-exporter channel binding for a
+// TLS connection. Both ends of a connection compute the same binding, which
+// is unique to the connection. TLS 1.2 connections are only supported if they
+// negotiated the extended master secret extension.
+func TLSChannelBinding(cs *tls.ConnectionState) ([]byte, error) {
+	if cs == nil {
+		return nil, fmt.Errorf("channel binding: not a TLS connection")
+	}
+
+	binding, err := cs.ExportKeyingMaterial(ChannelBindingLabel, nil, channelBindingLength)
+	if err != nil {
+		return nil, fmt.Errorf("channel binding: %w", err)
+	}
+
+	return binding, nil
+}
+
+// ChannelBinding is a caveat binding a token to a TLS connection. Requests
+// using the token must be made over the connection, as established by an
+// Access implementing [access.ChannelBinding] or with
+// [WithValidationChannelBinding]. Digest is the SHA256 digest of the
+// connection's channel binding (see [TLSChannelBinding]); the binding itself
+// is secret to the connection.
+type ChannelBinding struct {
+	Digest []byte `json:"digest"`
+}
+
+func init() { RegisterCaveatType("ChannelBinding", CavChannelBinding, &ChannelBinding{}) }
+
+// NewChannelBinding creates a ChannelBinding caveat for the channel binding
+// of a TLS connection.
+func NewChannelBinding(binding []byte) *ChannelBinding {
+	digest := sha256.Sum256(binding)
+	return &ChannelBinding{Digest: digest[:]}
+}
+
+func (c *ChannelBinding) CaveatType() CaveatType { return CavChannelBinding }
+func (c *ChannelBinding) IsAttestation() bool    { return false }
+
+func (c *ChannelBinding) Prohibits(f Access) error {
+	return c.ProhibitsWithContext(newValidationContext(f), f)
+}
+
+func (c *ChannelBinding) ProhibitsWithContext(vc *ValidationContext, f Access) error {
+	if cb, ok := access.As[access.ChannelBinding](f); ok {
+		return c.check(cb.GetChannelBinding())
+	}
+
+	if binding, ok := vc.Get(channelBindingKey{}); ok {
+		return c.check(binding.([]byte))
+	}
+
+	return fmt.Errorf("%w: no channel binding", ErrInvalidAccess)
+}
+
+func (c *ChannelBinding) check(binding []byte) error {
+	digest := sha256.Sum256(binding)
+	if len(binding) == 0 || subtle.ConstantTimeCompare(digest[:], c.Digest) != 1 {
+		return fmt.Errorf("%w: token bound to a different TLS channel", ErrUnauthorized)
+	}
+	return nil
+}
+
+type channelBindingKey struct{}
+
+// WithValidationChannelBinding has the Validator check [ChannelBinding]
+// caveats against the channel binding of the TLS connection the accesses are
+// made over (see [TLSChannelBinding]), for Accesses that don't implement
+// [access.ChannelBinding]. Bindings are checked on every validation, rather
+// than being remembered by verified caveat sets, because verifications may
+// be cached and shared between connections (see [ReadThroughVerifier]).
+func WithValidationChannelBinding(binding []byte) ValidationOption {
+	return WithContextValue(channelBindingKey{}, binding)
+}
+
+// WithChannelBinding has Verify check that the token and its discharges are
+// used over the TLS connection with the specified channel binding (see
+// [TLSChannelBinding]). Verification fails if any [ChannelBinding] caveat
+// doesn't match. This only rejects tokens early: the verified caveat set's
+// ChannelBinding caveats must still be satisfied during validation, by the
+// Access or with [WithValidationChannelBinding].
+func WithChannelBinding(binding []byte) VerifyOption {
+	return func(o *verifyOptions) { o.channelBinding = binding }
+}
+
+// checkChannelBinding checks the verified caveats' channel bindings against
+// the binding passed to WithChannelBinding, if any.
+func (o *verifyOptions) checkChannelBinding(cs *CaveatSet) error {
+	if o.channelBinding == nil {
+		return nil
+	}
+
+	for _, cb := range GetCaveats[*ChannelBinding](cs) {
+		if err := cb.check(o.channelBinding); err != nil {
+			return fmt.Errorf("macaroon verify: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package macaroon
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+// tlsPipe returns the connection states of both ends of a TLS connection.
+func tlsPipe(t *testing.T) (client, server tls.ConnectionState) {
+	t.Helper()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"api"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	assert.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	cc, sc := net.Pipe()
+	tc := tls.Client(cc, &tls.Config{ServerName: "api", RootCAs: pool})
+	ts := tls.Server(sc, &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: priv}}})
+	t.Cleanup(func() { cc.Close(); sc.Close() })
+
+	errs := make(chan error, 1)
+	go func() { errs <- ts.Handshake() }()
+	assert.NoError(t, tc.Handshake())
+	assert.NoError(t, <-errs)
+
+	return tc.ConnectionState(), ts.ConnectionState()
+}
+
+type channelBindingPart []byte
+
+func (p channelBindingPart) GetChannelBinding() []byte { return p }
+
+func TestChannelBinding(t *testing.T) {
+	client, server := tlsPipe(t)
+
+	cb, err := TLSChannelBinding(&client)
+	assert.NoError(t, err)
+	sb, err := TLSChannelBinding(&server)
+	assert.NoError(t, err)
+	assert.Equal(t, cb, sb)
+
+	other, _ := tlsPipe(t)
+	ob, err := TLSChannelBinding(&other)
+	assert.NoError(t, err)
+	assert.NotEqual(t, cb, ob)
+
+	_, err = TLSChannelBinding(nil)
+	assert.Error(t, err)
+
+	key := NewSigningKey()
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavParent(ActionRead, 1), NewChannelBinding(cb)))
+
+	a := &testAccess{action: ActionRead, parentResource: ptr(uint64(1))}
+
+	// checked at verification time, but still enforced by validation
+	cs, err := m.Verify(key, nil, nil, WithChannelBinding(sb))
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(cs.Validate(a), ErrInvalidAccess))
+	assert.NoError(t, NewValidator(WithValidationChannelBinding(sb)).Validate(cs, a))
+	assert.True(t, errors.Is(NewValidator(WithValidationChannelBinding(ob)).Validate(cs, a), ErrUnauthorized))
+
+	_, err = m.Verify(key, nil, nil, WithChannelBinding(ob))
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+
+	// bound at validation time
+	cs, err = m.Verify(key, nil, nil)
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(cs.Validate(a), ErrInvalidAccess))
+	assert.NoError(t, cs.Validate(Compose(a, channelBindingPart(sb))))
+	assert.True(t, errors.Is(cs.Validate(Compose(a, channelBindingPart(ob))), ErrUnauthorized))
+	assert.True(t, errors.Is(cs.Validate(Compose(a, channelBindingPart(nil))), ErrUnauthorized))
+}
+
+func TestChannelBindingReadThrough(t *testing.T) {
+	clientA, _ := tlsPipe(t)
+	clientB, _ := tlsPipe(t)
+
+	bindingA, err := TLSChannelBinding(&clientA)
+	assert.NoError(t, err)
+	bindingB, err := TLSChannelBinding(&clientB)
+	assert.NoError(t, err)
+
+	key := NewSigningKey()
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavParent(ActionRead, 1), NewChannelBinding(bindingA)))
+	tok, err := m.Encode()
+	assert.NoError(t, err)
+
+	v := &ReadThroughVerifier{
+		Verify: func(m *Macaroon, discharges [][]byte, opts ...VerifyOption) (*CaveatSet, error) {
+			return m.Verify(key, discharges, nil, opts...)
+		},
+		Cache: NewLRUVerifiedCache(2),
+	}
+
+	a := &testAccess{action: ActionRead, parentResource: ptr(uint64(1))}
+
+	cs, err := v.VerifyToken(tok, nil, WithChannelBinding(bindingA))
+	assert.NoError(t, err)
+	assert.NoError(t, NewValidator(WithValidationChannelBinding(bindingA)).Validate(cs, a))
+
+	// the cached verification for connection A isn't used for connection B
+	_, err = v.VerifyToken(tok, nil, WithChannelBinding(bindingB))
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+
+	// nor does it carry connection A's binding to other requests
+	cs, err = v.VerifyToken(tok, nil, WithChannelBinding(bindingA))
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(NewValidator(WithValidationChannelBinding(bindingB)).Validate(cs, a), ErrUnauthorized))
+	assert.True(t, errors.Is(cs.Validate(Compose(a, channelBindingPart(bindingB))), ErrUnauthorized))
+	assert.True(t, errors.Is(cs.Validate(a), ErrInvalidAccess))
+}
@@ -0,0 +1,225 @@
+package macaroon
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// VerifierConfig describes a service's token verification configuration, for
+// checking with [Checkup].
+type VerifierConfig struct {
+	// Keys are the signing keys tokens are verified with.
+	Keys []KeyConfig
+
+	// Trusted3Ps are the third parties whose discharges' attestations are
+	// trusted.
+	Trusted3Ps Trusted3Ps
+
+	// Reachable, if set, checks that the trusted third party at the
+	// location is reachable. See [HTTPReachable].
+	Reachable func(loc string) error
+
+	// ReferenceTime, if set, returns the time according to a trusted source
+	// (e.g. an NTP server), for checking the local clock.
+	ReferenceTime func() (time.Time, error)
+
+	// MaxClockSkew is how far the local clock may be from the reference
+	// time, defaulting to DefaultMaxClockSkew.
+	MaxClockSkew time.Duration
+}
+
+// VerifierConfig returns the Keyring's keys and trusted third parties, for
+// checking with [Checkup]. Other fields of the config are left to the caller.
+func (k *Keyring) VerifierConfig() VerifierConfig {
+	kids := make([]string, 0, len(k.keys))
+	for kid := range k.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	cfg := VerifierConfig{Trusted3Ps: k.Trusted3Ps()}
+	for _, kid := range kids {
+		for _, nk := range k.keys[kid] {
+			cfg.Keys = append(cfg.Keys, KeyConfig{KID: []byte(kid), Name: nk.name, Key: nk.key})
+		}
+	}
+	return cfg
+}
+
+// KeyConfig is a signing key in a [VerifierConfig].
+type KeyConfig struct {
+	KID  []byte
+	Name string
+	Key  SigningKey
+}
+
+// DefaultMaxClockSkew is the default for [VerifierConfig.MaxClockSkew].
+const DefaultMaxClockSkew = time.Minute
+
+// earliestSaneTime is before any token this library could have minted. A
+// clock reading earlier than this hasn't been set.
+var earliestSaneTime = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Problem is a problem with a verification configuration, found by [Checkup].
+// Check names the check that failed (e.g. "key"), and Subject what it
+// failed for (e.g. a KID or location), if anything in particular.
+type Problem struct {
+	Check   string `json:"check"`
+	Subject string `json:"subject,omitempty"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	if p.Subject == "" {
+		return fmt.Sprintf("%s: %s", p.Check, p.Message)
+	}
+	return fmt.Sprintf("%s %s: %s", p.Check, p.Subject, p.Message)
+}
+
+// Checkup checks a verification configuration for problems that would make
+// verification fail or be unsafe, for inclusion in service health endpoints:
+//
+//   - there are signing keys, each with a KID and of the right length
+//   - no KID has two keys with the same name, and no key is used for more
+//     than one KID
+//   - trusted third parties have well-formed locations and keys, and are
+//     reachable (if cfg.Reachable is set)
+//   - the clock is set, and agrees with the reference time (if
+//     cfg.ReferenceTime is set)
+//
+// It returns nil if it finds no problems.
+func Checkup(cfg VerifierConfig) []Problem {
+	var ret []Problem
+	ret = append(ret, checkupKeys(cfg.Keys)...)
+	ret = append(ret, checkupTrusted3Ps(cfg)...)
+	ret = append(ret, checkupClock(cfg)...)
+	return ret
+}
+
+func checkupKeys(keys []KeyConfig) []Problem {
+	if len(keys) == 0 {
+		return []Problem{{Check: "keys", Message: "no signing keys"}}
+	}
+
+	var (
+		ret   []Problem
+		names = map[string]bool{}
+		kids  = map[string]string{}
+	)
+
+	for _, k := range keys {
+		kid := fmt.Sprintf("%x", k.KID)
+		problem := func(format string, args ...any) {
+			ret = append(ret, Problem{Check: "key", Subject: kid, Message: fmt.Sprintf(format, args...)})
+		}
+
+		switch {
+		case len(k.KID) == 0:
+			problem("blank KID")
+		case len(k.Key) != sha256.Size:
+			problem("key %q is %d bytes, want %d", k.Name, len(k.Key), sha256.Size)
+		case bytes.Count(k.Key, []byte{0}) == len(k.Key):
+			problem("key %q is all zeroes", k.Name)
+		}
+
+		if id := kid + "\x00" + k.Name; names[id] {
+			problem("duplicate key name %q", k.Name)
+		} else {
+			names[id] = true
+		}
+
+		if other, ok := kids[string(k.Key)]; ok && other != kid {
+			problem("key %q is also used for KID %s", k.Name, other)
+		} else {
+			kids[string(k.Key)] = kid
+		}
+	}
+
+	return ret
+}
+
+func checkupTrusted3Ps(cfg VerifierConfig) []Problem {
+	locs := make([]string, 0, len(cfg.Trusted3Ps))
+	for loc := range cfg.Trusted3Ps {
+		locs = append(locs, loc)
+	}
+	sort.Strings(locs)
+
+	var ret []Problem
+	for _, loc := range locs {
+		problem := func(format string, args ...any) {
+			ret = append(ret, Problem{Check: "third party", Subject: loc, Message: fmt.Sprintf(format, args...)})
+		}
+
+		if norm := NormalizeLocation(loc); norm != loc {
+			problem("location isn't normalized (want %s), so discharges won't match it", norm)
+		}
+
+		keys := cfg.Trusted3Ps[loc]
+		if len(keys) == 0 {
+			problem("no keys")
+		}
+		for i, k := range keys {
+			if ka, ok := k.(EncryptionKey); ok && len(ka) != EncryptionKeySize {
+				problem("key %d is %d bytes, want %d", i, len(ka), EncryptionKeySize)
+			}
+		}
+
+		if cfg.Reachable != nil {
+			if err := cfg.Reachable(loc); err != nil {
+				problem("unreachable: %s", err)
+			}
+		}
+	}
+
+	return ret
+}
+
+func checkupClock(cfg VerifierConfig) []Problem {
+	now := time.Now()
+	if now.Before(earliestSaneTime) {
+		return []Problem{{Check: "clock", Message: fmt.Sprintf("clock reads %s, so it probably isn't set", now.UTC().Format(time.RFC3339))}}
+	}
+
+	if cfg.ReferenceTime == nil {
+		return nil
+	}
+
+	ref, err := cfg.ReferenceTime()
+	if err != nil {
+		return []Problem{{Check: "clock", Message: fmt.Sprintf("reference time unavailable: %s", err)}}
+	}
+
+	maxSkew := cfg.MaxClockSkew
+	if maxSkew <= 0 {
+		maxSkew = DefaultMaxClockSkew
+	}
+
+	if skew := now.Sub(ref); skew > maxSkew || -skew > maxSkew {
+		return []Problem{{Check: "clock", Message: fmt.Sprintf("clock is %s off the reference time, more than %s", skew.Round(time.Second), maxSkew)}}
+	}
+
+	return nil
+}
+
+// HTTPReachable returns a [VerifierConfig.Reachable] function checking that
+// third-party locations respond to HTTP HEAD requests made with client (or,
+// if it's nil, a client with a 5 second timeout). Any response counts, since
+// locations needn't serve anything in particular.
+func HTTPReachable(client *http.Client) func(loc string) error {
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+
+	return func(loc string) error {
+		resp, err := client.Head(loc)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+}
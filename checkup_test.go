@@ -0,0 +1,72 @@
+package macaroon
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestCheckup(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	kr := NewKeyring()
+	assert.NoError(t, kr.Add([]byte{1}, "2024", NewSigningKey()))
+	assert.NoError(t, kr.Add([]byte{2}, "2024", NewSigningKey()))
+	kr.Trust3P(srv.URL, NewEncryptionKey())
+
+	cfg := kr.VerifierConfig()
+	cfg.Reachable = HTTPReachable(nil)
+	cfg.ReferenceTime = func() (time.Time, error) { return time.Now().Add(time.Second), nil }
+	assert.Zero(t, Checkup(cfg))
+
+	key := NewSigningKey()
+	cfg = VerifierConfig{
+		Keys: []KeyConfig{
+			{KID: []byte{1}, Name: "a", Key: key},
+			{KID: []byte{1}, Name: "a", Key: NewSigningKey()},
+			{KID: []byte{2}, Name: "b", Key: key},
+			{KID: []byte{3}, Name: "short", Key: key[:16]},
+			{KID: []byte{4}, Name: "zero", Key: make(SigningKey, 32)},
+			{Name: "nokid", Key: NewSigningKey()},
+		},
+		Trusted3Ps: Trusted3Ps{
+			"https://auth.example/": {NewEncryptionKey()},
+			"https://nokeys":        nil,
+			"https://short":         {NewEncryptionKey()[:8]},
+		},
+		Reachable: func(loc string) error {
+			if loc == "https://nokeys" {
+				return nil
+			}
+			return errors.New("connection refused")
+		},
+		ReferenceTime: func() (time.Time, error) { return time.Now().Add(-time.Hour), nil },
+	}
+
+	var problems []string
+	for _, p := range Checkup(cfg) {
+		problems = append(problems, p.String())
+	}
+	assert.Equal(t, []string{
+		"key 01: duplicate key name \"a\"",
+		"key 02: key \"b\" is also used for KID 01",
+		"key 03: key \"short\" is 16 bytes, want 32",
+		"key 04: key \"zero\" is all zeroes",
+		"key: blank KID",
+		"third party https://auth.example/: location isn't normalized (want https://auth.example), so discharges won't match it",
+		"third party https://auth.example/: unreachable: connection refused",
+		"third party https://nokeys: no keys",
+		"third party https://short: key 0 is 8 bytes, want 32",
+		"third party https://short: unreachable: connection refused",
+		"clock: clock is 1h0m0s off the reference time, more than 1m0s",
+	}, problems)
+
+	assert.Equal(t, []Problem{{Check: "keys", Message: "no signing keys"}}, Checkup(VerifierConfig{}))
+}
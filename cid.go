@@ -2,8 +2,10 @@ package macaroon
 
 import (
 	"fmt"
+	"time"
 
 	msgpack "github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
 )
 
 // wireCID is the magic blob callers pass to 3rd-party services to obtain discharge
@@ -16,6 +18,119 @@ import (
 type wireCID struct {
 	RN      []byte
 	Caveats CaveatSet
+
+	// optional; see TicketInfo
+	Expiry     int64
+	IssuerHint string
+}
+
+// TicketInfo is optional metadata carried in a third-party caveat's ticket
+// (CID) alongside its caveats. See [Macaroon.Add3PTicket].
+//
+// Tickets carrying metadata use a versioned encoding, which third parties
+// using versions of this package that predate it can't discharge.
+type TicketInfo struct {
+	// Expiry, if non-zero, is when the ticket expires. [DischargeCID]
+	// refuses to discharge expired tickets, so captured tickets can't be
+	// discharged long after the token was issued.
+	Expiry time.Time
+
+	// IssuerHint is a free-form description of the ticket's issuer, e.g. for
+	// the third party's logs. It's only as trustworthy as the key the ticket
+	// was sealed with.
+	IssuerHint string
+}
+
+func (c *wireCID) info() TicketInfo {
+	var ret TicketInfo
+	if c.Expiry != 0 {
+		ret.Expiry = time.Unix(c.Expiry, 0)
+	}
+	ret.IssuerHint = c.IssuerHint
+	return ret
+}
+
+// CID payload versions. Version 0 is the original, unversioned format: a
+// two-element array of RN and Caveats. Later versions are arrays whose first
+// element is the version, followed by RN, Caveats and the version's
+// additional fields.
+//
+// Optional fields can be appended to the current version: decoders skip
+// trailing fields they don't recognize, so third parties running older code
+// can still discharge the tickets, ignoring the new fields. Fields that third
+// parties must understand (e.g. required caveats) need a new version, which
+// older decoders reject rather than misinterpret.
+const (
+	cidV0 = iota
+	cidV1 // adds Expiry and IssuerHint
+
+	cidVersion = cidV1
+)
+
+// EncodeMsgpack implements [msgpack.CustomEncoder]. Tickets only using
+// version 0 fields are encoded in that format, so they can still be
+// discharged by third parties predating versioning.
+func (c *wireCID) EncodeMsgpack(e *msgpack.Encoder) error {
+	if c.Expiry == 0 && c.IssuerHint == "" {
+		return e.Encode([]any{c.RN, &c.Caveats})
+	}
+
+	return e.Encode([]any{cidVersion, c.RN, &c.Caveats, c.Expiry, c.IssuerHint})
+}
+
+// DecodeMsgpack implements [msgpack.CustomDecoder]
+func (c *wireCID) DecodeMsgpack(d *msgpack.Decoder) error {
+	nFields, err := d.DecodeArrayLen()
+	if err != nil {
+		return err
+	}
+
+	*c = wireCID{}
+
+	code, err := d.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	// version 0 starts with RN, rather than a version number
+	if msgpcode.IsBin(code) {
+		if nFields != 2 {
+			return fmt.Errorf("unknown CID format: %d fields", nFields)
+		}
+		return d.DecodeMulti(&c.RN, &c.Caveats)
+	}
+
+	version, err := d.DecodeUint()
+	if err != nil {
+		return fmt.Errorf("CID version: %w", err)
+	}
+
+	switch {
+	case version == cidV0 || version > cidVersion:
+		return fmt.Errorf("unsupported CID version %d", version)
+	case nFields < 3:
+		return fmt.Errorf("unknown CID format: %d fields", nFields)
+	}
+
+	if err := d.DecodeMulti(&c.RN, &c.Caveats); err != nil {
+		return err
+	}
+
+	optional := []any{&c.Expiry, &c.IssuerHint}
+	for i := 3; i < nFields; i++ {
+		if i-3 >= len(optional) {
+			if err := d.Skip(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := d.Decode(optional[i-3]); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // Checks the macaroon for a third party caveat for the specified location.
@@ -29,23 +144,136 @@ func ThirdPartyCID(encodedMacaroon []byte, thirdPartyLocation string) ([]byte, e
 	return m.ThirdPartyCID(thirdPartyLocation)
 }
 
-// Decyrpts the CID from the 3p caveat and prepares a discharge token. Returned
+// Decrypts the CID from the 3p caveat and prepares a discharge token. Returned
 // caveats, if any, must be validated before issuing the discharge token to the
-// user.
-func DischargeCID(ka EncryptionKey, location string, cid []byte) ([]Caveat, *Macaroon, error) {
-	return dischargeCID(ka, location, cid, true)
+// user. Tickets that can't be discharged are refused with a [*TicketError];
+// expired tickets with one wrapping [ErrTicketExpired].
+func DischargeCID(ka EncryptionKey, location string, cid []byte, opts ...DischargeOption) ([]Caveat, *Macaroon, error) {
+	return dischargeCID(ka, location, cid, true, opts...)
+}
+
+// DischargeCIDWithSealer is like [DischargeCID], but unseals the CID with an
+// arbitrary [Sealer]. See [Macaroon.Add3PWithSealer].
+func DischargeCIDWithSealer(s Sealer, location string, cid []byte, opts ...DischargeOption) ([]Caveat, *Macaroon, error) {
+	return dischargeCID(s, location, cid, true, opts...)
+}
+
+// DischargeTicket is like [DischargeCIDWithSealer], but also returns the
+// ticket's metadata.
+func DischargeTicket(s Sealer, location string, cid []byte, opts ...DischargeOption) (TicketInfo, []Caveat, *Macaroon, error) {
+	tcid, dm, err := dischargeTicket(s, location, cid, true, opts...)
+	if err != nil {
+		return TicketInfo{}, nil, nil, err
+	}
+
+	return tcid.info(), tcid.Caveats.Caveats, dm, nil
+}
+
+// TicketFailure is the reason a ticket couldn't be discharged.
+type TicketFailure int
+
+const (
+	// TicketUnsealFailed means the ticket couldn't be unsealed: it was
+	// sealed with another key, corrupted or forged. Large numbers of these
+	// from a caller suggest probing.
+	TicketUnsealFailed TicketFailure = iota + 1
+
+	// TicketMalformed means the ticket was unsealed, but couldn't be
+	// decoded, e.g. because it was minted by a newer version of this
+	// package.
+	TicketMalformed
+
+	// TicketExpired means the ticket's expiry has passed. See [TicketInfo].
+	TicketExpired
+)
+
+// TicketError is returned when a ticket can't be discharged. Third-party
+// services can use it to count and alert on invalid tickets, e.g. per caller.
+// See also [OnTicketFailure].
+type TicketError struct {
+	Location string
+	Reason   TicketFailure
+	Err      error
+}
+
+func (e *TicketError) Error() string {
+	switch e.Reason {
+	case TicketUnsealFailed:
+		return fmt.Sprintf("recover for discharge: CID decrypt: %s", e.Err)
+	case TicketMalformed:
+		return fmt.Sprintf("recover for discharge: CID decode: %s", e.Err)
+	default:
+		return fmt.Sprintf("recover for discharge: %s", e.Err)
+	}
+}
+
+func (e *TicketError) Unwrap() error {
+	return e.Err
+}
+
+// DischargeOption configures [DischargeCID].
+type DischargeOption func(*dischargeOptions)
+
+type dischargeOptions struct {
+	onFailure func(*TicketError)
+	now       time.Time
+}
+
+// OnTicketFailure calls hook with the error whenever a ticket can't be
+// discharged, before DischargeCID returns it. This lets third-party services
+// instrument every discharge path in one place, with the hook closing over
+// the caller's identity for per-caller rate limiting and alerting.
+func OnTicketFailure(hook func(*TicketError)) DischargeOption {
+	return func(o *dischargeOptions) { o.onFailure = hook }
+}
+
+// WithDischargeNow pins the time used to check ticket expiry, overriding the
+// current time. This is mostly useful for deterministic tests.
+func WithDischargeNow(t time.Time) DischargeOption {
+	return func(o *dischargeOptions) { o.now = t }
 }
 
 // discharge macaroons will be proofs moving forward, but we need to be able to test the old non-proof dms too
-func dischargeCID(ka EncryptionKey, location string, cid []byte, issueProof bool) ([]Caveat, *Macaroon, error) {
-	cidr, err := unseal(ka, cid)
+func dischargeCID(s Sealer, location string, cid []byte, issueProof bool, opts ...DischargeOption) ([]Caveat, *Macaroon, error) {
+	tcid, dm, err := dischargeTicket(s, location, cid, issueProof, opts...)
 	if err != nil {
-		return nil, nil, fmt.Errorf("recover for discharge: CID decrypt: %w", err)
+		return nil, nil, err
+	}
+
+	return tcid.Caveats.Caveats, dm, nil
+}
+
+func dischargeTicket(s Sealer, location string, cid []byte, issueProof bool, opts ...DischargeOption) (*wireCID, *Macaroon, error) {
+	o := new(dischargeOptions)
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	fail := func(reason TicketFailure, err error) (*wireCID, *Macaroon, error) {
+		terr := &TicketError{Location: location, Reason: reason, Err: err}
+		if o.onFailure != nil {
+			o.onFailure(terr)
+		}
+		return nil, nil, terr
+	}
+
+	cidr, err := s.Unseal(cid)
+	if err != nil {
+		return fail(TicketUnsealFailed, err)
 	}
 
 	tcid := &wireCID{}
 	if err = msgpack.Unmarshal(cidr, tcid); err != nil {
-		return nil, nil, fmt.Errorf("recover for discharge: CID decode: %w", err)
+		return fail(TicketMalformed, err)
+	}
+
+	now := o.now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	if tcid.Expiry != 0 && !now.Before(time.Unix(tcid.Expiry, 0)) {
+		return fail(TicketExpired, fmt.Errorf("%w at %s", ErrTicketExpired, time.Unix(tcid.Expiry, 0).UTC().Format(time.RFC3339)))
 	}
 
 	dm, err := newMacaroon(cid, location, tcid.RN, issueProof)
@@ -53,5 +281,5 @@ func dischargeCID(ka EncryptionKey, location string, cid []byte, issueProof bool
 		return nil, nil, err
 	}
 
-	return tcid.Caveats.Caveats, dm, nil
+	return tcid, dm, nil
 }
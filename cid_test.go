@@ -0,0 +1,159 @@
+package macaroon
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	msgpack "github.com/vmihailenco/msgpack/v5"
+)
+
+func TestTicketInfo(t *testing.T) {
+	var (
+		key = NewSigningKey()
+		ka  = NewEncryptionKey()
+	)
+
+	ticket := func(t *testing.T, info TicketInfo) []byte {
+		t.Helper()
+
+		m, err := New([]byte("kid"), "https://api", key)
+		assert.NoError(t, err)
+		assert.NoError(t, m.Add3PTicket(ka, "https://auth", info, cavParent(ActionRead, 123)))
+		cid, err := m.ThirdPartyCID("https://auth")
+		assert.NoError(t, err)
+		return cid
+	}
+
+	t.Run("metadata", func(t *testing.T) {
+		expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+
+		info, cavs, dm, err := DischargeTicket(ka, "https://auth", ticket(t, TicketInfo{Expiry: expiry, IssuerHint: "api"}))
+		assert.NoError(t, err)
+		assert.True(t, info.Expiry.Equal(expiry))
+		assert.Equal(t, "api", info.IssuerHint)
+		assert.Equal(t, []Caveat{cavParent(ActionRead, 123)}, cavs)
+		assert.NotZero(t, dm)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		_, _, err := DischargeCID(ka, "https://auth", ticket(t, TicketInfo{Expiry: time.Now().Add(-time.Second)}))
+		assert.True(t, errors.Is(err, ErrTicketExpired))
+
+		cid := ticket(t, TicketInfo{Expiry: time.Now().Add(time.Hour)})
+		_, _, err = DischargeCID(ka, "https://auth", cid, WithDischargeNow(time.Now().Add(2*time.Hour)))
+		assert.True(t, errors.Is(err, ErrTicketExpired))
+		_, _, err = DischargeCID(ka, "https://auth", cid, WithDischargeNow(time.Now()))
+		assert.NoError(t, err)
+	})
+
+	t.Run("no metadata", func(t *testing.T) {
+		cid := ticket(t, TicketInfo{})
+
+		// tickets without metadata use the original encoding
+		buf, err := ka.Unseal(cid)
+		assert.NoError(t, err)
+		n, err := msgpack.NewDecoder(bytes.NewReader(buf)).DecodeArrayLen()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		info, cavs, _, err := DischargeTicket(ka, "https://auth", cid)
+		assert.NoError(t, err)
+		assert.Equal(t, TicketInfo{}, info)
+		assert.Equal(t, 1, len(cavs))
+	})
+}
+
+func TestCIDVersioning(t *testing.T) {
+	var (
+		ka  = NewEncryptionKey()
+		rn  = NewSigningKey()
+		cs  = NewCaveatSet(cavParent(ActionRead, 123))
+		exp = time.Now().Add(time.Hour).Unix()
+	)
+
+	discharge := func(t *testing.T, fields ...any) (TicketInfo, error) {
+		t.Helper()
+
+		buf, err := encode(fields)
+		assert.NoError(t, err)
+		cid, err := ka.Seal(buf)
+		assert.NoError(t, err)
+
+		info, cavs, _, err := DischargeTicket(ka, "https://auth", cid)
+		if err == nil {
+			assert.Equal(t, cs.Caveats, cavs)
+		}
+		return info, err
+	}
+
+	// unversioned
+	info, err := discharge(t, rn, cs)
+	assert.NoError(t, err)
+	assert.Equal(t, TicketInfo{}, info)
+
+	// current version
+	info, err = discharge(t, cidV1, rn, cs, exp, "api")
+	assert.NoError(t, err)
+	assert.Equal(t, TicketInfo{Expiry: time.Unix(exp, 0), IssuerHint: "api"}, info)
+
+	// optional fields may be omitted...
+	info, err = discharge(t, cidV1, rn, cs)
+	assert.NoError(t, err)
+	assert.Equal(t, TicketInfo{}, info)
+
+	// ...and unknown ones are skipped
+	info, err = discharge(t, cidV1, rn, cs, exp, "api", map[string]any{"future": true})
+	assert.NoError(t, err)
+	assert.Equal(t, "api", info.IssuerHint)
+
+	_, err = discharge(t, cidVersion+1, rn, cs)
+	assert.EqualError(t, err, "recover for discharge: CID decode: unsupported CID version 2")
+
+	_, err = discharge(t, cidV1, rn)
+	assert.EqualError(t, err, "recover for discharge: CID decode: unknown CID format: 2 fields")
+}
+
+func TestOnTicketFailure(t *testing.T) {
+	var (
+		key = NewSigningKey()
+		ka  = NewEncryptionKey()
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add3P(ka, "https://auth"))
+	assert.NoError(t, m.Add3PTicket(ka, "https://expired", TicketInfo{Expiry: time.Now().Add(-time.Minute)}))
+
+	cid, err := m.ThirdPartyCID("https://auth")
+	assert.NoError(t, err)
+	expired, err := m.ThirdPartyCID("https://expired")
+	assert.NoError(t, err)
+	malformed, err := ka.Seal([]byte("garbage"))
+	assert.NoError(t, err)
+
+	failures := map[string][]TicketFailure{}
+	discharge := func(caller string, ka EncryptionKey, cid []byte) error {
+		_, _, err := DischargeCID(ka, "https://auth", cid, OnTicketFailure(func(err *TicketError) {
+			failures[caller] = append(failures[caller], err.Reason)
+		}))
+		return err
+	}
+
+	assert.NoError(t, discharge("alice", ka, cid))
+	assert.Error(t, discharge("mallory", NewEncryptionKey(), cid))
+	assert.Error(t, discharge("mallory", ka, malformed))
+	err = discharge("bob", ka, expired)
+
+	var terr *TicketError
+	assert.True(t, errors.As(err, &terr))
+	assert.Equal(t, "https://auth", terr.Location)
+	assert.True(t, errors.Is(err, ErrTicketExpired))
+
+	assert.Equal(t, map[string][]TicketFailure{
+		"mallory": {TicketUnsealFailed, TicketMalformed},
+		"bob":     {TicketExpired},
+	}, failures)
+}
@@ -0,0 +1,55 @@
+//go:build js && wasm
+
+// Command macaroon-wasm exports token attenuation to JavaScript. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o macaroon.wasm ./cmd/macaroon-wasm
+//
+// and load it with the wasm_exec.js shipped with Go. It defines a global
+// macaroon object with these functions, each returning {result} on success
+// or {error} on failure:
+//
+//	macaroon.attenuate(header, location, caveatsJSON)
+//	macaroon.inspect(header, location)
+//
+// See the internal/attenuation package for details. The flyio caveat types
+// are registered in addition to the core ones.
+package main
+
+import (
+	"syscall/js"
+
+	_ "github.com/superfly/macaroon/flyio"
+	"github.com/superfly/macaroon/internal/attenuation"
+)
+
+func main() {
+	js.Global().Set("macaroon", js.ValueOf(map[string]any{
+		"attenuate": export(3, func(args []js.Value) (string, error) {
+			return attenuation.Attenuate(args[0].String(), args[1].String(), args[2].String())
+		}),
+		"inspect": export(2, func(args []js.Value) (string, error) {
+			return attenuation.Inspect(args[0].String(), args[1].String())
+		}),
+	}))
+
+	select {}
+}
+
+func export(nArgs int, fn func(args []js.Value) (string, error)) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		if len(args) != nArgs {
+			return map[string]any{"error": "wrong number of arguments"}
+		}
+		for _, arg := range args {
+			if arg.Type() != js.TypeString {
+				return map[string]any{"error": "arguments must be strings"}
+			}
+		}
+
+		ret, err := fn(args)
+		if err != nil {
+			return map[string]any{"error": err.Error()}
+		}
+		return map[string]any{"result": ret}
+	})
+}
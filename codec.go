@@ -0,0 +1,71 @@
+package macaroon
+
+import (
+	"fmt"
+
+	msgpack "github.com/vmihailenco/msgpack/v5"
+)
+
+// WireCodec is implemented by caveats that encode their own bodies, rather
+// than relying on msgpack's struct encoding. This is useful for very hot or
+// very large caveats (e.g. bloom-filter resource sets) that benefit from a compact or
+// zero-copy representation. The encoded body is stored in the caveat set as
+// a msgpack byte string, so it remains opaque to code that doesn't know the
+// caveat type. JSON encoding is unaffected.
+//
+// The encoding is covered by the token's signature, so it must be stable:
+// decoding and re-encoding a caveat must produce the same bytes.
+type WireCodec interface {
+	MarshalWire() ([]byte, error)
+	UnmarshalWire([]byte) error
+}
+
+func encodeCaveatBody(enc *msgpack.Encoder, cav Caveat) error {
+	wc, ok := cav.(WireCodec)
+	if !ok {
+		return enc.Encode(cav)
+	}
+
+	body, err := wc.MarshalWire()
+	if err != nil {
+		return fmt.Errorf("encode caveat type %d: %w", cav.CaveatType(), err)
+	}
+
+	return enc.EncodeBytes(body)
+}
+
+func decodeCaveatBody(dec *msgpack.Decoder, cav Caveat) error {
+	wc, ok := cav.(WireCodec)
+	if !ok {
+		return dec.Decode(cav)
+	}
+
+	body, err := dec.DecodeBytes()
+	if err != nil {
+		return err
+	}
+
+	if err := wc.UnmarshalWire(body); err != nil {
+		return fmt.Errorf("decode caveat type %d: %w", cav.CaveatType(), err)
+	}
+
+	return nil
+}
+
+func unmarshalCaveatBody(body []byte, cav Caveat) error {
+	wc, ok := cav.(WireCodec)
+	if !ok {
+		return msgpack.Unmarshal(body, cav)
+	}
+
+	var wire []byte
+	if err := msgpack.Unmarshal(body, &wire); err != nil {
+		return err
+	}
+
+	if err := wc.UnmarshalWire(wire); err != nil {
+		return fmt.Errorf("decode caveat type %d: %w", cav.CaveatType(), err)
+	}
+
+	return nil
+}
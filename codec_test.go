@@ -0,0 +1,76 @@
+package macaroon
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	msgpack "github.com/vmihailenco/msgpack/v5"
+)
+
+// testCaveatWire encodes its IDs as packed little-endian uint32s
+type testCaveatWire struct {
+	IDs []uint32
+}
+
+func init() { RegisterCaveatType("Wire", cavTestWire, &testCaveatWire{}) }
+
+func (c *testCaveatWire) CaveatType() CaveatType   { return cavTestWire }
+func (c *testCaveatWire) IsAttestation() bool      { return false }
+func (c *testCaveatWire) Prohibits(f Access) error { return nil }
+
+func (c *testCaveatWire) MarshalWire() ([]byte, error) {
+	buf := make([]byte, 4*len(c.IDs))
+	for i, id := range c.IDs {
+		binary.LittleEndian.PutUint32(buf[4*i:], id)
+	}
+	return buf, nil
+}
+
+func (c *testCaveatWire) UnmarshalWire(buf []byte) error {
+	if len(buf)%4 != 0 {
+		return errors.New("bad length")
+	}
+	c.IDs = make([]uint32, len(buf)/4)
+	for i := range c.IDs {
+		c.IDs[i] = binary.LittleEndian.Uint32(buf[4*i:])
+	}
+	return nil
+}
+
+func TestWireCodec(t *testing.T) {
+	key := NewSigningKey()
+	cav := &testCaveatWire{IDs: []uint32{1, 2, 0xdeadbeef}}
+
+	buf, err := NewCaveatSet(cav).MarshalMsgpack()
+	assert.NoError(t, err)
+
+	var raw []any
+	assert.NoError(t, msgpack.Unmarshal(buf, &raw))
+	assert.Equal(t, 2, len(raw))
+	assert.Equal(t, []byte{1, 0, 0, 0, 2, 0, 0, 0, 0xef, 0xbe, 0xad, 0xde}, raw[1].([]byte))
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavParent(ActionRead, 1), cav))
+	tok, err := m.Encode()
+	assert.NoError(t, err)
+
+	decoded, err := Decode(tok)
+	assert.NoError(t, err)
+	cs, err := decoded.Verify(key, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []Caveat{cavParent(ActionRead, 1), cav}, cs.Caveats)
+
+	// JSON encoding is unaffected
+	jbuf, err := NewCaveatSet(cav).MarshalJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"type":"Wire","body":{"IDs":[1,2,3735928559]}}]`, string(jbuf))
+
+	// malformed bodies are rejected
+	bad, err := msgpack.Marshal([]any{uint64(cavTestWire), []byte{1, 2, 3}})
+	assert.NoError(t, err)
+	_, err = DecodeCaveats(bad)
+	assert.Error(t, err)
+}
@@ -0,0 +1,25 @@
+package macaroon
+
+// DefaultCaveatCost is the evaluation cost of caveats that don't implement
+// [CostlyCaveat].
+const DefaultCaveatCost = 1
+
+// CostlyCaveat is implemented by caveats whose evaluation cost differs from
+// DefaultCaveatCost, e.g. because they evaluate an expression or check a
+// large set of resources. Costs are relative units, enforced by
+// [WithBudget]. A caveat's cost shouldn't include the cost of evaluating
+// caveats nested within it; those are charged separately as they're
+// evaluated.
+type CostlyCaveat interface {
+	Caveat
+
+	EvaluationCost() int
+}
+
+// CaveatCost returns the cost of evaluating the caveat.
+func CaveatCost(c Caveat) int {
+	if cc, ok := c.(CostlyCaveat); ok {
+		return cc.EvaluationCost()
+	}
+	return DefaultCaveatCost
+}
@@ -0,0 +1,39 @@
+package macaroon
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestBudget(t *testing.T) {
+	var (
+		access = &testAccess{action: ActionRead, parentResource: ptr(uint64(1))}
+		cs     = NewCaveatSet(cavParent(ActionAll, 1), cavParent(ActionAll, 1), cavParent(ActionAll, 1))
+	)
+
+	assert.NoError(t, NewValidator(WithBudget(3)).Validate(cs, access))
+	assert.True(t, errors.Is(NewValidator(WithBudget(2)).Validate(cs, access), ErrBudgetExceeded))
+
+	// budget is shared across accesses
+	assert.NoError(t, NewValidator(WithBudget(6)).Validate(cs, access, access))
+	assert.True(t, errors.Is(NewValidator(WithBudget(5)).Validate(cs, access, access), ErrBudgetExceeded))
+	assert.True(t, errors.Is(NewValidator(WithBudget(5)).ValidateAll(cs, access, access), ErrBudgetExceeded))
+
+	// nested caveats are charged as they're evaluated
+	cs = NewCaveatSet(&IfPresent{Ifs: NewCaveatSet(cavParent(ActionAll, 1), cavChild(ActionAll, 2))})
+	assert.Equal(t, DefaultCaveatCost, CaveatCost(cs.Caveats[0]))
+	assert.NoError(t, NewValidator(WithBudget(3)).Validate(cs, access))
+	assert.True(t, errors.Is(NewValidator(WithBudget(2)).Validate(cs, access), ErrBudgetExceeded))
+
+	// predicates declare their cost
+	cs = NewCaveatSet(&Predicate{"time < 3000-01-01"})
+	assert.Equal(t, DefaultPredicateCost, CaveatCost(cs.Caveats[0]))
+	assert.Equal(t, DefaultCaveatCost, CaveatCost(&Predicate{"bogus"}))
+	assert.NoError(t, NewValidator(WithBudget(DefaultPredicateCost)).Validate(cs, access))
+	assert.True(t, errors.Is(NewValidator(WithBudget(DefaultPredicateCost-1)).Validate(cs, access), ErrBudgetExceeded))
+
+	// unlimited by default
+	assert.NoError(t, NewValidator().Validate(cs, access))
+}
@@ -0,0 +1,199 @@
+package macaroon
+
+import "fmt"
+
+// Catalog enumerates the resources and actions a caveat set is analyzed
+// against by [Catalog.Denied]. It's meant for security review tooling that
+// needs to reason about what a token does not allow, rather than checking a
+// single request.
+type Catalog struct {
+	// Resources are the resources to analyze, in a form meaningful to
+	// NewAccess (e.g. "app:123").
+	Resources []string
+
+	// Actions are the actions to analyze. Defaults to each of the individual
+	// actions in ActionAll.
+	Actions []Action
+
+	// NewAccess builds an Access describing the action being taken on the
+	// resource.
+	NewAccess func(resource string, action Action) (Access, error)
+
+	// Implied, if set, returns resources referenced by a caveat set's caveats
+	// (e.g. the apps named in an Apps caveat). They're analyzed by
+	// [Catalog.Matrix] in addition to Resources.
+	Implied func(cs *CaveatSet) []string
+}
+
+// Denial is a (resource, action) combination denied by a caveat set, along
+// with the reason it was denied.
+type Denial struct {
+	Resource string
+	Action   Action
+	Err      error
+}
+
+func (d Denial) String() string {
+	return fmt.Sprintf("%s %s: %s", d.Action, d.Resource, d.Err)
+}
+
+var individualActions = []Action{ActionRead, ActionWrite, ActionCreate, ActionDelete, ActionControl}
+
+// Denied reports each (resource, action) combination in the catalog that the
+// caveat set doesn't allow, in catalog order. Validation options (e.g.
+// [WithNow]) apply to each evaluation.
+func (c *Catalog) Denied(cs *CaveatSet, opts ...ValidationOption) ([]Denial, error) {
+	var (
+		v       = NewValidator(opts...)
+		actions = c.Actions
+		ret     []Denial
+	)
+
+	if len(actions) == 0 {
+		actions = individualActions
+	}
+
+	for _, resource := range c.Resources {
+		for _, action := range actions {
+			access, err := c.NewAccess(resource, action)
+			if err != nil {
+				return nil, fmt.Errorf("coverage: %s %s: %w", action, resource, err)
+			}
+
+			if err := v.Validate(cs, access); err != nil {
+				ret = append(ret, Denial{Resource: resource, Action: action, Err: err})
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+// Allowed is the complement of [Catalog.Denied], reporting the actions the
+// caveat set allows on each resource in the catalog. Resources on which no
+// actions are allowed are omitted.
+func (c *Catalog) Allowed(cs *CaveatSet, opts ...ValidationOption) (map[string]Action, error) {
+	denials, err := c.Denied(cs, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := c.Actions
+	if len(actions) == 0 {
+		actions = individualActions
+	}
+
+	var all Action
+	for _, action := range actions {
+		all |= action
+	}
+
+	ret := make(map[string]Action, len(c.Resources))
+	for _, resource := range c.Resources {
+		ret[resource] = all
+	}
+
+	for _, d := range denials {
+		ret[d.Resource] = ret[d.Resource].Remove(d.Action)
+	}
+
+	for resource, allowed := range ret {
+		if allowed == ActionNone {
+			delete(ret, resource)
+		}
+	}
+
+	return ret, nil
+}
+
+// CapabilityMatrix is the result of dry-running validation of a caveat set
+// against every (resource, action) combination in a [Catalog]. See
+// [Catalog.Matrix].
+type CapabilityMatrix struct {
+	// Actions are the actions that were analyzed, in column order.
+	Actions []Action
+
+	// Rows are the analyzed resources, in catalog order followed by any
+	// implied resources in the order they were returned.
+	Rows []CapabilityRow
+}
+
+// CapabilityRow is the capabilities a caveat set grants on a resource.
+type CapabilityRow struct {
+	Resource string
+
+	// Allowed is the union of the allowed actions.
+	Allowed Action
+
+	// Denials are the reasons each other action was denied.
+	Denials []Denial
+}
+
+// Allows reports whether all of the specified actions are allowed on the
+// resource. Resources
+// that weren't analyzed are never allowed.
+func (m *CapabilityMatrix) Allows(resource string, action Action) bool {
+	for _, row := range m.Rows {
+		if row.Resource == resource {
+			return action.IsSubsetOf(row.Allowed)
+		}
+	}
+	return false
+}
+
+// Matrix generates a hypothetical Access for each resource (including those
+// implied by the caveat set, see Catalog.Implied) and action, and dry-runs
+// validation of the caveat set against each of them. It's meant for tooling
+// that describes what a token can do, so the caveat set should be one
+// returned by [Macaroon.Verify]. Validation options (e.g. [WithNow]) apply to
+// each evaluation.
+func (c *Catalog) Matrix(cs *CaveatSet, opts ...ValidationOption) (*CapabilityMatrix, error) {
+	resources := c.Resources
+	if c.Implied != nil {
+		seen := make(map[string]bool, len(resources))
+		for _, r := range resources {
+			seen[r] = true
+		}
+
+		resources = append([]string(nil), resources...)
+		for _, r := range c.Implied(cs) {
+			if !seen[r] {
+				seen[r] = true
+				resources = append(resources, r)
+			}
+		}
+	}
+
+	expanded := *c
+	expanded.Resources = resources
+
+	denials, err := expanded.Denied(cs, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &CapabilityMatrix{Actions: c.Actions}
+	if len(m.Actions) == 0 {
+		m.Actions = individualActions
+	}
+
+	var all Action
+	for _, action := range m.Actions {
+		all |= action
+	}
+
+	rows := make(map[string]*CapabilityRow, len(resources))
+	m.Rows = make([]CapabilityRow, len(resources))
+	for i, r := range resources {
+		m.Rows[i] = CapabilityRow{Resource: r, Allowed: all}
+		rows[r] = &m.Rows[i]
+	}
+
+	for _, d := range denials {
+		row := rows[d.Resource]
+		row.Allowed = row.Allowed.Remove(d.Action)
+		row.Denials = append(row.Denials, d)
+	}
+
+	return m, nil
+}
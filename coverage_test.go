@@ -0,0 +1,83 @@
+package macaroon
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestCatalog(t *testing.T) {
+	c := &Catalog{
+		Resources: []string{"parent:1", "parent:2"},
+		NewAccess: func(resource string, action Action) (Access, error) {
+			var id uint64
+			if _, err := fmt.Sscanf(resource, "parent:%d", &id); err != nil {
+				return nil, err
+			}
+			return &testAccess{action: action, parentResource: &id}, nil
+		},
+	}
+
+	cs := NewCaveatSet(cavParent(ActionRead|ActionWrite, 1))
+
+	denied, err := c.Denied(cs)
+	assert.NoError(t, err)
+	assert.Equal(t, 8, len(denied))
+	assert.Equal(t, "parent:1", denied[0].Resource)
+	assert.Equal(t, ActionCreate, denied[0].Action)
+	assert.True(t, errors.Is(denied[0].Err, ErrUnauthorizedForAction))
+	assert.Equal(t, "parent:2", denied[3].Resource)
+	assert.Equal(t, ActionRead, denied[3].Action)
+	assert.True(t, errors.Is(denied[3].Err, ErrUnauthorizedForResource))
+
+	allowed, err := c.Allowed(cs)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]Action{"parent:1": ActionRead | ActionWrite}, allowed)
+
+	c.Resources = append(c.Resources, "bogus")
+	_, err = c.Denied(cs)
+	assert.Error(t, err)
+}
+
+func TestCatalogMatrix(t *testing.T) {
+	c := &Catalog{
+		Resources: []string{"parent:1"},
+		Actions:   []Action{ActionRead, ActionWrite},
+		NewAccess: func(resource string, action Action) (Access, error) {
+			var id uint64
+			if _, err := fmt.Sscanf(resource, "parent:%d", &id); err != nil {
+				return nil, err
+			}
+			return &testAccess{action: action, parentResource: &id}, nil
+		},
+		Implied: func(cs *CaveatSet) []string {
+			var ret []string
+			for _, cav := range GetCaveats[*testCaveatParentResource](cs) {
+				ret = append(ret, fmt.Sprintf("parent:%d", cav.ID))
+			}
+			return ret
+		},
+	}
+
+	cs := NewCaveatSet(cavParent(ActionRead, 2))
+
+	m, err := c.Matrix(cs)
+	assert.NoError(t, err)
+	assert.Equal(t, []Action{ActionRead, ActionWrite}, m.Actions)
+	assert.Equal(t, 2, len(m.Rows))
+
+	assert.Equal(t, "parent:1", m.Rows[0].Resource)
+	assert.Equal(t, ActionNone, m.Rows[0].Allowed)
+	assert.Equal(t, 2, len(m.Rows[0].Denials))
+
+	assert.Equal(t, "parent:2", m.Rows[1].Resource)
+	assert.Equal(t, ActionRead, m.Rows[1].Allowed)
+	assert.Equal(t, 1, len(m.Rows[1].Denials))
+	assert.Equal(t, ActionWrite, m.Rows[1].Denials[0].Action)
+
+	assert.True(t, m.Allows("parent:2", ActionRead))
+	assert.False(t, m.Allows("parent:2", ActionRead|ActionWrite))
+	assert.False(t, m.Allows("parent:3", ActionRead))
+}
@@ -3,11 +3,11 @@ package macaroon
 import (
 	"bytes"
 	"crypto/hmac"
-	"crypto/rand"
 	"crypto/sha256"
 	"fmt"
 	"log"
 
+	"github.com/superfly/macaroon/internal/rnd"
 	"golang.org/x/crypto/chacha20poly1305"
 )
 
@@ -27,6 +27,41 @@ func NewEncryptionKey() EncryptionKey {
 	return EncryptionKey(rbuf(EncryptionKeySize))
 }
 
+// Sealer encrypts and authenticates the tickets (CIDs) of third-party caveats.
+// [EncryptionKey] is the usual implementation. Other implementations allow
+// the keys shared with third-party services to live in an external key
+// management system. See [Macaroon.Add3PWithSealer] and
+// [DischargeCIDWithSealer].
+type Sealer interface {
+	Seal(plaintext []byte) ([]byte, error)
+	Unseal(ciphertext []byte) ([]byte, error)
+}
+
+var _ Sealer = EncryptionKey(nil)
+
+// IdentifiedSealer is a Sealer with a stable ID, e.g. the name of a key held
+// in a key management system. Sealers with the same ID must seal and unseal
+// with the same keys. Verifications trusting third-party Sealers are only
+// cached by a [ReadThroughVerifier] if they're EncryptionKeys or
+// IdentifiedSealers.
+type IdentifiedSealer interface {
+	Sealer
+	ID() []byte
+}
+
+// Seal implements [Sealer].
+func (k EncryptionKey) Seal(plaintext []byte) ([]byte, error) {
+	if len(k) != EncryptionKeySize {
+		return nil, fmt.Errorf("bad key size: have %d, need %d", len(k), EncryptionKeySize)
+	}
+	return seal(k, plaintext), nil
+}
+
+// Unseal implements [Sealer].
+func (k EncryptionKey) Unseal(ciphertext []byte) ([]byte, error) {
+	return unseal(k, ciphertext)
+}
+
 func seal(key EncryptionKey, buf []byte) []byte {
 	aead, err := chacha20poly1305.New([]byte(key))
 	if err != nil {
@@ -76,10 +111,5 @@ func sealNonce() []byte {
 }
 
 func rbuf(sz int) []byte {
-	buf := make([]byte, sz)
-	if n, err := rand.Read(buf); n != sz || err != nil {
-		log.Panicf("crypto random failed: %d read of %d: err: %s", n, sz, err)
-	}
-
-	return buf
+	return rnd.Bytes(sz)
 }
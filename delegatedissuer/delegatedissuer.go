@@ -0,0 +1,200 @@
+// Package delegatedissuer implements intermediate issuer tokens, which let a
+// service that holds no root key mint tokens on an issuer's behalf.
+//
+// The pattern works like this:
+//
+//   - The real issuer mints an "issuer token" with [NewIssuerToken] and hands
+//     it to the minting service. The issuer token carries a third-party caveat
+//     pointing back at the issuer's own discharge endpoint, whose ticket names
+//     the minting service.
+//
+//   - The minting service "mints" tokens by attenuating the issuer token with
+//     [Minter.Mint]. No key is needed to do this; that's just how Macaroons
+//     work.
+//
+//   - The minting service (or its clients) presents the ticket from
+//     [Minter.Ticket] to the issuer's discharge endpoint, authenticating as
+//     itself. The issuer checks the caller's identity against the ticket with
+//     [Discharge], producing a discharge token that attests to which service
+//     did the minting.
+//
+//   - Verifiers check the minted token and discharge as usual, passing the
+//     issuer's discharge location and key as a trusted third party so that
+//     the attestation is trusted, and then call [Issuer] to learn which
+//     service minted the token.
+package delegatedissuer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/superfly/macaroon"
+)
+
+// Service is a requirement placed in the ticket of an issuer token's
+// third-party caveat, naming the only service the issuer may discharge it
+// for. It has no meaning in a 1P setting.
+type Service struct {
+	Name string `json:"name"`
+}
+
+func init() {
+	macaroon.RegisterCaveatType("DelegatedIssuerService", macaroon.CavDelegatedIssuerService, &Service{})
+}
+
+func (c *Service) CaveatType() macaroon.CaveatType {
+	return macaroon.CavDelegatedIssuerService
+}
+
+func (c *Service) Prohibits(macaroon.Access) error {
+	// Service is only used in 3P caveats and has no role in access validation.
+	return fmt.Errorf("%w (delegated-issuer-service)", macaroon.ErrBadCaveat)
+}
+
+func (c *Service) IsAttestation() bool { return false }
+
+// IssuedBy is an attestation, added by the issuer to its discharge tokens,
+// stating which service minted the token being discharged.
+type IssuedBy struct {
+	Service string `json:"service"`
+}
+
+func init() {
+	macaroon.RegisterCaveatType("DelegatedIssuerAttestation", macaroon.CavDelegatedIssuerAttestation, &IssuedBy{})
+}
+
+func (c *IssuedBy) CaveatType() macaroon.CaveatType {
+	return macaroon.CavDelegatedIssuerAttestation
+}
+
+func (c *IssuedBy) Prohibits(macaroon.Access) error {
+	// attestations play no role in access validation
+	return nil
+}
+
+func (c *IssuedBy) IsAttestation() bool { return true }
+
+// NewIssuerToken is used by the issuer to mint a token for a minting service.
+// The token is restricted by the specified caveats and carries a third-party
+// caveat for issuerLocation, which is the location of the issuer's discharge
+// endpoint, keyed with ka. Only the named service will be able to get that
+// caveat discharged.
+func NewIssuerToken(kid []byte, loc string, key macaroon.SigningKey, ka macaroon.EncryptionKey, issuerLocation, service string, cavs ...macaroon.Caveat) (*macaroon.Macaroon, error) {
+	if service == "" {
+		return nil, errors.New("delegated issuer: blank service name")
+	}
+
+	m, err := macaroon.New(kid, loc, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.Add(cavs...); err != nil {
+		return nil, err
+	}
+
+	if err := m.Add3P(ka, issuerLocation, &Service{Name: service}); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Discharge is used by the issuer's discharge endpoint to discharge the
+// ticket from an issuer token. The service argument is the authenticated
+// identity of the caller; it must match the service named in the ticket.
+// The returned discharge token attests to the minting service with an
+// [IssuedBy] caveat.
+func Discharge(ka macaroon.EncryptionKey, issuerLocation string, ticket []byte, service string) (*macaroon.Macaroon, error) {
+	cavs, dm, err := macaroon.DischargeCID(ka, issuerLocation, ticket)
+	if err != nil {
+		return nil, err
+	}
+
+	var found bool
+	for _, cav := range cavs {
+		s, ok := cav.(*Service)
+		if !ok {
+			return nil, fmt.Errorf("delegated issuer: unexpected caveat in ticket: %T", cav)
+		}
+		if s.Name != service {
+			return nil, fmt.Errorf("%w: ticket for service %s, not %s", macaroon.ErrUnauthorized, s.Name, service)
+		}
+		found = true
+	}
+
+	if !found {
+		return nil, errors.New("delegated issuer: ticket doesn't name a service")
+	}
+
+	if err := dm.Add(&IssuedBy{Service: service}); err != nil {
+		return nil, err
+	}
+
+	return dm, nil
+}
+
+// Minter is used by a minting service to mint tokens from an issuer token.
+type Minter struct {
+	issuerToken    []byte
+	issuerLocation string
+}
+
+// NewMinter creates a Minter from an encoded issuer token. The issuer token
+// must carry a third-party caveat for issuerLocation.
+func NewMinter(issuerToken []byte, issuerLocation string) (*Minter, error) {
+	ticket, err := macaroon.ThirdPartyCID(issuerToken, issuerLocation)
+	switch {
+	case err != nil:
+		return nil, err
+	case len(ticket) == 0:
+		return nil, fmt.Errorf("delegated issuer: no third-party caveat for %s", issuerLocation)
+	}
+
+	return &Minter{issuerToken: issuerToken, issuerLocation: issuerLocation}, nil
+}
+
+// Ticket returns the ticket to be presented to the issuer's discharge
+// endpoint.
+func (m *Minter) Ticket() ([]byte, error) {
+	return macaroon.ThirdPartyCID(m.issuerToken, m.issuerLocation)
+}
+
+// Mint mints a new token by attenuating the issuer token with the specified
+// caveats. The minted token must be presented along with a discharge from the
+// issuer.
+func (m *Minter) Mint(cavs ...macaroon.Caveat) ([]byte, error) {
+	t, err := macaroon.Decode(m.issuerToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.Add(cavs...); err != nil {
+		return nil, err
+	}
+
+	return t.Encode()
+}
+
+// Issuer returns the name of the service that minted the token from the
+// verified caveats. The issuer's discharge location must have been among the
+// trusted third parties passed to [macaroon.Macaroon.Verify]; otherwise its
+// attestations aren't included in the verified caveats.
+func Issuer(cs *macaroon.CaveatSet) (string, error) {
+	var service string
+
+	for _, ib := range macaroon.GetCaveats[*IssuedBy](cs) {
+		switch {
+		case service == "":
+			service = ib.Service
+		case service != ib.Service:
+			return "", fmt.Errorf("delegated issuer: conflicting issuers %s and %s", service, ib.Service)
+		}
+	}
+
+	if service == "" {
+		return "", errors.New("delegated issuer: no issuer attestation")
+	}
+
+	return service, nil
+}
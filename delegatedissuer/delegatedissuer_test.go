@@ -0,0 +1,87 @@
+package delegatedissuer
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+)
+
+func TestDelegatedIssuer(t *testing.T) {
+	var (
+		key       = macaroon.NewSigningKey()
+		ka        = macaroon.NewEncryptionKey()
+		loc       = "https://api"
+		issuerLoc = "https://api/delegate"
+		window    = &macaroon.ValidityWindow{NotBefore: time.Now().Unix(), NotAfter: time.Now().Add(time.Hour).Unix()}
+	)
+
+	it, err := NewIssuerToken([]byte("kid"), loc, key, ka, issuerLoc, "builder")
+	assert.NoError(t, err)
+	itBuf, err := it.Encode()
+	assert.NoError(t, err)
+
+	minter, err := NewMinter(itBuf, issuerLoc)
+	assert.NoError(t, err)
+
+	_, err = NewMinter(itBuf, "https://elsewhere")
+	assert.Error(t, err)
+
+	tok, err := minter.Mint(window)
+	assert.NoError(t, err)
+
+	ticket, err := minter.Ticket()
+	assert.NoError(t, err)
+
+	// wrong service
+	_, err = Discharge(ka, issuerLoc, ticket, "deployer")
+	assert.Error(t, err)
+
+	dm, err := Discharge(ka, issuerLoc, ticket, "builder")
+	assert.NoError(t, err)
+	dBuf, err := dm.Encode()
+	assert.NoError(t, err)
+
+	m, err := macaroon.Decode(tok)
+	assert.NoError(t, err)
+
+	// attestation isn't trusted without the issuer as a trusted 3P
+	cavs, err := m.Verify(key, [][]byte{dBuf}, nil)
+	assert.NoError(t, err)
+	_, err = Issuer(cavs)
+	assert.Error(t, err)
+
+	cavs, err = m.Verify(key, [][]byte{dBuf}, map[string]macaroon.EncryptionKey{issuerLoc: ka})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(macaroon.GetCaveats[*macaroon.ValidityWindow](cavs)))
+
+	issuer, err := Issuer(cavs)
+	assert.NoError(t, err)
+	assert.Equal(t, "builder", issuer)
+
+	_, err = Issuer(macaroon.NewCaveatSet(&IssuedBy{Service: "a"}, &IssuedBy{Service: "b"}))
+	assert.Error(t, err)
+}
+
+func TestCaveatSerialization(t *testing.T) {
+	cs := macaroon.NewCaveatSet(
+		&Service{Name: "builder"},
+		&IssuedBy{Service: "builder"},
+	)
+
+	b, err := json.Marshal(cs)
+	assert.NoError(t, err)
+
+	cs2 := macaroon.NewCaveatSet()
+	err = json.Unmarshal(b, cs2)
+	assert.NoError(t, err)
+	assert.Equal(t, cs, cs2)
+
+	b, err = cs.MarshalMsgpack()
+	assert.NoError(t, err)
+	cs2, err = macaroon.DecodeCaveats(b)
+	assert.NoError(t, err)
+	assert.Equal(t, cs, cs2)
+}
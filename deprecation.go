@@ -0,0 +1,152 @@
+package macaroon
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Deprecation describes a caveat type that's being retired. See
+// [DeprecateCaveatType].
+type Deprecation struct {
+	// Replacements are the caveat types that should be used instead, if
+	// any.
+	Replacements []CaveatType
+
+	// Sunset, if non-zero, is when the caveat type stops being supported.
+	// See [DeprecationRejectSunset].
+	Sunset time.Time
+}
+
+var deprecations = map[CaveatType]Deprecation{}
+
+// DeprecateCaveatType registers deprecation metadata for a caveat type.
+// Deprecated caveats still decode and are enforced as usual, but validating
+// them raises warnings (see [WithWarnings]) and minting them can be rejected
+// (see [SetDeprecationPolicy]). Once no live tokens carry the type, it can be
+// retired. To transparently convert existing caveats to their replacement
+// instead, use [RegisterCaveatMigration].
+func DeprecateCaveatType(typ CaveatType, d Deprecation) {
+	if _, dup := deprecations[typ]; dup {
+		panic("duplicate caveat deprecation")
+	}
+	if _, registered := t2c[typ]; !registered {
+		panic("deprecation of unregistered caveat type")
+	}
+
+	deprecations[typ] = d
+}
+
+// CaveatTypeDeprecation returns the deprecation metadata for the caveat type,
+// if it's deprecated.
+func CaveatTypeDeprecation(typ CaveatType) (Deprecation, bool) {
+	d, ok := deprecations[typ]
+	return d, ok
+}
+
+// DeprecationPolicy determines whether deprecated caveats may be added to
+// tokens. See [SetDeprecationPolicy].
+type DeprecationPolicy int32
+
+const (
+	// DeprecationAllow allows deprecated caveats to be added. This is the
+	// default.
+	DeprecationAllow DeprecationPolicy = iota
+
+	// DeprecationRejectSunset rejects deprecated caveats whose sunset has
+	// passed.
+	DeprecationRejectSunset
+
+	// DeprecationReject rejects all deprecated caveats.
+	DeprecationReject
+)
+
+var deprecationPolicy atomic.Int32
+
+// SetDeprecationPolicy sets the policy applied by [Macaroon.Add] to
+// deprecated caveats.
+func SetDeprecationPolicy(p DeprecationPolicy) {
+	deprecationPolicy.Store(int32(p))
+}
+
+// checkDeprecation applies the deprecation policy to a caveat being minted.
+func checkDeprecation(c Caveat, now time.Time) error {
+	d, ok := deprecations[c.CaveatType()]
+	if !ok {
+		return nil
+	}
+
+	switch DeprecationPolicy(deprecationPolicy.Load()) {
+	case DeprecationReject:
+	case DeprecationRejectSunset:
+		if d.Sunset.IsZero() || now.Before(d.Sunset) {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrDeprecatedCaveat, deprecationMessage(c.CaveatType(), d))
+}
+
+// Warning is a non-fatal problem found while validating a caveat set. See
+// [WithWarnings].
+type Warning struct {
+	Caveat  Caveat
+	Message string
+}
+
+func (w Warning) String() string {
+	return w.Message
+}
+
+// WithWarnings calls f with a warning for each deprecated caveat in a caveat
+// set being validated, including those nested within IfPresent caveats.
+// Warnings are raised once per call to the Validator. f is also called for
+// each failed evaluation of a shadowed caveat (see [WithShadowMode]).
+func WithWarnings(f func(Warning)) ValidationOption {
+	return func(v *Validator) { v.warn = f }
+}
+
+// Deprecated returns the caveats in the set, including those nested within
+// IfPresent caveats, whose types are deprecated.
+func (c *CaveatSet) Deprecated() []Caveat {
+	var ret []Caveat
+	for _, cav := range c.Caveats {
+		if _, ok := deprecations[cav.CaveatType()]; ok {
+			ret = append(ret, cav)
+		}
+
+		if ifp, ok := cav.(*IfPresent); ok && ifp.Ifs != nil {
+			ret = append(ret, ifp.Ifs.Deprecated()...)
+		}
+	}
+	return ret
+}
+
+func (v *Validator) warnDeprecated(cs *CaveatSet) {
+	if v.warn == nil {
+		return
+	}
+
+	for _, cav := range cs.Deprecated() {
+		d := deprecations[cav.CaveatType()]
+		v.warn(Warning{Caveat: cav, Message: deprecationMessage(cav.CaveatType(), d)})
+	}
+}
+
+func deprecationMessage(typ CaveatType, d Deprecation) string {
+	msg := fmt.Sprintf("caveat type %s is deprecated", caveatTypeToString(typ))
+	if len(d.Replacements) != 0 {
+		names := make([]string, len(d.Replacements))
+		for i, r := range d.Replacements {
+			names[i] = caveatTypeToString(r)
+		}
+		msg += fmt.Sprintf(", use %s instead", strings.Join(names, " or "))
+	}
+	if !d.Sunset.IsZero() {
+		msg += fmt.Sprintf(" (sunset %s)", d.Sunset.UTC().Format(time.DateOnly))
+	}
+	return msg
+}
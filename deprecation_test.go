@@ -0,0 +1,57 @@
+package macaroon
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+type testCaveatDeprecated struct{}
+
+func init() {
+	RegisterCaveatType("Deprecated", cavTestDeprecated, &testCaveatDeprecated{})
+	DeprecateCaveatType(cavTestDeprecated, Deprecation{
+		Replacements: []CaveatType{cavTestParentResource},
+		Sunset:       time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+}
+
+func (c *testCaveatDeprecated) CaveatType() CaveatType   { return cavTestDeprecated }
+func (c *testCaveatDeprecated) IsAttestation() bool      { return false }
+func (c *testCaveatDeprecated) Prohibits(f Access) error { return nil }
+
+func TestDeprecation(t *testing.T) {
+	d, ok := CaveatTypeDeprecation(cavTestDeprecated)
+	assert.True(t, ok)
+	assert.Equal(t, []CaveatType{cavTestParentResource}, d.Replacements)
+	_, ok = CaveatTypeDeprecation(cavTestParentResource)
+	assert.False(t, ok)
+
+	assert.Panics(t, func() { DeprecateCaveatType(cavTestDeprecated, Deprecation{}) })
+	assert.Panics(t, func() { DeprecateCaveatType(CavUnregistered, Deprecation{}) })
+
+	key := NewSigningKey()
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+
+	// allowed by default
+	assert.NoError(t, m.Add(cavParent(ActionAll, 1), &IfPresent{Ifs: NewCaveatSet(&testCaveatDeprecated{}), Else: ActionRead}))
+
+	var warnings []Warning
+	v := NewValidator(WithWarnings(func(w Warning) { warnings = append(warnings, w) }))
+	assert.NoError(t, v.Validate(&m.UnsafeCaveats, &testAccess{action: ActionRead, parentResource: ptr[uint64](1)}, &testAccess{action: ActionWrite, parentResource: ptr[uint64](1)}))
+	assert.Equal(t, 1, len(warnings))
+	assert.Equal(t, "caveat type Deprecated is deprecated, use ParentResource instead (sunset 2000-01-01)", warnings[0].String())
+
+	t.Cleanup(func() { SetDeprecationPolicy(DeprecationAllow) })
+
+	SetDeprecationPolicy(DeprecationRejectSunset)
+	err = m.Add(&testCaveatDeprecated{})
+	assert.True(t, errors.Is(err, ErrDeprecatedCaveat))
+
+	SetDeprecationPolicy(DeprecationReject)
+	assert.True(t, errors.Is(m.Add(&testCaveatDeprecated{}), ErrDeprecatedCaveat))
+	assert.NoError(t, m.Add(cavChild(ActionAll, 2)))
+}
@@ -0,0 +1,71 @@
+package macaroon
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// EncodeDetached encodes the token with its tail signature detached. The body
+// is the encoded token without a tail; it reveals the token's caveats, but
+// can't be used or attenuated without the tail. This allows the body to be
+// stored server-side (see [DetachedBodyID]), with constrained clients only
+// presenting the tail, which is 32 bytes. Use [DecodeDetached] to reassemble
+// the token for verification.
+func (m *Macaroon) EncodeDetached() (body []byte, tail []byte, err error) {
+	if m.display {
+		return nil, nil, errDisplayCopy
+	}
+
+	if m.Nonce.Proof && m.newProof {
+		m.Tail = finalizeSignature(m.Tail)
+		m.newProof = false
+	}
+
+	detached := *m
+	detached.Tail = nil
+
+	if body, err = encode(&detached); err != nil {
+		return nil, nil, err
+	}
+
+	return body, append([]byte{}, m.Tail...), nil
+}
+
+// DecodeDetached decodes a token encoded with [Macaroon.EncodeDetached],
+// reattaching its tail. The token is then verified as usual with
+// [Macaroon.Verify], which fails if the tail isn't the body's.
+func DecodeDetached(body, tail []byte) (*Macaroon, error) {
+	m, err := Decode(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(m.Tail) != 0 {
+		return nil, errors.New("macaroon decode: body has attached tail")
+	}
+	if len(tail) == 0 {
+		return nil, errors.New("macaroon decode: missing tail")
+	}
+
+	m.Tail = append([]byte{}, tail...)
+	return m, nil
+}
+
+// DetachedBodyID returns a stable identifier for a detached body, for use as
+// a key when storing bodies server-side. It's a truncated digest of the body
+// and needn't be kept secret; the tail must be.
+func DetachedBodyID(body []byte) string {
+	return hex.EncodeToString(digest(body)[:bindingIdLength])
+}
+
+// VerifyDetached is like [Macaroon.Verify], for a token whose body and tail
+// were encoded separately with [Macaroon.EncodeDetached].
+func VerifyDetached(body, tail []byte, k SigningKey, discharges [][]byte, trusted3Ps map[string]EncryptionKey, opts ...VerifyOption) (*CaveatSet, error) {
+	m, err := DecodeDetached(body, tail)
+	if err != nil {
+		return nil, fmt.Errorf("macaroon verify: %w", err)
+	}
+
+	return m.Verify(k, discharges, trusted3Ps, opts...)
+}
@@ -0,0 +1,51 @@
+package macaroon
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestDetached(t *testing.T) {
+	key := NewSigningKey()
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavParent(ActionRead, 1)))
+
+	body, tail, err := m.EncodeDetached()
+	assert.NoError(t, err)
+	assert.Equal(t, m.Tail, tail)
+	assert.Equal(t, 32, len(tail))
+
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+	assert.True(t, len(body) < len(buf))
+
+	cs, err := VerifyDetached(body, tail, key, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []Caveat{cavParent(ActionRead, 1)}, cs.Caveats)
+
+	// reassembled tokens are the original token
+	m2, err := DecodeDetached(body, tail)
+	assert.NoError(t, err)
+	buf2, err := m2.Encode()
+	assert.NoError(t, err)
+	assert.Equal(t, buf, buf2)
+
+	// tails only verify with their bodies
+	m3, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	_, tail3, err := m3.EncodeDetached()
+	assert.NoError(t, err)
+	_, err = VerifyDetached(body, tail3, key, nil, nil)
+	assert.Error(t, err)
+
+	_, err = DecodeDetached(body, nil)
+	assert.Error(t, err)
+	_, err = DecodeDetached(buf, tail)
+	assert.Error(t, err)
+
+	assert.Equal(t, DetachedBodyID(body), DetachedBodyID(append([]byte{}, body...)))
+	assert.NotEqual(t, DetachedBodyID(body), DetachedBodyID(buf))
+}
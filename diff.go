@@ -0,0 +1,159 @@
+package macaroon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TokenDiff describes the differences between two tokens. See [Compare].
+type TokenDiff struct {
+	A, B *Macaroon
+
+	// KID is whether the tokens' KIDs differ.
+	KID bool
+
+	// Nonce is whether the tokens' nonces differ. Tokens with the same nonce
+	// were derived from the same minted token, so one may be an attenuation
+	// of the other.
+	Nonce bool
+
+	// Location is whether the tokens' locations differ.
+	Location bool
+
+	// Caveats are the caveat-level differences, in caveat order.
+	Caveats []CaveatDiff
+}
+
+// CaveatDiffKind is the kind of a [CaveatDiff].
+type CaveatDiffKind int
+
+const (
+	CaveatAdded CaveatDiffKind = iota
+	CaveatRemoved
+	CaveatChanged
+)
+
+func (k CaveatDiffKind) String() string {
+	switch k {
+	case CaveatAdded:
+		return "added"
+	case CaveatRemoved:
+		return "removed"
+	case CaveatChanged:
+		return "changed"
+	default:
+		return fmt.Sprintf("CaveatDiffKind(%d)", int(k))
+	}
+}
+
+// CaveatDiff is a difference between the caveats at Index in two tokens. A
+// is nil for added caveats and B is nil for removed caveats.
+type CaveatDiff struct {
+	Kind  CaveatDiffKind
+	Index int
+	A, B  Caveat
+}
+
+// Compare decodes two encoded tokens and reports the differences between
+// them. Caveats are compared position by position, by their encodings, so a
+// token that's an attenuation of another shows up as having added caveats.
+// Signatures aren't checked.
+func Compare(a, b []byte) (*TokenDiff, error) {
+	ma, err := Decode(a)
+	if err != nil {
+		return nil, fmt.Errorf("compare: %w", err)
+	}
+
+	mb, err := Decode(b)
+	if err != nil {
+		return nil, fmt.Errorf("compare: %w", err)
+	}
+
+	d := &TokenDiff{
+		A:        ma,
+		B:        mb,
+		KID:      !bytes.Equal(ma.Nonce.KID, mb.Nonce.KID),
+		Nonce:    !bytes.Equal(ma.Nonce.MustEncode(), mb.Nonce.MustEncode()),
+		Location: ma.Location != mb.Location,
+	}
+
+	var (
+		ca = &ma.UnsafeCaveats
+		cb = &mb.UnsafeCaveats
+	)
+
+	for i := 0; i < len(ca.Caveats) || i < len(cb.Caveats); i++ {
+		switch {
+		case i >= len(ca.Caveats):
+			d.Caveats = append(d.Caveats, CaveatDiff{Kind: CaveatAdded, Index: i, B: cb.Caveats[i]})
+		case i >= len(cb.Caveats):
+			d.Caveats = append(d.Caveats, CaveatDiff{Kind: CaveatRemoved, Index: i, A: ca.Caveats[i]})
+		default:
+			ea, err := ca.caveatBytes(i)
+			if err != nil {
+				return nil, fmt.Errorf("compare: %w", err)
+			}
+
+			eb, err := cb.caveatBytes(i)
+			if err != nil {
+				return nil, fmt.Errorf("compare: %w", err)
+			}
+
+			if !bytes.Equal(ea, eb) {
+				d.Caveats = append(d.Caveats, CaveatDiff{Kind: CaveatChanged, Index: i, A: ca.Caveats[i], B: cb.Caveats[i]})
+			}
+		}
+	}
+
+	return d, nil
+}
+
+// Equal returns whether the tokens are the same, apart from their signatures.
+func (d *TokenDiff) Equal() bool {
+	return !d.KID && !d.Nonce && !d.Location && len(d.Caveats) == 0
+}
+
+// String formats the differences for display, one per line.
+func (d *TokenDiff) String() string {
+	var sb strings.Builder
+
+	if d.KID {
+		fmt.Fprintf(&sb, "kid: %x -> %x\n", d.A.Nonce.KID, d.B.Nonce.KID)
+	}
+	if d.Nonce && !d.KID {
+		fmt.Fprintf(&sb, "nonce: %s -> %s\n", d.A.Nonce.UUID(), d.B.Nonce.UUID())
+	}
+	if d.Location {
+		fmt.Fprintf(&sb, "location: %q -> %q\n", d.A.Location, d.B.Location)
+	}
+
+	for _, cd := range d.Caveats {
+		switch cd.Kind {
+		case CaveatAdded:
+			fmt.Fprintf(&sb, "+ [%d] %s\n", cd.Index, caveatString(cd.B))
+		case CaveatRemoved:
+			fmt.Fprintf(&sb, "- [%d] %s\n", cd.Index, caveatString(cd.A))
+		case CaveatChanged:
+			fmt.Fprintf(&sb, "- [%d] %s\n+ [%d] %s\n", cd.Index, caveatString(cd.A), cd.Index, caveatString(cd.B))
+		}
+	}
+
+	return sb.String()
+}
+
+// caveatString formats a caveat as its type name and JSON body.
+func caveatString(c Caveat) string {
+	name := caveatTypeToString(c.CaveatType())
+	if name == "" {
+		name = fmt.Sprintf("%d", c.CaveatType())
+	}
+
+	body, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Sprintf("%s <%s>", name, err)
+	}
+
+	return fmt.Sprintf("%s %s", name, body)
+}
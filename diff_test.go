@@ -0,0 +1,58 @@
+package macaroon
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestCompare(t *testing.T) {
+	key := NewSigningKey()
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavParent(ActionRead|ActionWrite, 1)))
+	a, err := m.Encode()
+	assert.NoError(t, err)
+
+	assert.NoError(t, m.Add(cavChild(ActionRead, 2)))
+	b, err := m.Encode()
+	assert.NoError(t, err)
+
+	d, err := Compare(a, a)
+	assert.NoError(t, err)
+	assert.True(t, d.Equal())
+	assert.Equal(t, "", d.String())
+
+	// attenuation shows up as added caveats
+	d, err = Compare(a, b)
+	assert.NoError(t, err)
+	assert.False(t, d.Equal())
+	assert.False(t, d.Nonce)
+	assert.Equal(t, []CaveatDiff{{Kind: CaveatAdded, Index: 1, B: cavChild(ActionRead, 2)}}, d.Caveats)
+
+	d, err = Compare(b, a)
+	assert.NoError(t, err)
+	assert.Equal(t, CaveatRemoved, d.Caveats[0].Kind)
+
+	m2, err := New([]byte("kid2"), "https://other", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m2.Add(cavParent(ActionRead, 1)))
+	c, err := m2.Encode()
+	assert.NoError(t, err)
+
+	d, err = Compare(a, c)
+	assert.NoError(t, err)
+	assert.True(t, d.KID && d.Nonce && d.Location)
+	assert.Equal(t, []CaveatDiff{{Kind: CaveatChanged, Index: 0, A: cavParent(ActionRead|ActionWrite, 1), B: cavParent(ActionRead, 1)}}, d.Caveats)
+	assert.Equal(t, ""+
+		"kid: 6b6964 -> 6b696432\n"+
+		`location: "https://api" -> "https://other"`+"\n"+
+		`- [0] ParentResource {"ID":1,"Permission":"rw"}`+"\n"+
+		`+ [0] ParentResource {"ID":1,"Permission":"r"}`+"\n",
+		d.String(),
+	)
+
+	_, err = Compare(a, []byte("bogus"))
+	assert.Error(t, err)
+}
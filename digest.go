@@ -0,0 +1,42 @@
+package macaroon
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// Digest returns the SHA256 digest of the canonical encoding of the caveat
+// set, for detecting changes to a token's policy, e.g. to invalidate cached
+// authorization decisions when a token is re-minted with different caveats.
+//
+// The canonical encoding is the caveat set's msgpack encoding, with each
+// caveat in its current form (migrated caveats aren't digested in their
+// original encodings, see [RegisterCaveatMigration]). Third-party caveats
+// contain secrets encrypted afresh whenever they're added, so only their
+// locations are digested. Caveat order is significant.
+func (c *CaveatSet) Digest() ([]byte, error) {
+	canonical := NewCaveatSet()
+	canonical.Caveats = make([]Caveat, 0, len(c.Caveats))
+
+	for _, cav := range c.Caveats {
+		if tp, ok := cav.(*Caveat3P); ok {
+			cav = &Caveat3P{Location: NormalizeLocation(tp.Location)}
+		}
+		canonical.Caveats = append(canonical.Caveats, cav)
+	}
+
+	buf, err := canonical.MarshalMsgpack()
+	if err != nil {
+		return nil, fmt.Errorf("caveat set digest: %w", err)
+	}
+
+	h := sha256.Sum256(buf)
+	return h[:], nil
+}
+
+// CaveatsDigest returns the digest of the token's caveats. See
+// [CaveatSet.Digest]. The caveats aren't verified, so the digest is only
+// meaningful for tokens that verify.
+func (m *Macaroon) CaveatsDigest() ([]byte, error) {
+	return m.UnsafeCaveats.Digest()
+}
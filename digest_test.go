@@ -0,0 +1,60 @@
+package macaroon
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestCaveatSetDigest(t *testing.T) {
+	var (
+		key = NewSigningKey()
+		ka  = NewEncryptionKey()
+	)
+
+	mint := func(cavs ...Caveat) *Macaroon {
+		t.Helper()
+
+		m, err := New([]byte("kid"), "https://api", key)
+		assert.NoError(t, err)
+		assert.NoError(t, m.Add(cavs...))
+		assert.NoError(t, m.Add3P(ka, "https://auth/"))
+		return m
+	}
+
+	a, err := mint(cavParent(ActionRead, 1), cavChild(ActionRead, 2)).CaveatsDigest()
+	assert.NoError(t, err)
+	assert.Equal(t, 32, len(a))
+
+	// re-minting with the same caveats doesn't change the digest, despite the
+	// new nonce and third-party caveat
+	b, err := mint(cavParent(ActionRead, 1), cavChild(ActionRead, 2)).CaveatsDigest()
+	assert.NoError(t, err)
+	assert.Equal(t, a, b)
+
+	// changing a caveat does
+	b, err = mint(cavParent(ActionRead, 1), cavChild(ActionAll, 2)).CaveatsDigest()
+	assert.NoError(t, err)
+	assert.NotEqual(t, a, b)
+
+	// the digest survives a round trip through the wire
+	m := mint(cavParent(ActionRead, 1), cavChild(ActionRead, 2))
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+	decoded, err := Decode(buf)
+	assert.NoError(t, err)
+	b, err = decoded.CaveatsDigest()
+	assert.NoError(t, err)
+	assert.Equal(t, a, b)
+
+	// migrated caveats digest in their current form
+	old, err := NewCaveatSet(&testCaveatWidgetV1{Name: "foo"}).MarshalMsgpack()
+	assert.NoError(t, err)
+	migrated, err := DecodeCaveats(old)
+	assert.NoError(t, err)
+	a, err = migrated.Digest()
+	assert.NoError(t, err)
+	b, err = NewCaveatSet(&testCaveatWidgetV2{Names: []string{"foo"}}).Digest()
+	assert.NoError(t, err)
+	assert.Equal(t, a, b)
+}
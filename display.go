@@ -0,0 +1,46 @@
+package macaroon
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Redacted3P stands in for a [Caveat3P] in a display copy of a Macaroon. It
+// retains the caveat's location but replaces its sealed VID and CID with
+// their lengths.
+type Redacted3P struct {
+	Location string
+	VIDLen   int
+	CIDLen   int
+}
+
+func (c *Redacted3P) CaveatType() CaveatType { return Cav3P }
+
+func (c *Redacted3P) Prohibits(f Access) error {
+	return fmt.Errorf("%w (redacted 3rd party caveat)", ErrBadCaveat)
+}
+
+func (c *Redacted3P) IsAttestation() bool { return false }
+
+var errDisplayCopy = errors.New("can't use display copy of macaroon")
+
+// DisplayCopy returns a copy of the Macaroon that's safe to show in logs and
+// UIs. Third-party caveats are replaced by [Redacted3P] caveats and the tail
+// signature is omitted, so the copy doesn't carry sealed key material or
+// confer any access. The copy can't be encoded, attenuated or verified.
+func (m *Macaroon) DisplayCopy() *Macaroon {
+	cavs := make([]Caveat, len(m.UnsafeCaveats.Caveats))
+	for i, c := range m.UnsafeCaveats.Caveats {
+		if tp, ok := c.(*Caveat3P); ok {
+			c = &Redacted3P{Location: tp.Location, VIDLen: len(tp.VID), CIDLen: len(tp.CID)}
+		}
+		cavs[i] = c
+	}
+
+	return &Macaroon{
+		Nonce:         m.Nonce,
+		Location:      m.Location,
+		UnsafeCaveats: CaveatSet{Caveats: cavs},
+		display:       true,
+	}
+}
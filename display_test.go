@@ -0,0 +1,42 @@
+package macaroon
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestDisplayCopy(t *testing.T) {
+	var (
+		key = NewSigningKey()
+		ka  = NewEncryptionKey()
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavParent(ActionRead, 1)))
+	assert.NoError(t, m.Add3P(ka, "https://auth"))
+
+	tp := m.UnsafeCaveats.Caveats[1].(*Caveat3P)
+
+	d := m.DisplayCopy()
+	assert.Equal(t, m.Nonce, d.Nonce)
+	assert.Equal(t, 0, len(d.Tail))
+	assert.Equal[Caveat](t, cavParent(ActionRead, 1), d.UnsafeCaveats.Caveats[0])
+	assert.Equal[Caveat](t, &Redacted3P{Location: "https://auth", VIDLen: len(tp.VID), CIDLen: len(tp.CID)}, d.UnsafeCaveats.Caveats[1])
+
+	buf, err := json.Marshal(d)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(buf), `"VID"`)
+
+	_, err = d.Encode()
+	assert.Error(t, err)
+	assert.Error(t, d.Add(cavChild(ActionRead, 2)))
+	_, err = d.Verify(key, nil, nil)
+	assert.Error(t, err)
+
+	// original is untouched
+	_, err = m.Encode()
+	assert.NoError(t, err)
+}
@@ -0,0 +1,476 @@
+//go:build macaroon_edge
+
+// Package edge is a minimal decoder and verifier for tokens, for edge and
+// WASM runtimes with tight binary size budgets. It's only built with the
+// macaroon_edge build tag:
+//
+//	GOOS=js GOARCH=wasm go build -tags macaroon_edge ...
+//
+// Unlike the macaroon package, it uses no JSON, no reflection-based msgpack
+// and no caveat registry. Verified caveats are returned with their bodies
+// still encoded; the package only decodes the caveat types needed for
+// verification, plus [ValidityWindow]s. Decoding and enforcing other caveats
+// is left to the caller.
+//
+// Compared to [macaroon.Macaroon.Verify], [Token.Verify] has these
+// limitations:
+//
+//   - No third parties are trusted, so the attestations of discharge tokens
+//     are dropped. Attestations are recognized from a fixed list of the
+//     types defined by this module, plus those declared with
+//     [RegisterAttestationType] (see [IsAttestation]). Tokens with unknown
+//     types in the range reserved for this module fail to verify.
+//   - Verify options aren't supported.
+//   - Tokens with legacy nonce layouts, or otherwise not encoded canonically
+//     (see [macaroon.Recode]), fail to verify.
+//
+// The package shares its test vectors with the macaroon package, so the two
+// implementations agree.
+//
+// [macaroon.Macaroon.Verify]: https://pkg.go.dev/github.com/superfly/macaroon#Macaroon.Verify
+// [macaroon.Recode]: https://pkg.go.dev/github.com/superfly/macaroon#Recode
+package edge
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Caveat types used by this package. These match the macaroon package's.
+const (
+	CavValidityWindow    = 4
+	Cav3P                = 11
+	CavBindToParentToken = 12
+	CavIssuerSeal        = 38
+)
+
+// minUserRegisterable is the first caveat type outside the range reserved
+// for this module's packages. See [macaroon.CavMinUserRegisterable].
+const minUserRegisterable = 1 << 32
+
+// reservedTypes are the caveat types in the reserved range defined by this
+// module's packages, and whether each is an attestation. Tokens with other
+// types in the reserved range fail to verify, since this package can't tell
+// whether they're attestations. Keep this in sync with the registry; the
+// tests check it.
+var reservedTypes = map[uint64]bool{
+	0:  false, // flyio Organization
+	2:  false, // flyio Volumes
+	3:  false, // flyio Apps
+	4:  false, // ValidityWindow
+	5:  false, // flyio FeatureSet
+	6:  false, // flyio Mutations
+	7:  false, // flyio Machines
+	8:  false, // flyio ConfineUser
+	9:  false, // flyio ConfineOrganization
+	10: false, // flyio IsUser
+	11: false, // 3P
+	12: false, // BindToParentToken
+	13: false, // IfPresent
+	14: false, // flyio MachineFeatureSet
+	15: false, // flyio FromMachineSource
+	16: false, // flyio Clusters
+	17: false, // flyio Databases
+	18: false, // flyio DatabaseRoles
+	19: false, // DelegatedIssuerService
+	20: true,  // DelegatedIssuerAttestation
+	21: false, // Predicate
+	22: false, // flyio Networks
+	23: false, // flyio LiteFSClusters
+	24: false, // Quota
+	25: false, // SpendLimit
+	26: false, // Obligation
+	27: false, // StepUp
+	28: true,  // StepUpAttestation
+	29: false, // WebAuthn
+	30: false, // DevicePosture
+	31: true,  // DevicePostureAttestation
+	32: false, // MeasuredEnvironment
+	33: true,  // MeasuredEnvironmentAttestation
+	34: false, // BloomResources
+	35: false, // flyio ResourcePath
+	36: false, // ChannelBinding
+	37: true,  // ParentToken
+	38: false, // IssuerSeal
+	39: true,  // SealedAttestation
+	40: false, // VerifierPin
+	41: false, // BreakGlass
+	42: true,  // BreakGlassApproval
+	43: false, // Lineage
+	44: true,  // flyio OrgRole
+	45: false, // flyio RequireOrgRole
+	46: false, // Labels
+	47: false, // ElevatedAction
+	48: false, // MultiRoot
+}
+
+// userAttestationTypes are attestation types outside the reserved range,
+// declared with RegisterAttestationType.
+var userAttestationTypes = map[uint64]bool{}
+
+// RegisterAttestationType declares a caveat type outside the reserved range
+// to be an attestation, so that it's dropped from untrusted discharges and
+// rejected in non-proof tokens. Like caveat registration in the macaroon
+// package, it should only be called from init functions.
+func RegisterAttestationType(typ uint64) {
+	if typ < minUserRegisterable {
+		panic(fmt.Sprintf("edge: caveat type %d is reserved", typ))
+	}
+	userAttestationTypes[typ] = true
+}
+
+// IsAttestation returns whether caveats of the type are attestations.
+func IsAttestation(typ uint64) bool {
+	if typ < minUserRegisterable {
+		return reservedTypes[typ]
+	}
+	return userAttestationTypes[typ]
+}
+
+// isKnown returns whether the type is outside the reserved range, or is
+// known to this package.
+func isKnown(typ uint64) bool {
+	_, ok := reservedTypes[typ]
+	return ok || typ >= minUserRegisterable
+}
+
+var (
+	ErrInvalid     = errors.New("edge: invalid token")
+	ErrNoDischarge = errors.New("edge: no discharge for third-party caveat")
+)
+
+// Caveat is a caveat whose body hasn't been decoded. Body is the body's
+// msgpack encoding.
+type Caveat struct {
+	Type uint64
+	Body []byte
+}
+
+// ValidityWindow decodes the caveat if it's a ValidityWindow, returning its
+// bounds in seconds since the Unix epoch.
+func (c Caveat) ValidityWindow() (notBefore, notAfter int64, ok bool) {
+	if c.Type != CavValidityWindow {
+		return 0, 0, false
+	}
+
+	r := &reader{buf: c.Body}
+	if n, err := r.arrayLen(); err != nil || n != 2 {
+		return 0, 0, false
+	}
+	notBefore, _, _, err := r.int()
+	if err != nil {
+		return 0, 0, false
+	}
+	notAfter, _, _, err = r.int()
+	if err != nil {
+		return 0, 0, false
+	}
+	return notBefore, notAfter, true
+}
+
+// CheckValidity checks that now is within the caveats' ValidityWindows.
+// Malformed ValidityWindows fail the check.
+func CheckValidity(caveats []Caveat, now time.Time) error {
+	for _, c := range caveats {
+		if c.Type != CavValidityWindow {
+			continue
+		}
+
+		notBefore, notAfter, ok := c.ValidityWindow()
+		switch {
+		case !ok:
+			return fmt.Errorf("%w: malformed validity window", ErrInvalid)
+		case now.Before(time.Unix(notBefore, 0)):
+			return fmt.Errorf("%w: not valid until %s", ErrInvalid, time.Unix(notBefore, 0))
+		case now.After(time.Unix(notAfter, 0)):
+			return fmt.Errorf("%w: only valid until %s", ErrInvalid, time.Unix(notAfter, 0))
+		}
+	}
+	return nil
+}
+
+// Token is a decoded token.
+type Token struct {
+	KID      []byte
+	Rnd      []byte
+	Proof    bool
+	Location string
+	Tail     []byte
+
+	// UnsafeCaveats are the token's caveats. Use [Token.Verify] to recover
+	// them from tokens you don't trust.
+	UnsafeCaveats []Caveat
+
+	nonce  []byte
+	signed [][]byte
+}
+
+// Decode decodes a token.
+func Decode(buf []byte) (*Token, error) {
+	r := &reader{buf: buf}
+	t := new(Token)
+
+	if n, err := r.arrayLen(); err != nil {
+		return nil, err
+	} else if n != 4 {
+		return nil, fmt.Errorf("edge: token has %d fields", n)
+	}
+
+	if err := t.decodeNonce(r); err != nil {
+		return nil, err
+	}
+
+	loc, err := r.bytes()
+	if err != nil {
+		return nil, err
+	}
+	t.Location = string(loc)
+
+	if err := t.decodeCaveats(r); err != nil {
+		return nil, err
+	}
+
+	if t.Tail, err = r.bytes(); err != nil {
+		return nil, err
+	}
+
+	if r.off != len(buf) {
+		return nil, errors.New("edge: trailing bytes after token")
+	}
+
+	return t, nil
+}
+
+func (t *Token) decodeNonce(r *reader) error {
+	start := r.off
+
+	if c, err := r.peek(); err != nil {
+		return err
+	} else if c&0xf0 == 0x80 || c == 0xde || c == 0xdf {
+		return errors.New("edge: legacy nonce layout not supported")
+	}
+
+	n, err := r.arrayLen()
+	switch {
+	case err != nil:
+		return err
+	case n != 2 && n != 3:
+		return fmt.Errorf("edge: unknown nonce format: %d fields", n)
+	}
+
+	if t.KID, err = r.bytes(); err != nil {
+		return err
+	}
+	if t.Rnd, err = r.bytes(); err != nil {
+		return err
+	}
+	if n == 3 {
+		if t.Proof, err = r.bool(); err != nil {
+			return err
+		}
+	}
+
+	t.nonce = r.buf[start:r.off]
+	return nil
+}
+
+func (t *Token) decodeCaveats(r *reader) error {
+	n, err := r.arrayLen()
+	switch {
+	case err != nil:
+		return err
+	case n%2 != 0:
+		return errors.New("edge: bad caveat container")
+	}
+
+	for i := 0; i < n/2; i++ {
+		start := r.off
+
+		typ, err := r.uint()
+		if err != nil {
+			return err
+		}
+
+		body, err := r.raw()
+		if err != nil {
+			return err
+		}
+
+		// the signature covers the encoding of a caveat set containing
+		// only this caveat: a two-element array of the type and body
+		signed := append([]byte{0x92}, r.buf[start:r.off]...)
+
+		t.UnsafeCaveats = append(t.UnsafeCaveats, Caveat{Type: typ, Body: body})
+		t.signed = append(t.signed, signed)
+	}
+
+	return nil
+}
+
+// Verify verifies the token with key, along with discharges for its
+// third-party caveats, returning the caveats of the token and its
+// discharges, less the discharges' attestations. Callers must enforce every returned caveat,
+// failing closed on types they don't know.
+func (t *Token) Verify(key []byte, discharges [][]byte) ([]Caveat, error) {
+	byCID := make(map[string]*Token, len(discharges))
+	for _, buf := range discharges {
+		d, err := Decode(buf)
+		if err != nil {
+			continue // ignore malformed discharges
+		}
+		byCID[string(d.KID)] = d
+	}
+
+	return t.verify(key, byCID, nil, true)
+}
+
+func (t *Token) verify(key []byte, discharges map[string]*Token, parentBindingIDs [][]byte, trustAttestations bool) ([]Caveat, error) {
+	var (
+		curMac     = sign(key, t.nonce)
+		bindingIDs = [][]byte{digest(curMac)}
+		ret        []Caveat
+		pending    []*Token
+		pendingKey [][]byte
+	)
+
+	for i, c := range t.UnsafeCaveats {
+		switch {
+		case !isKnown(c.Type):
+			return nil, fmt.Errorf("%w: unknown reserved caveat type %d", ErrInvalid, c.Type)
+		case c.Type == Cav3P:
+			vid, cid, err := decode3P(c.Body)
+			if err != nil {
+				return nil, err
+			}
+
+			dk, err := unseal(curMac, vid)
+			if err != nil {
+				return nil, fmt.Errorf("%w: unseal VID for third-party caveat", ErrInvalid)
+			}
+
+			d, ok := discharges[string(cid)]
+			if !ok {
+				return nil, ErrNoDischarge
+			}
+			pending, pendingKey = append(pending, d), append(pendingKey, dk)
+		case c.Type == CavBindToParentToken:
+			bid, err := (&reader{buf: c.Body}).bytes()
+			if err != nil {
+				return nil, err
+			}
+			if !hasPrefix(parentBindingIDs, bid) {
+				return nil, fmt.Errorf("%w: discharge bound to different parent token", ErrInvalid)
+			}
+		case c.Type == CavIssuerSeal:
+			seal, err := (&reader{buf: c.Body}).bytes()
+			if err != nil {
+				return nil, err
+			}
+			if subtle.ConstantTimeCompare(seal, issuerSeal(key, curMac)) != 1 {
+				return nil, fmt.Errorf("%w: invalid issuer seal", ErrInvalid)
+			}
+		case IsAttestation(c.Type):
+			if !t.Proof {
+				return nil, fmt.Errorf("%w: attestation in non-proof token", ErrInvalid)
+			}
+			if trustAttestations {
+				ret = append(ret, c)
+			}
+		default:
+			ret = append(ret, c)
+		}
+
+		curMac = sign(curMac, t.signed[i])
+		bindingIDs = append(bindingIDs, digest(curMac))
+	}
+
+	for i, d := range pending {
+		// discharges' attestations are never trusted
+		dcavs, err := d.verify(pendingKey[i], nil, bindingIDs, false)
+		if err != nil {
+			return nil, fmt.Errorf("discharge for %s: %w", d.Location, err)
+		}
+		ret = append(ret, dcavs...)
+	}
+
+	if t.Proof {
+		curMac = finalizeSignature(curMac)
+	}
+
+	if subtle.ConstantTimeCompare(curMac, t.Tail) != 1 {
+		return nil, ErrInvalid
+	}
+
+	return ret, nil
+}
+
+func decode3P(body []byte) (vid, cid []byte, err error) {
+	r := &reader{buf: body}
+	if n, err := r.arrayLen(); err != nil {
+		return nil, nil, err
+	} else if n != 3 {
+		return nil, nil, fmt.Errorf("edge: third-party caveat has %d fields", n)
+	}
+
+	if _, err = r.bytes(); err != nil { // location
+		return nil, nil, err
+	}
+	if vid, err = r.bytes(); err != nil {
+		return nil, nil, err
+	}
+	if cid, err = r.bytes(); err != nil {
+		return nil, nil, err
+	}
+	return vid, cid, nil
+}
+
+func hasPrefix(ids [][]byte, prefix []byte) bool {
+	for _, id := range ids {
+		if bytes.HasPrefix(id, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func sign(key, buf []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(buf)
+	return mac.Sum(nil)
+}
+
+func digest(buf []byte) []byte {
+	sum := sha256.Sum256(buf)
+	return sum[:]
+}
+
+func finalizeSignature(tail []byte) []byte {
+	return sign([]byte("proof-signature-finalization"), tail)
+}
+
+func issuerSeal(key, curMac []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("issuer-seal"))
+	mac.Write(curMac)
+	return mac.Sum(nil)[:16]
+}
+
+func unseal(key, buf []byte) ([]byte, error) {
+	const nonceLen = chacha20poly1305.NonceSize
+	if len(buf) < nonceLen+1 {
+		return nil, errors.New("edge: malformed sealed data")
+	}
+
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, buf[:nonceLen], buf[nonceLen:], nil)
+}
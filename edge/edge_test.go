@@ -0,0 +1,177 @@
+//go:build macaroon_edge
+
+package edge
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+
+	// register every caveat type defined by this module
+	_ "github.com/superfly/macaroon/bloom"
+	_ "github.com/superfly/macaroon/breakglass"
+	_ "github.com/superfly/macaroon/delegatedissuer"
+	_ "github.com/superfly/macaroon/flyio"
+	_ "github.com/superfly/macaroon/measured"
+	_ "github.com/superfly/macaroon/posture"
+	_ "github.com/superfly/macaroon/stepup"
+	_ "github.com/superfly/macaroon/webauthn"
+)
+
+// testVector is a test vector generated by the macaroon package. See its
+// vectors_test.go.
+type testVector struct {
+	Name       string   `json:"name"`
+	Key        string   `json:"key"`
+	Token      string   `json:"token"`
+	Discharges []string `json:"discharges"`
+	Valid      bool     `json:"valid"`
+	Caveats    []struct {
+		Type    uint64 `json:"type"`
+		Encoded string `json:"encoded"`
+	} `json:"caveats"`
+}
+
+func TestVectors(t *testing.T) {
+	buf, err := os.ReadFile("../testdata/vectors.json")
+	assert.NoError(t, err)
+
+	var vectors []testVector
+	assert.NoError(t, json.Unmarshal(buf, &vectors))
+	assert.NotEqual(t, 0, len(vectors))
+
+	for _, v := range vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			key, err := hex.DecodeString(v.Key)
+			assert.NoError(t, err)
+
+			buf, err := base64.StdEncoding.DecodeString(v.Token)
+			assert.NoError(t, err)
+
+			var discharges [][]byte
+			for _, d := range v.Discharges {
+				buf, err := base64.StdEncoding.DecodeString(d)
+				assert.NoError(t, err)
+				discharges = append(discharges, buf)
+			}
+
+			tok, err := Decode(buf)
+			assert.NoError(t, err)
+
+			cavs, err := tok.Verify(key, discharges)
+			if !v.Valid {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			assert.Equal(t, len(v.Caveats), len(cavs))
+			for i, want := range v.Caveats {
+				enc, err := hex.DecodeString(want.Encoded)
+				assert.NoError(t, err)
+
+				// strip the caveat set's array header and the type
+				r := &reader{buf: enc}
+				_, err = r.arrayLen()
+				assert.NoError(t, err)
+				typ, err := r.uint()
+				assert.NoError(t, err)
+
+				assert.Equal(t, want.Type, typ)
+				assert.Equal(t, Caveat{Type: want.Type, Body: enc[r.off:]}, cavs[i])
+			}
+		})
+	}
+}
+
+func TestAttestationTypes(t *testing.T) {
+	var n int
+	for _, c := range macaroon.RegisteredCaveats() {
+		typ := uint64(c.CaveatType())
+		if typ >= minUserRegisterable {
+			continue
+		}
+		n++
+
+		_, known := reservedTypes[typ]
+		assert.True(t, known, "caveat type %d (%s) missing from reservedTypes", typ, macaroon.CaveatTypeName(c.CaveatType()))
+		assert.Equal(t, c.IsAttestation(), IsAttestation(typ), "caveat type %d (%s)", typ, macaroon.CaveatTypeName(c.CaveatType()))
+	}
+	assert.Equal(t, len(reservedTypes), n)
+
+	assert.True(t, isKnown(macaroon.CavMinUserRegisterable))
+	assert.False(t, isKnown(macaroon.CavMinUserRegisterable-1))
+	assert.False(t, IsAttestation(macaroon.CavMinUserDefined))
+	RegisterAttestationType(macaroon.CavMinUserDefined)
+	defer delete(userAttestationTypes, macaroon.CavMinUserDefined)
+	assert.True(t, IsAttestation(macaroon.CavMinUserDefined))
+	assert.Panics(t, func() { RegisterAttestationType(CavValidityWindow) })
+
+	assert.Equal(t, macaroon.CavValidityWindow, macaroon.CaveatType(CavValidityWindow))
+	assert.Equal(t, macaroon.Cav3P, macaroon.CaveatType(Cav3P))
+	assert.Equal(t, macaroon.CavBindToParentToken, macaroon.CaveatType(CavBindToParentToken))
+	assert.Equal(t, macaroon.CavIssuerSeal, macaroon.CaveatType(CavIssuerSeal))
+}
+
+func TestUnknownReservedType(t *testing.T) {
+	buf, err := os.ReadFile("../testdata/vectors.json")
+	assert.NoError(t, err)
+
+	var vectors []testVector
+	assert.NoError(t, json.Unmarshal(buf, &vectors))
+
+	for _, v := range vectors {
+		if v.Name != "first-party" {
+			continue
+		}
+
+		key, err := hex.DecodeString(v.Key)
+		assert.NoError(t, err)
+		buf, err := base64.StdEncoding.DecodeString(v.Token)
+		assert.NoError(t, err)
+		tok, err := Decode(buf)
+		assert.NoError(t, err)
+
+		_, err = tok.Verify(key, nil)
+		assert.NoError(t, err)
+
+		// a reserved type this package doesn't know might be an attestation
+		tok.UnsafeCaveats[1].Type = 1
+		_, err = tok.Verify(key, nil)
+		assert.True(t, errors.Is(err, ErrInvalid))
+		assert.Contains(t, err.Error(), "unknown reserved caveat type 1")
+		return
+	}
+
+	t.Fatal("missing first-party vector")
+}
+
+func TestCheckValidity(t *testing.T) {
+	vw := func(notBefore, notAfter int64) Caveat {
+		return Caveat{Type: CavValidityWindow, Body: []byte{0x92, 0xd3, 0, 0, 0, 0, 0, 0, 0, byte(notBefore), 0xcc, byte(notAfter)}}
+	}
+
+	assert.NoError(t, CheckValidity([]Caveat{vw(10, 20)}, time.Unix(15, 0)))
+	assert.True(t, errors.Is(CheckValidity([]Caveat{vw(10, 20)}, time.Unix(5, 0)), ErrInvalid))
+	assert.True(t, errors.Is(CheckValidity([]Caveat{vw(10, 20)}, time.Unix(25, 0)), ErrInvalid))
+	assert.True(t, errors.Is(CheckValidity([]Caveat{vw(10, 20), vw(10, 12)}, time.Unix(15, 0)), ErrInvalid))
+	assert.True(t, errors.Is(CheckValidity([]Caveat{{Type: CavValidityWindow, Body: []byte{0x91, 0}}}, time.Unix(15, 0)), ErrInvalid))
+
+	// other caveats are the caller's
+	assert.NoError(t, CheckValidity([]Caveat{{Type: 46, Body: []byte{0xc0}}}, time.Unix(15, 0)))
+}
+
+func TestDecode(t *testing.T) {
+	_, err := Decode([]byte{0x94, 0x81})
+	assert.Error(t, err)
+
+	_, err = Decode(nil)
+	assert.Error(t, err)
+}
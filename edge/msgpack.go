@@ -0,0 +1,271 @@
+//go:build macaroon_edge
+
+package edge
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var errShort = errors.New("edge: truncated msgpack")
+
+// reader reads the subset of msgpack used by tokens, without reflection.
+type reader struct {
+	buf []byte
+	off int
+}
+
+func (r *reader) next(n int) ([]byte, error) {
+	if n < 0 || len(r.buf)-r.off < n {
+		return nil, errShort
+	}
+	ret := r.buf[r.off : r.off+n]
+	r.off += n
+	return ret, nil
+}
+
+func (r *reader) code() (byte, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (r *reader) peek() (byte, error) {
+	if r.off >= len(r.buf) {
+		return 0, errShort
+	}
+	return r.buf[r.off], nil
+}
+
+// length reads an n-byte big-endian length.
+func (r *reader) length(n int) (int, error) {
+	b, err := r.next(n)
+	if err != nil {
+		return 0, err
+	}
+
+	var l uint64
+	switch n {
+	case 1:
+		l = uint64(b[0])
+	case 2:
+		l = uint64(binary.BigEndian.Uint16(b))
+	case 4:
+		l = uint64(binary.BigEndian.Uint32(b))
+	}
+
+	// no length can exceed what's left of the buffer, which also protects
+	// against overflow on 32-bit platforms
+	if l > uint64(len(r.buf)) {
+		return 0, errShort
+	}
+	return int(l), nil
+}
+
+func (r *reader) arrayLen() (int, error) {
+	c, err := r.code()
+	switch {
+	case err != nil:
+		return 0, err
+	case c >= 0x90 && c <= 0x9f:
+		return int(c & 0x0f), nil
+	case c == 0xdc:
+		return r.length(2)
+	case c == 0xdd:
+		return r.length(4)
+	default:
+		return 0, fmt.Errorf("edge: want array, have code %#x", c)
+	}
+}
+
+// bytes reads a bin or str value, or nil.
+func (r *reader) bytes() ([]byte, error) {
+	c, err := r.code()
+	if err != nil {
+		return nil, err
+	}
+
+	var n int
+	switch {
+	case c == 0xc0:
+		return nil, nil
+	case c >= 0xa0 && c <= 0xbf:
+		n = int(c & 0x1f)
+	case c == 0xc4 || c == 0xd9:
+		n, err = r.length(1)
+	case c == 0xc5 || c == 0xda:
+		n, err = r.length(2)
+	case c == 0xc6 || c == 0xdb:
+		n, err = r.length(4)
+	default:
+		return nil, fmt.Errorf("edge: want bytes, have code %#x", c)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return r.next(n)
+}
+
+func (r *reader) bool() (bool, error) {
+	c, err := r.code()
+	switch {
+	case err != nil:
+		return false, err
+	case c == 0xc2:
+		return false, nil
+	case c == 0xc3:
+		return true, nil
+	default:
+		return false, fmt.Errorf("edge: want bool, have code %#x", c)
+	}
+}
+
+// int reads an integer of any encoding. Unsigned integers above MaxInt64
+// are reported with big set, and their value in u.
+func (r *reader) int() (i int64, u uint64, big bool, err error) {
+	c, err := r.code()
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), uint64(c), false, nil
+	case c >= 0xe0:
+		return int64(int8(c)), 0, false, nil
+	}
+
+	var n int
+	switch c {
+	case 0xcc, 0xd0:
+		n = 1
+	case 0xcd, 0xd1:
+		n = 2
+	case 0xce, 0xd2:
+		n = 4
+	case 0xcf, 0xd3:
+		n = 8
+	default:
+		return 0, 0, false, fmt.Errorf("edge: want int, have code %#x", c)
+	}
+
+	b, err := r.next(n)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	var v uint64
+	for _, x := range b {
+		v = v<<8 | uint64(x)
+	}
+
+	if c >= 0xd0 {
+		// sign-extend
+		shift := 64 - 8*uint(n)
+		i := int64(v<<shift) >> shift
+		return i, uint64(i), false, nil
+	}
+
+	return int64(v), v, v > 1<<63-1, nil
+}
+
+func (r *reader) uint() (uint64, error) {
+	i, u, big, err := r.int()
+	switch {
+	case err != nil:
+		return 0, err
+	case big:
+		return u, nil
+	case i < 0:
+		return 0, errors.New("edge: want unsigned int, have negative")
+	default:
+		return uint64(i), nil
+	}
+}
+
+// skip skips a value of any type, up to a nesting depth.
+func (r *reader) skip(depth int) error {
+	if depth > 32 {
+		return errors.New("edge: msgpack nested too deeply")
+	}
+
+	c, err := r.peek()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case c <= 0x7f, c >= 0xe0, c == 0xc0, c == 0xc2, c == 0xc3:
+		r.off++
+		return nil
+	case c >= 0xcc && c <= 0xd3:
+		_, _, _, err := r.int()
+		return err
+	case c == 0xca:
+		_, err := r.next(5)
+		return err
+	case c == 0xcb:
+		_, err := r.next(9)
+		return err
+	case c >= 0xa0 && c <= 0xbf, c >= 0xc4 && c <= 0xc6, c >= 0xd9 && c <= 0xdb:
+		_, err := r.bytes()
+		return err
+	case c >= 0x90 && c <= 0x9f, c == 0xdc, c == 0xdd:
+		n, err := r.arrayLen()
+		if err != nil {
+			return err
+		}
+		return r.skipN(n, depth)
+	case c >= 0x80 && c <= 0x8f, c == 0xde, c == 0xdf:
+		r.off++
+		var n int
+		switch c {
+		case 0xde:
+			n, err = r.length(2)
+		case 0xdf:
+			n, err = r.length(4)
+		default:
+			n = int(c & 0x0f)
+		}
+		if err != nil {
+			return err
+		}
+		return r.skipN(2*n, depth)
+	case c >= 0xd4 && c <= 0xd8:
+		// fixext: type byte and 1, 2, 4, 8 or 16 bytes of data
+		_, err := r.next(2 + 1<<(c-0xd4))
+		return err
+	case c >= 0xc7 && c <= 0xc9:
+		r.off++
+		n, err := r.length(1 << (c - 0xc7))
+		if err != nil {
+			return err
+		}
+		_, err = r.next(1 + n)
+		return err
+	default:
+		return fmt.Errorf("edge: unknown msgpack code %#x", c)
+	}
+}
+
+func (r *reader) skipN(n, depth int) error {
+	for i := 0; i < n; i++ {
+		if err := r.skip(depth + 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// raw reads a value of any type, returning its encoding.
+func (r *reader) raw() ([]byte, error) {
+	start := r.off
+	if err := r.skip(0); err != nil {
+		return nil, err
+	}
+	return r.buf[start:r.off], nil
+}
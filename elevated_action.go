@@ -0,0 +1,57 @@
+package macaroon
+
+import (
+	"fmt"
+	"time"
+)
+
+// ElevatedAction is a caveat limiting accesses to the Base actions, plus the
+// Elevated actions during Window, e.g. allowing writes for the next hour and
+// only reads after that. The window only bounds the elevated actions: unlike
+// a ValidityWindow caveat, it doesn't limit the token's lifetime.
+type ElevatedAction struct {
+	Base     Action         `json:"base"`
+	Elevated Action         `json:"elevated"`
+	Window   ValidityWindow `json:"window"`
+}
+
+func init() { RegisterCaveatType("ElevatedAction", CavElevatedAction, &ElevatedAction{}) }
+
+// NewElevatedAction creates an ElevatedAction caveat allowing the base
+// actions, and the elevated actions for d from now.
+func NewElevatedAction(base, elevated Action, d time.Duration) *ElevatedAction {
+	now := time.Now()
+	return &ElevatedAction{
+		Base:     base,
+		Elevated: elevated,
+		Window:   ValidityWindow{NotBefore: now.Unix(), NotAfter: now.Add(d).Unix()},
+	}
+}
+
+func (c *ElevatedAction) CaveatType() CaveatType { return CavElevatedAction }
+func (c *ElevatedAction) IsAttestation() bool    { return false }
+
+func (c *ElevatedAction) Prohibits(f Access) error {
+	return c.ProhibitsWithContext(newValidationContext(f), f)
+}
+
+func (c *ElevatedAction) ProhibitsWithContext(vc *ValidationContext, f Access) error {
+	if f.GetAction().IsSubsetOf(c.Base) {
+		return nil
+	}
+
+	if !f.GetAction().IsSubsetOf(c.Base | c.Elevated) {
+		return &ActionError{Requested: f.GetAction(), Permitted: c.Base | c.Elevated}
+	}
+
+	if err := c.Window.ProhibitsWithContext(vc, f); err != nil {
+		return fmt.Errorf("%w (elevated actions %s only allowed from %s to %s)",
+			&ActionError{Requested: f.GetAction(), Permitted: c.Base},
+			c.Elevated,
+			time.Unix(c.Window.NotBefore, 0).UTC().Format(time.RFC3339),
+			time.Unix(c.Window.NotAfter, 0).UTC().Format(time.RFC3339),
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package macaroon
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestElevatedAction(t *testing.T) {
+	key := NewSigningKey()
+	now := time.Now()
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(
+		cavParent(ActionAll, 1),
+		NewElevatedAction(ActionRead, ActionWrite, time.Hour),
+	))
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	decoded, err := Decode(buf)
+	assert.NoError(t, err)
+	cs, err := decoded.Verify(key, nil, nil)
+	assert.NoError(t, err)
+
+	read := &testAccess{action: ActionRead, parentResource: ptr(uint64(1))}
+	write := &testAccess{action: ActionWrite, parentResource: ptr(uint64(1))}
+	del := &testAccess{action: ActionDelete, parentResource: ptr(uint64(1))}
+
+	at := func(t time.Time) *Validator { return NewValidator(WithNow(t)) }
+
+	// elevated
+	assert.NoError(t, at(now).Validate(cs, read))
+	assert.NoError(t, at(now).Validate(cs, write))
+	assert.True(t, errors.Is(at(now).Validate(cs, del), ErrUnauthorized))
+
+	// after the elevation
+	assert.NoError(t, at(now.Add(2*time.Hour)).Validate(cs, read))
+	err = at(now.Add(2*time.Hour)).Validate(cs, write)
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+	var ae *ActionError
+	assert.True(t, errors.As(err, &ae))
+	assert.Equal(t, ActionWrite, ae.Missing())
+
+	// before the elevation
+	assert.True(t, errors.Is(at(now.Add(-time.Hour)).Validate(cs, write), ErrUnauthorized))
+}
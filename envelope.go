@@ -0,0 +1,226 @@
+package macaroon
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
+	msgpack "github.com/vmihailenco/msgpack/v5"
+)
+
+// Envelope roles. Discharge tokens are labeled with the location of the
+// third party that issued them (see [DischargeRole]).
+const (
+	RolePermission = "permission"
+	RoleService    = "service"
+
+	roleDischargePrefix = "discharge:"
+)
+
+const envelopeVersion = 1
+
+var errMalformedEnvelope = fmt.Errorf("%w: malformed envelope", ErrUnrecognizedToken)
+
+// DischargeRole returns the envelope role for a discharge token issued by
+// the third party at the specified location.
+func DischargeRole(location string) string {
+	return roleDischargePrefix + NormalizeLocation(location)
+}
+
+// IsDischargeRole reports whether the envelope role is that of a discharge
+// token.
+func IsDischargeRole(role string) bool {
+	return strings.HasPrefix(role, roleDischargePrefix)
+}
+
+// Envelope carries several tokens in a single blob, each labeled with its
+// role (e.g. RolePermission, DischargeRole(loc) or RoleService). It's encoded
+// with msgpack as the format version, an index mapping each role to the
+// positions of its tokens, and the tokens themselves.
+type Envelope struct {
+	roles  []string
+	tokens [][]byte
+}
+
+// Add adds a token with the specified role.
+func (e *Envelope) Add(role string, tok []byte) {
+	e.roles = append(e.roles, role)
+	e.tokens = append(e.tokens, tok)
+}
+
+// Get returns the tokens with the specified role, in the order they were
+// added.
+func (e *Envelope) Get(role string) [][]byte {
+	var ret [][]byte
+	for i, r := range e.roles {
+		if r == role {
+			ret = append(ret, e.tokens[i])
+		}
+	}
+	return ret
+}
+
+// Roles returns the distinct roles of the envelope's tokens, sorted.
+func (e *Envelope) Roles() []string {
+	seen := map[string]bool{}
+	var ret []string
+	for _, r := range e.roles {
+		if !seen[r] {
+			seen[r] = true
+			ret = append(ret, r)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Tokens returns all of the envelope's tokens, in the order they were added.
+func (e *Envelope) Tokens() [][]byte {
+	return append([][]byte{}, e.tokens...)
+}
+
+type wireEnvelope struct {
+	_msgpack struct{}        `msgpack:",as_array"`
+	Version  uint            `msgpack:"v"`
+	Index    []wireIndexItem `msgpack:"i"`
+	Tokens   [][]byte        `msgpack:"t"`
+}
+
+type wireIndexItem struct {
+	_msgpack  struct{} `msgpack:",as_array"`
+	Role      string   `msgpack:"r"`
+	Positions []int    `msgpack:"p"`
+}
+
+// Encode encodes the envelope.
+func (e *Envelope) Encode() ([]byte, error) {
+	w := wireEnvelope{Version: envelopeVersion, Tokens: e.tokens}
+
+	for _, role := range e.Roles() {
+		item := wireIndexItem{Role: role}
+		for i, r := range e.roles {
+			if r == role {
+				item.Positions = append(item.Positions, i)
+			}
+		}
+		w.Index = append(w.Index, item)
+	}
+
+	return encode(w)
+}
+
+// DecodeEnvelope decodes an envelope. Every token must be listed in the index
+// exactly once.
+func DecodeEnvelope(buf []byte) (*Envelope, error) {
+	var w wireEnvelope
+	if err := msgpack.Unmarshal(buf, &w); err != nil {
+		return nil, fmt.Errorf("%w: %s", errMalformedEnvelope, err)
+	}
+
+	if w.Version != envelopeVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", errMalformedEnvelope, w.Version)
+	}
+
+	e := &Envelope{roles: make([]string, len(w.Tokens)), tokens: w.Tokens}
+	indexed := make([]bool, len(w.Tokens))
+
+	for _, item := range w.Index {
+		for _, p := range item.Positions {
+			if p < 0 || p >= len(w.Tokens) || indexed[p] {
+				return nil, fmt.Errorf("%w: bad index", errMalformedEnvelope)
+			}
+			indexed[p] = true
+			e.roles[p] = item.Role
+		}
+	}
+
+	for _, ok := range indexed {
+		if !ok {
+			return nil, fmt.Errorf("%w: unindexed token", errMalformedEnvelope)
+		}
+	}
+
+	return e, nil
+}
+
+// Envelope returns an envelope containing the bundle's tokens. Discharges
+// are labeled with their locations.
+func (b *Bundle) Envelope() *Envelope {
+	e := new(Envelope)
+	e.Add(RolePermission, b.Permission)
+
+	for _, d := range b.Discharges {
+		var loc string
+		if dm, err := Decode(d); err == nil {
+			loc = dm.Location
+		}
+		e.Add(DischargeRole(loc), d)
+	}
+
+	return e
+}
+
+// BundleFromEnvelope creates a bundle from the permission and discharge
+// tokens in an envelope. The envelope must contain exactly one permission
+// token. Tokens with other roles are ignored.
+func BundleFromEnvelope(e *Envelope) (*Bundle, error) {
+	perms := e.Get(RolePermission)
+	if len(perms) != 1 {
+		return nil, fmt.Errorf("envelope has %d permission tokens, expected 1", len(perms))
+	}
+
+	b := &Bundle{Permission: perms[0]}
+	for i, r := range e.roles {
+		if IsDischargeRole(r) {
+			b.Discharges = append(b.Discharges, e.tokens[i])
+		}
+	}
+
+	return b, nil
+}
+
+// EncodeEnvelope encodes the bundle as an envelope. See [Bundle.Envelope].
+func (b *Bundle) EncodeEnvelope() ([]byte, error) {
+	return b.Envelope().Encode()
+}
+
+// DecodeBundle decodes a bundle encoded with [Bundle.EncodeEnvelope].
+func DecodeBundle(buf []byte) (*Bundle, error) {
+	e, err := DecodeEnvelope(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return BundleFromEnvelope(e)
+}
+
+// Header formats the envelope as an HTTP Authorization header. [Parse] and
+// [ParseBundle] accept envelopes in headers, expanding them into their
+// tokens.
+func (e *Envelope) Header() (string, error) {
+	buf, err := e.Encode()
+	if err != nil {
+		return "", err
+	}
+
+	return authorizationScheme + " " + encodeEnvelope(buf), nil
+}
+
+// ParseEnvelope parses an HTTP Authorization header containing a single
+// envelope, as formatted by [Envelope.Header].
+func ParseEnvelope(header string) (*Envelope, error) {
+	header = strings.TrimPrefix(header, authorizationScheme+" ")
+
+	b64, ok := strings.CutPrefix(header, envelopeLabel+"_")
+	if !ok || strings.Contains(b64, ",") {
+		return nil, fmt.Errorf("%w: not an envelope", ErrUnrecognizedToken)
+	}
+
+	buf, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errMalformedEnvelope, err)
+	}
+
+	return DecodeEnvelope(buf)
+}
@@ -0,0 +1,77 @@
+package macaroon
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestEnvelope(t *testing.T) {
+	var (
+		key     = NewSigningKey()
+		ka      = NewEncryptionKey()
+		authLoc = "https://auth"
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add3P(ka, authLoc))
+	tok, err := m.Encode()
+	assert.NoError(t, err)
+
+	_, _, dm, err := dischargeMacaroon(ka, authLoc, tok)
+	assert.NoError(t, err)
+	dtok, err := dm.Encode()
+	assert.NoError(t, err)
+
+	b := &Bundle{Permission: tok, Discharges: [][]byte{dtok}}
+	e := b.Envelope()
+	e.Add(RoleService, []byte("service-token"))
+
+	assert.Equal(t, []string{DischargeRole(authLoc), RolePermission, RoleService}, e.Roles())
+	assert.Equal(t, [][]byte{dtok}, e.Get("discharge:https://auth"))
+
+	buf, err := e.Encode()
+	assert.NoError(t, err)
+	e2, err := DecodeEnvelope(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, e, e2)
+
+	b2, err := BundleFromEnvelope(e2)
+	assert.NoError(t, err)
+	assert.Equal(t, b, b2)
+
+	buf, err = b.EncodeEnvelope()
+	assert.NoError(t, err)
+	b2, err = DecodeBundle(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, b, b2)
+
+	// headers
+	hdr, err := e.Header()
+	assert.NoError(t, err)
+	b2, err = ParseBundle(hdr, "https://api")
+	assert.NoError(t, err)
+	assert.Equal(t, b, b2)
+	_, err = b2.Verify(key, nil)
+	assert.NoError(t, err)
+
+	toks, err := Parse(hdr)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{tok, dtok, []byte("service-token")}, toks)
+
+	// malformed
+	_, err = DecodeEnvelope([]byte("bogus"))
+	assert.Error(t, err)
+	bad, err := encode(wireEnvelope{Version: envelopeVersion, Tokens: [][]byte{tok}})
+	assert.NoError(t, err)
+	_, err = DecodeEnvelope(bad)
+	assert.Error(t, err)
+	bad, err = encode(wireEnvelope{Version: envelopeVersion, Index: []wireIndexItem{{Role: RolePermission, Positions: []int{0, 0}}}, Tokens: [][]byte{tok}})
+	assert.NoError(t, err)
+	_, err = DecodeEnvelope(bad)
+	assert.Error(t, err)
+
+	_, err = BundleFromEnvelope(new(Envelope))
+	assert.Error(t, err)
+}
@@ -0,0 +1,217 @@
+package macaroon
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// ErrRetiredEpoch is returned when verifying a token signed with the key of a
+// retired epoch after the epoch's cutoff.
+var ErrRetiredEpoch = fmt.Errorf("%w: key epoch retired", ErrUnauthorized)
+
+// EpochState is the state of a key epoch in an [EpochKeyring].
+type EpochState int
+
+const (
+	// EpochPrimary is the current epoch, whose key new tokens are signed
+	// with. Each KID has at most one.
+	EpochPrimary EpochState = iota
+
+	// EpochPrevious is an epoch being rotated out. Its tokens are still
+	// accepted.
+	EpochPrevious
+
+	// EpochRetired is an epoch whose tokens are accepted until its cutoff,
+	// and rejected with ErrRetiredEpoch after.
+	EpochRetired
+)
+
+func (s EpochState) String() string {
+	switch s {
+	case EpochPrimary:
+		return "primary"
+	case EpochPrevious:
+		return "previous"
+	case EpochRetired:
+		return "retired"
+	default:
+		return fmt.Sprintf("EpochState(%d)", int(s))
+	}
+}
+
+// Epoch is a signing key and its place in a key rotation.
+type Epoch struct {
+	Name  string
+	Key   SigningKey
+	State EpochState
+
+	// Since is when the epoch entered its state.
+	Since time.Time
+
+	// Cutoff is when tokens signed with a retired epoch's key stop being
+	// accepted. If it's zero, they're rejected as soon as the epoch is
+	// retired.
+	Cutoff time.Time
+}
+
+// EpochStats are the verification counts for a key epoch, for monitoring a
+// rotation's progress before retiring an epoch or passing its cutoff.
+type EpochStats struct {
+	KID   []byte
+	Name  string
+	State EpochState
+
+	// Matched is the number of tokens verified with the epoch's key.
+	Matched uint64
+
+	// Rejected is the number of tokens signed with the retired epoch's key
+	// that were rejected because its cutoff had passed.
+	Rejected uint64
+}
+
+// EpochKeyring is like [Keyring], but models fleet-wide key rotation: each
+// KID's keys belong to primary, previous or retired epochs. Verification
+// falls back from the primary epoch's key to previous epochs' and then
+// retired epochs', counting which epoch matched (see [EpochKeyring.Stats]),
+// and rejects tokens from retired epochs past their cutoff.
+//
+// Like a Keyring, an EpochKeyring may be used for concurrent verifications,
+// but must not be modified concurrently with use.
+type EpochKeyring struct {
+	epochs  map[string][]*epochEntry
+	trusted Trusted3Ps
+	now     func() time.Time
+}
+
+type epochEntry struct {
+	Epoch
+	matched  atomic.Uint64
+	rejected atomic.Uint64
+}
+
+// NewEpochKeyring creates an empty EpochKeyring.
+func NewEpochKeyring() *EpochKeyring {
+	return &EpochKeyring{
+		epochs:  map[string][]*epochEntry{},
+		trusted: Trusted3Ps{},
+		now:     time.Now,
+	}
+}
+
+// Trust3P trusts the attestations of discharges issued by the third party at
+// the location with the keys. See [Trusted3Ps].
+func (k *EpochKeyring) Trust3P(loc string, keys ...Sealer) {
+	k.trusted.Add(loc, keys...)
+}
+
+// Add adds an epoch for the KID. Names must be unique within a KID, and a KID
+// may only have one primary epoch.
+func (k *EpochKeyring) Add(kid []byte, e Epoch) error {
+	for _, existing := range k.epochs[string(kid)] {
+		switch {
+		case existing.Name == e.Name:
+			return fmt.Errorf("keyring: duplicate epoch name %q for KID %x", e.Name, kid)
+		case existing.State == EpochPrimary && e.State == EpochPrimary:
+			return fmt.Errorf("keyring: KID %x already has primary epoch %q", kid, existing.Name)
+		}
+	}
+
+	k.epochs[string(kid)] = append(k.epochs[string(kid)], &epochEntry{Epoch: e})
+	return nil
+}
+
+// Rotate makes a new primary epoch for the KID. The primary epoch becomes a
+// previous epoch, and previous epochs are retired, with tokens signed with
+// their keys accepted for grace longer.
+func (k *EpochKeyring) Rotate(kid []byte, name string, key SigningKey, grace time.Duration) error {
+	for _, existing := range k.epochs[string(kid)] {
+		if existing.Name == name {
+			return fmt.Errorf("keyring: duplicate epoch name %q for KID %x", name, kid)
+		}
+	}
+
+	now := k.now()
+	for _, e := range k.epochs[string(kid)] {
+		switch e.State {
+		case EpochPrimary:
+			e.State, e.Since = EpochPrevious, now
+		case EpochPrevious:
+			e.State, e.Since, e.Cutoff = EpochRetired, now, now.Add(grace)
+		}
+	}
+
+	return k.Add(kid, Epoch{Name: name, Key: key, State: EpochPrimary, Since: now})
+}
+
+// Verify verifies the token with its KID's epochs. Its signature matches
+// [VerifyFunc].
+func (k *EpochKeyring) Verify(m *Macaroon, discharges [][]byte, opts ...VerifyOption) (*CaveatSet, error) {
+	cs, _, err := k.VerifyEpoch(m, discharges, opts...)
+	return cs, err
+}
+
+// VerifyEpoch is like [EpochKeyring.Verify], but also returns the name of the
+// epoch whose key verified the token. Epochs are tried from primary to
+// retired, stopping at the first match.
+func (k *EpochKeyring) VerifyEpoch(m *Macaroon, discharges [][]byte, opts ...VerifyOption) (*CaveatSet, string, error) {
+	epochs := k.epochs[string(m.Nonce.KID)]
+	if len(epochs) == 0 {
+		return nil, "", fmt.Errorf("%w: %x", ErrUnknownKID, m.Nonce.KID)
+	}
+
+	var first error
+	for _, state := range []EpochState{EpochPrimary, EpochPrevious, EpochRetired} {
+		for _, e := range epochs {
+			if e.State != state {
+				continue
+			}
+
+			cs, err := m.Verify(e.Key, discharges, nil, append([]VerifyOption{WithTrusted3Ps(k.trusted)}, opts...)...)
+			if err != nil {
+				if first == nil {
+					first = err
+				}
+				continue
+			}
+
+			if e.State == EpochRetired && !k.now().Before(e.Cutoff) {
+				e.rejected.Add(1)
+				return nil, e.Name, fmt.Errorf("%w: epoch %q, cutoff %s", ErrRetiredEpoch, e.Name, e.Cutoff.UTC().Format(time.RFC3339))
+			}
+
+			e.matched.Add(1)
+			return cs, e.Name, nil
+		}
+	}
+
+	if first == nil {
+		first = errors.New("keyring: no epochs")
+	}
+	return nil, "", first
+}
+
+// Stats returns the verification counts for each epoch, ordered by KID.
+func (k *EpochKeyring) Stats() []EpochStats {
+	kids := make([]string, 0, len(k.epochs))
+	for kid := range k.epochs {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	var ret []EpochStats
+	for _, kid := range kids {
+		for _, e := range k.epochs[kid] {
+			ret = append(ret, EpochStats{
+				KID:      []byte(kid),
+				Name:     e.Name,
+				State:    e.State,
+				Matched:  e.matched.Load(),
+				Rejected: e.rejected.Load(),
+			})
+		}
+	}
+	return ret
+}
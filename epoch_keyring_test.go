@@ -0,0 +1,71 @@
+package macaroon
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestEpochKeyring(t *testing.T) {
+	var (
+		kid    = []byte("kid")
+		k1, k2 = NewSigningKey(), NewSigningKey()
+		k3     = NewSigningKey()
+		now    = time.Now()
+	)
+
+	mint := func(key SigningKey) *Macaroon {
+		m, err := New(kid, "https://api", key)
+		assert.NoError(t, err)
+		return m
+	}
+
+	kr := NewEpochKeyring()
+	kr.now = func() time.Time { return now }
+
+	assert.NoError(t, kr.Add(kid, Epoch{Name: "2024", Key: k1, State: EpochPrimary}))
+	assert.Error(t, kr.Add(kid, Epoch{Name: "2024", Key: k2, State: EpochPrevious}))
+	assert.Error(t, kr.Add(kid, Epoch{Name: "other", Key: k2, State: EpochPrimary}))
+
+	_, name, err := kr.VerifyEpoch(mint(k1), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024", name)
+
+	// first rotation: old tokens fall back to the previous epoch
+	assert.NoError(t, kr.Rotate(kid, "2025", k2, 24*time.Hour))
+	_, name, err = kr.VerifyEpoch(mint(k1), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024", name)
+	_, name, err = kr.VerifyEpoch(mint(k2), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "2025", name)
+
+	// second rotation: the oldest epoch is retired, with a grace period
+	assert.NoError(t, kr.Rotate(kid, "2026", k3, 24*time.Hour))
+	_, name, err = kr.VerifyEpoch(mint(k1), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024", name)
+
+	now = now.Add(25 * time.Hour)
+	_, err = kr.Verify(mint(k1), nil)
+	assert.True(t, errors.Is(err, ErrRetiredEpoch))
+	_, err = kr.Verify(mint(k2), nil)
+	assert.NoError(t, err)
+
+	_, err = kr.Verify(mint(NewSigningKey()), nil)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrRetiredEpoch))
+
+	m, err := New([]byte("other"), "https://api", k1)
+	assert.NoError(t, err)
+	_, err = kr.Verify(m, nil)
+	assert.True(t, errors.Is(err, ErrUnknownKID))
+
+	assert.Equal(t, []EpochStats{
+		{KID: kid, Name: "2024", State: EpochRetired, Matched: 3, Rejected: 1},
+		{KID: kid, Name: "2025", State: EpochPrevious, Matched: 2},
+		{KID: kid, Name: "2026", State: EpochPrimary},
+	}, kr.Stats())
+}
@@ -14,8 +14,98 @@ var (
 	ErrUnauthorizedForResource    = fmt.Errorf("%w for", ErrUnauthorized)
 	ErrUnauthorizedForAction      = fmt.Errorf("%w for", ErrUnauthorized)
 	ErrBadCaveat                  = fmt.Errorf("%w: bad caveat", ErrUnauthorized)
+	ErrBudgetExceeded             = fmt.Errorf("%w: caveat evaluation budget exceeded", ErrUnauthorized)
+	ErrUnhandledObligation        = fmt.Errorf("%w: unhandled obligation", ErrUnauthorized)
+	ErrCaveatTimeout              = fmt.Errorf("%w: caveat evaluation timed out", ErrUnauthorized)
+	ErrDeprecatedCaveat           = errors.New("deprecated caveat")
+	ErrConflictingCaveats         = errors.New("conflicting caveats")
+	ErrTicketExpired              = errors.New("ticket expired")
+	ErrNonCanonical               = fmt.Errorf("%w: non-canonical encoding", ErrUnrecognizedToken)
 )
 
+// ActionError is returned when a caveat doesn't allow an access's action. It
+// wraps ErrUnauthorizedForAction, and describes the failure in enough detail
+// for clients to say exactly which access is needed. Find it in an error
+// chain with errors.As.
+type ActionError struct {
+	// Requested is the access's action.
+	Requested Action
+
+	// Permitted is the action allowed by the caveat.
+	Permitted Action
+
+	// Resource optionally describes the resource the caveat restricts
+	// access to (e.g. "app 123").
+	Resource string
+}
+
+// Missing returns the requested action bits that weren't permitted.
+func (e *ActionError) Missing() Action {
+	return e.Requested.Remove(e.Permitted)
+}
+
+func (e *ActionError) Error() string {
+	msg := fmt.Sprintf("%s access %s (%s not allowed)", ErrUnauthorizedForAction, e.Requested, e.Missing())
+	if e.Resource != "" {
+		msg += " on " + e.Resource
+	}
+	return msg
+}
+
+func (e *ActionError) Unwrap() error {
+	return ErrUnauthorizedForAction
+}
+
+// ErrNoDischarge is the cause of [DischargeError]s for third-party caveats
+// without a matching discharge token.
+var ErrNoDischarge = errors.New("no matching discharge token")
+
+// DischargeError describes a problem with the discharge for one of a token's
+// third-party caveats, so operators can tell which third party to
+// investigate. When several discharges fail, Verify reports an error for
+// each of them; use [DischargeErrors] to find them all.
+type DischargeError struct {
+	// Index is the index of the discharge among those passed to Verify, or
+	// -1 if there was no discharge for the caveat.
+	Index int
+
+	// Location is the location of the third-party caveat.
+	Location string
+
+	Err error
+}
+
+func (e *DischargeError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("%s for %s", e.Err, e.Location)
+	}
+	return fmt.Sprintf("verify discharge %d for %s: %s", e.Index, e.Location, e.Err)
+}
+
+func (e *DischargeError) Unwrap() error {
+	return e.Err
+}
+
+// DischargeErrors returns the DischargeErrors in the error's tree.
+func DischargeErrors(err error) []*DischargeError {
+	switch err := err.(type) {
+	case nil:
+		return nil
+	case *DischargeError:
+		return []*DischargeError{err}
+	case interface{ Unwrap() []error }:
+		var ret []*DischargeError
+		for _, e := range err.Unwrap() {
+			ret = append(ret, DischargeErrors(e)...)
+		}
+		return ret
+	case interface{ Unwrap() error }:
+		return DischargeErrors(err.Unwrap())
+	default:
+		return nil
+	}
+}
+
 func appendErrs(base error, others ...error) error {
 	for _, other := range others {
 		if other == nil {
@@ -40,3 +40,24 @@ func TestAppendErrs(t *testing.T) {
 	assert.Zero(t, appendErrs(nil))
 	assert.Zero(t, appendErrs(nil, nil))
 }
+
+func TestActionError(t *testing.T) {
+	cs := NewCaveatSet(
+		cavParent(ActionAll, 1),
+		&IfPresent{Ifs: NewCaveatSet(cavChild(ActionAll, 2)), Else: ActionRead},
+	)
+
+	err := cs.Validate(&testAccess{action: ActionRead | ActionWrite | ActionDelete, parentResource: ptr[uint64](1)})
+	assert.True(t, errors.Is(err, ErrUnauthorizedForAction))
+
+	var ae *ActionError
+	assert.True(t, errors.As(err, &ae))
+	assert.Equal(t, ActionRead|ActionWrite|ActionDelete, ae.Requested)
+	assert.Equal(t, ActionRead, ae.Permitted)
+	assert.Equal(t, ActionWrite|ActionDelete, ae.Missing())
+	assert.Equal(t, []string{"write", "delete"}, ae.Missing().Names())
+
+	assert.Equal(t, "unauthorized for access rwd (wd not allowed)", ae.Error())
+	ae.Resource = "app 123"
+	assert.Equal(t, "unauthorized for access rwd (wd not allowed) on app 123", ae.Error())
+}
@@ -0,0 +1,139 @@
+// Package escrow splits [macaroon.SigningKey]s into Shamir secret shares and
+// recombines them.
+//
+// This is meant for high-value root keys that are kept offline and are only
+// brought together to mint intermediate issuer tokens. No single custodian
+// holds the key; any threshold-sized subset of custodians can reconstruct it.
+//
+// Shares are computed byte-wise over GF(2^8). Combining fewer shares than the
+// threshold used when splitting yields a garbage key rather than an error, so
+// callers should check a reconstructed key (e.g. by verifying a known token)
+// before using it.
+package escrow
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/superfly/macaroon"
+	"github.com/superfly/macaroon/internal/rnd"
+)
+
+// Share is one share of a split key. The first byte is the share's
+// x-coordinate; the remainder is the share's value.
+type Share []byte
+
+// X returns the x-coordinate of the share.
+func (s Share) X() byte { return s[0] }
+
+// Split splits key into n shares, any threshold of which can be combined to
+// recover the key.
+func Split(key macaroon.SigningKey, n, threshold int) ([]Share, error) {
+	switch {
+	case len(key) == 0:
+		return nil, errors.New("escrow split: empty key")
+	case threshold < 2:
+		return nil, fmt.Errorf("escrow split: threshold must be at least 2, got %d", threshold)
+	case n < threshold:
+		return nil, fmt.Errorf("escrow split: %d shares is fewer than threshold %d", n, threshold)
+	case n > 255:
+		return nil, fmt.Errorf("escrow split: at most 255 shares supported, got %d", n)
+	}
+
+	shares := make([]Share, n)
+	for i := range shares {
+		shares[i] = make(Share, len(key)+1)
+		shares[i][0] = byte(i + 1)
+	}
+
+	coeffs := make([]byte, threshold)
+	for b, secret := range key {
+		if err := rnd.Read(coeffs[1:]); err != nil {
+			return nil, fmt.Errorf("escrow split: %w", err)
+		}
+		coeffs[0] = secret
+
+		for _, share := range shares {
+			share[b+1] = evaluate(coeffs, share.X())
+		}
+	}
+
+	return shares, nil
+}
+
+// Combine reconstructs a key from its shares.
+func Combine(shares ...Share) (macaroon.SigningKey, error) {
+	if len(shares) < 2 {
+		return nil, errors.New("escrow combine: need at least 2 shares")
+	}
+
+	var (
+		keyLen = len(shares[0]) - 1
+		seen   = make(map[byte]bool, len(shares))
+	)
+
+	if keyLen < 1 {
+		return nil, errors.New("escrow combine: malformed share")
+	}
+
+	for _, share := range shares {
+		switch {
+		case len(share)-1 != keyLen:
+			return nil, errors.New("escrow combine: shares have different lengths")
+		case share.X() == 0:
+			return nil, errors.New("escrow combine: malformed share")
+		case seen[share.X()]:
+			return nil, fmt.Errorf("escrow combine: duplicate share %d", share.X())
+		}
+		seen[share.X()] = true
+	}
+
+	key := make(macaroon.SigningKey, keyLen)
+	for i, si := range shares {
+		// lagrange basis polynomial for share i, evaluated at 0
+		basis := byte(1)
+		for j, sj := range shares {
+			if i != j {
+				basis = mul(basis, div(sj.X(), sj.X()^si.X()))
+			}
+		}
+
+		for b := range key {
+			key[b] ^= mul(si[b+1], basis)
+		}
+	}
+
+	return key, nil
+}
+
+// evaluate evaluates the polynomial with the given coefficients at x using
+// Horner's method.
+func evaluate(coeffs []byte, x byte) byte {
+	var ret byte
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		ret = mul(ret, x) ^ coeffs[i]
+	}
+	return ret
+}
+
+// mul multiplies in GF(2^8) with the AES reducing polynomial, without
+// data-dependent branches.
+func mul(a, b byte) byte {
+	var ret byte
+	for i := 0; i < 8; i++ {
+		ret ^= a & -(b & 1)
+		a = (a << 1) ^ (0x1b & -(a >> 7))
+		b >>= 1
+	}
+	return ret
+}
+
+// div divides in GF(2^8). b must be non-zero.
+func div(a, b byte) byte {
+	// b^254 is the multiplicative inverse of b
+	inv := b
+	for i := 0; i < 6; i++ {
+		inv = mul(mul(inv, inv), b)
+	}
+	return mul(a, mul(inv, inv))
+}
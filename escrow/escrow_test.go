@@ -0,0 +1,67 @@
+package escrow
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+)
+
+func TestSplitCombine(t *testing.T) {
+	key := macaroon.NewSigningKey()
+
+	shares, err := Split(key, 5, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(shares))
+
+	for i := range shares {
+		for j := i + 1; j < len(shares); j++ {
+			for k := j + 1; k < len(shares); k++ {
+				combined, err := Combine(shares[i], shares[j], shares[k])
+				assert.NoError(t, err)
+				assert.Equal(t, key, combined)
+			}
+		}
+	}
+
+	combined, err := Combine(shares...)
+	assert.NoError(t, err)
+	assert.Equal(t, key, combined)
+
+	// below threshold
+	combined, err = Combine(shares[0], shares[1])
+	assert.NoError(t, err)
+	assert.NotEqual(t, key, combined)
+
+	_, err = Combine(shares[0])
+	assert.Error(t, err)
+
+	_, err = Combine(shares[0], shares[0])
+	assert.Error(t, err)
+
+	_, err = Combine(shares[0], shares[1][:10])
+	assert.Error(t, err)
+
+	_, err = Split(key, 2, 3)
+	assert.Error(t, err)
+
+	_, err = Split(key, 3, 1)
+	assert.Error(t, err)
+
+	_, err = Split(key, 256, 3)
+	assert.Error(t, err)
+
+	_, err = Split(nil, 5, 3)
+	assert.Error(t, err)
+}
+
+func TestField(t *testing.T) {
+	for a := 0; a < 256; a++ {
+		assert.Equal(t, byte(0), mul(byte(a), 0))
+		assert.Equal(t, byte(a), mul(byte(a), 1))
+
+		for b := 1; b < 256; b++ {
+			assert.Equal(t, byte(a), mul(div(byte(a), byte(b)), byte(b)))
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package macaroon
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"sort"
+)
+
+// fingerprintSize is the number of bytes of HMAC output in a fingerprint.
+// It's plenty to avoid collisions between the tokens seen by one service.
+const fingerprintSize = 10
+
+// Fingerprint returns a short, log-safe identifier for a token, for
+// correlating log lines mentioning the same token without logging the token
+// itself. Fingerprints are keyed, so they can't be matched against tokens
+// (e.g. leaked ones) by anyone without the key. Use the same key across a
+// fleet to correlate tokens between services.
+func Fingerprint(key []byte, token []byte) string {
+	h := hmac.New(sha256.New, key)
+	h.Write(token)
+	return hex.EncodeToString(h.Sum(nil)[:fingerprintSize])
+}
+
+// Fingerprint returns a fingerprint (see [Fingerprint]) identifying the
+// bundle's permission token together with its discharges, regardless of the
+// discharges' order. To correlate requests made with a permission token as
+// its discharges are refreshed, fingerprint the permission token alone.
+func (b *Bundle) Fingerprint(key []byte) string {
+	discharges := make([][]byte, len(b.Discharges))
+	copy(discharges, b.Discharges)
+	sort.Slice(discharges, func(i, j int) bool {
+		return bytes.Compare(discharges[i], discharges[j]) < 0
+	})
+
+	h := hmac.New(sha256.New, key)
+	for _, tok := range append([][]byte{b.Permission}, discharges...) {
+		h.Write(binary.AppendUvarint(nil, uint64(len(tok))))
+		h.Write(tok)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:fingerprintSize])
+}
+
+// Fingerprints returns the fingerprints (see [Fingerprint]) of the
+// permission token followed by those of the discharge tokens.
+func (b *Bundle) Fingerprints(key []byte) []string {
+	toks := b.Tokens()
+	ret := make([]string, len(toks))
+	for i, tok := range toks {
+		ret[i] = Fingerprint(key, tok)
+	}
+	return ret
+}
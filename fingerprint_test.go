@@ -0,0 +1,34 @@
+package macaroon
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestFingerprint(t *testing.T) {
+	var (
+		key  = []byte("fingerprint key")
+		tok1 = []byte("token 1")
+		tok2 = []byte("token 2")
+		tok3 = []byte("token 3")
+	)
+
+	fp := Fingerprint(key, tok1)
+	assert.Equal(t, 2*fingerprintSize, len(fp))
+	assert.Equal(t, fp, Fingerprint(key, tok1))
+	assert.NotEqual(t, fp, Fingerprint(key, tok2))
+	assert.NotEqual(t, fp, Fingerprint([]byte("other key"), tok1))
+
+	b := &Bundle{Permission: tok1, Discharges: [][]byte{tok2, tok3}}
+	assert.Equal(t, []string{fp, Fingerprint(key, tok2), Fingerprint(key, tok3)}, b.Fingerprints(key))
+
+	// discharge order doesn't matter...
+	bfp := b.Fingerprint(key)
+	assert.Equal(t, bfp, (&Bundle{Permission: tok1, Discharges: [][]byte{tok3, tok2}}).Fingerprint(key))
+
+	// ...but the tokens do
+	assert.NotEqual(t, bfp, fp)
+	assert.NotEqual(t, bfp, (&Bundle{Permission: tok1, Discharges: [][]byte{tok2}}).Fingerprint(key))
+	assert.NotEqual(t, bfp, (&Bundle{Permission: tok2, Discharges: [][]byte{tok1, tok3}}).Fingerprint(key))
+}
@@ -0,0 +1,285 @@
+package macaroon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FlatCaveat is a caveat in the flat JSON projection of caveat sets (see
+// [EncodeFlatJSON]). Attrs maps the dotted paths of the caveat's JSON fields
+// (e.g. "ifs.0.body.id") to their values, which are JSON strings, numbers,
+// booleans or nulls, or empty arrays or objects. The value of a caveat whose
+// JSON encoding isn't an object or array is stored under the empty path.
+type FlatCaveat struct {
+	Type  string         `json:"type"`
+	Attrs map[string]any `json:"attrs"`
+}
+
+// EncodeFlatJSON encodes a caveat set in a flat JSON projection meant for
+// infrastructure-as-code tools, which declare token policies and diff them in
+// plans: an array of [FlatCaveat]s, with each caveat's fields flattened into
+// a single level of attributes. The projection is stable: it is derived from
+// the caveats' JSON encodings, and keys are sorted.
+//
+// Caveats carrying binary data (e.g. third-party caveats), which can't be
+// meaningfully declared or diffed, are rejected, as are caveats whose JSON
+// field names contain dots.
+func EncodeFlatJSON(cs *CaveatSet) ([]byte, error) {
+	fcavs := make([]FlatCaveat, 0, len(cs.Caveats))
+	for _, cav := range cs.Caveats {
+		fcav, err := flattenCaveat(cav)
+		if err != nil {
+			return nil, err
+		}
+		fcavs = append(fcavs, fcav)
+	}
+
+	return json.Marshal(fcavs)
+}
+
+// DecodeFlatJSON decodes a caveat set encoded with [EncodeFlatJSON]. Decoding
+// is strict: unknown caveat types and attributes are rejected, as are
+// documents that wouldn't be re-encoded identically (e.g. with numbers
+// written differently), so a declared policy always means what it says.
+func DecodeFlatJSON(buf []byte) (*CaveatSet, error) {
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	dec.DisallowUnknownFields()
+
+	var fcavs []FlatCaveat
+	if err := dec.Decode(&fcavs); err != nil {
+		return nil, fmt.Errorf("flat json: %w", err)
+	}
+
+	cs := NewCaveatSet()
+	for i, fcav := range fcavs {
+		cav, err := unflattenCaveat(fcav)
+		if err != nil {
+			return nil, fmt.Errorf("flat json: caveat %d: %w", i, err)
+		}
+		cs.Caveats = append(cs.Caveats, cav)
+	}
+
+	return cs, nil
+}
+
+func flattenCaveat(cav Caveat) (FlatCaveat, error) {
+	name := caveatTypeToString(cav.CaveatType())
+	if _, ok := s2t[name]; !ok {
+		return FlatCaveat{}, fmt.Errorf("flat json: unregistered caveat type %d", cav.CaveatType())
+	}
+
+	if hasBinary(reflect.ValueOf(cav)) {
+		return FlatCaveat{}, fmt.Errorf("flat json: %s caveat carries binary data", name)
+	}
+
+	body, err := json.Marshal(cav)
+	if err != nil {
+		return FlatCaveat{}, fmt.Errorf("flat json: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var tree any
+	if err := dec.Decode(&tree); err != nil {
+		return FlatCaveat{}, fmt.Errorf("flat json: %w", err)
+	}
+
+	fcav := FlatCaveat{Type: name, Attrs: map[string]any{}}
+	if err := flatten("", tree, fcav.Attrs); err != nil {
+		return FlatCaveat{}, fmt.Errorf("flat json: %s: %w", name, err)
+	}
+
+	return fcav, nil
+}
+
+func flatten(path string, v any, attrs map[string]any) error {
+	join := func(k string) string {
+		if path == "" {
+			return k
+		}
+		return path + "." + k
+	}
+
+	switch tv := v.(type) {
+	case map[string]any:
+		if len(tv) == 0 && path != "" {
+			attrs[path] = tv
+		}
+		for k, e := range tv {
+			if k == "" || strings.Contains(k, ".") {
+				return fmt.Errorf("unflattenable key %q", k)
+			}
+			if err := flatten(join(k), e, attrs); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if len(tv) == 0 {
+			attrs[path] = tv
+		}
+		for i, e := range tv {
+			if err := flatten(join(strconv.Itoa(i)), e, attrs); err != nil {
+				return err
+			}
+		}
+	default:
+		attrs[path] = tv
+	}
+
+	return nil
+}
+
+func unflattenCaveat(fcav FlatCaveat) (Caveat, error) {
+	t := caveatTypeFromString(fcav.Type)
+	cav, err := typeToCaveat(t)
+	if err != nil {
+		return nil, fmt.Errorf("bad caveat type: %s", fcav.Type)
+	}
+
+	tree, err := unflatten(fcav.Attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cav); err != nil {
+		return nil, fmt.Errorf("%s: %w", fcav.Type, err)
+	}
+
+	if cav, err = migrate(cav); err != nil {
+		return nil, err
+	}
+
+	// check that nothing was lost or changed, e.g. by defaults or by
+	// fields json.Decoder ignores
+	roundTrip, err := flattenCaveat(cav)
+	if err != nil {
+		return nil, err
+	}
+
+	want, _ := json.Marshal(FlatCaveat{Type: fcav.Type, Attrs: fcav.Attrs})
+	got, _ := json.Marshal(roundTrip)
+	if !bytes.Equal(want, got) {
+		return nil, fmt.Errorf("%s: attributes don't round-trip: have %s, want %s", fcav.Type, got, want)
+	}
+
+	return cav, nil
+}
+
+// unflatten rebuilds the JSON tree from flattened attributes. Objects whose
+// keys are exactly 0..n-1 are arrays.
+func unflatten(attrs map[string]any) (any, error) {
+	if v, ok := attrs[""]; ok {
+		if len(attrs) != 1 {
+			return nil, fmt.Errorf("attribute with empty path alongside others")
+		}
+		return v, nil
+	}
+
+	root := map[string]any{}
+
+	paths := make([]string, 0, len(attrs))
+	for path := range attrs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		var (
+			node  = root
+			parts = strings.Split(path, ".")
+		)
+
+		for _, part := range parts[:len(parts)-1] {
+			switch child := node[part].(type) {
+			case nil:
+				next := map[string]any{}
+				node[part] = next
+				node = next
+			case map[string]any:
+				node = child
+			default:
+				return nil, fmt.Errorf("conflicting attribute %s", path)
+			}
+		}
+
+		last := parts[len(parts)-1]
+		if _, dup := node[last]; dup {
+			return nil, fmt.Errorf("conflicting attribute %s", path)
+		}
+		node[last] = attrs[path]
+	}
+
+	return arrays(root), nil
+}
+
+func arrays(v any) any {
+	m, ok := v.(map[string]any)
+	if !ok || len(m) == 0 {
+		return v
+	}
+
+	for k, e := range m {
+		m[k] = arrays(e)
+	}
+
+	arr := make([]any, len(m))
+	for k, e := range m {
+		i, err := strconv.Atoi(k)
+		if err != nil || i < 0 || i >= len(m) || strconv.Itoa(i) != k {
+			return m
+		}
+		arr[i] = e
+	}
+
+	return arr
+}
+
+// hasBinary reports whether v contains any exported byte slices or arrays.
+func hasBinary(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return !v.IsNil() && hasBinary(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return true
+		}
+		for i := 0; i < v.Len(); i++ {
+			if hasBinary(v.Index(i)) {
+				return true
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if hasBinary(iter.Value()) {
+				return true
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if !f.IsExported() || f.Tag.Get("json") == "-" {
+				continue
+			}
+			if hasBinary(v.Field(i)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,52 @@
+package macaroon
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestFlatJSON(t *testing.T) {
+	cs := NewCaveatSet(
+		cavParent(ActionRead|ActionWrite, 123),
+		&ValidityWindow{NotBefore: 1700000000, NotAfter: 1700003600},
+		&IfPresent{Ifs: NewCaveatSet(cavChild(ActionRead, 1<<62)), Else: ActionRead},
+		&VerifierPin{Hostnames: []string{"mgmt1", "mgmt2"}},
+	)
+
+	buf, err := EncodeFlatJSON(cs)
+	assert.NoError(t, err)
+	assert.Equal(t, `[`+
+		`{"type":"ParentResource","attrs":{"ID":123,"Permission":"rw"}},`+
+		`{"type":"ValidityWindow","attrs":{"not_after":1700003600,"not_before":1700000000}},`+
+		`{"type":"IfPresent","attrs":{"else":"r","ifs.0.body.ID":4611686018427387904,"ifs.0.body.Permission":"r","ifs.0.type":"ChildResource"}},`+
+		`{"type":"VerifierPin","attrs":{"hostnames.0":"mgmt1","hostnames.1":"mgmt2"}}`+
+		`]`, string(buf))
+
+	decoded, err := DecodeFlatJSON(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, cs.Caveats, decoded.Caveats)
+
+	buf2, err := EncodeFlatJSON(decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, string(buf), string(buf2))
+
+	// binary data can't be projected
+	_, err = EncodeFlatJSON(NewCaveatSet(&BindToParentToken{1, 2, 3}))
+	assert.Error(t, err)
+	_, err = EncodeFlatJSON(NewCaveatSet(&IfPresent{Ifs: NewCaveatSet(NewChannelBinding([]byte("x")))}))
+	assert.Error(t, err)
+
+	for name, doc := range map[string]string{
+		"unknown type":      `[{"type":"Bogus","attrs":{}}]`,
+		"unknown attribute": `[{"type":"ValidityWindow","attrs":{"not_after":1,"not_before":0,"bogus":1}}]`,
+		"unknown field":     `[{"type":"ValidityWindow","attrs":{},"bogus":1}]`,
+		"missing attribute": `[{"type":"ValidityWindow","attrs":{"not_after":1}}]`,
+		"non-canonical":     `[{"type":"ValidityWindow","attrs":{"not_after":1.0,"not_before":0}}]`,
+		"conflict":          `[{"type":"VerifierPin","attrs":{"hostnames":"a","hostnames.0":"b"}}]`,
+		"nested unknown":    `[{"type":"IfPresent","attrs":{"else":"r","ifs.0.body.ID":1,"ifs.0.body.Permission":"r","ifs.0.body.Bogus":1,"ifs.0.type":"ChildResource"}}]`,
+	} {
+		_, err := DecodeFlatJSON([]byte(doc))
+		assert.Error(t, err, name)
+	}
+}
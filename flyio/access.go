@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/superfly/macaroon"
+	"github.com/superfly/macaroon/access"
 )
 
 type Access struct {
@@ -13,11 +14,16 @@ type Access struct {
 	Action         macaroon.Action `json:"action"`
 	Feature        *string         `json:"feature"`
 	Volume         *string         `json:"volume"`
+	VolumeSnapshot *string         `json:"volume_snapshot"`
 	Machine        *string         `json:"machine"`
 	MachineFeature *string         `json:"machine_feature"`
 	Mutation       *string         `json:"mutation"`
 	SourceMachine  *string         `json:"sourceMachine"`
 	Cluster        *string         `json:"cluster"`
+	Database       *string         `json:"database"`
+	DatabaseRole   *string         `json:"database_role"`
+	Network        *string         `json:"network"`
+	LiteFSCluster  *string         `json:"litefs_cluster"`
 }
 
 func (a *Access) GetAction() macaroon.Action {
@@ -28,39 +34,99 @@ func (a *Access) Now() time.Time {
 	return time.Now()
 }
 
-// validate checks that the Access has sensible values set. This consists of
-// ensuring that parent-resources are specified when child-resources are
-// present (e.g. machine requires app requires org) and ensuring that multiple
-// child resources aren't specified for a single parent resource (e.g. machine
-// and volume are mutually exclusive).
-//
-// This ensure that a Access represents a single action taken on a single object.
-func (f *Access) Validate() error {
-	// root-level resources = org
-	if f.OrgID == 0 {
-		return fmt.Errorf("%w org", macaroon.ErrResourceUnspecified)
-	}
+type accessKey struct{}
+
+// accessFrom finds the flyio Access in a (see [access.As]). Tokens may carry
+// dozens of resource caveats, each of which needs it, so it's memoized for
+// the validation call.
+func accessFrom(vc *macaroon.ValidationContext, a macaroon.Access) (*Access, bool) {
+	f := macaroon.Memoize(vc, a, accessKey{}, func() *Access {
+		f, _ := access.As[*Access](a)
+		return f
+	})
+	return f, f != nil
+}
 
-	// org-level resources = apps, features
-	if f.AppID != nil && f.Feature != nil {
-		return fmt.Errorf("%w: app, feature", macaroon.ErrResourcesMutuallyExclusive)
-	}
+// AccessRules are the resource-hierarchy rules enforced by
+// [Access.Validate]. New kinds of resources added to Access should be
+// registered here, along with the rules relating them to other resources.
+var AccessRules = new(macaroon.ResourceRules[*Access])
 
-	// app-level resources = machines, volumes
-	if f.Machine != nil || f.Volume != nil {
-		if f.AppID == nil {
-			return fmt.Errorf("%w app", macaroon.ErrResourceUnspecified)
-		}
+// StrictFeatureActions makes [Access.Validate] reject accesses to known
+// features with actions outside the feature's catalogued actions (see
+// [OrgFeature.Actions]). It's off by default, since accesses that were valid
+// before the catalog existed would otherwise start failing validation. It
+// should only be set during initialization.
+var StrictFeatureActions bool
 
-		if f.Machine != nil && f.Volume != nil {
-			return fmt.Errorf("%w: volume, machine", macaroon.ErrResourcesMutuallyExclusive)
-		}
-	}
+func init() {
+	AccessRules.
+		Resource("org", func(a *Access) bool { return a.OrgID != 0 }).
+		Resource("app", func(a *Access) bool { return a.AppID != nil }).
+		Resource("feature", func(a *Access) bool { return a.Feature != nil }).
+		Resource("network", func(a *Access) bool { return a.Network != nil }).
+		Resource("machine", func(a *Access) bool { return a.Machine != nil }).
+		Resource("machine feature", func(a *Access) bool { return a.MachineFeature != nil }).
+		Resource("volume", func(a *Access) bool { return a.Volume != nil }).
+		// snapshot actions are only meaningful on volumes
+		Resource("volume snapshot", func(a *Access) bool { return a.VolumeSnapshot != nil || a.Action&ActionSnapshotAll != 0 }).
+		Resource("cluster", func(a *Access) bool { return a.Cluster != nil }).
+		Resource("database", func(a *Access) bool { return a.Database != nil }).
+		Resource("database role", func(a *Access) bool { return a.DatabaseRole != nil })
 
-	// machine feature requires machine
-	if f.MachineFeature != nil && f.Machine == nil {
-		return fmt.Errorf("%w machine", macaroon.ErrResourceUnspecified)
-	}
+	AccessRules.
+		// root-level resources = org
+		Required("org").
+		// org-level resources = apps, features, networks
+		Exclusive("app", "feature").
+		Exclusive("network", "app", "feature").
+		// app-level resources = machines, volumes
+		Requires("machine", "app").
+		Requires("volume", "app").
+		Exclusive("volume", "machine").
+		// snapshots belong to volumes
+		Requires("volume snapshot", "volume").
+		Requires("machine feature", "machine").
+		// known features only support their catalogued actions, if strict
+		Check(func(a *Access) error {
+			if !StrictFeatureActions || a.Feature == nil || !OrgFeature(*a.Feature).Valid() {
+				return nil
+			}
+			if err := OrgFeature(*a.Feature).checkAction(a.Action); err != nil {
+				return fmt.Errorf("%w: %s", macaroon.ErrInvalidAccess, err)
+			}
+			return nil
+		}).
+		// mutations are namespaced identifiers, not patterns
+		Check(func(a *Access) error {
+			if a.Mutation == nil {
+				return nil
+			}
+			if err := validateMutationName(*a.Mutation); err != nil {
+				return fmt.Errorf("%w: %s", macaroon.ErrInvalidAccess, err)
+			}
+			return nil
+		}).
+		// cluster-level resources = databases, database roles
+		Requires("database", "cluster").
+		Requires("database role", "cluster")
 
-	return nil
+	// caveats for exclusive resources can't be combined, or no access could
+	// satisfy them all
+	macaroon.RegisterIncompatibleCaveatTypes(CavApps, CavFeatureSet, "apps and features are exclusive; use IfPresent")
+	macaroon.RegisterIncompatibleCaveatTypes(CavNetworks, CavApps, "networks and apps are exclusive; use IfPresent")
+	macaroon.RegisterIncompatibleCaveatTypes(CavNetworks, CavFeatureSet, "networks and features are exclusive; use IfPresent")
+	macaroon.RegisterIncompatibleCaveatTypes(CavVolumes, CavMachines, "volumes and machines are exclusive; use IfPresent")
+}
+
+// Validate checks that the Access has sensible values set, according to
+// [AccessRules]. This consists of ensuring that parent-resources are
+// specified when child-resources are present (e.g. machine requires app
+// requires org) and ensuring that multiple child resources aren't specified
+// for a single parent resource (e.g. machine and volume are mutually
+// exclusive).
+//
+// This ensure that a Access represents a single action taken on a single object.
+func (f *Access) Validate() error {
+	return AccessRules.Validate(f)
 }
@@ -0,0 +1,37 @@
+package flyio
+
+import "github.com/superfly/macaroon"
+
+// Volume snapshot actions. These are distinct from the generic actions on
+// volumes, so that tokens for backup automation can be allowed to snapshot
+// volumes without being allowed to write to or delete them.
+//
+// Grants of macaroon.ActionAll predate these actions and don't include them.
+// Tokens needing snapshot access must be granted the snapshot actions
+// explicitly (or macaroon.ActionAllCurrentAndFuture, "*").
+const (
+	ActionSnapshotCreate macaroon.Action = 1 << (iota + 5)
+	ActionSnapshotRestore
+	ActionSnapshotDelete
+
+	ActionSnapshotAll = ActionSnapshotCreate | ActionSnapshotRestore | ActionSnapshotDelete
+)
+
+func init() {
+	macaroon.RegisterAction("snapshot-create", 's', ActionSnapshotCreate)
+	macaroon.RegisterAction("snapshot-restore", 'R', ActionSnapshotRestore)
+	macaroon.RegisterAction("snapshot-delete", 'x', ActionSnapshotDelete)
+}
+
+// ActionUse indicates using an organization-level feature without managing
+// it, e.g. running builds on the organization's remote builder. It's only
+// meaningful for features whose catalog entry includes it (see
+// [OrgFeature.Actions]).
+//
+// Like the snapshot actions, ActionUse isn't included in grants of
+// macaroon.ActionAll.
+const ActionUse macaroon.Action = 1 << 8
+
+func init() {
+	macaroon.RegisterAction("use", 'u', ActionUse)
+}
@@ -20,6 +20,13 @@ const (
 	CavMachineFeatureSet   = 14
 	CavFromMachineSource   = 15
 	CavClusters            = 16
+	CavDatabases           = 17
+	CavDatabaseRoles       = 18
+	CavNetworks            = 22
+	CavLiteFSClusters      = 23
+	CavResourcePath        = 35
+	CavOrgRole             = 44
+	CavRequireOrgRole      = 45
 )
 
 type notAttestation struct{}
@@ -40,7 +47,11 @@ func (s *FromMachine) CaveatType() macaroon.CaveatType {
 }
 
 func (s *FromMachine) Prohibits(a macaroon.Access) error {
-	f, isFlyioAccess := a.(*Access)
+	return s.ProhibitsWithContext(nil, a)
+}
+
+func (s *FromMachine) ProhibitsWithContext(vc *macaroon.ValidationContext, a macaroon.Access) error {
+	f, isFlyioAccess := accessFrom(vc, a)
 
 	switch {
 	case !isFlyioAccess:
@@ -63,6 +74,7 @@ type Organization struct {
 
 func init() {
 	macaroon.RegisterCaveatType("Organization", CavOrganization, &Organization{})
+	macaroon.RegisterSingletonCaveatType(CavOrganization)
 }
 
 func (c *Organization) CaveatType() macaroon.CaveatType {
@@ -70,7 +82,11 @@ func (c *Organization) CaveatType() macaroon.CaveatType {
 }
 
 func (c *Organization) Prohibits(a macaroon.Access) error {
-	f, isFlyioAccess := a.(*Access)
+	return c.ProhibitsWithContext(nil, a)
+}
+
+func (c *Organization) ProhibitsWithContext(vc *macaroon.ValidationContext, a macaroon.Access) error {
+	f, isFlyioAccess := accessFrom(vc, a)
 
 	switch {
 	case !isFlyioAccess:
@@ -80,7 +96,7 @@ func (c *Organization) Prohibits(a macaroon.Access) error {
 	case c.ID != f.OrgID:
 		return fmt.Errorf("%w org %d, only %d", macaroon.ErrUnauthorizedForResource, f.OrgID, c.ID)
 	case !f.Action.IsSubsetOf(c.Mask):
-		return fmt.Errorf("%w access %s (%s not allowed)", macaroon.ErrUnauthorizedForAction, f.Action, f.Action.Remove(c.Mask))
+		return &macaroon.ActionError{Requested: f.Action, Permitted: c.Mask, Resource: fmt.Sprintf("org %d", f.OrgID)}
 	default:
 		return nil
 	}
@@ -142,7 +158,11 @@ func (c *Apps) CaveatType() macaroon.CaveatType {
 }
 
 func (c *Apps) Prohibits(a macaroon.Access) error {
-	f, isFlyioAccess := a.(*Access)
+	return c.ProhibitsWithContext(nil, a)
+}
+
+func (c *Apps) ProhibitsWithContext(vc *macaroon.ValidationContext, a macaroon.Access) error {
+	f, isFlyioAccess := accessFrom(vc, a)
 	if !isFlyioAccess {
 		return macaroon.ErrInvalidAccess
 	}
@@ -163,7 +183,11 @@ func (c *Volumes) CaveatType() macaroon.CaveatType {
 }
 
 func (c *Volumes) Prohibits(a macaroon.Access) error {
-	f, isFlyioAccess := a.(*Access)
+	return c.ProhibitsWithContext(nil, a)
+}
+
+func (c *Volumes) ProhibitsWithContext(vc *macaroon.ValidationContext, a macaroon.Access) error {
+	f, isFlyioAccess := accessFrom(vc, a)
 	if !isFlyioAccess {
 		return macaroon.ErrInvalidAccess
 	}
@@ -184,7 +208,11 @@ func (c *Machines) CaveatType() macaroon.CaveatType {
 }
 
 func (c *Machines) Prohibits(a macaroon.Access) error {
-	f, isFlyioAccess := a.(*Access)
+	return c.ProhibitsWithContext(nil, a)
+}
+
+func (c *Machines) ProhibitsWithContext(vc *macaroon.ValidationContext, a macaroon.Access) error {
+	f, isFlyioAccess := accessFrom(vc, a)
 	if !isFlyioAccess {
 		return macaroon.ErrInvalidAccess
 	}
@@ -205,7 +233,11 @@ func (c *MachineFeatureSet) CaveatType() macaroon.CaveatType {
 }
 
 func (c *MachineFeatureSet) Prohibits(a macaroon.Access) error {
-	f, isFlyioAccess := a.(*Access)
+	return c.ProhibitsWithContext(nil, a)
+}
+
+func (c *MachineFeatureSet) ProhibitsWithContext(vc *macaroon.ValidationContext, a macaroon.Access) error {
+	f, isFlyioAccess := accessFrom(vc, a)
 	if !isFlyioAccess {
 		return macaroon.ErrInvalidAccess
 	}
@@ -216,7 +248,8 @@ func (c *MachineFeatureSet) Prohibits(a macaroon.Access) error {
 // as single units. For example, the ability to manage wireguard networks is
 // gated by the "wg" feature, though you could conceptually gate access to them
 // individually with a Networks caveat. The feature name is free-form and more
-// should be addded as it makes sense.
+// should be addded as it makes sense. Known features are listed as [OrgFeature]
+// values.
 type FeatureSet struct {
 	Features       resset.ResourceSet[string] `json:"features"`
 	notAttestation `msgpack:"-" json:"-"`
@@ -231,14 +264,20 @@ func (c *FeatureSet) CaveatType() macaroon.CaveatType {
 }
 
 func (c *FeatureSet) Prohibits(a macaroon.Access) error {
-	f, isFlyioAccess := a.(*Access)
+	return c.ProhibitsWithContext(nil, a)
+}
+
+func (c *FeatureSet) ProhibitsWithContext(vc *macaroon.ValidationContext, a macaroon.Access) error {
+	f, isFlyioAccess := accessFrom(vc, a)
 	if !isFlyioAccess {
 		return macaroon.ErrInvalidAccess
 	}
 	return c.Features.Prohibits(f.Feature, f.Action)
 }
 
-// Mutations is a set of GraphQL mutations allowed by this token.
+// Mutations is a set of GraphQL mutations allowed by this token. Entries may
+// be namespaced mutation names or wildcard patterns like "apps.*". Use
+// [NewMutations] to check entries against the registered mutation catalog.
 type Mutations struct {
 	Mutations      []string `json:"mutations"`
 	notAttestation `msgpack:"-" json:"-"`
@@ -253,7 +292,11 @@ func (c *Mutations) CaveatType() macaroon.CaveatType {
 }
 
 func (c *Mutations) Prohibits(a macaroon.Access) error {
-	f, isFlyioAccess := a.(*Access)
+	return c.ProhibitsWithContext(nil, a)
+}
+
+func (c *Mutations) ProhibitsWithContext(vc *macaroon.ValidationContext, a macaroon.Access) error {
+	f, isFlyioAccess := accessFrom(vc, a)
 	if !isFlyioAccess {
 		return macaroon.ErrInvalidAccess
 	}
@@ -266,10 +309,10 @@ func (c *Mutations) Prohibits(a macaroon.Access) error {
 
 	var found bool
 	for _, mutation := range c.Mutations {
-		if mutation != *f.Mutation {
-			continue
+		if mutationMatches(mutation, *f.Mutation) {
+			found = true
+			break
 		}
-		found = true
 	}
 
 	if !found {
@@ -313,10 +356,199 @@ func (c *Clusters) CaveatType() macaroon.CaveatType {
 }
 
 func (c *Clusters) Prohibits(a macaroon.Access) error {
-	f, isFlyioAccess := a.(*Access)
+	return c.ProhibitsWithContext(nil, a)
+}
+
+func (c *Clusters) ProhibitsWithContext(vc *macaroon.ValidationContext, a macaroon.Access) error {
+	f, isFlyioAccess := accessFrom(vc, a)
 	if !isFlyioAccess {
 		return macaroon.ErrInvalidAccess
 	}
 
 	return c.Clusters.Prohibits(f.Cluster, f.Action)
 }
+
+// Databases is a set of databases within a cluster, with their RWX access
+// levels. Database names are only meaningful within a cluster, so this is
+// normally used alongside a Clusters caveat.
+type Databases struct {
+	Databases      resset.ResourceSet[string] `json:"databases"`
+	notAttestation `msgpack:"-" json:"-"`
+}
+
+func init() {
+	macaroon.RegisterCaveatType("Databases", CavDatabases, &Databases{})
+}
+
+func (c *Databases) CaveatType() macaroon.CaveatType {
+	return CavDatabases
+}
+
+func (c *Databases) Prohibits(a macaroon.Access) error {
+	return c.ProhibitsWithContext(nil, a)
+}
+
+func (c *Databases) ProhibitsWithContext(vc *macaroon.ValidationContext, a macaroon.Access) error {
+	f, isFlyioAccess := accessFrom(vc, a)
+	if !isFlyioAccess {
+		return macaroon.ErrInvalidAccess
+	}
+
+	return c.Databases.Prohibits(f.Database, f.Action)
+}
+
+// DatabaseRoles is a set of database roles within a cluster that the token
+// may act as, with their RWX access levels. For example, a token confined to
+// a read-only role. Like Databases, it's normally used alongside a Clusters
+// caveat.
+type DatabaseRoles struct {
+	Roles          resset.ResourceSet[string] `json:"roles"`
+	notAttestation `msgpack:"-" json:"-"`
+}
+
+func init() {
+	macaroon.RegisterCaveatType("DatabaseRoles", CavDatabaseRoles, &DatabaseRoles{})
+}
+
+func (c *DatabaseRoles) CaveatType() macaroon.CaveatType {
+	return CavDatabaseRoles
+}
+
+func (c *DatabaseRoles) Prohibits(a macaroon.Access) error {
+	return c.ProhibitsWithContext(nil, a)
+}
+
+func (c *DatabaseRoles) ProhibitsWithContext(vc *macaroon.ValidationContext, a macaroon.Access) error {
+	f, isFlyioAccess := accessFrom(vc, a)
+	if !isFlyioAccess {
+		return macaroon.ErrInvalidAccess
+	}
+
+	return c.Roles.Prohibits(f.DatabaseRole, f.Action)
+}
+
+// Networks is a set of private (6PN) networks within an organization, with
+// their RWX access levels, for confining tokens to specific networks.
+type Networks struct {
+	Networks       resset.ResourceSet[string] `json:"networks"`
+	notAttestation `msgpack:"-" json:"-"`
+}
+
+func init() {
+	macaroon.RegisterCaveatType("Networks", CavNetworks, &Networks{})
+}
+
+func (c *Networks) CaveatType() macaroon.CaveatType {
+	return CavNetworks
+}
+
+func (c *Networks) Prohibits(a macaroon.Access) error {
+	return c.ProhibitsWithContext(nil, a)
+}
+
+func (c *Networks) ProhibitsWithContext(vc *macaroon.ValidationContext, a macaroon.Access) error {
+	f, isFlyioAccess := accessFrom(vc, a)
+	if !isFlyioAccess {
+		return macaroon.ErrInvalidAccess
+	}
+
+	return c.Networks.Prohibits(f.Network, f.Action)
+}
+
+// LiteFSRole is a role within a LiteFS Cloud cluster. Each role includes the
+// access granted by the roles before it.
+type LiteFSRole uint8
+
+const (
+	// LiteFSRoleReplica may read from the cluster.
+	LiteFSRoleReplica LiteFSRole = iota + 1
+
+	// LiteFSRolePrimary may read from and write to the cluster.
+	LiteFSRolePrimary
+
+	// LiteFSRoleAdmin may do anything with the cluster, including creating
+	// and deleting databases.
+	LiteFSRoleAdmin
+)
+
+// Actions returns the actions permitted by the role.
+func (r LiteFSRole) Actions() macaroon.Action {
+	switch r {
+	case LiteFSRoleReplica:
+		return macaroon.ActionRead
+	case LiteFSRolePrimary:
+		return macaroon.ActionRead | macaroon.ActionWrite
+	case LiteFSRoleAdmin:
+		return macaroon.ActionAll
+	default:
+		return macaroon.ActionNone
+	}
+}
+
+func (r LiteFSRole) String() string {
+	switch r {
+	case LiteFSRoleReplica:
+		return "replica"
+	case LiteFSRolePrimary:
+		return "primary"
+	case LiteFSRoleAdmin:
+		return "admin"
+	default:
+		return fmt.Sprintf("LiteFSRole(%d)", uint8(r))
+	}
+}
+
+// LiteFSClusterRole grants a role in a single LiteFS Cloud cluster.
+type LiteFSClusterRole struct {
+	Cluster string     `json:"cluster"`
+	Role    LiteFSRole `json:"role"`
+}
+
+// LiteFSClusters is a set of LiteFS Cloud clusters, with the role granted in
+// each. It's like Clusters, but grants roles rather than RWX access levels.
+// If a cluster is listed more than once, the least privileged role applies.
+type LiteFSClusters struct {
+	Clusters       []LiteFSClusterRole `json:"clusters"`
+	notAttestation `msgpack:"-" json:"-"`
+}
+
+func init() {
+	macaroon.RegisterCaveatType("LiteFSClusters", CavLiteFSClusters, &LiteFSClusters{})
+}
+
+func (c *LiteFSClusters) CaveatType() macaroon.CaveatType {
+	return CavLiteFSClusters
+}
+
+func (c *LiteFSClusters) Prohibits(a macaroon.Access) error {
+	return c.ProhibitsWithContext(nil, a)
+}
+
+func (c *LiteFSClusters) ProhibitsWithContext(vc *macaroon.ValidationContext, a macaroon.Access) error {
+	f, isFlyioAccess := accessFrom(vc, a)
+	switch {
+	case !isFlyioAccess:
+		return macaroon.ErrInvalidAccess
+	case f.LiteFSCluster == nil:
+		return fmt.Errorf("%w litefs cluster", macaroon.ErrResourceUnspecified)
+	}
+
+	var role LiteFSRole
+	for _, cr := range c.Clusters {
+		if cr.Role < LiteFSRoleReplica || cr.Role > LiteFSRoleAdmin {
+			return fmt.Errorf("%w: bad litefs role %s", macaroon.ErrBadCaveat, cr.Role)
+		}
+		if cr.Cluster == *f.LiteFSCluster && (role == 0 || cr.Role < role) {
+			role = cr.Role
+		}
+	}
+
+	switch {
+	case role == 0:
+		return fmt.Errorf("%w litefs cluster %s", macaroon.ErrUnauthorizedForResource, *f.LiteFSCluster)
+	case !f.Action.IsSubsetOf(role.Actions()):
+		return &macaroon.ActionError{Requested: f.Action, Permitted: role.Actions(), Resource: fmt.Sprintf("litefs cluster %s (%s role)", *f.LiteFSCluster, role)}
+	default:
+		return nil
+	}
+}
@@ -2,7 +2,9 @@ package flyio
 
 import (
 	"encoding/json"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/alecthomas/assert/v2"
 	"github.com/superfly/macaroon"
@@ -23,6 +25,10 @@ func TestCaveatSerialization(t *testing.T) {
 		&MachineFeatureSet{Features: resset.New(macaroon.ActionRead, "123")},
 		&FromMachine{ID: "asdf"},
 		&Clusters{Clusters: resset.New(macaroon.ActionRead, "123")},
+		&Databases{Databases: resset.New(macaroon.ActionRead, "123")},
+		&DatabaseRoles{Roles: resset.New(macaroon.ActionRead, "123")},
+		&Networks{Networks: resset.New(macaroon.ActionRead, "123")},
+		&LiteFSClusters{Clusters: []LiteFSClusterRole{{Cluster: "123", Role: LiteFSRolePrimary}}},
 	)
 
 	b, err := json.Marshal(cs)
@@ -39,3 +45,181 @@ func TestCaveatSerialization(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, cs, cs2)
 }
+
+func TestDatabases(t *testing.T) {
+	cs := macaroon.NewCaveatSet(
+		&Clusters{Clusters: resset.New(macaroon.ActionAll, "pg")},
+		&Databases{Databases: resset.New(macaroon.ActionRead|macaroon.ActionWrite, "app")},
+		&DatabaseRoles{Roles: resset.New(macaroon.ActionRead, "readonly")},
+	)
+
+	access := func(action macaroon.Action, db, role *string) *Access {
+		return &Access{OrgID: 1, Action: action, Cluster: ptr("pg"), Database: db, DatabaseRole: role}
+	}
+
+	assert.NoError(t, cs.Validate(access(macaroon.ActionRead, ptr("app"), ptr("readonly"))))
+
+	// role is read-only
+	assert.Error(t, cs.Validate(access(macaroon.ActionWrite, ptr("app"), ptr("readonly"))))
+
+	// other databases and roles
+	assert.Error(t, cs.Validate(access(macaroon.ActionRead, ptr("other"), ptr("readonly"))))
+	assert.Error(t, cs.Validate(access(macaroon.ActionRead, ptr("app"), ptr("admin"))))
+
+	// unspecified database/role
+	assert.Error(t, cs.Validate(access(macaroon.ActionRead, nil, ptr("readonly"))))
+
+	// database requires cluster
+	assert.Error(t, (&Access{OrgID: 1, Database: ptr("app")}).Validate())
+
+	// can be scoped with IfPresent
+	cs = macaroon.NewCaveatSet(
+		&macaroon.IfPresent{
+			Ifs:  macaroon.NewCaveatSet(&Databases{Databases: resset.New(macaroon.ActionRead, "app")}),
+			Else: macaroon.ActionAll,
+		},
+	)
+	assert.NoError(t, cs.Validate(access(macaroon.ActionWrite, nil, nil)))
+	assert.Error(t, cs.Validate(access(macaroon.ActionWrite, ptr("app"), nil)))
+}
+
+func TestNetworks(t *testing.T) {
+	cs := macaroon.NewCaveatSet(&Networks{Networks: resset.New(macaroon.ActionRead, "tenant-a")})
+
+	assert.NoError(t, cs.Validate(&Access{OrgID: 1, Action: macaroon.ActionRead, Network: ptr("tenant-a")}))
+	assert.Error(t, cs.Validate(&Access{OrgID: 1, Action: macaroon.ActionWrite, Network: ptr("tenant-a")}))
+	assert.Error(t, cs.Validate(&Access{OrgID: 1, Action: macaroon.ActionRead, Network: ptr("tenant-b")}))
+	assert.Error(t, cs.Validate(&Access{OrgID: 1, Action: macaroon.ActionRead}))
+
+	assert.Error(t, (&Access{OrgID: 1, AppID: ptr(uint64(1)), Network: ptr("tenant-a")}).Validate())
+}
+
+func TestVolumeSnapshots(t *testing.T) {
+	vol := ptr("vol_1")
+	access := func(action macaroon.Action) *Access {
+		return &Access{OrgID: 1, AppID: ptr(uint64(1)), Volume: vol, Action: action}
+	}
+
+	// backup automation can snapshot, but not delete volumes or snapshots
+	cs := macaroon.NewCaveatSet(&Volumes{Volumes: resset.New(macaroon.ActionRead|ActionSnapshotCreate, "vol_1")})
+	assert.NoError(t, cs.Validate(access(ActionSnapshotCreate)))
+	assert.Error(t, cs.Validate(access(ActionSnapshotDelete)))
+	assert.Error(t, cs.Validate(access(macaroon.ActionDelete)))
+
+	// ActionAll predates snapshot actions
+	cs = macaroon.NewCaveatSet(&Organization{ID: 1, Mask: macaroon.ActionAll})
+	assert.Error(t, cs.Validate(access(ActionSnapshotRestore)))
+	cs = macaroon.NewCaveatSet(&Organization{ID: 1, Mask: macaroon.ActionFromString("*")})
+	assert.NoError(t, cs.Validate(access(ActionSnapshotRestore)))
+
+	assert.Equal(t, "rsRx", (macaroon.ActionRead | ActionSnapshotAll).String())
+	assert.Equal(t, macaroon.ActionRead|ActionSnapshotAll, macaroon.ActionFromString("rsRx"))
+
+	// snapshot actions require a volume
+	assert.Error(t, (&Access{OrgID: 1, AppID: ptr(uint64(1)), Action: ActionSnapshotCreate}).Validate())
+	assert.Error(t, (&Access{OrgID: 1, AppID: ptr(uint64(1)), VolumeSnapshot: ptr("snap_1")}).Validate())
+}
+
+func TestLiteFSClusters(t *testing.T) {
+	cs := macaroon.NewCaveatSet(&LiteFSClusters{Clusters: []LiteFSClusterRole{
+		{Cluster: "primary", Role: LiteFSRolePrimary},
+		{Cluster: "replica", Role: LiteFSRoleReplica},
+		{Cluster: "admin", Role: LiteFSRoleAdmin},
+	}})
+
+	access := func(action macaroon.Action, cluster string) *Access {
+		return &Access{OrgID: 1, Action: action, LiteFSCluster: &cluster}
+	}
+
+	assert.NoError(t, cs.Validate(access(macaroon.ActionRead, "replica")))
+	assert.Error(t, cs.Validate(access(macaroon.ActionWrite, "replica")))
+	assert.NoError(t, cs.Validate(access(macaroon.ActionWrite, "primary")))
+	assert.Error(t, cs.Validate(access(macaroon.ActionDelete, "primary")))
+	assert.NoError(t, cs.Validate(access(macaroon.ActionDelete, "admin")))
+	assert.Error(t, cs.Validate(access(macaroon.ActionRead, "other")))
+	assert.Error(t, cs.Validate(&Access{OrgID: 1, Action: macaroon.ActionRead}))
+
+	// least privileged role wins
+	cs = macaroon.NewCaveatSet(&LiteFSClusters{Clusters: []LiteFSClusterRole{
+		{Cluster: "c", Role: LiteFSRoleAdmin},
+		{Cluster: "c", Role: LiteFSRoleReplica},
+	}})
+	assert.Error(t, cs.Validate(access(macaroon.ActionWrite, "c")))
+
+	// unknown roles are rejected
+	cs = macaroon.NewCaveatSet(&LiteFSClusters{Clusters: []LiteFSClusterRole{{Cluster: "c", Role: 9}}})
+	assert.True(t, errors.Is(cs.Validate(access(macaroon.ActionRead, "c")), macaroon.ErrBadCaveat))
+
+	assert.Equal(t, "primary", LiteFSRolePrimary.String())
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestAccessRules(t *testing.T) {
+	// rules are checked in order, so the first violation is reported
+	err := (&Access{Machine: ptr("m"), Volume: ptr("v")}).Validate()
+	assert.True(t, errors.Is(err, macaroon.ErrResourceUnspecified))
+	assert.Contains(t, err.Error(), "org")
+
+	err = (&Access{OrgID: 1, Machine: ptr("m"), Volume: ptr("v")}).Validate()
+	assert.Contains(t, err.Error(), "app")
+
+	err = (&Access{OrgID: 1, AppID: ptr(uint64(1)), Machine: ptr("m"), Volume: ptr("v")}).Validate()
+	assert.True(t, errors.Is(err, macaroon.ErrResourcesMutuallyExclusive))
+
+	err = (&Access{OrgID: 1, Mutation: ptr("apps.*")}).Validate()
+	assert.True(t, errors.Is(err, macaroon.ErrInvalidAccess))
+}
+
+type userIDPart struct{ id uint64 }
+
+func (p *userIDPart) GetUserID() uint64 { return p.id }
+
+func TestComposedAccess(t *testing.T) {
+	cs := macaroon.NewCaveatSet(&Organization{ID: 1, Mask: macaroon.ActionRead}, &macaroon.Predicate{Expression: "user == 2"})
+
+	base := &Access{OrgID: 1, Action: macaroon.ActionRead}
+	assert.NoError(t, cs.Validate(macaroon.Compose(base, &userIDPart{2})))
+	assert.Error(t, cs.Validate(macaroon.Compose(base, &userIDPart{3})))
+	assert.Error(t, cs.Validate(macaroon.Compose(&Access{Action: macaroon.ActionRead}, &userIDPart{2})))
+}
+
+func TestCaveatRules(t *testing.T) {
+	_, err := macaroon.NewCaveatSetBuilder().
+		Add(&Organization{ID: 1, Mask: macaroon.ActionAll}).
+		Add(&Apps{Apps: resset.New(macaroon.ActionAll, uint64(10))}).
+		Add(&Organization{ID: 1, Mask: macaroon.ActionRead}).
+		Build()
+	assert.True(t, errors.Is(err, macaroon.ErrConflictingCaveats))
+
+	_, err = macaroon.NewCaveatSetBuilder().
+		Add(&Apps{Apps: resset.New(macaroon.ActionAll, uint64(10))}).
+		Add(NewBillingFeatureSet(macaroon.ActionRead)).
+		Build()
+	assert.True(t, errors.Is(err, macaroon.ErrConflictingCaveats))
+
+	// exclusive resources may be combined with IfPresent
+	_, err = macaroon.NewCaveatSetBuilder().
+		Add(&Organization{ID: 1, Mask: macaroon.ActionAll}).
+		Add(&macaroon.IfPresent{
+			Ifs: macaroon.NewCaveatSet(
+				&Apps{Apps: resset.New(macaroon.ActionAll, uint64(10))},
+				NewBillingFeatureSet(macaroon.ActionRead),
+			),
+			Else: macaroon.ActionRead,
+		}).
+		Build()
+	assert.NoError(t, err)
+}
+
+// valueAccess is a comparable Access type that may hold uncomparable values.
+type valueAccess struct{ X any }
+
+func (valueAccess) GetAction() macaroon.Action { return macaroon.ActionRead }
+func (valueAccess) Now() time.Time             { return time.Now() }
+func (valueAccess) Validate() error            { return nil }
+
+func TestUnhashableAccess(t *testing.T) {
+	cs := macaroon.NewCaveatSet(&Organization{ID: 1, Mask: macaroon.ActionRead})
+	assert.True(t, errors.Is(cs.Validate(valueAccess{X: []int{1}}), macaroon.ErrInvalidAccess))
+}
@@ -0,0 +1,148 @@
+package flyio
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/superfly/macaroon"
+	"github.com/superfly/macaroon/resset"
+)
+
+// OrgFeature is the name of an organization-level feature, for use with
+// [FeatureSet] caveats and [Access.Feature].
+type OrgFeature string
+
+const (
+	FeatureWireguard     OrgFeature = "wg"
+	FeatureDomains       OrgFeature = "domain"
+	FeatureSites         OrgFeature = "site"
+	FeatureRemoteBuilder OrgFeature = "builder"
+	FeatureAddOns        OrgFeature = "addon"
+	FeatureChecks        OrgFeature = "checks"
+	FeatureMembership    OrgFeature = "membership"
+	FeatureDeletion      OrgFeature = "deletion"
+
+	// billing features, for accounting automation. Use [NewBillingFeatureSet]
+	// to scope a token to these.
+	FeatureBilling        OrgFeature = "billing"
+	FeatureInvoices       OrgFeature = "invoices"
+	FeaturePaymentMethods OrgFeature = "payment_methods"
+)
+
+// ErrUnknownFeature is returned when creating a [FeatureSet] with a feature
+// that isn't one of the known [OrgFeature] values.
+var ErrUnknownFeature = errors.New("unknown feature")
+
+// ErrUnsupportedFeatureAction is returned when creating a [FeatureSet]
+// granting, or validating an [Access] attempting, an action that isn't
+// meaningful for the feature (see [OrgFeature.Actions]).
+var ErrUnsupportedFeatureAction = errors.New("unsupported feature action")
+
+const actionCRUD = macaroon.ActionRead | macaroon.ActionWrite | macaroon.ActionCreate | macaroon.ActionDelete
+
+var (
+	// knownFeatures is the feature catalog, mapping each feature to the
+	// actions meaningful for it.
+	knownFeatures = map[OrgFeature]macaroon.Action{
+		FeatureWireguard:      macaroon.ActionRead | macaroon.ActionCreate | macaroon.ActionDelete,
+		FeatureDomains:        actionCRUD,
+		FeatureSites:          actionCRUD,
+		FeatureRemoteBuilder:  macaroon.ActionRead | macaroon.ActionCreate | macaroon.ActionDelete | ActionUse,
+		FeatureAddOns:         actionCRUD,
+		FeatureChecks:         actionCRUD,
+		FeatureMembership:     actionCRUD,
+		FeatureDeletion:       macaroon.ActionDelete,
+		FeatureBilling:        macaroon.ActionRead | macaroon.ActionWrite,
+		FeatureInvoices:       macaroon.ActionRead,
+		FeaturePaymentMethods: actionCRUD,
+	}
+
+	// BillingFeatures are the features governing an organization's billing
+	// details, invoices and payment methods.
+	BillingFeatures = []OrgFeature{FeatureBilling, FeatureInvoices, FeaturePaymentMethods}
+)
+
+// Valid returns whether f is a known feature.
+func (f OrgFeature) Valid() bool {
+	_, ok := knownFeatures[f]
+	return ok
+}
+
+// Actions returns the actions meaningful for the feature, e.g. create and
+// delete for wireguard peers, or ActionUse for the remote builder. It returns
+// ActionNone for unknown features.
+func (f OrgFeature) Actions() macaroon.Action {
+	return knownFeatures[f]
+}
+
+// checkAction checks that action is meaningful for the feature. Grants of
+// macaroon.ActionAllCurrentAndFuture ("*") are allowed for any feature.
+func (f OrgFeature) checkAction(action macaroon.Action) error {
+	switch {
+	case !f.Valid():
+		return fmt.Errorf("%w: %s", ErrUnknownFeature, f)
+	case action == macaroon.ActionAllCurrentAndFuture:
+		return nil
+	case action.Remove(f.Actions()) != macaroon.ActionNone:
+		return fmt.Errorf("%w: %s on %s (want subset of %s)", ErrUnsupportedFeatureAction, action.Remove(f.Actions()), f, f.Actions())
+	default:
+		return nil
+	}
+}
+
+// Ptr returns a pointer to the feature's name, for use with
+// [Access.Feature].
+func (f OrgFeature) Ptr() *string {
+	s := string(f)
+	return &s
+}
+
+// NewFeatureSet creates a [FeatureSet] granting action on each of the
+// features. Unlike constructing a FeatureSet directly, unknown features and
+// actions that aren't meaningful for a feature are rejected, so typos don't
+// produce unusable tokens.
+func NewFeatureSet(action macaroon.Action, features ...OrgFeature) (*FeatureSet, error) {
+	grants := make(map[OrgFeature]macaroon.Action, len(features))
+	for _, f := range features {
+		grants[f] = action
+	}
+
+	return NewFeatureGrants(grants)
+}
+
+// NewFeatureGrants creates a [FeatureSet] granting each feature its own
+// actions, e.g. create and delete on wireguard but only use on the remote
+// builder. Grants are checked against the feature catalog like with
+// [NewFeatureSet].
+func NewFeatureGrants(grants map[OrgFeature]macaroon.Action) (*FeatureSet, error) {
+	features := make(resset.ResourceSet[string], len(grants))
+	for f, action := range grants {
+		if err := f.checkAction(action); err != nil {
+			return nil, err
+		}
+		features[string(f)] = action
+	}
+
+	return &FeatureSet{Features: features}, nil
+}
+
+// NewBillingFeatureSet creates a [FeatureSet] granting action on the billing
+// features, limited to the actions meaningful for each. E.g. granting
+// macaroon.ActionAll allows writing billing details but only reading
+// invoices.
+func NewBillingFeatureSet(action macaroon.Action) *FeatureSet {
+	grants := make(map[OrgFeature]macaroon.Action, len(BillingFeatures))
+	for _, f := range BillingFeatures {
+		if action == macaroon.ActionAllCurrentAndFuture {
+			grants[f] = action
+		} else {
+			grants[f] = action & f.Actions()
+		}
+	}
+
+	fs, err := NewFeatureGrants(grants)
+	if err != nil {
+		panic(err)
+	}
+	return fs
+}
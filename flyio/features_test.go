@@ -0,0 +1,81 @@
+package flyio
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+	"github.com/superfly/macaroon/resset"
+)
+
+func TestFeatures(t *testing.T) {
+	cs := macaroon.NewCaveatSet(
+		&Organization{ID: 1, Mask: macaroon.ActionAll},
+		NewBillingFeatureSet(macaroon.ActionRead),
+	)
+
+	access := func(action macaroon.Action, feature OrgFeature) *Access {
+		return &Access{OrgID: 1, Action: action, Feature: feature.Ptr()}
+	}
+
+	assert.NoError(t, cs.Validate(access(macaroon.ActionRead, FeatureInvoices)))
+	assert.NoError(t, cs.Validate(access(macaroon.ActionRead, FeaturePaymentMethods)))
+	assert.Error(t, cs.Validate(access(macaroon.ActionWrite, FeatureBilling)))
+	assert.Error(t, cs.Validate(access(macaroon.ActionRead, FeatureWireguard)))
+	assert.Error(t, cs.Validate(&Access{OrgID: 1, Action: macaroon.ActionRead}))
+
+	_, err := NewFeatureSet(macaroon.ActionRead, FeatureWireguard, "invoice")
+	assert.True(t, errors.Is(err, ErrUnknownFeature))
+	assert.False(t, OrgFeature("invoice").Valid())
+	assert.True(t, FeatureInvoices.Valid())
+}
+
+func TestFeatureActions(t *testing.T) {
+	fs, err := NewFeatureGrants(map[OrgFeature]macaroon.Action{
+		FeatureWireguard:     macaroon.ActionCreate | macaroon.ActionDelete,
+		FeatureRemoteBuilder: ActionUse,
+	})
+	assert.NoError(t, err)
+
+	cs := macaroon.NewCaveatSet(&Organization{ID: 1, Mask: macaroon.ActionAllCurrentAndFuture}, fs)
+
+	access := func(action macaroon.Action, feature OrgFeature) *Access {
+		return &Access{OrgID: 1, Action: action, Feature: feature.Ptr()}
+	}
+
+	assert.NoError(t, cs.Validate(access(macaroon.ActionCreate, FeatureWireguard)))
+	assert.NoError(t, cs.Validate(access(ActionUse, FeatureRemoteBuilder)))
+	assert.Error(t, cs.Validate(access(macaroon.ActionRead, FeatureWireguard)))
+	assert.Error(t, cs.Validate(access(macaroon.ActionCreate, FeatureRemoteBuilder)))
+
+	// accesses attempting actions the feature doesn't support are only
+	// invalid with StrictFeatureActions
+	assert.NoError(t, access(ActionUse, FeatureWireguard).Validate())
+	assert.NoError(t, access(macaroon.ActionWrite, FeatureWireguard).Validate())
+
+	StrictFeatureActions = true
+	defer func() { StrictFeatureActions = false }()
+
+	assert.True(t, errors.Is(access(ActionUse, FeatureWireguard).Validate(), macaroon.ErrInvalidAccess))
+	assert.True(t, errors.Is(access(macaroon.ActionWrite, FeatureWireguard).Validate(), macaroon.ErrInvalidAccess))
+	assert.NoError(t, access(macaroon.ActionCreate, FeatureWireguard).Validate())
+	assert.NoError(t, (&Access{OrgID: 1, Action: ActionUse, Feature: ptr("unlisted")}).Validate())
+
+	_, err = NewFeatureGrants(map[OrgFeature]macaroon.Action{FeatureRemoteBuilder: macaroon.ActionWrite})
+	assert.True(t, errors.Is(err, ErrUnsupportedFeatureAction))
+	_, err = NewFeatureSet(macaroon.ActionAll, FeatureWireguard)
+	assert.True(t, errors.Is(err, ErrUnsupportedFeatureAction))
+	_, err = NewFeatureSet(macaroon.ActionAllCurrentAndFuture, FeatureWireguard)
+	assert.NoError(t, err)
+
+	assert.Equal(t, macaroon.ActionDelete, FeatureDeletion.Actions())
+	assert.Equal(t, macaroon.ActionNone, OrgFeature("invoice").Actions())
+
+	// billing grants are limited to each feature's actions
+	assert.Equal(t, resset.ResourceSet[string]{
+		"billing":         macaroon.ActionRead | macaroon.ActionWrite,
+		"invoices":        macaroon.ActionRead,
+		"payment_methods": actionCRUD,
+	}, NewBillingFeatureSet(macaroon.ActionAll).Features)
+}
@@ -0,0 +1,58 @@
+package flyio
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/superfly/macaroon"
+)
+
+var (
+	// ErrUnverifiedCaveats is returned when extracting an identity from a
+	// CaveatSet that didn't come from a successful [macaroon.Macaroon.Verify].
+	ErrUnverifiedCaveats = errors.New("caveats not verified")
+
+	// ErrNoIdentity is returned when a CaveatSet doesn't identify a user or
+	// machine.
+	ErrNoIdentity = errors.New("no identity")
+
+	// ErrConflictingIdentity is returned when a CaveatSet identifies more
+	// than one user or machine.
+	ErrConflictingIdentity = errors.New("conflicting identities")
+)
+
+// AuthenticatedUser returns the user ID asserted by the IsUser caveats in
+// a verified CaveatSet. Multiple IsUser caveats are allowed as long as they
+// agree.
+func AuthenticatedUser(cs *macaroon.CaveatSet) (uint64, error) {
+	return identity(cs, "user", func(c *IsUser) uint64 { return c.ID })
+}
+
+// SourceMachine returns the machine ID asserted by the FromMachine caveats
+// in a verified CaveatSet. Multiple FromMachine caveats are allowed as long
+// as they agree.
+func SourceMachine(cs *macaroon.CaveatSet) (string, error) {
+	return identity(cs, "machine", func(c *FromMachine) string { return c.ID })
+}
+
+func identity[C macaroon.Caveat, T comparable](cs *macaroon.CaveatSet, kind string, id func(C) T) (T, error) {
+	var zero T
+
+	if cs == nil || !cs.Verified() {
+		return zero, ErrUnverifiedCaveats
+	}
+
+	cavs := macaroon.GetCaveats[C](cs)
+	if len(cavs) == 0 {
+		return zero, fmt.Errorf("%w: %s", ErrNoIdentity, kind)
+	}
+
+	ret := id(cavs[0])
+	for _, cav := range cavs[1:] {
+		if other := id(cav); other != ret {
+			return zero, fmt.Errorf("%w: %s %v and %v", ErrConflictingIdentity, kind, ret, other)
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,46 @@
+package flyio
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+)
+
+func TestIdentity(t *testing.T) {
+	key := macaroon.NewSigningKey()
+
+	verified := func(cavs ...macaroon.Caveat) *macaroon.CaveatSet {
+		t.Helper()
+		m, err := macaroon.New([]byte("kid"), LocationPermission, key)
+		assert.NoError(t, err)
+		assert.NoError(t, m.Add(cavs...))
+		cs, err := m.Verify(key, nil, nil)
+		assert.NoError(t, err)
+		return cs
+	}
+
+	cs := verified(&IsUser{ID: 123}, &FromMachine{ID: "m1"}, &IsUser{ID: 123})
+
+	user, err := AuthenticatedUser(cs)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(123), user)
+
+	machine, err := SourceMachine(cs)
+	assert.NoError(t, err)
+	assert.Equal(t, "m1", machine)
+
+	// unverified caveats aren't trusted
+	_, err = AuthenticatedUser(macaroon.NewCaveatSet(&IsUser{ID: 123}))
+	assert.True(t, errors.Is(err, ErrUnverifiedCaveats))
+
+	_, err = SourceMachine(verified(&IsUser{ID: 123}))
+	assert.True(t, errors.Is(err, ErrNoIdentity))
+
+	_, err = AuthenticatedUser(verified(&IsUser{ID: 123}, &IsUser{ID: 234}))
+	assert.True(t, errors.Is(err, ErrConflictingIdentity))
+
+	_, err = SourceMachine(verified(&FromMachine{ID: "m1"}, &FromMachine{ID: "m2"}))
+	assert.True(t, errors.Is(err, ErrConflictingIdentity))
+}
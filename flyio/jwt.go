@@ -0,0 +1,33 @@
+package flyio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/superfly/macaroon"
+	"github.com/superfly/macaroon/jwt"
+)
+
+// JWT claim mappings for fly.io caveats. See [jwt.FromCaveats] and
+// [jwt.ToCaveats].
+func init() {
+	jwt.RegisterClaimMapper(CavIsUser, func(c macaroon.Caveat, claims *jwt.Claims) error {
+		return claims.SetSubject("user:" + strconv.FormatUint(c.(*IsUser).ID, 10))
+	})
+
+	jwt.RegisterClaimTranslator("sub", func(v any) ([]macaroon.Caveat, error) {
+		sub, _ := v.(string)
+		id, err := strconv.ParseUint(strings.TrimPrefix(sub, "user:"), 10, 64)
+		if err != nil || !strings.HasPrefix(sub, "user:") {
+			return nil, fmt.Errorf("bad subject %q", sub)
+		}
+		return []macaroon.Caveat{&IsUser{ID: id}}, nil
+	})
+
+	jwt.RegisterClaimMapper(CavOrganization, func(c macaroon.Caveat, claims *jwt.Claims) error {
+		org := c.(*Organization)
+		claims.AddScope(fmt.Sprintf("org:%d:%s", org.ID, org.Mask))
+		return nil
+	})
+}
@@ -0,0 +1,40 @@
+package flyio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+	"github.com/superfly/macaroon/jwt"
+)
+
+func TestJWTClaims(t *testing.T) {
+	key := macaroon.NewSigningKey()
+	m, err := macaroon.New([]byte("kid"), LocationPermission, key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(&IsUser{ID: 123}, &Organization{ID: 1, Mask: macaroon.ActionRead}))
+	cs, err := m.Verify(key, nil, nil)
+	assert.NoError(t, err)
+
+	claims, err := jwt.ClaimsFromCaveats(cs, jwt.Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, "user:123", claims.Subject)
+	assert.Equal(t, []string{"org:1:r"}, claims.Scopes)
+}
+
+func TestJWTIngest(t *testing.T) {
+	key := jwt.HS256("secret")
+	tok, err := jwt.Sign(key, "", &jwt.Claims{Subject: "user:123", Expiry: time.Now().Add(time.Hour).Unix()})
+	assert.NoError(t, err)
+
+	cavs, _, err := jwt.ToCaveats(tok, func(jwt.Header) (jwt.Verifier, error) { return key, nil }, jwt.IngestOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(cavs))
+	assert.Equal[macaroon.Caveat](t, &IsUser{ID: 123}, cavs[1])
+
+	tok, err = jwt.Sign(key, "", &jwt.Claims{Subject: "alice", Expiry: time.Now().Add(time.Hour).Unix()})
+	assert.NoError(t, err)
+	_, _, err = jwt.ToCaveats(tok, func(jwt.Header) (jwt.Verifier, error) { return key, nil }, jwt.IngestOptions{})
+	assert.Error(t, err)
+}
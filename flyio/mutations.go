@@ -0,0 +1,101 @@
+package flyio
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mutation names may be namespaced with dots (e.g. "apps.create"). Patterns
+// in a [Mutations] caveat may end with a ".*" wildcard, matching every
+// mutation in that namespace and its sub-namespaces (e.g. "apps.*" matches
+// "apps.create" and "apps.secrets.set"). A bare "*" matches every mutation.
+
+// ErrUnknownMutation is returned when a [Mutations] caveat is created with a
+// pattern that doesn't match any registered mutation.
+var ErrUnknownMutation = errors.New("unknown mutation")
+
+var (
+	mutationCatalog   = map[string]struct{}{}
+	mutationCatalogMu sync.RWMutex
+)
+
+// RegisterMutation adds mutation names to the catalog that [NewMutations]
+// checks patterns against. Names may not be empty, contain empty namespace
+// segments, or contain wildcards.
+func RegisterMutation(names ...string) {
+	mutationCatalogMu.Lock()
+	defer mutationCatalogMu.Unlock()
+
+	for _, name := range names {
+		if err := validateMutationName(name); err != nil {
+			panic(err)
+		}
+		mutationCatalog[name] = struct{}{}
+	}
+}
+
+// RegisteredMutations returns the sorted names of all registered mutations.
+func RegisteredMutations() []string {
+	mutationCatalogMu.RLock()
+	defer mutationCatalogMu.RUnlock()
+
+	ret := make([]string, 0, len(mutationCatalog))
+	for name := range mutationCatalog {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+
+	return ret
+}
+
+// NewMutations creates a [Mutations] caveat, checking that each pattern
+// matches at least one registered mutation. This catches typos at mint time
+// that would otherwise produce tokens that can't be used.
+func NewMutations(patterns ...string) (*Mutations, error) {
+	registered := RegisteredMutations()
+
+	for _, pattern := range patterns {
+		var found bool
+		for _, name := range registered {
+			if mutationMatches(pattern, name) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownMutation, pattern)
+		}
+	}
+
+	return &Mutations{Mutations: append([]string{}, patterns...)}, nil
+}
+
+// mutationMatches checks whether the mutation name is matched by the pattern.
+func mutationMatches(pattern, name string) bool {
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasSuffix(pattern, ".*"):
+		return strings.HasPrefix(name, strings.TrimSuffix(pattern, "*"))
+	default:
+		return pattern == name
+	}
+}
+
+func validateMutationName(name string) error {
+	if strings.Contains(name, "*") {
+		return fmt.Errorf("bad mutation name %q: wildcards not allowed", name)
+	}
+
+	for _, segment := range strings.Split(name, ".") {
+		if segment == "" {
+			return fmt.Errorf("bad mutation name %q: empty namespace", name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package flyio
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+)
+
+func TestMutations(t *testing.T) {
+	RegisterMutation("apps.create", "apps.secrets.set", "machines.start", "createApp")
+
+	access := func(mutation string) *Access {
+		return &Access{OrgID: 1, Action: macaroon.ActionWrite, Mutation: &mutation}
+	}
+
+	c, err := NewMutations("apps.*", "createApp")
+	assert.NoError(t, err)
+	cs := macaroon.NewCaveatSet(c)
+
+	assert.NoError(t, cs.Validate(access("apps.create")))
+	assert.NoError(t, cs.Validate(access("apps.secrets.set")))
+	assert.NoError(t, cs.Validate(access("createApp")))
+	assert.Error(t, cs.Validate(access("machines.start")))
+	assert.Error(t, cs.Validate(access("appsx.create")))
+
+	cs = macaroon.NewCaveatSet(&Mutations{Mutations: []string{"*"}})
+	assert.NoError(t, cs.Validate(access("machines.start")))
+
+	// namespaced identifiers must be well formed
+	assert.Error(t, cs.Validate(access("apps.*")))
+	assert.Error(t, cs.Validate(access("apps..create")))
+
+	// patterns are checked against the catalog at mint time
+	_, err = NewMutations("apps.delete")
+	assert.True(t, errors.Is(err, ErrUnknownMutation))
+	_, err = NewMutations("volumes.*")
+	assert.True(t, errors.Is(err, ErrUnknownMutation))
+	_, err = NewMutations("machines.*", "*")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"apps.create", "apps.secrets.set", "createApp", "machines.start"}, RegisteredMutations())
+}
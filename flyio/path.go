@@ -0,0 +1,128 @@
+package flyio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/superfly/macaroon"
+)
+
+// ResourcePath restricts a token to a path through the org → app → machine
+// hierarchy, with an action mask at each level. It's equivalent to an
+// Organization caveat followed by single-entry Apps and Machines caveats, but
+// is smaller and cheaper to evaluate. Create one with [NewResourcePath].
+//
+// A nil AppID leaves the path at the org level; otherwise accesses must
+// specify an app and, if MachineID is set, a machine. Zero IDs (0 and "")
+// match any app or machine, as in a [resset.ResourceSet]. The access's action
+// must be allowed by the mask of every level in the path.
+type ResourcePath struct {
+	OrgID          uint64          `json:"org_id"`
+	OrgMask        macaroon.Action `json:"org_mask"`
+	AppID          *uint64         `json:"app_id,omitempty"`
+	AppMask        macaroon.Action `json:"app_mask,omitempty"`
+	MachineID      *string         `json:"machine_id,omitempty"`
+	MachineMask    macaroon.Action `json:"machine_mask,omitempty"`
+	notAttestation `msgpack:"-" json:"-"`
+}
+
+func init() {
+	macaroon.RegisterCaveatType("ResourcePath", CavResourcePath, &ResourcePath{})
+}
+
+// NewResourcePath creates a ResourcePath allowing mask on the org. Extend
+// the path with [ResourcePath.App] and [ResourcePath.Machine].
+func NewResourcePath(orgID uint64, mask macaroon.Action) *ResourcePath {
+	return &ResourcePath{OrgID: orgID, OrgMask: mask}
+}
+
+// App extends the path to the app, allowing mask on it. An ID of 0 matches
+// any app.
+func (c *ResourcePath) App(id uint64, mask macaroon.Action) *ResourcePath {
+	c.AppID, c.AppMask = &id, mask
+	return c
+}
+
+// Machine extends the path to the machine, allowing mask on it. An empty ID
+// matches any machine. The path must already include an app.
+func (c *ResourcePath) Machine(id string, mask macaroon.Action) *ResourcePath {
+	c.MachineID, c.MachineMask = &id, mask
+	return c
+}
+
+func (c *ResourcePath) CaveatType() macaroon.CaveatType {
+	return CavResourcePath
+}
+
+func (c *ResourcePath) Prohibits(a macaroon.Access) error {
+	return c.ProhibitsWithContext(nil, a)
+}
+
+func (c *ResourcePath) ProhibitsWithContext(vc *macaroon.ValidationContext, a macaroon.Access) error {
+	f, isFlyioAccess := accessFrom(vc, a)
+
+	switch {
+	case !isFlyioAccess:
+		return macaroon.ErrInvalidAccess
+	case c.MachineID != nil && c.AppID == nil:
+		return fmt.Errorf("%w: resource path has machine without app", macaroon.ErrBadCaveat)
+	case f.OrgID == 0:
+		return fmt.Errorf("%w org", macaroon.ErrResourceUnspecified)
+	case c.OrgID != f.OrgID:
+		return fmt.Errorf("%w org %d, only %d", macaroon.ErrUnauthorizedForResource, f.OrgID, c.OrgID)
+	case !f.Action.IsSubsetOf(c.OrgMask):
+		return &macaroon.ActionError{Requested: f.Action, Permitted: c.OrgMask, Resource: fmt.Sprintf("org %d", f.OrgID)}
+	}
+
+	if c.AppID == nil {
+		return nil
+	}
+	if err := prohibitsPathLevel("app", *c.AppID, c.AppMask, f.AppID, f.Action); err != nil {
+		return err
+	}
+
+	if c.MachineID == nil {
+		return nil
+	}
+	return prohibitsPathLevel("machine", *c.MachineID, c.MachineMask, f.Machine, f.Action)
+}
+
+func prohibitsPathLevel[ID uint64 | string](kind string, want ID, mask macaroon.Action, got *ID, action macaroon.Action) error {
+	var zero ID
+
+	switch {
+	case got == nil:
+		return fmt.Errorf("%w %s", macaroon.ErrResourceUnspecified, kind)
+	case want != zero && want != *got:
+		return fmt.Errorf("%w %s %v, only %v", macaroon.ErrUnauthorizedForResource, kind, *got, want)
+	case !action.IsSubsetOf(mask):
+		return &macaroon.ActionError{Requested: action, Permitted: mask, Resource: fmt.Sprintf("%s %v", kind, *got)}
+	default:
+		return nil
+	}
+}
+
+// String formats the path like "org 123: rwcdC / app *: r / machine m-abc: r".
+func (c *ResourcePath) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "org %d: %s", c.OrgID, c.OrgMask)
+
+	if c.AppID != nil {
+		id := "*"
+		if *c.AppID != 0 {
+			id = strconv.FormatUint(*c.AppID, 10)
+		}
+		fmt.Fprintf(&sb, " / app %s: %s", id, c.AppMask)
+	}
+
+	if c.MachineID != nil {
+		id := "*"
+		if *c.MachineID != "" {
+			id = *c.MachineID
+		}
+		fmt.Fprintf(&sb, " / machine %s: %s", id, c.MachineMask)
+	}
+
+	return sb.String()
+}
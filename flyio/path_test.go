@@ -0,0 +1,64 @@
+package flyio
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+	"github.com/superfly/macaroon/resset"
+)
+
+func TestResourcePath(t *testing.T) {
+	path := NewResourcePath(123, macaroon.ActionAll).App(0, macaroon.ActionRead|macaroon.ActionWrite).Machine("m-abc", macaroon.ActionRead)
+	assert.Equal(t, "org 123: rwcdC / app *: rw / machine m-abc: r", path.String())
+
+	chain := macaroon.NewCaveatSet(
+		&Organization{ID: 123, Mask: macaroon.ActionAll},
+		&Apps{Apps: resset.New(macaroon.ActionRead|macaroon.ActionWrite, uint64(0))},
+		&Machines{Machines: resset.New(macaroon.ActionRead, "m-abc")},
+	)
+	cs := macaroon.NewCaveatSet(path)
+
+	pathBuf, err := cs.MarshalMsgpack()
+	assert.NoError(t, err)
+	chainBuf, err := chain.MarshalMsgpack()
+	assert.NoError(t, err)
+	assert.True(t, len(pathBuf) < len(chainBuf))
+
+	// the path behaves like the equivalent chain of caveats
+	for _, a := range []*Access{
+		{OrgID: 123, AppID: ptr[uint64](1), Machine: ptr("m-abc"), Action: macaroon.ActionRead},
+		{OrgID: 123, AppID: ptr[uint64](2), Machine: ptr("m-abc"), Action: macaroon.ActionRead},
+		{OrgID: 123, AppID: ptr[uint64](1), Machine: ptr("m-abc"), Action: macaroon.ActionWrite},
+		{OrgID: 123, AppID: ptr[uint64](1), Machine: ptr("m-def"), Action: macaroon.ActionRead},
+		{OrgID: 123, AppID: ptr[uint64](1), Action: macaroon.ActionRead},
+		{OrgID: 123, Action: macaroon.ActionRead},
+		{OrgID: 456, AppID: ptr[uint64](1), Machine: ptr("m-abc"), Action: macaroon.ActionRead},
+	} {
+		expected := chain.Validate(a)
+		actual := cs.Validate(a)
+		assert.Equal(t, expected == nil, actual == nil, "%#v", a)
+	}
+
+	assert.NoError(t, cs.Validate(&Access{OrgID: 123, AppID: ptr[uint64](1), Machine: ptr("m-abc"), Action: macaroon.ActionRead}))
+	assert.True(t, errors.Is(cs.Validate(&Access{OrgID: 123, AppID: ptr[uint64](1), Machine: ptr("m-def"), Action: macaroon.ActionRead}), macaroon.ErrUnauthorizedForResource))
+	assert.True(t, errors.Is(cs.Validate(&Access{OrgID: 123, AppID: ptr[uint64](1), Action: macaroon.ActionRead}), macaroon.ErrResourceUnspecified))
+
+	// org-only paths
+	cs = macaroon.NewCaveatSet(NewResourcePath(123, macaroon.ActionRead))
+	assert.NoError(t, cs.Validate(&Access{OrgID: 123, Action: macaroon.ActionRead}))
+	assert.NoError(t, cs.Validate(&Access{OrgID: 123, AppID: ptr[uint64](1), Action: macaroon.ActionRead}))
+	assert.True(t, errors.Is(cs.Validate(&Access{OrgID: 123, Action: macaroon.ActionWrite}), macaroon.ErrUnauthorizedForAction))
+
+	// machines require apps
+	cs = macaroon.NewCaveatSet(&ResourcePath{OrgID: 123, OrgMask: macaroon.ActionAll, MachineID: ptr("m-abc")})
+	assert.True(t, errors.Is(cs.Validate(&Access{OrgID: 123, Action: macaroon.ActionRead}), macaroon.ErrBadCaveat))
+
+	// round trip
+	buf, err := macaroon.NewCaveatSet(path).MarshalMsgpack()
+	assert.NoError(t, err)
+	decoded, err := macaroon.DecodeCaveats(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, []macaroon.Caveat{path}, decoded.Caveats)
+}
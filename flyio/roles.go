@@ -0,0 +1,140 @@
+package flyio
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/superfly/macaroon"
+)
+
+// Role is a user's role in an organization.
+type Role string
+
+const (
+	RoleAdmin   Role = "admin"
+	RoleMember  Role = "member"
+	RoleBilling Role = "billing"
+)
+
+// Valid returns whether r is a known role.
+func (r Role) Valid() bool {
+	switch r {
+	case RoleAdmin, RoleMember, RoleBilling:
+		return true
+	default:
+		return false
+	}
+}
+
+// OrgRole is an attestation, added by the authentication service to its
+// discharge tokens, stating the authenticated user's role in an organization.
+// Like other attestations, it's only considered if the discharge comes from a
+// trusted third party (see [macaroon.Macaroon.Verify]).
+type OrgRole struct {
+	OrgID uint64 `json:"org_id"`
+	Role  Role   `json:"role"`
+}
+
+func init() {
+	macaroon.RegisterCaveatType("OrgRole", CavOrgRole, &OrgRole{})
+}
+
+func (c *OrgRole) CaveatType() macaroon.CaveatType {
+	return CavOrgRole
+}
+
+func (c *OrgRole) Prohibits(macaroon.Access) error {
+	// attestations play no role in access validation
+	return nil
+}
+
+func (c *OrgRole) IsAttestation() bool { return true }
+
+// AttestOrgRole is used by the authentication service to add an [OrgRole]
+// attestation to a discharge token (as returned by [macaroon.DischargeCID]).
+func AttestOrgRole(dm *macaroon.Macaroon, orgID uint64, role Role) error {
+	if !role.Valid() {
+		return fmt.Errorf("unknown org role %q", role)
+	}
+	return dm.Add(&OrgRole{OrgID: orgID, Role: role})
+}
+
+// AuthenticatedOrgRole returns the role in the organization asserted by the
+// OrgRole attestations in a verified CaveatSet. Multiple attestations for the
+// organization are allowed as long as they agree.
+func AuthenticatedOrgRole(cs *macaroon.CaveatSet, orgID uint64) (Role, error) {
+	if cs == nil || !cs.Verified() {
+		return "", ErrUnverifiedCaveats
+	}
+	return orgRole(cs, orgID)
+}
+
+func orgRole(cs *macaroon.CaveatSet, orgID uint64) (Role, error) {
+	var ret Role
+	for _, att := range macaroon.GetCaveats[*OrgRole](cs) {
+		switch {
+		case att.OrgID != orgID:
+		case ret == "":
+			ret = att.Role
+		case att.Role != ret:
+			return "", fmt.Errorf("%w: org role %s and %s", ErrConflictingIdentity, ret, att.Role)
+		}
+	}
+
+	if ret == "" {
+		return "", fmt.Errorf("%w: org role", ErrNoIdentity)
+	}
+	return ret, nil
+}
+
+// RequireOrgRole requires that the token carry an [OrgRole] attestation for
+// the accessed organization with one of Roles.
+//
+// If there's no attestation for the organization, the caveat reports the role
+// as an unspecified resource, so it can be used in [macaroon.IfPresent]:
+// e.g. requiring the admin role when the user's role is known, and allowing
+// only reads otherwise. Used on its own, it rejects such accesses.
+type RequireOrgRole struct {
+	Roles          []Role `json:"roles"`
+	notAttestation `msgpack:"-" json:"-"`
+}
+
+func init() {
+	macaroon.RegisterCaveatType("RequireOrgRole", CavRequireOrgRole, &RequireOrgRole{})
+}
+
+func (c *RequireOrgRole) CaveatType() macaroon.CaveatType {
+	return CavRequireOrgRole
+}
+
+func (c *RequireOrgRole) Prohibits(a macaroon.Access) error {
+	return c.ProhibitsWithContext(new(macaroon.ValidationContext), a)
+}
+
+func (c *RequireOrgRole) ProhibitsWithContext(vc *macaroon.ValidationContext, a macaroon.Access) error {
+	f, isFlyioAccess := accessFrom(vc, a)
+	if !isFlyioAccess {
+		return macaroon.ErrInvalidAccess
+	}
+
+	cs := vc.Caveats()
+	if cs == nil {
+		return fmt.Errorf("%w org role", macaroon.ErrResourceUnspecified)
+	}
+
+	role, err := orgRole(cs, f.OrgID)
+	switch {
+	case errors.Is(err, ErrNoIdentity):
+		return fmt.Errorf("%w org role", macaroon.ErrResourceUnspecified)
+	case err != nil:
+		return fmt.Errorf("%w: %s", macaroon.ErrUnauthorized, err)
+	}
+
+	for _, r := range c.Roles {
+		if r == role {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: org role %s not permitted", macaroon.ErrUnauthorized, role)
+}
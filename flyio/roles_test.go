@@ -0,0 +1,88 @@
+package flyio
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+)
+
+func TestOrgRole(t *testing.T) {
+	var (
+		key = macaroon.NewSigningKey()
+		ka  = macaroon.NewEncryptionKey()
+	)
+
+	m, err := macaroon.New([]byte("kid"), LocationPermission, key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(
+		&Organization{ID: 1, Mask: macaroon.ActionAll},
+		&macaroon.IfPresent{
+			Ifs:  macaroon.NewCaveatSet(&RequireOrgRole{Roles: []Role{RoleAdmin}}),
+			Else: macaroon.ActionRead,
+		},
+	))
+	assert.NoError(t, m.Add3P(ka, LocationAuthentication))
+
+	discharge := func(roles ...Role) [][]byte {
+		cid, err := m.ThirdPartyCID(LocationAuthentication)
+		assert.NoError(t, err)
+		_, dm, err := macaroon.DischargeCID(ka, LocationAuthentication, cid)
+		assert.NoError(t, err)
+		for _, role := range roles {
+			assert.NoError(t, AttestOrgRole(dm, 1, role))
+		}
+		tok, err := dm.Encode()
+		assert.NoError(t, err)
+		return [][]byte{tok}
+	}
+
+	trusted := map[string]macaroon.EncryptionKey{LocationAuthentication: ka}
+	read := &Access{OrgID: 1, Action: macaroon.ActionRead}
+	write := &Access{OrgID: 1, Action: macaroon.ActionWrite}
+
+	// admins can write
+	cs, err := m.Verify(key, discharge(RoleAdmin), trusted)
+	assert.NoError(t, err)
+	assert.NoError(t, cs.Validate(write))
+	role, err := AuthenticatedOrgRole(cs, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, RoleAdmin, role)
+
+	// other roles can't do anything
+	cs, err = m.Verify(key, discharge(RoleBilling), trusted)
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(cs.Validate(read), macaroon.ErrUnauthorized))
+
+	// no known role: read only
+	cs, err = m.Verify(key, discharge(), trusted)
+	assert.NoError(t, err)
+	assert.NoError(t, cs.Validate(read))
+	assert.Error(t, cs.Validate(write))
+	_, err = AuthenticatedOrgRole(cs, 1)
+	assert.True(t, errors.Is(err, ErrNoIdentity))
+
+	// attestations from untrusted discharges are ignored
+	cs, err = m.Verify(key, discharge(RoleAdmin), nil)
+	assert.NoError(t, err)
+	assert.Error(t, cs.Validate(write))
+
+	// conflicting attestations are rejected
+	cs, err = m.Verify(key, discharge(RoleAdmin, RoleMember), trusted)
+	assert.NoError(t, err)
+	assert.Error(t, cs.Validate(read))
+	_, err = AuthenticatedOrgRole(cs, 1)
+	assert.True(t, errors.Is(err, ErrConflictingIdentity))
+
+	// used on its own, RequireOrgRole needs a role
+	cs = macaroon.NewCaveatSet(&RequireOrgRole{Roles: []Role{RoleMember}})
+	assert.True(t, errors.Is(cs.Validate(read), macaroon.ErrResourceUnspecified))
+
+	_, err = AuthenticatedOrgRole(macaroon.NewCaveatSet(&OrgRole{OrgID: 1, Role: RoleAdmin}), 1)
+	assert.True(t, errors.Is(err, ErrUnverifiedCaveats))
+
+	dm, err := macaroon.New([]byte("kid"), LocationAuthentication, key)
+	assert.NoError(t, err)
+	assert.Error(t, AttestOrgRole(dm, 1, "owner"))
+}
@@ -0,0 +1,342 @@
+package flyio
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/superfly/macaroon"
+	"github.com/superfly/macaroon/resset"
+)
+
+// Scope is a user-facing description of a grant, written as
+// "resource:name:verbs", e.g. "app:web:deploy" or "org:personal:read". It's
+// the grammar used by `fly tokens create` and the API, which share this
+// implementation.
+//
+// Resources are "org", "app", "volume", "machine" and "feature". Orgs and
+// apps may be named by slug or name, which are resolved to IDs with a
+// [ScopeResolver], or by numeric ID. Verbs are a comma-separated list of
+// action names (e.g. "read,write"), presets (see [ScopeVerbs]) or "*" for
+// all current and future actions.
+type Scope struct {
+	Resource string
+	Name     string
+	Action   macaroon.Action
+}
+
+// Scope resources.
+const (
+	ScopeOrg     = "org"
+	ScopeApp     = "app"
+	ScopeVolume  = "volume"
+	ScopeMachine = "machine"
+	ScopeFeature = "feature"
+)
+
+// ScopeVerbs are preset verbs, standing for common combinations of actions.
+var ScopeVerbs = map[string]macaroon.Action{
+	"deploy": macaroon.ActionRead | macaroon.ActionWrite | macaroon.ActionCreate | macaroon.ActionControl,
+	"all":    macaroon.ActionAll,
+}
+
+// ScopeResolver resolves the names of orgs and apps in scopes to their IDs.
+type ScopeResolver interface {
+	ResolveOrg(slug string) (uint64, error)
+	ResolveApp(name string) (uint64, error)
+}
+
+// ParseScope parses a scope, e.g. "app:web:deploy".
+func ParseScope(s string) (Scope, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+		return Scope{}, fmt.Errorf("scope %q: want resource:name:verbs", s)
+	}
+
+	switch parts[0] {
+	case ScopeOrg, ScopeApp, ScopeVolume, ScopeMachine, ScopeFeature:
+	default:
+		return Scope{}, fmt.Errorf("scope %q: unknown resource %q", s, parts[0])
+	}
+
+	action, err := parseVerbs(parts[2])
+	if err != nil {
+		return Scope{}, fmt.Errorf("scope %q: %w", s, err)
+	}
+
+	return Scope{Resource: parts[0], Name: parts[1], Action: action}, nil
+}
+
+func parseVerbs(verbs string) (macaroon.Action, error) {
+	var ret macaroon.Action
+	for _, verb := range strings.Split(verbs, ",") {
+		if verb == "*" {
+			ret |= macaroon.ActionAllCurrentAndFuture
+			continue
+		}
+
+		a, ok := ScopeVerbs[verb]
+		if !ok {
+			if a, ok = macaroon.ActionByName(verb); !ok {
+				return 0, fmt.Errorf("unknown verb %q", verb)
+			}
+		}
+		ret |= a
+	}
+	return ret, nil
+}
+
+// String renders the scope in the scope grammar, using presets where they
+// match exactly.
+func (s Scope) String() string {
+	return fmt.Sprintf("%s:%s:%s", s.Resource, s.Name, renderVerbs(s.Action))
+}
+
+func renderVerbs(a macaroon.Action) string {
+	if a == macaroon.ActionAllCurrentAndFuture {
+		return "*"
+	}
+
+	presets := make([]string, 0, len(ScopeVerbs))
+	for name := range ScopeVerbs {
+		presets = append(presets, name)
+	}
+	sort.Strings(presets)
+
+	for _, name := range presets {
+		if ScopeVerbs[name] == a {
+			return name
+		}
+	}
+
+	return strings.Join(a.Names(), ",")
+}
+
+// ScopeCaveats produces the caveats granting the scopes. A list of scopes
+// grants the union of their access: scopes for the same kind of resource are
+// combined, so "app:web:read" and "app:api:deploy" allow access to either app,
+// and each kind of resource is only restricted for accesses to that kind. So
+// "org:personal:read app:web:deploy" allows deploying to the web app and
+// reading the org's other resources that aren't apps. Accesses naming
+// resources of several scoped kinds (e.g. a volume in an app) must be allowed
+// by the scopes for each. At most one org may be granted. r resolves org and
+// app names; it may be nil if they're given as numeric IDs.
+func ScopeCaveats(scopes []Scope, r ScopeResolver) ([]macaroon.Caveat, error) {
+	var (
+		org       *Organization
+		orgAction macaroon.Action
+		granted   macaroon.Action
+		apps      = resset.ResourceSet[uint64]{}
+		volumes   = resset.ResourceSet[string]{}
+		machines  = resset.ResourceSet[string]{}
+		features  = resset.ResourceSet[string]{}
+	)
+
+	for _, s := range scopes {
+		switch s.Resource {
+		case ScopeOrg:
+			id, err := resolveID(s.Name, r, ScopeResolver.ResolveOrg)
+			if err != nil {
+				return nil, fmt.Errorf("scope %s: %w", s, err)
+			}
+			if org != nil && org.ID != id {
+				return nil, fmt.Errorf("scope %s: only one org may be granted", s)
+			}
+			if org == nil {
+				org = &Organization{ID: id}
+			}
+			orgAction |= s.Action
+		case ScopeApp:
+			id, err := resolveID(s.Name, r, ScopeResolver.ResolveApp)
+			if err != nil {
+				return nil, fmt.Errorf("scope %s: %w", s, err)
+			}
+			apps[id] |= s.Action
+		case ScopeVolume:
+			volumes[s.Name] |= s.Action
+		case ScopeMachine:
+			machines[s.Name] |= s.Action
+		case ScopeFeature:
+			if err := OrgFeature(s.Name).checkAction(s.Action); err != nil {
+				return nil, fmt.Errorf("scope %s: %w", s, err)
+			}
+			features[s.Name] |= s.Action
+		default:
+			return nil, fmt.Errorf("scope %s: unknown resource %q", s, s.Resource)
+		}
+		granted |= s.Action
+	}
+
+	var kinds []macaroon.Caveat
+	if len(apps) != 0 {
+		kinds = append(kinds, &Apps{Apps: apps})
+	}
+	if len(volumes) != 0 {
+		kinds = append(kinds, &Volumes{Volumes: volumes})
+	}
+	if len(machines) != 0 {
+		kinds = append(kinds, &Machines{Machines: machines})
+	}
+	if len(features) != 0 {
+		kinds = append(kinds, &FeatureSet{Features: features})
+	}
+
+	var ret []macaroon.Caveat
+	if org != nil {
+		// the org allows everything granted by any scope. The resource
+		// kinds narrow that for accesses to them, leaving the org scope's
+		// own actions for accesses to none of them.
+		org.Mask = granted
+		ret = append(ret, org)
+	}
+
+	switch {
+	case len(kinds) == 0:
+	case org == nil && len(kinds) == 1:
+		ret = append(ret, kinds[0])
+	default:
+		ret = append(ret, &macaroon.IfPresent{Ifs: macaroon.NewCaveatSet(kinds...), Else: orgAction})
+	}
+
+	if err := macaroon.CheckCaveatRules(ret...); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func resolveID(name string, r ScopeResolver, resolve func(ScopeResolver, string) (uint64, error)) (uint64, error) {
+	if id, err := strconv.ParseUint(name, 10, 64); err == nil {
+		return id, nil
+	}
+	if r == nil {
+		return 0, errors.New("can't resolve names without a resolver")
+	}
+	return resolve(r, name)
+}
+
+// CaveatScopes renders caveats as scopes, naming orgs and apps by ID. It's
+// the inverse of ScopeCaveats, and fails for caveats that can't be expressed
+// as scopes, including combinations of caveats whose meaning differs from
+// that of a list of scopes.
+func CaveatScopes(cavs []macaroon.Caveat) ([]Scope, error) {
+	var (
+		org   *Organization
+		ifp   *macaroon.IfPresent
+		kinds []macaroon.Caveat
+	)
+
+	for _, cav := range cavs {
+		switch c := cav.(type) {
+		case *Organization:
+			if org != nil {
+				return nil, errors.New("only one org may be expressed as scopes")
+			}
+			org = c
+		case *macaroon.IfPresent:
+			if ifp != nil || c.Ifs == nil {
+				return nil, errors.New("IfPresent caveat can't be expressed as scopes")
+			}
+			ifp = c
+		default:
+			kinds = append(kinds, cav)
+		}
+	}
+
+	var ret []Scope
+	switch {
+	case ifp == nil && len(kinds) <= 1 && (org == nil || len(kinds) == 0):
+		// a lone org or resource caveat
+		if org != nil {
+			ret = append(ret, Scope{ScopeOrg, strconv.FormatUint(org.ID, 10), org.Mask})
+		}
+	case ifp != nil && len(kinds) == 0:
+		// an org's mask is the union of every scope's actions
+		granted := ifp.Else
+		for _, k := range ifp.Ifs.Caveats {
+			granted |= kindActions(k)
+		}
+
+		switch {
+		case org == nil && ifp.Else != macaroon.ActionNone:
+			return nil, errors.New("IfPresent caveat without org can't be expressed as scopes")
+		case org != nil && org.Mask != granted:
+			return nil, errors.New("org mask doesn't match the union of the scopes")
+		case org != nil:
+			ret = append(ret, Scope{ScopeOrg, strconv.FormatUint(org.ID, 10), ifp.Else})
+		}
+		kinds = ifp.Ifs.Caveats
+	default:
+		return nil, errors.New("caveats restrict several kinds of resource at once and can't be expressed as scopes")
+	}
+
+	return appendKindScopes(ret, kinds)
+}
+
+// kindActions is the union of the actions allowed by a resource caveat.
+func kindActions(cav macaroon.Caveat) macaroon.Action {
+	var ret macaroon.Action
+	switch c := cav.(type) {
+	case *Apps:
+		for _, a := range c.Apps {
+			ret |= a
+		}
+	case *Volumes:
+		for _, a := range c.Volumes {
+			ret |= a
+		}
+	case *Machines:
+		for _, a := range c.Machines {
+			ret |= a
+		}
+	case *FeatureSet:
+		for _, a := range c.Features {
+			ret |= a
+		}
+	}
+	return ret
+}
+
+func appendKindScopes(scopes []Scope, cavs []macaroon.Caveat) ([]Scope, error) {
+	seen := map[macaroon.CaveatType]bool{}
+	for _, cav := range cavs {
+		if seen[cav.CaveatType()] {
+			return nil, fmt.Errorf("several %s caveats can't be expressed as scopes", macaroon.CaveatTypeName(cav.CaveatType()))
+		}
+		seen[cav.CaveatType()] = true
+
+		switch c := cav.(type) {
+		case *Apps:
+			for _, id := range sortedKeys(c.Apps) {
+				scopes = append(scopes, Scope{ScopeApp, strconv.FormatUint(id, 10), c.Apps[id]})
+			}
+		case *Volumes:
+			scopes = appendScopes(scopes, ScopeVolume, c.Volumes)
+		case *Machines:
+			scopes = appendScopes(scopes, ScopeMachine, c.Machines)
+		case *FeatureSet:
+			scopes = appendScopes(scopes, ScopeFeature, c.Features)
+		default:
+			return nil, fmt.Errorf("caveat %s can't be expressed as a scope", macaroon.CaveatTypeName(cav.CaveatType()))
+		}
+	}
+	return scopes, nil
+}
+
+func appendScopes(scopes []Scope, resource string, rs resset.ResourceSet[string]) []Scope {
+	for _, id := range sortedKeys(rs) {
+		scopes = append(scopes, Scope{resource, id, rs[id]})
+	}
+	return scopes
+}
+
+func sortedKeys[ID uint64 | string](rs resset.ResourceSet[ID]) []ID {
+	ret := make([]ID, 0, len(rs))
+	for id := range rs {
+		ret = append(ret, id)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret
+}
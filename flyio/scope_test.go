@@ -0,0 +1,144 @@
+package flyio
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+	"github.com/superfly/macaroon/resset"
+)
+
+type testResolver map[string]uint64
+
+func (r testResolver) ResolveOrg(slug string) (uint64, error) { return r.resolve("org/" + slug) }
+func (r testResolver) ResolveApp(name string) (uint64, error) { return r.resolve("app/" + name) }
+
+func (r testResolver) resolve(name string) (uint64, error) {
+	if id, ok := r[name]; ok {
+		return id, nil
+	}
+	return 0, fmt.Errorf("%s not found", name)
+}
+
+func TestScopeUnion(t *testing.T) {
+	var scopes []Scope
+	for _, s := range []string{"org:1:read", "app:10:deploy", "feature:wg:create"} {
+		scope, err := ParseScope(s)
+		assert.NoError(t, err)
+		scopes = append(scopes, scope)
+	}
+
+	cavs, err := ScopeCaveats(scopes, nil)
+	assert.NoError(t, err)
+	cs := macaroon.NewCaveatSet(cavs...)
+
+	access := func(action macaroon.Action, f func(*Access)) *Access {
+		a := &Access{OrgID: 1, Action: action}
+		if f != nil {
+			f(a)
+		}
+		return a
+	}
+	app := func(id uint64) func(*Access) { return func(a *Access) { a.AppID = &id } }
+
+	assert.NoError(t, cs.Validate(access(macaroon.ActionWrite, app(10))))
+	assert.NoError(t, cs.Validate(access(macaroon.ActionCreate, func(a *Access) { a.Feature = FeatureWireguard.Ptr() })))
+	assert.NoError(t, cs.Validate(access(macaroon.ActionRead, nil)))
+
+	assert.Error(t, cs.Validate(access(macaroon.ActionWrite, nil)))
+	assert.Error(t, cs.Validate(access(macaroon.ActionRead, app(11))))
+	assert.Error(t, cs.Validate(access(macaroon.ActionDelete, func(a *Access) { a.Feature = FeatureWireguard.Ptr() })))
+	assert.Error(t, cs.Validate(&Access{OrgID: 2, Action: macaroon.ActionRead}))
+}
+
+func TestScopes(t *testing.T) {
+	r := testResolver{"org/personal": 1, "app/web": 10, "app/api": 11}
+
+	var scopes []Scope
+	for _, s := range []string{"org:personal:read", "app:web:deploy", "app:api:read,snapshot-create", "volume:vol_123:*", "feature:wg:create,delete"} {
+		scope, err := ParseScope(s)
+		assert.NoError(t, err)
+		scopes = append(scopes, scope)
+	}
+
+	cavs, err := ScopeCaveats(scopes, r)
+	assert.NoError(t, err)
+	assert.Equal(t, []macaroon.Caveat{
+		&Organization{ID: 1, Mask: macaroon.ActionAllCurrentAndFuture},
+		&macaroon.IfPresent{
+			Ifs: macaroon.NewCaveatSet(
+				&Apps{Apps: resset.ResourceSet[uint64]{
+					10: ScopeVerbs["deploy"],
+					11: macaroon.ActionRead | ActionSnapshotCreate,
+				}},
+				&Volumes{Volumes: resset.ResourceSet[string]{"vol_123": macaroon.ActionAllCurrentAndFuture}},
+				&FeatureSet{Features: resset.ResourceSet[string]{"wg": macaroon.ActionCreate | macaroon.ActionDelete}},
+			),
+			Else: macaroon.ActionRead,
+		},
+	}, cavs)
+
+	rendered, err := CaveatScopes(cavs)
+	assert.NoError(t, err)
+
+	var strs []string
+	for _, s := range rendered {
+		strs = append(strs, s.String())
+	}
+	assert.Equal(t, []string{"org:1:read", "app:10:deploy", "app:11:read,snapshot-create", "volume:vol_123:*", "feature:wg:create,delete"}, strs)
+
+	// rendered scopes round-trip without a resolver
+	cavs2, err := ScopeCaveats(rendered, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, cavs, cavs2)
+
+	// lone resource caveats aren't wrapped
+	cavs, err = ScopeCaveats([]Scope{{Resource: ScopeApp, Name: "10", Action: macaroon.ActionRead}}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []macaroon.Caveat{&Apps{Apps: resset.ResourceSet[uint64]{10: macaroon.ActionRead}}}, cavs)
+
+	// caveats narrowing several kinds of resource at once mean something
+	// else than a list of scopes
+	for _, cavs := range [][]macaroon.Caveat{
+		{&Organization{ID: 1, Mask: macaroon.ActionRead}, &Apps{Apps: resset.New(macaroon.ActionRead, uint64(10))}},
+		{&Apps{Apps: resset.New(macaroon.ActionRead, uint64(10))}, &Volumes{Volumes: resset.New(macaroon.ActionRead, "vol_123")}},
+		{&Organization{ID: 1, Mask: macaroon.ActionAll}, &macaroon.IfPresent{Ifs: macaroon.NewCaveatSet(&Apps{Apps: resset.New(macaroon.ActionRead, uint64(10))}), Else: macaroon.ActionRead}},
+	} {
+		_, err := CaveatScopes(cavs)
+		assert.Error(t, err)
+	}
+
+	for _, bad := range []string{"app:web", "app::read", "bucket:b:read", "app:web:fly"} {
+		_, err := ParseScope(bad)
+		assert.Error(t, err, bad)
+	}
+
+	for _, bad := range [][]string{
+		{"org:personal:read", "org:other:read"},
+		{"app:missing:read"},
+		{"feature:bogus:read"},
+		{"feature:builder:write"},
+	} {
+		var scopes []Scope
+		for _, s := range bad {
+			scope, err := ParseScope(s)
+			assert.NoError(t, err)
+			scopes = append(scopes, scope)
+		}
+		_, err := ScopeCaveats(scopes, testResolver{"org/personal": 1, "org/other": 2})
+		assert.Error(t, err)
+	}
+
+	scope, err := ParseScope("app:web:read")
+	assert.NoError(t, err)
+	_, err = ScopeCaveats([]Scope{scope}, nil)
+	assert.Error(t, err)
+
+	_, err = CaveatScopes([]macaroon.Caveat{&IsUser{ID: 1}})
+	assert.Error(t, err)
+
+	_, err = ScopeCaveats([]Scope{{Resource: ScopeFeature, Name: "bogus", Action: macaroon.ActionRead}}, nil)
+	assert.True(t, errors.Is(err, ErrUnknownFeature))
+}
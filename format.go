@@ -12,6 +12,7 @@ const (
 	permissionTokenLabel = "fm1r"
 	dischargeTokenLabel  = "fm1a"
 	v2TokenLabel         = "fm2"
+	envelopeLabel        = "fme1"
 )
 
 // Parses an Authorization header into its constituent tokens.
@@ -35,7 +36,7 @@ func Parse(header string) ([][]byte, error) {
 		}
 
 		switch pfx {
-		case permissionTokenLabel, dischargeTokenLabel, v2TokenLabel:
+		case permissionTokenLabel, dischargeTokenLabel, v2TokenLabel, envelopeLabel:
 		default:
 			return nil, fmt.Errorf("parse token: invalid token prefix '%s': %w", pfx, ErrUnrecognizedToken)
 		}
@@ -49,6 +50,16 @@ func Parse(header string) ([][]byte, error) {
 			return nil, fmt.Errorf("parse flyv1 token: blank %s: %w", pfx, ErrUnrecognizedToken)
 		}
 
+		if pfx == envelopeLabel {
+			e, err := DecodeEnvelope(raw)
+			if err != nil {
+				return nil, fmt.Errorf("parse flyv1 token: %w", err)
+			}
+
+			toks = append(toks, e.Tokens()...)
+			continue
+		}
+
 		toks = append(toks, raw)
 	}
 
@@ -88,7 +99,7 @@ func FindPermissionAndDischargeTokens(tokens [][]byte, location string) ([]*Maca
 	)
 
 	for _, token := range tokens {
-		if m, err := Decode(token); err == nil && m.Location == location {
+		if m, err := Decode(token); err == nil && NormalizeLocation(m.Location) == NormalizeLocation(location) {
 			permissionMacaroons = append(permissionMacaroons, m)
 			permissionTokens = append(permissionTokens, token)
 		} else {
@@ -106,6 +117,10 @@ func ToAuthorizationHeader(toks ...[]byte) string {
 	return authorizationScheme + " " + encodeTokens(toks...)
 }
 
+func encodeEnvelope(buf []byte) string {
+	return fmt.Sprintf("%s_%s", envelopeLabel, base64.StdEncoding.EncodeToString(buf))
+}
+
 func encodeTokens(toks ...[]byte) string {
 	ret := ""
 	for i, tok := range toks {
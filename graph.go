@@ -0,0 +1,184 @@
+package macaroon
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Graph describes the trust chain of a [Bundle]: its tokens, the third-party
+// caveats they require and the discharges satisfying those caveats. It's
+// meant for documentation and debugging UIs and can be rendered with
+// [Graph.DOT] or encoded as JSON. Building a graph doesn't verify anything.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// GraphNodeKind is the kind of a [GraphNode].
+type GraphNodeKind string
+
+const (
+	// GraphPermission is the bundle's permission token.
+	GraphPermission GraphNodeKind = "permission"
+
+	// GraphDischarge is a discharge token.
+	GraphDischarge GraphNodeKind = "discharge"
+
+	// GraphMalformed is a discharge that couldn't be decoded.
+	GraphMalformed GraphNodeKind = "malformed"
+
+	// GraphThirdParty is a third-party caveat.
+	GraphThirdParty GraphNodeKind = "third-party"
+)
+
+// GraphNode is a token or third-party caveat.
+type GraphNode struct {
+	ID       string        `json:"id"`
+	Kind     GraphNodeKind `json:"kind"`
+	Location string        `json:"location,omitempty"`
+
+	// Caveats are descriptions of a token's first-party caveats.
+	Caveats []string `json:"caveats,omitempty"`
+}
+
+// GraphEdgeKind is the kind of a [GraphEdge].
+type GraphEdgeKind string
+
+const (
+	// GraphRequires goes from a token to one of its third-party caveats.
+	GraphRequires GraphEdgeKind = "requires"
+
+	// GraphDischarges goes from a discharge token to the third-party caveat
+	// it discharges.
+	GraphDischarges GraphEdgeKind = "discharges"
+
+	// GraphBound goes from a discharge token to the token it's bound to with
+	// a [BindToParentToken] caveat.
+	GraphBound GraphEdgeKind = "bound"
+)
+
+// GraphEdge is a relationship between two nodes, identified by their IDs.
+type GraphEdge struct {
+	From string        `json:"from"`
+	To   string        `json:"to"`
+	Kind GraphEdgeKind `json:"kind"`
+}
+
+// Graph builds a [Graph] of the bundle's trust chain. Tokens are identified
+// as "t0" (the permission token), "t1", ... in the order returned by
+// [Bundle.Tokens] and their third-party caveats as "t0.c1" etc., by caveat
+// index. Discharges bound to an attenuated version of a token other than the
+// one in the bundle can't be identified without the key and have no
+// [GraphBound] edge.
+func (b *Bundle) Graph() (*Graph, error) {
+	var (
+		g      = new(Graph)
+		toks   = b.Tokens()
+		macs   = make([]*Macaroon, len(toks))
+		tpByID = map[string]string{}
+	)
+
+	for i, tok := range toks {
+		node := GraphNode{ID: fmt.Sprintf("t%d", i), Kind: GraphDischarge}
+		if i == 0 {
+			node.Kind = GraphPermission
+		}
+
+		m, err := Decode(tok)
+		switch {
+		case err != nil && i == 0:
+			return nil, fmt.Errorf("graph: decode permission token: %w", err)
+		case err != nil:
+			node.Kind = GraphMalformed
+			g.Nodes = append(g.Nodes, node)
+			continue
+		}
+
+		macs[i] = m
+		node.Location = m.Location
+
+		var tps []GraphNode
+		for j, c := range m.UnsafeCaveats.Caveats {
+			switch cav := c.(type) {
+			case *Caveat3P:
+				id := fmt.Sprintf("%s.c%d", node.ID, j)
+				tpByID[string(cav.CID)] = id
+				tps = append(tps, GraphNode{ID: id, Kind: GraphThirdParty, Location: cav.Location})
+				g.Edges = append(g.Edges, GraphEdge{From: node.ID, To: id, Kind: GraphRequires})
+			case *BindToParentToken:
+			default:
+				node.Caveats = append(node.Caveats, caveatString(c))
+			}
+		}
+
+		g.Nodes = append(g.Nodes, node)
+		g.Nodes = append(g.Nodes, tps...)
+	}
+
+	for i, m := range macs {
+		if m == nil {
+			continue
+		}
+
+		from := fmt.Sprintf("t%d", i)
+		if tp, ok := tpByID[string(m.Nonce.KID)]; ok {
+			g.Edges = append(g.Edges, GraphEdge{From: from, To: tp, Kind: GraphDischarges})
+		}
+
+		for _, bid := range GetCaveats[*BindToParentToken](&m.UnsafeCaveats) {
+			for j, parent := range macs {
+				if parent != nil && j != i && bytes.HasPrefix(digest(parent.Tail), *bid) {
+					g.Edges = append(g.Edges, GraphEdge{From: from, To: fmt.Sprintf("t%d", j), Kind: GraphBound})
+				}
+			}
+		}
+	}
+
+	return g, nil
+}
+
+// DOT renders the graph in the Graphviz DOT language.
+func (g *Graph) DOT() string {
+	var sb strings.Builder
+
+	sb.WriteString("digraph macaroon {\n")
+
+	for _, n := range g.Nodes {
+		label := string(n.Kind)
+		if n.Location != "" {
+			label += "\n" + n.Location
+		}
+		for _, c := range n.Caveats {
+			label += "\n" + c
+		}
+
+		shape := "box"
+		if n.Kind == GraphThirdParty {
+			shape = "ellipse"
+		}
+
+		fmt.Fprintf(&sb, "\t%q [label=%s, shape=%s];\n", n.ID, dotQuote(label), shape)
+	}
+
+	for _, e := range g.Edges {
+		style := "solid"
+		if e.Kind == GraphBound {
+			style = "dashed"
+		}
+
+		fmt.Fprintf(&sb, "\t%q -> %q [label=%q, style=%s];\n", e.From, e.To, e.Kind, style)
+	}
+
+	sb.WriteString("}\n")
+
+	return sb.String()
+}
+
+// dotQuote quotes s as a DOT string, using DOT's "\l" escape for line breaks
+// so multi-line labels are left-justified.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + strings.ReplaceAll(s, "\n", `\l`) + `\l"`
+}
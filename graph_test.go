@@ -0,0 +1,58 @@
+package macaroon
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestBundleGraph(t *testing.T) {
+	var (
+		key     = NewSigningKey()
+		ka      = NewEncryptionKey()
+		authLoc = "https://auth"
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavParent(ActionRead, 1)))
+	assert.NoError(t, m.Add3P(ka, authLoc))
+	tok, err := m.Encode()
+	assert.NoError(t, err)
+
+	_, _, dm, err := dischargeMacaroon(ka, authLoc, tok)
+	assert.NoError(t, err)
+	assert.NoError(t, dm.Bind(tok))
+	dtok, err := dm.Encode()
+	assert.NoError(t, err)
+
+	b := &Bundle{Permission: tok, Discharges: [][]byte{dtok, []byte("bogus")}}
+	g, err := b.Graph()
+	assert.NoError(t, err)
+
+	assert.Equal(t, []GraphEdge{
+		{From: "t0", To: "t0.c1", Kind: GraphRequires},
+		{From: "t1", To: "t0.c1", Kind: GraphDischarges},
+		{From: "t1", To: "t0", Kind: GraphBound},
+	}, g.Edges)
+
+	assert.Equal(t, 4, len(g.Nodes))
+	assert.Equal(t, GraphPermission, g.Nodes[0].Kind)
+	assert.Equal(t, 1, len(g.Nodes[0].Caveats))
+	assert.Equal(t, GraphThirdParty, g.Nodes[1].Kind)
+	assert.Equal(t, authLoc, g.Nodes[1].Location)
+	assert.Equal(t, GraphDischarge, g.Nodes[2].Kind)
+	assert.Equal(t, GraphMalformed, g.Nodes[3].Kind)
+
+	dot := g.DOT()
+	assert.True(t, strings.HasPrefix(dot, "digraph macaroon {\n"))
+	assert.Contains(t, dot, `"t1" -> "t0" [label="bound", style=dashed];`)
+
+	_, err = json.Marshal(g)
+	assert.NoError(t, err)
+
+	_, err = (&Bundle{Permission: []byte("bogus")}).Graph()
+	assert.Error(t, err)
+}
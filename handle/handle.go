@@ -0,0 +1,184 @@
+// Package handle lets web applications keep macaroons out of browser cookies.
+// Rather than storing the token itself, the application stores it server-side
+// and hands the browser a short, opaque handle: an HMAC of the token's tail
+// signature under a server key. Handles reveal nothing about the token and
+// can't be forged without the server key.
+//
+// Handles are looked up with [Handles.Verify], which checks that the stored
+// token still matches the handle before verifying it with this package's
+// [macaroon.VerifyFunc].
+package handle
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/superfly/macaroon"
+)
+
+// Handle is a cookie-safe reference to a stored token.
+type Handle string
+
+var (
+	ErrNotFound  = errors.New("handle: not found")
+	ErrMalformed = errors.New("handle: malformed")
+)
+
+// Entry is a token and its discharges, as stored server-side.
+type Entry struct {
+	Token      []byte
+	Discharges [][]byte
+
+	// Expires is when the entry may be discarded. It's the expiration of the
+	// token.
+	Expires time.Time
+}
+
+// Store stores entries by handle. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	Put(h Handle, e *Entry) error
+	Get(h Handle) (*Entry, error)
+	Delete(h Handle) error
+}
+
+// Handles issues and resolves token handles.
+type Handles struct {
+	// Key is the server key handles are derived with.
+	Key macaroon.SigningKey
+
+	// Store holds the tokens referenced by handles.
+	Store Store
+}
+
+// Register stores the token and its discharges, returning a handle to them.
+func (h *Handles) Register(token []byte, discharges [][]byte) (Handle, error) {
+	m, err := macaroon.Decode(token)
+	if err != nil {
+		return "", fmt.Errorf("handle register: %w", err)
+	}
+
+	handle := h.derive(m)
+
+	e := &Entry{
+		Token:      token,
+		Discharges: discharges,
+		Expires:    m.Expiration(),
+	}
+
+	if err := h.Store.Put(handle, e); err != nil {
+		return "", fmt.Errorf("handle register: %w", err)
+	}
+
+	return handle, nil
+}
+
+// Lookup retrieves the token and discharges referenced by the handle,
+// checking that the stored token matches the handle.
+func (h *Handles) Lookup(handle Handle) (*macaroon.Macaroon, [][]byte, error) {
+	if raw, err := base64.RawURLEncoding.DecodeString(string(handle)); err != nil || len(raw) != sha256.Size {
+		return nil, nil, ErrMalformed
+	}
+
+	e, err := h.Store.Get(handle)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, err := macaroon.Decode(e.Token)
+	if err != nil {
+		return nil, nil, fmt.Errorf("handle lookup: %w", err)
+	}
+
+	if !hmac.Equal([]byte(h.derive(m)), []byte(handle)) {
+		return nil, nil, ErrNotFound
+	}
+
+	return m, e.Discharges, nil
+}
+
+// Verify looks up the token referenced by the handle and verifies it with
+// verify.
+func (h *Handles) Verify(handle Handle, verify macaroon.VerifyFunc) (*macaroon.CaveatSet, error) {
+	m, discharges, err := h.Lookup(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	return verify(m, discharges)
+}
+
+// Revoke deletes the token referenced by the handle, e.g. on logout.
+func (h *Handles) Revoke(handle Handle) error {
+	return h.Store.Delete(handle)
+}
+
+func (h *Handles) derive(m *macaroon.Macaroon) Handle {
+	mac := hmac.New(sha256.New, h.Key)
+	mac.Write(m.Tail)
+	return Handle(base64.RawURLEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+// MemoryStore is an in-memory [Store]. Expired entries are discarded when
+// they're looked up.
+type MemoryStore struct {
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	entries map[Handle]*Entry
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// Put implements Store.
+func (s *MemoryStore) Put(h Handle, e *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.entries == nil {
+		s.entries = map[Handle]*Entry{}
+	}
+	s.entries[h] = e
+
+	return nil
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(h Handle) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[h]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	if s.now().After(e.Expires) {
+		delete(s.entries, h)
+		return nil, ErrNotFound
+	}
+
+	return e, nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(h Handle) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, h)
+	return nil
+}
+
+func (s *MemoryStore) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,57 @@
+package handle
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+)
+
+func TestHandles(t *testing.T) {
+	var (
+		key   = macaroon.NewSigningKey()
+		now   = time.Now()
+		store = &MemoryStore{Now: func() time.Time { return now }}
+		h     = &Handles{Key: macaroon.NewSigningKey(), Store: store}
+	)
+
+	m, err := macaroon.New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(&macaroon.ValidityWindow{NotBefore: now.Unix(), NotAfter: now.Add(time.Hour).Unix()}))
+	tok, err := m.Encode()
+	assert.NoError(t, err)
+
+	handle, err := h.Register(tok, nil)
+	assert.NoError(t, err)
+	assert.True(t, len(handle) < len(tok))
+
+	verify := func(m *macaroon.Macaroon, discharges [][]byte, opts ...macaroon.VerifyOption) (*macaroon.CaveatSet, error) {
+		return m.Verify(key, discharges, nil, opts...)
+	}
+
+	cavs, err := h.Verify(handle, verify)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(cavs.Caveats))
+
+	// handles are bound to the server key
+	other := &Handles{Key: macaroon.NewSigningKey(), Store: store}
+	_, err = other.Verify(handle, verify)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	_, err = h.Verify("bogus", verify)
+	assert.True(t, errors.Is(err, ErrMalformed))
+
+	// entries expire with the token
+	now = now.Add(2 * time.Hour)
+	_, err = h.Verify(handle, verify)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	now = now.Add(-2 * time.Hour)
+	handle, err = h.Register(tok, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, h.Revoke(handle))
+	_, err = h.Verify(handle, verify)
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
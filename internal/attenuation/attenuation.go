@@ -0,0 +1,95 @@
+// Package attenuation is the keyless subset of this module — decoding tokens,
+// adding first-party caveats and re-encoding them — behind a string-in,
+// string-out API, so it can be exported to JavaScript by cmd/macaroon-wasm.
+// Browsers and edge runtimes can then attenuate tokens before sending them
+// onward, without holding any keys.
+//
+// Tokens are exchanged in Authorization header format (see
+// [macaroon.ToAuthorizationHeader]) and caveats as JSON (see
+// [macaroon.CaveatSet.MarshalJSON]). Only caveat types registered in the
+// importing binary can be used.
+package attenuation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/superfly/macaroon"
+)
+
+// Attenuate adds the JSON-encoded caveats to the permission token for the
+// location in the header, returning the updated header. Discharge tokens are
+// passed through unchanged.
+func Attenuate(header, location, caveatsJSON string) (string, error) {
+	b, err := macaroon.ParseBundle(header, location)
+	if err != nil {
+		return "", fmt.Errorf("attenuate: %w", err)
+	}
+
+	cs := macaroon.NewCaveatSet()
+	if err := json.Unmarshal([]byte(caveatsJSON), cs); err != nil {
+		return "", fmt.Errorf("attenuate: caveats: %w", err)
+	}
+
+	for _, cav := range cs.Caveats {
+		if _, is3P := cav.(*macaroon.Caveat3P); is3P || cav.IsAttestation() {
+			return "", errors.New("attenuate: only first-party caveats can be added")
+		}
+	}
+
+	if b.Permission, err = macaroon.Attenuate(b.Permission, cs.Caveats...); err != nil {
+		return "", err
+	}
+
+	return b.Header(), nil
+}
+
+// Token describes a permission token, as returned by [Inspect].
+type Token struct {
+	Location string              `json:"location"`
+	Caveats  *macaroon.CaveatSet `json:"caveats"`
+
+	// ThirdParties are the locations of the token's undischarged
+	// third-party caveats.
+	ThirdParties []string `json:"third_parties,omitempty"`
+}
+
+// Inspect returns a JSON-encoded [Token] describing the permission token for
+// the location in the header. The token isn't verified, so its contents are
+// only as trustworthy as its source.
+func Inspect(header, location string) (string, error) {
+	b, err := macaroon.ParseBundle(header, location)
+	if err != nil {
+		return "", fmt.Errorf("inspect: %w", err)
+	}
+
+	m, err := macaroon.Decode(b.Permission)
+	if err != nil {
+		return "", fmt.Errorf("inspect: %w", err)
+	}
+
+	cids, err := m.ThirdPartyCIDs(b.Discharges...)
+	if err != nil {
+		return "", fmt.Errorf("inspect: %w", err)
+	}
+
+	t := Token{Location: m.Location, Caveats: macaroon.NewCaveatSet()}
+	for _, cav := range m.UnsafeCaveats.Caveats {
+		if _, is3P := cav.(*macaroon.Caveat3P); !is3P {
+			t.Caveats.Caveats = append(t.Caveats.Caveats, cav)
+		}
+	}
+	for loc := range cids {
+		t.ThirdParties = append(t.ThirdParties, loc)
+	}
+	sort.Strings(t.ThirdParties)
+
+	buf, err := json.Marshal(t)
+	if err != nil {
+		return "", fmt.Errorf("inspect: %w", err)
+	}
+
+	return string(buf), nil
+}
@@ -0,0 +1,50 @@
+package attenuation
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+)
+
+func TestAttenuate(t *testing.T) {
+	var (
+		key = macaroon.NewSigningKey()
+		ka  = macaroon.NewEncryptionKey()
+	)
+
+	m, err := macaroon.New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add3P(ka, "https://auth"))
+	tok, err := m.Encode()
+	assert.NoError(t, err)
+
+	header := macaroon.ToAuthorizationHeader(tok)
+	notAfter := time.Now().Add(time.Hour).Unix()
+
+	attenuated, err := Attenuate(header, "https://api", fmt.Sprintf(`[{"type": "ValidityWindow", "body": {"not_before": 0, "not_after": %d}}]`, notAfter))
+	assert.NoError(t, err)
+
+	b, err := macaroon.ParseBundle(attenuated, "https://api")
+	assert.NoError(t, err)
+	am, err := macaroon.Decode(b.Permission)
+	assert.NoError(t, err)
+	assert.Equal(t, notAfter, am.Expiration().Unix())
+
+	inspected, err := Inspect(attenuated, "https://api")
+	assert.NoError(t, err)
+
+	var tk Token
+	assert.NoError(t, json.Unmarshal([]byte(inspected), &tk))
+	assert.Equal(t, "https://api", tk.Location)
+	assert.Equal(t, []string{"https://auth"}, tk.ThirdParties)
+	assert.Equal(t, 1, len(tk.Caveats.Caveats))
+
+	_, err = Attenuate(header, "https://api", `[{"type": "Bogus", "body": {}}]`)
+	assert.Error(t, err)
+	_, err = Attenuate("garbage", "https://api", `[]`)
+	assert.Error(t, err)
+}
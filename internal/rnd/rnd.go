@@ -0,0 +1,87 @@
+// Package rnd is the source of randomness for keys, nonces and other random
+// values generated by this module. By default it reads from crypto/rand.
+//
+// Tests can swap in a deterministic reader with [SetReaderForTesting], so
+// that golden files and cross-language test vectors can be generated
+// reproducibly. Nothing outside of tests should ever do that.
+package rnd
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/crypto/chacha20"
+)
+
+// override is the reader installed by [SetReaderForTesting], if any. It's
+// only locked when set, so the default crypto/rand path stays lock-free.
+var override atomic.Pointer[lockedReader]
+
+type lockedReader struct {
+	mu sync.Mutex
+	r  io.Reader
+}
+
+// Read fills buf with random bytes.
+func Read(buf []byte) error {
+	if lr := override.Load(); lr != nil {
+		lr.mu.Lock()
+		defer lr.mu.Unlock()
+
+		_, err := io.ReadFull(lr.r, buf)
+		return err
+	}
+
+	_, err := io.ReadFull(rand.Reader, buf)
+	return err
+}
+
+// Bytes returns n random bytes, panicking if the source of randomness fails.
+func Bytes(n int) []byte {
+	buf := make([]byte, n)
+	if err := Read(buf); err != nil {
+		log.Panicf("crypto random failed: read of %d: err: %s", n, err)
+	}
+
+	return buf
+}
+
+// SetReaderForTesting replaces the source of randomness, returning a function
+// that restores the previous source. It must only be used in tests.
+func SetReaderForTesting(r io.Reader) (restore func()) {
+	prev := override.Swap(&lockedReader{r: r})
+
+	return func() {
+		override.Store(prev)
+	}
+}
+
+// NewSeededReader returns a deterministic reader producing the ChaCha20
+// keystream for a key derived from the seed.
+func NewSeededReader(seed []byte) io.Reader {
+	key := sha256.Sum256(seed)
+
+	c, err := chacha20.NewUnauthenticatedCipher(key[:], make([]byte, chacha20.NonceSize))
+	if err != nil {
+		// only fails on bad key or nonce sizes
+		panic(err)
+	}
+
+	return &seededReader{c}
+}
+
+type seededReader struct {
+	c *chacha20.Cipher
+}
+
+func (r *seededReader) Read(buf []byte) (int, error) {
+	for i := range buf {
+		buf[i] = 0
+	}
+	r.c.XORKeyStream(buf, buf)
+	return len(buf), nil
+}
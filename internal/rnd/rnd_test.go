@@ -0,0 +1,36 @@
+package rnd
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestSeededReader(t *testing.T) {
+	restore := SetReaderForTesting(NewSeededReader([]byte("seed")))
+	a := Bytes(64)
+	restore()
+
+	restore = SetReaderForTesting(NewSeededReader([]byte("seed")))
+	b := Bytes(32)
+	c := Bytes(32)
+	restore()
+
+	assert.Equal(t, a, append(b, c...))
+
+	restore = SetReaderForTesting(NewSeededReader([]byte("other seed")))
+	d := Bytes(64)
+	restore()
+
+	assert.NotEqual(t, a, d)
+	assert.NotEqual(t, a, Bytes(64))
+}
+
+func TestRestoreDefaultReader(t *testing.T) {
+	restore := SetReaderForTesting(NewSeededReader([]byte("seed")))
+	a := Bytes(32)
+	restore()
+
+	assert.Zero(t, override.Load())
+	assert.NotEqual(t, a, Bytes(32))
+}
@@ -0,0 +1,179 @@
+// Package introspect implements an HTTP token introspection endpoint, in the
+// style of RFC 7662: an internal "what is this token" service that verifies a
+// token and describes what it allows.
+//
+// Clients POST the token, along with its discharges, as the form parameter
+// "token", formatted as for an Authorization header (see
+// [macaroon.ToAuthorizationHeader]). The response is a JSON [Response].
+// Tokens that fail to verify are reported as inactive, without saying why.
+//
+// The endpoint reveals the contents of any token it's given, so it should
+// only be exposed to trusted internal clients.
+package introspect
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/superfly/macaroon"
+)
+
+// Response describes a token.
+type Response struct {
+	// Active is whether the token verified and is currently within its
+	// validity windows. The other fields are only set for active tokens.
+	Active bool `json:"active"`
+
+	Location string `json:"location,omitempty"`
+
+	// KID is the token's key ID and UUID is its nonce's UUID (see
+	// [macaroon.Nonce.UUID]).
+	KID  []byte `json:"kid,omitempty"`
+	UUID string `json:"uuid,omitempty"`
+
+	// Caveats are the verified caveats, excluding attestations.
+	Caveats *macaroon.CaveatSet `json:"caveats,omitempty"`
+
+	// Attestations are the verified attestations.
+	Attestations *macaroon.CaveatSet `json:"attestations,omitempty"`
+
+	// Scope summarizes the caveats, as returned by [Handler.Summarize].
+	Scope any `json:"scope,omitempty"`
+
+	// ExpiresAt is the earliest expiry of the verified caveats, if any.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Handler is an http.Handler serving token introspection requests.
+type Handler struct {
+	// Verify verifies tokens, e.g. the Verify method of a [macaroon.Keyring].
+	Verify macaroon.VerifyFunc
+
+	// Location selects the permission token when the request includes tokens
+	// for several locations. It's ignored for envelopes.
+	Location string
+
+	// Summarize summarizes the verified caveats for the response's Scope.
+	// If nil, the scope is a description of each caveat: its String method
+	// for caveats implementing fmt.Stringer, or its type name.
+	Summarize func(cs *macaroon.CaveatSet) (any, error)
+
+	// Now returns the time against which tokens' validity windows are
+	// checked. If nil, the current time is used.
+	Now func() time.Time
+}
+
+// New creates a Handler that verifies tokens for the location with the
+// keyring.
+func New(k *macaroon.Keyring, location string) *Handler {
+	return &Handler{Verify: k.Verify, Location: location}
+}
+
+// maxRequestSize bounds the size of introspection requests.
+const maxRequestSize = 1 << 20
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+
+	header := r.PostFormValue("token")
+	if header == "" {
+		http.Error(w, "missing token", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.Introspect(header)
+	if err != nil {
+		http.Error(w, "introspection failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Introspect describes the token in an Authorization header. Tokens that
+// can't be parsed or verified are described as inactive. An error is only
+// returned if the token's caveats can't be summarized.
+func (h *Handler) Introspect(header string) (*Response, error) {
+	b, err := macaroon.ParseBundle(header, h.Location)
+	if err != nil {
+		return &Response{}, nil
+	}
+
+	m, err := macaroon.Decode(b.Permission)
+	if err != nil {
+		return &Response{}, nil
+	}
+
+	cs, err := h.Verify(m, b.Discharges)
+	if err != nil {
+		return &Response{}, nil
+	}
+
+	// verification doesn't check the time, so tokens that have expired or
+	// aren't yet valid still verify
+	now := time.Now()
+	if h.Now != nil {
+		now = h.Now()
+	}
+	for _, vw := range macaroon.GetCaveats[*macaroon.ValidityWindow](cs) {
+		if now.Before(time.Unix(vw.NotBefore, 0)) || now.After(time.Unix(vw.NotAfter, 0)) {
+			return &Response{}, nil
+		}
+	}
+
+	resp := &Response{
+		Active:       true,
+		Location:     m.Location,
+		KID:          m.Nonce.KID,
+		UUID:         m.Nonce.UUID().String(),
+		Caveats:      macaroon.NewCaveatSet(),
+		Attestations: macaroon.NewCaveatSet(),
+	}
+
+	for _, cav := range cs.Caveats {
+		if cav.IsAttestation() {
+			resp.Attestations.Caveats = append(resp.Attestations.Caveats, cav)
+		} else {
+			resp.Caveats.Caveats = append(resp.Caveats.Caveats, cav)
+		}
+	}
+
+	for _, vw := range macaroon.GetCaveats[*macaroon.ValidityWindow](cs) {
+		if exp := time.Unix(vw.NotAfter, 0).UTC(); resp.ExpiresAt == nil || exp.Before(*resp.ExpiresAt) {
+			resp.ExpiresAt = &exp
+		}
+	}
+
+	summarize := h.Summarize
+	if summarize == nil {
+		summarize = describe
+	}
+
+	if resp.Scope, err = summarize(resp.Caveats); err != nil {
+		return nil, fmt.Errorf("introspect: summarize: %w", err)
+	}
+
+	return resp, nil
+}
+
+func describe(cs *macaroon.CaveatSet) (any, error) {
+	ret := make([]string, 0, len(cs.Caveats))
+	for _, cav := range cs.Caveats {
+		if s, ok := cav.(fmt.Stringer); ok {
+			ret = append(ret, s.String())
+		} else {
+			ret = append(ret, macaroon.CaveatTypeName(cav.CaveatType()))
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,127 @@
+package introspect
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+)
+
+func TestHandler(t *testing.T) {
+	var (
+		kid    = []byte("kid")
+		key    = macaroon.NewSigningKey()
+		kr     = macaroon.NewKeyring()
+		expiry = time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	)
+	assert.NoError(t, kr.Add(kid, "test", key))
+
+	m, err := macaroon.New(kid, "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(
+		&macaroon.ValidityWindow{NotBefore: time.Now().Unix(), NotAfter: expiry.Add(time.Hour).Unix()},
+		&macaroon.ValidityWindow{NotBefore: time.Now().Unix(), NotAfter: expiry.Unix()},
+	))
+	tok, err := m.Encode()
+	assert.NoError(t, err)
+
+	srv := httptest.NewServer(New(kr, "https://api"))
+	t.Cleanup(srv.Close)
+
+	introspect := func(t *testing.T, token string) (int, *Response) {
+		t.Helper()
+
+		resp, err := http.PostForm(srv.URL, url.Values{"token": {token}})
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return resp.StatusCode, nil
+		}
+
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		ret := new(Response)
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(ret))
+		return resp.StatusCode, ret
+	}
+
+	t.Run("active", func(t *testing.T) {
+		status, resp := introspect(t, macaroon.ToAuthorizationHeader(tok))
+		assert.Equal(t, http.StatusOK, status)
+		assert.True(t, resp.Active)
+		assert.Equal(t, "https://api", resp.Location)
+		assert.Equal(t, kid, resp.KID)
+		assert.Equal(t, m.Nonce.UUID().String(), resp.UUID)
+		assert.Equal(t, 2, len(resp.Caveats.Caveats))
+		assert.Equal(t, 0, len(resp.Attestations.Caveats))
+		assert.Equal(t, expiry, *resp.ExpiresAt)
+		assert.Equal[any](t, []any{"ValidityWindow", "ValidityWindow"}, resp.Scope)
+	})
+
+	t.Run("inactive", func(t *testing.T) {
+		other, err := macaroon.New(kid, "https://api", macaroon.NewSigningKey())
+		assert.NoError(t, err)
+		otherTok, err := other.Encode()
+		assert.NoError(t, err)
+
+		status, resp := introspect(t, macaroon.ToAuthorizationHeader(otherTok))
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, &Response{}, resp)
+
+		// tokens outside their validity windows are inactive
+		expired, err := macaroon.New(kid, "https://api", key)
+		assert.NoError(t, err)
+		assert.NoError(t, expired.Add(&macaroon.ValidityWindow{NotBefore: time.Now().Add(-2 * time.Hour).Unix(), NotAfter: time.Now().Add(-time.Hour).Unix()}))
+		expiredTok, err := expired.Encode()
+		assert.NoError(t, err)
+
+		status, resp = introspect(t, macaroon.ToAuthorizationHeader(expiredTok))
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, &Response{}, resp)
+
+		h := New(kr, "https://api")
+		resp, err = h.Introspect(macaroon.ToAuthorizationHeader(tok))
+		assert.NoError(t, err)
+		assert.True(t, resp.Active)
+
+		h.Now = func() time.Time { return expiry.Add(time.Second) }
+		resp, err = h.Introspect(macaroon.ToAuthorizationHeader(tok))
+		assert.NoError(t, err)
+		assert.Equal(t, &Response{}, resp)
+
+		h.Now = func() time.Time { return time.Now().Add(-time.Hour) }
+		resp, err = h.Introspect(macaroon.ToAuthorizationHeader(tok))
+		assert.NoError(t, err)
+		assert.Equal(t, &Response{}, resp)
+
+		status, resp = introspect(t, "FlyV1 garbage")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, &Response{}, resp)
+	})
+
+	t.Run("bad request", func(t *testing.T) {
+		status, _ := introspect(t, "")
+		assert.Equal(t, http.StatusBadRequest, status)
+
+		resp, err := http.Get(srv.URL)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+
+	t.Run("summarize", func(t *testing.T) {
+		h := New(kr, "https://api")
+		h.Summarize = func(cs *macaroon.CaveatSet) (any, error) {
+			return len(cs.Caveats), nil
+		}
+
+		resp, err := h.Introspect(macaroon.ToAuthorizationHeader(tok))
+		assert.NoError(t, err)
+		assert.Equal[any](t, 2, resp.Scope)
+	})
+}
@@ -0,0 +1,228 @@
+// Package invariants provides checks of properties that should hold for any
+// macaroon and any caveat type. They're meant to be run from tests, against
+// user-defined caveats and Accesses, either with hand-picked inputs or with
+// inputs generated by a property-based testing tool like testing/quick.
+//
+// Each check returns an error wrapping [ErrViolation] describing the first
+// counterexample it finds, or nil if the property holds for its inputs.
+package invariants
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/superfly/macaroon"
+)
+
+// ErrViolation is returned when an invariant doesn't hold.
+var ErrViolation = errors.New("invariant violated")
+
+const (
+	testKID      = "invariants"
+	testLocation = "https://invariants.test"
+)
+
+// AttenuationNarrows checks that attenuating a token never broadens access:
+// any of the accesses allowed by a token with the base and extra caveats is
+// also allowed by a token with only the base caveats. Tokens are minted with
+// a throwaway key, encoded, decoded and verified before being validated, so
+// the whole path a real token takes is exercised. Since caveats are checked
+// conjunctively, violations point at caveats whose decisions depend on
+// something other than the access, like mutable state.
+func AttenuationNarrows(base, extra []macaroon.Caveat, accesses ...macaroon.Access) error {
+	key := macaroon.NewSigningKey()
+
+	m, err := macaroon.New([]byte(testKID), testLocation, key)
+	if err != nil {
+		return err
+	}
+	if err := m.Add(base...); err != nil {
+		return fmt.Errorf("add base caveats: %w", err)
+	}
+
+	baseCavs, err := reverify(m, key)
+	if err != nil {
+		return fmt.Errorf("base token: %w", err)
+	}
+
+	if err := m.Add(extra...); err != nil {
+		return fmt.Errorf("add extra caveats: %w", err)
+	}
+
+	attenuatedCavs, err := reverify(m, key)
+	if err != nil {
+		return fmt.Errorf("attenuated token: %w", err)
+	}
+
+	for i, access := range accesses {
+		if attenuatedCavs.Validate(access) != nil {
+			continue
+		}
+
+		if err := baseCavs.Validate(access); err != nil {
+			return fmt.Errorf("%w: attenuation allowed access %d, which was prohibited before: %s", ErrViolation, i, err)
+		}
+	}
+
+	return nil
+}
+
+// reverify round-trips m through its encoding and verifies it.
+func reverify(m *macaroon.Macaroon, key macaroon.SigningKey) (*macaroon.CaveatSet, error) {
+	tok, err := m.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := macaroon.Decode(tok)
+	if err != nil {
+		return nil, err
+	}
+
+	return decoded.Verify(key, nil, nil)
+}
+
+// corruptionMasks are XORed into each byte by CorruptionDetected.
+var corruptionMasks = []byte{0x01, 0x80, 0xff}
+
+// CorruptionDetected checks that corrupting any single byte of a token or
+// of one of its discharges doesn't produce a bundle that verifies with
+// different signed contents. The token's location isn't signed, so
+// corruption that only alters it is tolerated. The bundle must verify before
+// it's corrupted.
+func CorruptionDetected(k macaroon.SigningKey, tok []byte, discharges [][]byte, trusted3Ps map[string]macaroon.EncryptionKey) error {
+	m, err := macaroon.Decode(tok)
+	if err != nil {
+		return err
+	}
+	if _, err := m.Verify(k, discharges, trusted3Ps); err != nil {
+		return fmt.Errorf("uncorrupted token: %w", err)
+	}
+
+	check := func(what string, orig []byte, verify func([]byte) error) error {
+		for i := range orig {
+			for _, mask := range corruptionMasks {
+				buf := append([]byte{}, orig...)
+				buf[i] ^= mask
+
+				cm, err := macaroon.Decode(buf)
+				if err != nil {
+					continue
+				}
+
+				if err := verify(buf); err != nil {
+					continue
+				}
+
+				same, err := sameSignedContents(orig, cm)
+				if err != nil {
+					return err
+				}
+				if !same {
+					return fmt.Errorf("%w: %s verified with byte %d corrupted (^%#02x)", ErrViolation, what, i, mask)
+				}
+			}
+		}
+
+		return nil
+	}
+
+	err = check("token", tok, func(buf []byte) error {
+		cm, err := macaroon.Decode(buf)
+		if err != nil {
+			return err
+		}
+		_, err = cm.Verify(k, discharges, trusted3Ps)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for di := range discharges {
+		err = check(fmt.Sprintf("discharge %d", di), discharges[di], func(buf []byte) error {
+			corrupted := append([][]byte{}, discharges...)
+			corrupted[di] = buf
+			_, err := m.Verify(k, corrupted, trusted3Ps)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sameSignedContents checks whether the decoded token cm has the same nonce,
+// caveats and signature as the encoded token orig.
+func sameSignedContents(orig []byte, cm *macaroon.Macaroon) (bool, error) {
+	om, err := macaroon.Decode(orig)
+	if err != nil {
+		return false, err
+	}
+
+	oc, err := om.UnsafeCaveats.MarshalMsgpack()
+	if err != nil {
+		return false, err
+	}
+
+	cc, err := cm.UnsafeCaveats.MarshalMsgpack()
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(om.Nonce.MustEncode(), cm.Nonce.MustEncode()) &&
+		bytes.Equal(oc, cc) &&
+		bytes.Equal(om.Tail, cm.Tail), nil
+}
+
+// RoundTrip checks that the caveats survive msgpack and JSON encoding
+// unchanged. Caveats that don't round-trip can't be verified once they've
+// been added to a token.
+func RoundTrip(caveats ...macaroon.Caveat) error {
+	for i, c := range caveats {
+		cs := macaroon.NewCaveatSet(c)
+
+		enc, err := cs.MarshalMsgpack()
+		if err != nil {
+			return fmt.Errorf("caveat %d: %w", i, err)
+		}
+
+		decoded, err := macaroon.DecodeCaveats(enc)
+		if err != nil {
+			return fmt.Errorf("%w: caveat %d doesn't decode: %s", ErrViolation, i, err)
+		}
+
+		reenc, err := decoded.MarshalMsgpack()
+		if err != nil {
+			return fmt.Errorf("caveat %d: %w", i, err)
+		}
+
+		if !bytes.Equal(enc, reenc) {
+			return fmt.Errorf("%w: caveat %d changes after msgpack round-trip", ErrViolation, i)
+		}
+
+		js, err := json.Marshal(cs)
+		if err != nil {
+			return fmt.Errorf("caveat %d: %w", i, err)
+		}
+
+		fromJSON := macaroon.NewCaveatSet()
+		if err := json.Unmarshal(js, fromJSON); err != nil {
+			return fmt.Errorf("%w: caveat %d doesn't decode from JSON: %s", ErrViolation, i, err)
+		}
+
+		if reenc, err = fromJSON.MarshalMsgpack(); err != nil {
+			return fmt.Errorf("caveat %d: %w", i, err)
+		}
+
+		if !bytes.Equal(enc, reenc) {
+			return fmt.Errorf("%w: caveat %d changes after JSON round-trip", ErrViolation, i)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package invariants
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+	"github.com/superfly/macaroon/flyio"
+	"github.com/superfly/macaroon/resset"
+)
+
+func TestAttenuationNarrows(t *testing.T) {
+	var (
+		base  = []macaroon.Caveat{&flyio.Organization{ID: 1, Mask: macaroon.ActionAll}}
+		extra = []macaroon.Caveat{&flyio.Apps{Apps: resset.New(macaroon.ActionRead, uint64(2))}}
+	)
+
+	var accesses []macaroon.Access
+	for _, action := range []macaroon.Action{macaroon.ActionRead, macaroon.ActionWrite, macaroon.ActionAll} {
+		for _, app := range []uint64{2, 3} {
+			app := app
+			accesses = append(accesses,
+				&flyio.Access{OrgID: 1, Action: action, AppID: &app},
+				&flyio.Access{OrgID: 2, Action: action, AppID: &app},
+			)
+		}
+	}
+
+	assert.NoError(t, AttenuationNarrows(base, extra, accesses...))
+
+	// caveats whose decisions aren't deterministic break the property
+	err := AttenuationNarrows([]macaroon.Caveat{&flaky{}}, extra, accesses...)
+	assert.True(t, errors.Is(err, ErrViolation))
+}
+
+func TestCorruptionDetected(t *testing.T) {
+	var (
+		key = macaroon.NewSigningKey()
+		ka  = macaroon.NewEncryptionKey()
+	)
+
+	m, err := macaroon.New([]byte("kid"), flyio.LocationPermission, key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(&flyio.Organization{ID: 1, Mask: macaroon.ActionRead}))
+	assert.NoError(t, m.Add3P(ka, flyio.LocationAuthentication))
+	tok, err := m.Encode()
+	assert.NoError(t, err)
+
+	cid, err := m.ThirdPartyCID(flyio.LocationAuthentication)
+	assert.NoError(t, err)
+	_, dm, err := macaroon.DischargeCID(ka, flyio.LocationAuthentication, cid)
+	assert.NoError(t, err)
+	assert.NoError(t, dm.Add(&macaroon.ValidityWindow{NotBefore: time.Now().Unix(), NotAfter: time.Now().Add(time.Hour).Unix()}))
+	dtok, err := dm.Encode()
+	assert.NoError(t, err)
+
+	assert.NoError(t, CorruptionDetected(key, tok, [][]byte{dtok}, map[string]macaroon.EncryptionKey{flyio.LocationAuthentication: ka}))
+
+	// the wrong key isn't a violation, but the bundle has to verify
+	assert.Error(t, CorruptionDetected(macaroon.NewSigningKey(), tok, [][]byte{dtok}, nil))
+}
+
+func TestRoundTrip(t *testing.T) {
+	assert.NoError(t, RoundTrip(
+		&flyio.Organization{ID: 1, Mask: macaroon.ActionRead},
+		&flyio.Apps{Apps: resset.New(macaroon.ActionRead, uint64(1), uint64(2))},
+		&macaroon.ValidityWindow{NotBefore: 1, NotAfter: 2},
+	))
+}
+
+const cavFlaky = macaroon.CavMinUserDefined + 1000
+
+// flaky is a caveat that alternates between allowing and prohibiting.
+type flaky struct {
+	Dummy int
+}
+
+var flakyCalls int
+
+func init() { macaroon.RegisterCaveatType("InvariantsFlaky", cavFlaky, &flaky{}) }
+
+func (c *flaky) CaveatType() macaroon.CaveatType { return cavFlaky }
+func (c *flaky) IsAttestation() bool             { return false }
+
+func (c *flaky) Prohibits(macaroon.Access) error {
+	if flakyCalls++; flakyCalls%2 == 1 {
+		return nil
+	}
+	return macaroon.ErrUnauthorized
+}
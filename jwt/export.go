@@ -0,0 +1,285 @@
+package jwt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/superfly/macaroon"
+)
+
+// ErrUnmappedCaveat is returned by [FromCaveats] when a caveat can't be
+// represented in a JWT.
+var ErrUnmappedCaveat = errors.New("jwt: no claim mapping for caveat")
+
+// Claims are the claims of a JWT summarizing a caveat set.
+type Claims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	Expiry    int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+
+	// Scopes are encoded as the space-separated "scope" claim. Each scope
+	// comes from a caveat and, like caveats, they restrict the JWT: consumers
+	// must check that the request is permitted by every scope.
+	Scopes []string `json:"-"`
+
+	// Extra holds any other claims.
+	Extra map[string]any `json:"-"`
+}
+
+// Audience is the "aud" claim, identifying the JWT's recipients. RFC 7519
+// allows it to be a single string or an array of strings; it's encoded as a
+// string when it has one element.
+type Audience []string
+
+// Contains returns whether aud is among the recipients.
+func (a Audience) Contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = Audience{s}
+		return nil
+	}
+
+	var l []string
+	if err := json.Unmarshal(b, &l); err != nil {
+		return fmt.Errorf("jwt: aud claim must be a string or array of strings: %w", err)
+	}
+	*a = l
+	return nil
+}
+
+// SetSubject sets the subject, failing if a different subject is already
+// set.
+func (c *Claims) SetSubject(sub string) error {
+	if c.Subject != "" && c.Subject != sub {
+		return fmt.Errorf("jwt: conflicting subjects %q and %q", c.Subject, sub)
+	}
+	c.Subject = sub
+	return nil
+}
+
+// Restrict narrows the validity period of the claims to the one specified.
+// Zero times are ignored.
+func (c *Claims) Restrict(notBefore, expiry time.Time) {
+	if !expiry.IsZero() && (c.Expiry == 0 || expiry.Unix() < c.Expiry) {
+		c.Expiry = expiry.Unix()
+	}
+	if !notBefore.IsZero() && notBefore.Unix() > c.NotBefore {
+		c.NotBefore = notBefore.Unix()
+	}
+}
+
+// AddScope adds scopes to the space-separated "scope" claim.
+func (c *Claims) AddScope(scopes ...string) {
+	c.Scopes = append(c.Scopes, scopes...)
+}
+
+func (c Claims) MarshalJSON() ([]byte, error) {
+	type registered Claims
+
+	buf, err := json.Marshal(registered(c))
+	if err != nil || (len(c.Scopes) == 0 && len(c.Extra) == 0) {
+		return buf, err
+	}
+
+	m := make(map[string]any, len(c.Extra)+8)
+	for k, v := range c.Extra {
+		m[k] = v
+	}
+	if err := json.Unmarshal(buf, &m); err != nil {
+		return nil, err
+	}
+	if len(c.Scopes) != 0 {
+		m["scope"] = strings.Join(c.Scopes, " ")
+	}
+
+	return json.Marshal(m)
+}
+
+func (c *Claims) UnmarshalJSON(b []byte) error {
+	type registered Claims
+
+	// NumericDates may have fractional seconds, which are dropped
+	var r struct {
+		registered
+		Expiry    *json.Number `json:"exp,omitempty"`
+		NotBefore *json.Number `json:"nbf,omitempty"`
+		IssuedAt  *json.Number `json:"iat,omitempty"`
+	}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	for _, d := range []struct {
+		name string
+		n    *json.Number
+		v    *int64
+	}{
+		{"exp", r.Expiry, &r.registered.Expiry},
+		{"nbf", r.NotBefore, &r.registered.NotBefore},
+		{"iat", r.IssuedAt, &r.registered.IssuedAt},
+	} {
+		if d.n == nil {
+			continue
+		}
+		v, err := numericDate(*d.n)
+		if err != nil {
+			return fmt.Errorf("jwt: %s claim: %w", d.name, err)
+		}
+		*d.v = v
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+
+	for _, k := range []string{"iss", "sub", "aud", "exp", "nbf", "iat"} {
+		delete(m, k)
+	}
+
+	if scope, ok := m["scope"].(string); ok {
+		r.registered.Scopes = strings.Fields(scope)
+		delete(m, "scope")
+	}
+
+	if len(m) != 0 {
+		r.registered.Extra = m
+	}
+
+	*c = Claims(r.registered)
+	return nil
+}
+
+// numericDate parses a NumericDate, rounding fractional seconds down.
+func numericDate(n json.Number) (int64, error) {
+	if i, err := n.Int64(); err == nil {
+		return i, nil
+	}
+
+	f, err := n.Float64()
+	switch {
+	case err != nil:
+		return 0, err
+	case math.IsNaN(f) || f < math.MinInt64 || f >= math.MaxInt64:
+		return 0, fmt.Errorf("NumericDate %s out of range", n)
+	default:
+		return int64(math.Floor(f)), nil
+	}
+}
+
+// ClaimMapper adds claims representing a caveat. Mappers must only add
+// claims that restrict the JWT at least as much as the caveat restricts the
+// macaroon.
+type ClaimMapper func(c macaroon.Caveat, claims *Claims) error
+
+var (
+	mappers   = map[macaroon.CaveatType]ClaimMapper{}
+	mappersMu sync.RWMutex
+)
+
+// RegisterClaimMapper registers the mapper for caveats of the specified
+// type, replacing any existing mapper.
+func RegisterClaimMapper(typ macaroon.CaveatType, m ClaimMapper) {
+	mappersMu.Lock()
+	defer mappersMu.Unlock()
+
+	mappers[typ] = m
+}
+
+func init() {
+	RegisterClaimMapper(macaroon.CavValidityWindow, func(c macaroon.Caveat, claims *Claims) error {
+		vw := c.(*macaroon.ValidityWindow)
+		claims.Restrict(time.Unix(vw.NotBefore, 0), time.Unix(vw.NotAfter, 0))
+		return nil
+	})
+}
+
+// Options configure [FromCaveats].
+type Options struct {
+	Issuer   string
+	Audience string
+	KeyID    string
+
+	// TTL caps the JWT's lifetime. If zero, the JWT expires with the caveat
+	// set.
+	TTL time.Duration
+
+	// Now is used to get the current time. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+// FromCaveats summarizes a caveat set returned by [macaroon.Macaroon.Verify]
+// as a JWT signed by s. Each caveat is translated by its registered
+// [ClaimMapper]. Attestations without a mapper are omitted, but any other
+// caveat without a mapper causes FromCaveats to fail with ErrUnmappedCaveat.
+func FromCaveats(cs *macaroon.CaveatSet, s Signer, opts Options) (string, error) {
+	claims, err := ClaimsFromCaveats(cs, opts)
+	if err != nil {
+		return "", err
+	}
+
+	return Sign(s, opts.KeyID, claims)
+}
+
+// ClaimsFromCaveats is like [FromCaveats], but returns the claims rather
+// than a signed JWT.
+func ClaimsFromCaveats(cs *macaroon.CaveatSet, opts Options) (*Claims, error) {
+	if !cs.Verified() {
+		return nil, errors.New("jwt: caveats not verified")
+	}
+
+	now := time.Now()
+	if opts.Now != nil {
+		now = opts.Now()
+	}
+
+	claims := &Claims{Issuer: opts.Issuer, IssuedAt: now.Unix()}
+	if opts.Audience != "" {
+		claims.Audience = Audience{opts.Audience}
+	}
+	if opts.TTL != 0 {
+		claims.Restrict(time.Time{}, now.Add(opts.TTL))
+	}
+
+	mappersMu.RLock()
+	defer mappersMu.RUnlock()
+
+	for _, cav := range cs.Caveats {
+		m, ok := mappers[cav.CaveatType()]
+		switch {
+		case ok:
+			if err := m(cav, claims); err != nil {
+				return nil, err
+			}
+		case cav.IsAttestation():
+		default:
+			return nil, fmt.Errorf("%w: %T", ErrUnmappedCaveat, cav)
+		}
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,167 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/superfly/macaroon"
+)
+
+var (
+	ErrExpired     = errors.New("jwt: expired")
+	ErrNotYetValid = errors.New("jwt: not yet valid")
+	ErrBadIssuer   = errors.New("jwt: wrong issuer")
+	ErrBadAudience = errors.New("jwt: wrong audience")
+)
+
+// ClaimTranslator translates the value of a JWT claim into caveats (or, when
+// minting proofs, attestations). The value is as decoded by encoding/json.
+type ClaimTranslator func(value any) ([]macaroon.Caveat, error)
+
+var (
+	translators   = map[string]ClaimTranslator{}
+	translatorsMu sync.RWMutex
+)
+
+// RegisterClaimTranslator registers the translator for the named claim,
+// replacing any existing translator. A nil translator removes the existing
+// one. The "exp" and "nbf" claims are always
+// translated into a [macaroon.ValidityWindow] and can't be registered.
+func RegisterClaimTranslator(claim string, t ClaimTranslator) {
+	switch claim {
+	case "exp", "nbf":
+		panic(fmt.Sprintf("jwt: claim %s can't be translated", claim))
+	}
+
+	translatorsMu.Lock()
+	defer translatorsMu.Unlock()
+
+	if t == nil {
+		delete(translators, claim)
+		return
+	}
+	translators[claim] = t
+}
+
+// IngestOptions configure [ToCaveats].
+type IngestOptions struct {
+	// Issuer, if set, must match the JWT's issuer, and Audience, if set,
+	// must be among its audiences.
+	Issuer   string
+	Audience string
+
+	// Leeway is the clock skew tolerated when checking the JWT's validity
+	// period.
+	Leeway time.Duration
+
+	// Now is used to get the current time. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+// ToCaveats parses and validates a JWT, checking its signature with the
+// Verifier returned by keyfunc (see [Parse]), and translates its claims into
+// caveats to be added to a newly minted macaroon. The JWT must have an
+// expiry, which is carried over to the caveats as a [macaroon.ValidityWindow].
+// Other claims are translated by translators registered with
+// [RegisterClaimTranslator]; claims without a translator are ignored.
+func ToCaveats(tok string, keyfunc func(Header) (Verifier, error), opts IngestOptions) ([]macaroon.Caveat, *Claims, error) {
+	var claims Claims
+	if _, err := Parse(tok, keyfunc, &claims); err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if opts.Now != nil {
+		now = opts.Now()
+	}
+
+	switch {
+	case claims.Expiry == 0:
+		return nil, nil, fmt.Errorf("%w: no expiry", ErrMalformed)
+	case now.Add(-opts.Leeway).Unix() >= claims.Expiry:
+		return nil, nil, ErrExpired
+	case claims.NotBefore != 0 && now.Add(opts.Leeway).Unix() < claims.NotBefore:
+		return nil, nil, ErrNotYetValid
+	case opts.Issuer != "" && claims.Issuer != opts.Issuer:
+		return nil, nil, fmt.Errorf("%w: %q", ErrBadIssuer, claims.Issuer)
+	case opts.Audience != "" && !claims.Audience.Contains(opts.Audience):
+		return nil, nil, fmt.Errorf("%w: %q", ErrBadAudience, []string(claims.Audience))
+	}
+
+	notBefore := claims.NotBefore
+	if notBefore == 0 {
+		notBefore = now.Unix()
+	}
+
+	cavs := []macaroon.Caveat{&macaroon.ValidityWindow{NotBefore: notBefore, NotAfter: claims.Expiry}}
+
+	values := map[string]any{
+		"iss":   claims.Issuer,
+		"sub":   claims.Subject,
+		"aud":   audienceValue(claims.Audience),
+		"iat":   claims.IssuedAt,
+		"scope": claims.Scopes,
+	}
+	for k, v := range claims.Extra {
+		values[k] = v
+	}
+
+	translatorsMu.RLock()
+	defer translatorsMu.RUnlock()
+
+	// translate in a stable order, so tokens are minted deterministically
+	names := make([]string, 0, len(translators))
+	for claim := range translators {
+		names = append(names, claim)
+	}
+	sort.Strings(names)
+
+	for _, claim := range names {
+		t := translators[claim]
+		v, ok := values[claim]
+		if !ok || isZero(v) {
+			continue
+		}
+
+		tcavs, err := t(v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("jwt: translate %s claim: %w", claim, err)
+		}
+		cavs = append(cavs, tcavs...)
+	}
+
+	return cavs, &claims, nil
+}
+
+// audienceValue returns the aud claim as decoded by encoding/json: a string,
+// or a []any for several audiences.
+func audienceValue(a Audience) any {
+	switch len(a) {
+	case 0:
+		return nil
+	case 1:
+		return a[0]
+	default:
+		ret := make([]any, len(a))
+		for i, v := range a {
+			ret[i] = v
+		}
+		return ret
+	}
+}
+
+func isZero(v any) bool {
+	switch tv := v.(type) {
+	case string:
+		return tv == ""
+	case int64:
+		return tv == 0
+	case []string:
+		return len(tv) == 0
+	default:
+		return v == nil
+	}
+}
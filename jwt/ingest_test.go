@@ -0,0 +1,90 @@
+package jwt
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+)
+
+func TestToCaveats(t *testing.T) {
+	var (
+		key     = HS256("secret")
+		keyfunc = func(Header) (Verifier, error) { return key, nil }
+		now     = time.Unix(1700000000, 0)
+		opts    = IngestOptions{Issuer: "idp", Audience: "api", Now: func() time.Time { return now }}
+	)
+
+	RegisterClaimTranslator("scope", func(v any) ([]macaroon.Caveat, error) {
+		return []macaroon.Caveat{&macaroon.Obligation{Type: macaroon.ObligationLog, Value: "jwt"}}, nil
+	})
+	defer RegisterClaimTranslator("scope", nil)
+
+	sign := func(c Claims) string {
+		tok, err := Sign(key, "", c)
+		assert.NoError(t, err)
+		return tok
+	}
+
+	good := Claims{Issuer: "idp", Audience: Audience{"api"}, Subject: "alice", Expiry: now.Add(time.Hour).Unix(), Scopes: []string{"read"}}
+
+	cavs, claims, err := ToCaveats(sign(good), keyfunc, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", claims.Subject)
+	assert.Equal(t, []macaroon.Caveat{
+		&macaroon.ValidityWindow{NotBefore: now.Unix(), NotAfter: now.Add(time.Hour).Unix()},
+		&macaroon.Obligation{Type: macaroon.ObligationLog, Value: "jwt"},
+	}, cavs)
+
+	// the caveats can be used to mint a token
+	mkey := macaroon.NewSigningKey()
+	m, err := macaroon.New([]byte("kid"), "https://api", mkey)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavs...))
+
+	for _, tc := range []struct {
+		mutate func(*Claims)
+		err    error
+	}{
+		{func(c *Claims) { c.Expiry = now.Add(-time.Minute).Unix() }, ErrExpired},
+		{func(c *Claims) { c.NotBefore = now.Add(time.Minute).Unix() }, ErrNotYetValid},
+		{func(c *Claims) { c.Issuer = "other" }, ErrBadIssuer},
+		{func(c *Claims) { c.Audience = Audience{"other"} }, ErrBadAudience},
+		{func(c *Claims) { c.Audience = Audience{"other", "another"} }, ErrBadAudience},
+		{func(c *Claims) { c.Expiry = 0 }, ErrMalformed},
+	} {
+		c := good
+		tc.mutate(&c)
+		_, _, err := ToCaveats(sign(c), keyfunc, opts)
+		assert.True(t, errors.Is(err, tc.err), "%v", err)
+	}
+
+	// opts.Audience may be any of several audiences
+	multi := good
+	multi.Audience = Audience{"other", "api"}
+	_, claims, err = ToCaveats(sign(multi), keyfunc, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, Audience{"other", "api"}, claims.Audience)
+
+	// IdPs may send aud as an array and NumericDates with fractional seconds
+	raw, err := Sign(key, "", map[string]any{
+		"iss": "idp",
+		"aud": []string{"other", "api"},
+		"sub": "alice",
+		"exp": float64(now.Add(time.Hour).Unix()) + 0.5,
+		"iat": float64(now.Unix()) - 0.25,
+	})
+	assert.NoError(t, err)
+	cavs, claims, err = ToCaveats(raw, keyfunc, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(time.Hour).Unix(), claims.Expiry)
+	assert.Equal(t, now.Unix()-1, claims.IssuedAt)
+	assert.Equal(t, []macaroon.Caveat{
+		&macaroon.ValidityWindow{NotBefore: now.Unix(), NotAfter: now.Add(time.Hour).Unix()},
+	}, cavs)
+
+	_, _, err = ToCaveats(sign(good), func(Header) (Verifier, error) { return HS256("wrong"), nil }, opts)
+	assert.True(t, errors.Is(err, ErrBadSignature))
+}
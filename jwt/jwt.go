@@ -0,0 +1,173 @@
+// Package jwt bridges macaroons and JSON Web Tokens, for migrating between
+// services that only speak JWT and those using macaroons.
+//
+// [FromCaveats] summarizes a verified caveat set as a signed JWT. Caveats are
+// translated to claims by [ClaimMapper]s registered with [RegisterClaimMapper];
+// caveats without a mapper can't be represented and cause the conversion to
+// fail, so the JWT never grants more than the macaroon did.
+//
+// Only compact-serialized, signed JWTs are supported, with the HS256 and
+// EdDSA algorithms.
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrMalformed    = errors.New("jwt: malformed")
+	ErrBadSignature = errors.New("jwt: bad signature")
+)
+
+// Signer signs JWTs.
+type Signer interface {
+	// Algorithm returns the JWS "alg" header value.
+	Algorithm() string
+	Sign(msg []byte) ([]byte, error)
+}
+
+// Verifier checks JWT signatures.
+type Verifier interface {
+	// Algorithm returns the JWS "alg" header value.
+	Algorithm() string
+	Verify(msg, sig []byte) error
+}
+
+// HS256 signs and verifies JWTs with HMAC-SHA256.
+type HS256 []byte
+
+func (k HS256) Algorithm() string { return "HS256" }
+
+func (k HS256) Sign(msg []byte) ([]byte, error) {
+	h := hmac.New(sha256.New, k)
+	h.Write(msg)
+	return h.Sum(nil), nil
+}
+
+func (k HS256) Verify(msg, sig []byte) error {
+	expected, _ := k.Sign(msg)
+	if !hmac.Equal(expected, sig) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// EdDSASigner signs JWTs with an Ed25519 private key.
+type EdDSASigner ed25519.PrivateKey
+
+func (k EdDSASigner) Algorithm() string { return "EdDSA" }
+
+func (k EdDSASigner) Sign(msg []byte) ([]byte, error) {
+	return ed25519.Sign(ed25519.PrivateKey(k), msg), nil
+}
+
+// EdDSAVerifier verifies JWTs with an Ed25519 public key.
+type EdDSAVerifier ed25519.PublicKey
+
+func (k EdDSAVerifier) Algorithm() string { return "EdDSA" }
+
+func (k EdDSAVerifier) Verify(msg, sig []byte) error {
+	if len(k) != ed25519.PublicKeySize || !ed25519.Verify(ed25519.PublicKey(k), msg, sig) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// Header is a JWT's JOSE header.
+type Header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// Sign encodes and signs a JWT with the specified claims, which must
+// marshal to a JSON object.
+func Sign(s Signer, keyID string, claims any) (string, error) {
+	hdr, err := json.Marshal(Header{Algorithm: s.Algorithm(), Type: "JWT", KeyID: keyID})
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := b64(hdr) + "." + b64(body)
+
+	sig, err := s.Sign([]byte(signingInput))
+	if err != nil {
+		return "", fmt.Errorf("jwt: sign: %w", err)
+	}
+
+	return signingInput + "." + b64(sig), nil
+}
+
+// Parse checks the JWT's signature with the Verifier returned by keyfunc and
+// decodes its claims into claims. keyfunc is given the JWT's header, so it
+// can select a key by KeyID; the Verifier's algorithm must match the header.
+func Parse(tok string, keyfunc func(Header) (Verifier, error), claims any) (*Header, error) {
+	parts := strings.Split(tok, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	rawHdr, err := unb64(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var hdr Header
+	if err := json.Unmarshal(rawHdr, &hdr); err != nil {
+		return nil, fmt.Errorf("%w: header: %s", ErrMalformed, err)
+	}
+
+	v, err := keyfunc(hdr)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: get key: %w", err)
+	}
+
+	// never let the token choose the algorithm
+	if v.Algorithm() != hdr.Algorithm {
+		return nil, fmt.Errorf("%w: algorithm %q, expected %q", ErrBadSignature, hdr.Algorithm, v.Algorithm())
+	}
+
+	sig, err := unb64(parts[2])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := v.Verify([]byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	body, err := unb64(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(body, claims); err != nil {
+		return nil, fmt.Errorf("%w: claims: %s", ErrMalformed, err)
+	}
+
+	return &hdr, nil
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func unb64(s string) ([]byte, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMalformed, err)
+	}
+	return b, nil
+}
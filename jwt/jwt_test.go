@@ -0,0 +1,82 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+)
+
+func TestSignParse(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		s Signer
+		v Verifier
+	}{
+		{HS256("secret"), HS256("secret")},
+		{EdDSASigner(priv), EdDSAVerifier(pub)},
+	} {
+		tok, err := Sign(tc.s, "k1", &Claims{Subject: "user:1", Scopes: []string{"a", "b"}, Extra: map[string]any{"x": "y"}})
+		assert.NoError(t, err)
+
+		var claims Claims
+		hdr, err := Parse(tok, func(h Header) (Verifier, error) { return tc.v, nil }, &claims)
+		assert.NoError(t, err)
+		assert.Equal(t, "k1", hdr.KeyID)
+		assert.Equal(t, Claims{Subject: "user:1", Scopes: []string{"a", "b"}, Extra: map[string]any{"x": "y"}}, claims)
+
+		_, err = Parse(tok[:len(tok)-2], func(h Header) (Verifier, error) { return tc.v, nil }, &claims)
+		assert.Error(t, err)
+	}
+
+	// the verifier picks the algorithm
+	tok, err := Sign(HS256("secret"), "", &Claims{})
+	assert.NoError(t, err)
+	_, err = Parse(tok, func(h Header) (Verifier, error) { return EdDSAVerifier(pub), nil }, &Claims{})
+	assert.True(t, errors.Is(err, ErrBadSignature))
+}
+
+func TestFromCaveats(t *testing.T) {
+	var (
+		key = macaroon.NewSigningKey()
+		now = time.Unix(1700000000, 0)
+		exp = now.Add(time.Hour)
+	)
+
+	m, err := macaroon.New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(&macaroon.ValidityWindow{NotBefore: now.Unix() - 10, NotAfter: exp.Unix()}))
+	cs, err := m.Verify(key, nil, nil)
+	assert.NoError(t, err)
+
+	opts := Options{Issuer: "iss", Audience: "aud", Now: func() time.Time { return now }}
+	tok, err := FromCaveats(cs, HS256("secret"), opts)
+	assert.NoError(t, err)
+
+	var claims Claims
+	_, err = Parse(tok, func(Header) (Verifier, error) { return HS256("secret"), nil }, &claims)
+	assert.NoError(t, err)
+	assert.Equal(t, Claims{Issuer: "iss", Audience: Audience{"aud"}, IssuedAt: now.Unix(), NotBefore: now.Unix() - 10, Expiry: exp.Unix()}, claims)
+
+	// TTL caps lifetime
+	opts.TTL = time.Minute
+	c, err := ClaimsFromCaveats(cs, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(time.Minute).Unix(), c.Expiry)
+
+	// caveats that can't be represented
+	assert.NoError(t, m.Add(&macaroon.Quota{Resource: "machines", Limit: 1}))
+	cs, err = m.Verify(key, nil, nil)
+	assert.NoError(t, err)
+	_, err = ClaimsFromCaveats(cs, opts)
+	assert.True(t, errors.Is(err, ErrUnmappedCaveat))
+
+	// unverified caveats
+	_, err = ClaimsFromCaveats(macaroon.NewCaveatSet(), opts)
+	assert.Error(t, err)
+}
@@ -0,0 +1,119 @@
+package macaroon
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownKID is returned when verifying a token whose KID isn't in the
+// [Keyring].
+var ErrUnknownKID = errors.New("unknown KID")
+
+// Keyring holds the signing keys used to verify tokens, indexed by KID. A KID
+// may have several candidate keys (e.g. during an emergency rotation, where
+// it's ambiguous which key a token was minted with). Each key is given a name
+// so that verifications can be attributed to the key that succeeded, allowing
+// operators to monitor old-key usage before retiring a key.
+//
+// A Keyring may be used for concurrent verifications, but must not be
+// modified concurrently with use.
+type Keyring struct {
+	keys    map[string][]namedKey
+	trusted Trusted3Ps
+}
+
+type namedKey struct {
+	name string
+	key  SigningKey
+}
+
+// NewKeyring creates an empty Keyring.
+func NewKeyring() *Keyring {
+	return &Keyring{keys: map[string][]namedKey{}, trusted: Trusted3Ps{}}
+}
+
+// Trust3P trusts the attestations of discharges issued by the third party at
+// the location with the keys. See [Trusted3Ps].
+func (k *Keyring) Trust3P(loc string, keys ...Sealer) {
+	k.trusted.Add(loc, keys...)
+}
+
+// Trusted3Ps returns the third parties trusted by the Keyring.
+func (k *Keyring) Trusted3Ps() Trusted3Ps {
+	ret := make(Trusted3Ps, len(k.trusted))
+	for loc, keys := range k.trusted {
+		ret[loc] = append([]Sealer(nil), keys...)
+	}
+	return ret
+}
+
+// Add adds a candidate key for the KID. Names must be unique within a KID.
+func (k *Keyring) Add(kid []byte, name string, key SigningKey) error {
+	for _, nk := range k.keys[string(kid)] {
+		if nk.name == name {
+			return fmt.Errorf("keyring: duplicate key name %q for KID %x", name, kid)
+		}
+	}
+
+	k.keys[string(kid)] = append(k.keys[string(kid)], namedKey{name, key})
+	return nil
+}
+
+// Remove removes the named candidate key for the KID.
+func (k *Keyring) Remove(kid []byte, name string) {
+	keys := k.keys[string(kid)]
+	for i, nk := range keys {
+		if nk.name == name {
+			keys = append(keys[:i:i], keys[i+1:]...)
+			break
+		}
+	}
+
+	if len(keys) == 0 {
+		delete(k.keys, string(kid))
+	} else {
+		k.keys[string(kid)] = keys
+	}
+}
+
+// Verify verifies the token with the candidate keys for its KID, trusting
+// the attestations of discharges from the third parties added with
+// [Keyring.Trust3P]. Its signature matches [VerifyFunc].
+func (k *Keyring) Verify(m *Macaroon, discharges [][]byte, opts ...VerifyOption) (*CaveatSet, error) {
+	cs, _, err := k.VerifyAttributed(m, discharges, opts...)
+	return cs, err
+}
+
+// VerifyAttributed is like [Keyring.Verify], but also returns the name of the
+// key that verified the token. Every candidate key for the KID is tried, even
+// after one succeeds, so the time taken doesn't reveal which candidate
+// matched.
+func (k *Keyring) VerifyAttributed(m *Macaroon, discharges [][]byte, opts ...VerifyOption) (*CaveatSet, string, error) {
+	candidates := k.keys[string(m.Nonce.KID)]
+	if len(candidates) == 0 {
+		return nil, "", fmt.Errorf("%w: %x", ErrUnknownKID, m.Nonce.KID)
+	}
+
+	var (
+		ret   *CaveatSet
+		name  string
+		first error
+	)
+
+	for _, nk := range candidates {
+		cs, err := m.Verify(nk.key, discharges, nil, append([]VerifyOption{WithTrusted3Ps(k.trusted)}, opts...)...)
+
+		switch {
+		case err == nil && ret == nil:
+			ret, name = cs, nk.name
+		case err != nil && first == nil:
+			first = err
+		}
+	}
+
+	if ret == nil {
+		return nil, "", first
+	}
+
+	return ret, name, nil
+}
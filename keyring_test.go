@@ -0,0 +1,53 @@
+package macaroon
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestKeyring(t *testing.T) {
+	var (
+		kid    = []byte("kid")
+		oldKey = NewSigningKey()
+		newKey = NewSigningKey()
+		kr     = NewKeyring()
+	)
+
+	assert.NoError(t, kr.Add(kid, "new", newKey))
+	assert.NoError(t, kr.Add(kid, "old", oldKey))
+	assert.Error(t, kr.Add(kid, "old", oldKey))
+
+	mint := func(key SigningKey) *Macaroon {
+		m, err := New(kid, "https://api", key)
+		assert.NoError(t, err)
+		assert.NoError(t, m.Add(cavParent(ActionRead, 1)))
+		return m
+	}
+
+	cs, name, err := kr.VerifyAttributed(mint(oldKey), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "old", name)
+	assert.Equal(t, 1, len(cs.Caveats))
+
+	_, name, err = kr.VerifyAttributed(mint(newKey), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", name)
+
+	_, err = kr.Verify(mint(NewSigningKey()), nil)
+	assert.Error(t, err)
+
+	// works as a VerifyFunc
+	var verify VerifyFunc = kr.Verify
+
+	kr.Remove(kid, "old")
+	_, err = verify(mint(oldKey), nil)
+	assert.Error(t, err)
+	_, err = verify(mint(newKey), nil)
+	assert.NoError(t, err)
+
+	kr.Remove(kid, "new")
+	_, err = verify(mint(newKey), nil)
+	assert.True(t, errors.Is(err, ErrUnknownKID))
+}
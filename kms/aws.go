@@ -0,0 +1,245 @@
+package kms
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AWSCredentials are the credentials used to sign requests to AWS.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// AWSKey is a [KeyWrapper] for a symmetric AWS KMS key.
+type AWSKey struct {
+	// KeyID is the ID or ARN of the KMS key.
+	KeyID string
+
+	// Region is the AWS region the key lives in.
+	Region string
+
+	// Credentials returns the credentials used to sign requests. It's called
+	// for each request, so that expiring credentials can be refreshed.
+	Credentials func() (AWSCredentials, error)
+
+	// EncryptionContext is passed to KMS with each request and must match
+	// between wrapping and unwrapping.
+	EncryptionContext map[string]string
+
+	// Endpoint overrides the KMS endpoint. Defaults to the regional
+	// endpoint.
+	Endpoint string
+
+	// Client is the HTTP client used to talk to KMS. Defaults to
+	// http.DefaultClient.
+	Client *http.Client
+}
+
+var _ KeyWrapper = (*AWSKey)(nil)
+
+// WrapKey implements [KeyWrapper] using the KMS Encrypt API.
+func (k *AWSKey) WrapKey(dataKey []byte) ([]byte, error) {
+	var resp struct {
+		CiphertextBlob []byte
+	}
+
+	req := map[string]any{
+		"KeyId":     k.KeyID,
+		"Plaintext": dataKey,
+	}
+	if len(k.EncryptionContext) != 0 {
+		req["EncryptionContext"] = k.EncryptionContext
+	}
+
+	if err := k.do("Encrypt", req, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.CiphertextBlob, nil
+}
+
+// UnwrapKey implements [KeyWrapper] using the KMS Decrypt API.
+func (k *AWSKey) UnwrapKey(wrapped []byte) ([]byte, error) {
+	var resp struct {
+		Plaintext []byte
+	}
+
+	req := map[string]any{
+		"KeyId":          k.KeyID,
+		"CiphertextBlob": wrapped,
+	}
+	if len(k.EncryptionContext) != 0 {
+		req["EncryptionContext"] = k.EncryptionContext
+	}
+
+	if err := k.do("Decrypt", req, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Plaintext, nil
+}
+
+func (k *AWSKey) do(op string, reqBody any, respBody any) error {
+	endpoint := k.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://kms.%s.amazonaws.com/", k.Region)
+	}
+
+	client := k.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	creds, err := k.Credentials()
+	if err != nil {
+		return fmt.Errorf("aws kms %s: credentials: %w", op, err)
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return fmt.Errorf("aws kms %s: %w", op, err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("aws kms %s: %w", op, err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+op)
+
+	signV4(req, body, creds, k.Region, "kms", time.Now())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("aws kms %s: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var awsErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&awsErr)
+		return fmt.Errorf("aws kms %s: status %d: %s: %s", op, resp.StatusCode, awsErr.Type, awsErr.Message)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(respBody); err != nil {
+		return fmt.Errorf("aws kms %s: %w", op, err)
+	}
+
+	return nil
+}
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat = "20060102T150405Z"
+)
+
+// signV4 signs the request with AWS Signature Version 4, setting the
+// X-Amz-Date, X-Amz-Security-Token and Authorization headers. All headers
+// already present on the request are signed.
+func signV4(req *http.Request, body []byte, creds AWSCredentials, region, service string, now time.Time) {
+	var (
+		amzDate = now.UTC().Format(sigV4TimeFormat)
+		date    = amzDate[:8]
+		scope   = strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hexDigest(body),
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		scope,
+		hexDigest([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	for _, part := range []string{region, service, "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		vs := append([]string{}, q[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, sigV4Escape(k)+"="+sigV4Escape(v))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+func sigV4Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hexDigest(buf []byte) string {
+	h := sha256.Sum256(buf)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,124 @@
+package kms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const gcpDefaultEndpoint = "https://cloudkms.googleapis.com"
+
+// GCPKey is a [KeyWrapper] for a symmetric GCP Cloud KMS key.
+type GCPKey struct {
+	// Name is the resource name of the key, e.g.
+	// "projects/p/locations/global/keyRings/r/cryptoKeys/k".
+	Name string
+
+	// Token returns an OAuth2 access token used to authenticate requests.
+	// It's called for each request, so that expiring tokens can be
+	// refreshed.
+	Token func() (string, error)
+
+	// AdditionalAuthenticatedData is passed to KMS with each request and must
+	// match between wrapping and unwrapping.
+	AdditionalAuthenticatedData []byte
+
+	// Endpoint overrides the Cloud KMS endpoint.
+	Endpoint string
+
+	// Client is the HTTP client used to talk to Cloud KMS. Defaults to
+	// http.DefaultClient.
+	Client *http.Client
+}
+
+var _ KeyWrapper = (*GCPKey)(nil)
+
+// WrapKey implements [KeyWrapper] using the Cloud KMS encrypt method.
+func (k *GCPKey) WrapKey(dataKey []byte) ([]byte, error) {
+	var resp struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}
+
+	req := map[string]any{"plaintext": dataKey}
+	if len(k.AdditionalAuthenticatedData) != 0 {
+		req["additionalAuthenticatedData"] = k.AdditionalAuthenticatedData
+	}
+
+	if err := k.do("encrypt", req, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Ciphertext, nil
+}
+
+// UnwrapKey implements [KeyWrapper] using the Cloud KMS decrypt method.
+func (k *GCPKey) UnwrapKey(wrapped []byte) ([]byte, error) {
+	var resp struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+
+	req := map[string]any{"ciphertext": wrapped}
+	if len(k.AdditionalAuthenticatedData) != 0 {
+		req["additionalAuthenticatedData"] = k.AdditionalAuthenticatedData
+	}
+
+	if err := k.do("decrypt", req, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Plaintext, nil
+}
+
+func (k *GCPKey) do(op string, reqBody any, respBody any) error {
+	endpoint := k.Endpoint
+	if endpoint == "" {
+		endpoint = gcpDefaultEndpoint
+	}
+
+	client := k.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	token, err := k.Token()
+	if err != nil {
+		return fmt.Errorf("gcp kms %s: token: %w", op, err)
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return fmt.Errorf("gcp kms %s: %w", op, err)
+	}
+
+	u := fmt.Sprintf("%s/v1/%s:%s", strings.TrimSuffix(endpoint, "/"), k.Name, op)
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("gcp kms %s: %w", op, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("gcp kms %s: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var gcpErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&gcpErr)
+		return fmt.Errorf("gcp kms %s: status %d: %s", op, resp.StatusCode, gcpErr.Error.Message)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(respBody); err != nil {
+		return fmt.Errorf("gcp kms %s: %w", op, err)
+	}
+
+	return nil
+}
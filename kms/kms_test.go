@@ -0,0 +1,191 @@
+package kms
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+)
+
+// fakeWrapper wraps keys with a local key, standing in for a KMS.
+type fakeWrapper struct{ key macaroon.EncryptionKey }
+
+func (w *fakeWrapper) WrapKey(dk []byte) ([]byte, error)   { return w.key.Seal(dk) }
+func (w *fakeWrapper) UnwrapKey(wk []byte) ([]byte, error) { return w.key.Unseal(wk) }
+
+func TestSealerRotation(t *testing.T) {
+	var (
+		old = &fakeWrapper{macaroon.NewEncryptionKey()}
+		cur = &fakeWrapper{macaroon.NewEncryptionKey()}
+	)
+
+	s1 := &Sealer{KeyID: "old", Keys: map[string]KeyWrapper{"old": old}}
+	sealedOld, err := s1.Seal([]byte("hello"))
+	assert.NoError(t, err)
+
+	s2 := &Sealer{KeyID: "cur", Keys: map[string]KeyWrapper{"old": old, "cur": cur}}
+	sealedCur, err := s2.Seal([]byte("world"))
+	assert.NoError(t, err)
+
+	pt, err := s2.Unseal(sealedOld)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello"), pt)
+
+	pt, err = s2.Unseal(sealedCur)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("world"), pt)
+
+	// s1 doesn't know about the current key
+	_, err = s1.Unseal(sealedCur)
+	assert.Error(t, err)
+
+	_, err = (&Sealer{KeyID: "missing"}).Seal([]byte("hello"))
+	assert.Error(t, err)
+
+	_, err = s2.Unseal(sealedCur[:len(sealedCur)-1])
+	assert.Error(t, err)
+
+	// works as a sealer for 3P caveats
+	key := macaroon.NewSigningKey()
+	m, err := macaroon.New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add3PWithSealer(s1, "https://auth"))
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	cid, err := macaroon.ThirdPartyCID(buf, "https://auth")
+	assert.NoError(t, err)
+	_, _, err = macaroon.DischargeCIDWithSealer(s2, "https://auth", cid)
+	assert.NoError(t, err)
+}
+
+func TestSignV4(t *testing.T) {
+	// example from AWS's signature version 4 documentation
+	req, err := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	creds := AWSCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+
+	signV4(req, nil, creds, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	assert.Equal(t,
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+		req.Header.Get("Authorization"),
+	)
+}
+
+func TestAWSKey(t *testing.T) {
+	local := macaroon.NewEncryptionKey()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"))
+		assert.Equal(t, "session", r.Header.Get("X-Amz-Security-Token"))
+
+		var req struct {
+			KeyId             string
+			Plaintext         []byte
+			CiphertextBlob    []byte
+			EncryptionContext map[string]string
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "alias/macaroon", req.KeyId)
+		assert.Equal(t, map[string]string{"purpose": "cid"}, req.EncryptionContext)
+
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.Encrypt":
+			ct, _ := local.Seal(req.Plaintext)
+			json.NewEncoder(w).Encode(map[string]any{"CiphertextBlob": ct})
+		case "TrentService.Decrypt":
+			pt, err := local.Unseal(req.CiphertextBlob)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]any{"__type": "InvalidCiphertextException"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"Plaintext": pt})
+		}
+	}))
+	defer srv.Close()
+
+	k := &AWSKey{
+		KeyID:    "alias/macaroon",
+		Region:   "us-east-1",
+		Endpoint: srv.URL,
+		Credentials: func() (AWSCredentials, error) {
+			return AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "session"}, nil
+		},
+		EncryptionContext: map[string]string{"purpose": "cid"},
+	}
+
+	testWrapper(t, k)
+}
+
+func TestGCPKey(t *testing.T) {
+	var (
+		local = macaroon.NewEncryptionKey()
+		name  = "projects/p/locations/global/keyRings/r/cryptoKeys/k"
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer tok", r.Header.Get("Authorization"))
+
+		var req struct {
+			Plaintext                   []byte `json:"plaintext"`
+			Ciphertext                  []byte `json:"ciphertext"`
+			AdditionalAuthenticatedData []byte `json:"additionalAuthenticatedData"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, []byte("aad"), req.AdditionalAuthenticatedData)
+
+		switch r.URL.Path {
+		case "/v1/" + name + ":encrypt":
+			ct, _ := local.Seal(req.Plaintext)
+			json.NewEncoder(w).Encode(map[string]any{"ciphertext": ct})
+		case "/v1/" + name + ":decrypt":
+			pt, err := local.Unseal(req.Ciphertext)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"message": "bad"}})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"plaintext": pt})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	k := &GCPKey{
+		Name:                        name,
+		Endpoint:                    srv.URL,
+		Token:                       func() (string, error) { return "tok", nil },
+		AdditionalAuthenticatedData: []byte("aad"),
+	}
+
+	testWrapper(t, k)
+}
+
+func testWrapper(t *testing.T, kw KeyWrapper) {
+	t.Helper()
+
+	s := &Sealer{KeyID: "k", Keys: map[string]KeyWrapper{"k": kw}}
+
+	ct, err := s.Seal([]byte("hello"))
+	assert.NoError(t, err)
+
+	pt, err := s.Unseal(ct)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello"), pt)
+
+	_, err = kw.UnwrapKey([]byte("garbage-garbage-garbage"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,145 @@
+package macaroon
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/superfly/macaroon/access"
+)
+
+// Labels is a caveat allowing Action on resources matching any of Selectors,
+// by their labels rather than their IDs, so that e.g. a token granting access
+// to "anything labeled env=staging" stays valid as staging resources come and
+// go. The resource's labels are reported by an Access implementing
+// [access.Labels].
+//
+// Each selector is a comma-separated list of requirements, all of which must
+// hold: "key=value" requires the label to have the value, and "key!=value"
+// requires it not to (or to be absent). Use [NewLabels] to check selectors'
+// syntax when minting.
+type Labels struct {
+	Selectors []string `json:"selectors"`
+	Action    Action   `json:"action"`
+}
+
+func init() { RegisterCaveatType("Labels", CavLabels, &Labels{}) }
+
+// NewLabels creates a Labels caveat allowing action on resources matching any
+// of the selectors.
+func NewLabels(action Action, selectors ...string) (*Labels, error) {
+	for _, sel := range selectors {
+		if _, err := parseLabelSelector(sel); err != nil {
+			return nil, err
+		}
+	}
+	return &Labels{Selectors: selectors, Action: action}, nil
+}
+
+func (c *Labels) CaveatType() CaveatType { return CavLabels }
+func (c *Labels) IsAttestation() bool    { return false }
+
+func (c *Labels) Prohibits(f Access) error {
+	return c.ProhibitsWithContext(nil, f)
+}
+
+type labelsKey struct{}
+
+type memoLabels struct {
+	labels    map[string]string
+	known, ok bool
+}
+
+// ProhibitsWithContext implements [ContextualCaveat]. The access's labels are
+// memoized, since looking them up may be expensive and tokens may carry
+// several Labels caveats.
+func (c *Labels) ProhibitsWithContext(vc *ValidationContext, f Access) error {
+	ml := Memoize(vc, f, labelsKey{}, func() memoLabels {
+		la, known := access.As[access.Labels](f)
+		if !known {
+			return memoLabels{}
+		}
+		labels, ok := la.GetLabels()
+		return memoLabels{labels, true, ok}
+	})
+
+	labels := ml.labels
+	switch {
+	case !ml.known:
+		return fmt.Errorf("%w: resource labels unknown", ErrInvalidAccess)
+	case !ml.ok:
+		return fmt.Errorf("%w labeled resource", ErrResourceUnspecified)
+	}
+
+	matched := false
+	for _, sel := range c.Selectors {
+		reqs, err := parseLabelSelector(sel)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrBadCaveat, err)
+		}
+		if reqs.matches(labels) {
+			matched = true
+			break
+		}
+	}
+
+	if !matched {
+		return fmt.Errorf("%w labeled %s", ErrUnauthorizedForResource, formatLabels(labels))
+	}
+
+	if !f.GetAction().IsSubsetOf(c.Action) {
+		return &ActionError{Requested: f.GetAction(), Permitted: c.Action, Resource: formatLabels(labels)}
+	}
+
+	return nil
+}
+
+type labelRequirement struct {
+	key, value string
+	negated    bool
+}
+
+type labelSelector []labelRequirement
+
+func parseLabelSelector(sel string) (labelSelector, error) {
+	var ret labelSelector
+	for _, req := range strings.Split(sel, ",") {
+		key, value, found := strings.Cut(req, "=")
+		if !found {
+			return nil, fmt.Errorf("label selector %q: %q isn't key=value or key!=value", sel, req)
+		}
+
+		negated := strings.HasSuffix(key, "!")
+		key = strings.TrimSpace(strings.TrimSuffix(key, "!"))
+		if key == "" {
+			return nil, fmt.Errorf("label selector %q: blank key", sel)
+		}
+
+		ret = append(ret, labelRequirement{key: key, value: strings.TrimSpace(value), negated: negated})
+	}
+	return ret, nil
+}
+
+func (s labelSelector) matches(labels map[string]string) bool {
+	for _, req := range s {
+		v, ok := labels[req.key]
+		if (ok && v == req.value) == req.negated {
+			return false
+		}
+	}
+	return true
+}
+
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+labels[k])
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
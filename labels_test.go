@@ -0,0 +1,50 @@
+package macaroon
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+type labelsPart map[string]string
+
+func (p labelsPart) GetLabels() (map[string]string, bool) { return p, p != nil }
+
+func TestLabels(t *testing.T) {
+	key := NewSigningKey()
+
+	labels, err := NewLabels(ActionRead|ActionWrite, "env=staging", "env=production,team=web,tier!=db")
+	assert.NoError(t, err)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(labels))
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	decoded, err := Decode(buf)
+	assert.NoError(t, err)
+	cs, err := decoded.Verify(key, nil, nil)
+	assert.NoError(t, err)
+
+	a := &testAccess{action: ActionWrite}
+
+	assert.NoError(t, cs.Validate(Compose(a, labelsPart{"env": "staging", "team": "api"})))
+	assert.NoError(t, cs.Validate(Compose(a, labelsPart{"env": "production", "team": "web"})))
+	assert.NoError(t, cs.Validate(Compose(a, labelsPart{"env": "production", "team": "web", "tier": "app"})))
+
+	assert.True(t, errors.Is(cs.Validate(Compose(a, labelsPart{"env": "production", "team": "web", "tier": "db"})), ErrUnauthorizedForResource))
+	assert.True(t, errors.Is(cs.Validate(Compose(a, labelsPart{"env": "production"})), ErrUnauthorizedForResource))
+	assert.True(t, errors.Is(cs.Validate(Compose(a, labelsPart{})), ErrUnauthorizedForResource))
+	assert.True(t, errors.Is(cs.Validate(Compose(&testAccess{action: ActionDelete}, labelsPart{"env": "staging"})), ErrUnauthorized))
+
+	// accesses not involving a labeled resource
+	assert.True(t, errors.Is(cs.Validate(Compose(a, labelsPart(nil))), ErrResourceUnspecified))
+	assert.True(t, errors.Is(cs.Validate(a), ErrInvalidAccess))
+
+	for _, bad := range []string{"", "env", "=staging", "env=staging,"} {
+		_, err := NewLabels(ActionRead, bad)
+		assert.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,67 @@
+package macaroon
+
+import (
+	"fmt"
+	"strings"
+
+	msgpack "github.com/vmihailenco/msgpack/v5"
+)
+
+// Tokens minted by earlier versions of the fly.io token libraries are still
+// in circulation, and some of them are long-lived. Those tokens are decoded
+// into the current structures, so callers don't need to care which version
+// of the library minted a token:
+//
+//   - Nonces with only the KID and Rnd fields, minted before discharge tokens
+//     were proofs, decode with Proof unset. Such discharges may not carry
+//     attestations.
+//
+//   - Nonces encoded as msgpack maps, keyed by field name, rather than
+//     arrays. The original encoding is retained, since the token's signature
+//     covers it.
+//
+//   - Macaroons encoded as msgpack maps, keyed by field name, rather than
+//     arrays. The signature doesn't cover the Macaroon's own layout, so these
+//     are re-encoded in the current format.
+
+// decodeLegacy decodes a nonce encoded as a map, retaining its original
+// encoding. Keys are matched case-insensitively, and the proof field is
+// optional, as with array-encoded nonces.
+func (n *Nonce) decodeLegacy(d *msgpack.Decoder) error {
+	raw, err := d.DecodeRaw()
+	if err != nil {
+		return err
+	}
+
+	var fields map[string]msgpack.RawMessage
+	if err := msgpack.Unmarshal(raw, &fields); err != nil {
+		return fmt.Errorf("legacy nonce: %w", err)
+	}
+
+	*n = Nonce{version: nonceV0, raw: append([]byte(nil), raw...)}
+
+	var seenKID, seenRnd bool
+	for k, v := range fields {
+		var dst any
+		switch strings.ToLower(k) {
+		case "kid":
+			dst, seenKID = &n.KID, true
+		case "rnd":
+			dst, seenRnd = &n.Rnd, true
+		case "proof":
+			dst, n.version = &n.Proof, nonceV1
+		default:
+			return fmt.Errorf("legacy nonce: unknown field %q", k)
+		}
+
+		if err := msgpack.Unmarshal(v, dst); err != nil {
+			return fmt.Errorf("legacy nonce: %s: %w", k, err)
+		}
+	}
+
+	if !seenKID || !seenRnd {
+		return fmt.Errorf("legacy nonce: missing kid or rnd")
+	}
+
+	return nil
+}
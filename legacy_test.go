@@ -0,0 +1,152 @@
+package macaroon
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	msgpack "github.com/vmihailenco/msgpack/v5"
+)
+
+// legacyMacaroon mints a token whose nonce has the specified encoding.
+func legacyMacaroon(t *testing.T, nonce []byte, loc string, key SigningKey) *Macaroon {
+	t.Helper()
+
+	var n Nonce
+	assert.NoError(t, msgpack.Unmarshal(nonce, &n))
+
+	return &Macaroon{
+		Nonce:         n,
+		Location:      loc,
+		Tail:          sign(key, nonce),
+		UnsafeCaveats: *NewCaveatSet(),
+	}
+}
+
+func TestLegacyNonceMap(t *testing.T) {
+	key := NewSigningKey()
+
+	nonce, err := msgpack.Marshal(map[string]any{"KID": []byte("kid"), "Rnd": rbuf(nonceRndSize)})
+	assert.NoError(t, err)
+
+	m := legacyMacaroon(t, nonce, "https://api", key)
+	assert.NoError(t, m.Add(cavParent(ActionRead, 1)))
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	decoded, err := Decode(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("kid"), decoded.Nonce.KID)
+	assert.False(t, decoded.Nonce.Proof)
+
+	dn, err := DecodeNonce(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, decoded.Nonce.UUID(), dn.UUID())
+
+	cavs, err := decoded.Verify(key, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []Caveat{cavParent(ActionRead, 1)}, cavs.Caveats)
+
+	// attenuating and re-encoding retains the original nonce encoding
+	assert.NoError(t, decoded.Add(cavChild(ActionRead, 2)))
+	buf, err = decoded.Encode()
+	assert.NoError(t, err)
+
+	decoded, err = Decode(buf)
+	assert.NoError(t, err)
+
+	cavs, err = decoded.Verify(key, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(cavs.Caveats))
+
+	// proof nonces
+	nonce, err = msgpack.Marshal(map[string]any{"kid": []byte("kid"), "rnd": rbuf(nonceRndSize), "proof": true})
+	assert.NoError(t, err)
+
+	var n Nonce
+	assert.NoError(t, msgpack.Unmarshal(nonce, &n))
+	assert.True(t, n.Proof)
+
+	// malformed nonces
+	for _, fields := range []map[string]any{
+		{"kid": []byte("kid")},
+		{"kid": []byte("kid"), "rnd": rbuf(nonceRndSize), "extra": 1},
+		{"kid": "kid", "rnd": 1},
+	} {
+		nonce, err = msgpack.Marshal(fields)
+		assert.NoError(t, err)
+		assert.Error(t, msgpack.Unmarshal(nonce, &n))
+	}
+}
+
+func TestLegacyPreProofDischarge(t *testing.T) {
+	var (
+		authLoc = "https://auth"
+		key     = NewSigningKey()
+		ka      = NewEncryptionKey()
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add3P(ka, authLoc, cavParent(ActionRead, 1)))
+
+	cid, err := m.ThirdPartyCID(authLoc)
+	assert.NoError(t, err)
+
+	cidr, err := unseal(ka, cid)
+	assert.NoError(t, err)
+
+	var wcid wireCID
+	assert.NoError(t, msgpack.Unmarshal(cidr, &wcid))
+
+	// a discharge minted before discharges were proofs has a nonce without
+	// the proof field
+	nonce, err := encode([]any{cid, rbuf(nonceRndSize)})
+	assert.NoError(t, err)
+
+	legacy := legacyMacaroon(t, nonce, authLoc, wcid.RN)
+	assert.NoError(t, legacy.Add(cavChild(ActionRead, 2)))
+	assert.Error(t, legacy.Add(&testCaveatAttestation{}))
+
+	dbuf, err := legacy.Encode()
+	assert.NoError(t, err)
+
+	decoded, err := Decode(dbuf)
+	assert.NoError(t, err)
+	assert.False(t, decoded.Nonce.Proof)
+
+	cavs, err := m.Verify(key, [][]byte{dbuf}, map[string]EncryptionKey{authLoc: ka})
+	assert.NoError(t, err)
+	assert.Equal(t, []Caveat{cavChild(ActionRead, 2)}, cavs.Caveats)
+}
+
+func TestLegacyMacaroonMap(t *testing.T) {
+	key := NewSigningKey()
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavParent(ActionRead, 1)))
+
+	// early tokens were encoded as maps rather than arrays
+	buf, err := msgpack.Marshal(map[string]any{
+		"Nonce":         msgpack.RawMessage(m.Nonce.MustEncode()),
+		"Location":      m.Location,
+		"UnsafeCaveats": &m.UnsafeCaveats,
+		"Tail":          m.Tail,
+	})
+	assert.NoError(t, err)
+
+	decoded, err := Decode(buf)
+	assert.NoError(t, err)
+
+	cavs, err := decoded.Verify(key, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []Caveat{cavParent(ActionRead, 1)}, cavs.Caveats)
+
+	// re-encoded in the current format
+	rebuf, err := decoded.Encode()
+	assert.NoError(t, err)
+
+	orig, err := m.Encode()
+	assert.NoError(t, err)
+	assert.Equal(t, orig, rebuf)
+}
@@ -0,0 +1,72 @@
+package macaroon
+
+import "fmt"
+
+// lineageIDLength is the length of lineage IDs. They're for tracing, not
+// security, so they're short.
+const lineageIDLength = 8
+
+// Lineage is a caveat recording which version of a token it was attenuated
+// from, by the parent's lineage ID (see [Macaroon.LineageID]). It lets
+// operators reconstruct where a circulating attenuated token came from
+// without a database lookup. It plays no role in access validation, and,
+// since any holder can add it, it's only as trustworthy as the holders.
+// See [Macaroon.MarkLineage] and [AttenuateWithLineage].
+type Lineage struct {
+	Parent []byte `json:"parent"`
+}
+
+func init() { RegisterCaveatType("Lineage", CavLineage, &Lineage{}) }
+
+func (c *Lineage) CaveatType() CaveatType { return CavLineage }
+func (c *Lineage) IsAttestation() bool    { return false }
+
+func (c *Lineage) Prohibits(f Access) error {
+	// Lineage is metadata and plays no role in access validation.
+	return nil
+}
+
+// LineageID identifies this version of the token: it's derived from the tail,
+// so it changes as caveats are added. It's short and deterministic, so
+// operators can compute it for tokens they've issued or seen and match it
+// against the lineage of tokens they find (see [Macaroon.Lineage]).
+func (m *Macaroon) LineageID() []byte {
+	return digest(m.Tail)[:lineageIDLength]
+}
+
+// MarkLineage adds a [Lineage] caveat identifying the token's current version
+// as the parent of the version being created. Call it before adding the
+// attenuating caveats.
+func (m *Macaroon) MarkLineage() error {
+	return m.Add(&Lineage{Parent: m.LineageID()})
+}
+
+// Lineage returns the lineage IDs of the versions the token was attenuated
+// from, oldest first, as recorded by [Macaroon.MarkLineage]. Versions
+// attenuated without marking their lineage aren't included.
+func (m *Macaroon) Lineage() [][]byte {
+	var ret [][]byte
+	for _, l := range GetCaveats[*Lineage](&m.UnsafeCaveats) {
+		ret = append(ret, l.Parent)
+	}
+	return ret
+}
+
+// AttenuateWithLineage is like [Attenuate], but marks the attenuated token's
+// lineage first. See [Macaroon.MarkLineage].
+func AttenuateWithLineage(encoded []byte, caveats ...Caveat) ([]byte, error) {
+	m, err := Decode(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("attenuate: %w", err)
+	}
+
+	if err := m.MarkLineage(); err != nil {
+		return nil, fmt.Errorf("attenuate: %w", err)
+	}
+
+	if err := m.Add(caveats...); err != nil {
+		return nil, fmt.Errorf("attenuate: %w", err)
+	}
+
+	return m.Encode()
+}
@@ -0,0 +1,40 @@
+package macaroon
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestLineage(t *testing.T) {
+	key := NewSigningKey()
+
+	root, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, root.Add(cavParent(ActionAll, 1)))
+	rootBuf, err := root.Encode()
+	assert.NoError(t, err)
+	assert.Equal(t, 8, len(root.LineageID()))
+
+	childBuf, err := AttenuateWithLineage(rootBuf, cavParent(ActionRead|ActionWrite, 1))
+	assert.NoError(t, err)
+
+	// unmarked attenuations aren't recorded
+	childBuf, err = Attenuate(childBuf, cavChild(ActionRead|ActionWrite, 2))
+	assert.NoError(t, err)
+	child, err := Decode(childBuf)
+	assert.NoError(t, err)
+
+	grandchildBuf, err := AttenuateWithLineage(childBuf, cavParent(ActionRead, 1))
+	assert.NoError(t, err)
+	grandchild, err := Decode(grandchildBuf)
+	assert.NoError(t, err)
+
+	assert.Equal(t, [][]byte{root.LineageID(), child.LineageID()}, grandchild.Lineage())
+	assert.Zero(t, root.Lineage())
+
+	// lineage markers don't affect validation
+	cs, err := grandchild.Verify(key, nil, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, cs.Validate(&testAccess{action: ActionRead, parentResource: ptr(uint64(1)), childResource: ptr(uint64(2))}))
+}
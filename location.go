@@ -0,0 +1,107 @@
+package macaroon
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// NormalizeLocation canonicalizes a token or third-party location, so that
+// equivalent locations compare equal: the scheme and host of URL locations
+// are lowercased, default ports are removed and trailing slashes are
+// trimmed. Locations that aren't absolute URLs only have trailing slashes
+// trimmed.
+func NormalizeLocation(loc string) string {
+	u, err := url.Parse(loc)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return strings.TrimRight(loc, "/")
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+
+	switch port := u.Port(); {
+	case u.Scheme == "https" && port == "443", u.Scheme == "http" && port == "80":
+		u.Host = u.Hostname()
+	}
+
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+
+	return u.String()
+}
+
+// LocationMatch is a rule for matching a location against a pattern, e.g.
+// when looking up the ticket for a third party. Both are normalized with
+// [NormalizeLocation] before matching.
+type LocationMatch int
+
+const (
+	// LocationExact matches locations equal to the pattern.
+	LocationExact LocationMatch = iota
+
+	// LocationHost matches locations with the same host (and port) as the
+	// pattern, regardless of scheme and path.
+	LocationHost
+
+	// LocationPrefix matches locations equal to the pattern or beneath it,
+	// e.g. "https://auth.example/orgs/1" matches the pattern
+	// "https://auth.example/orgs".
+	LocationPrefix
+)
+
+func (lm LocationMatch) String() string {
+	switch lm {
+	case LocationExact:
+		return "exact"
+	case LocationHost:
+		return "host"
+	case LocationPrefix:
+		return "prefix"
+	default:
+		return fmt.Sprintf("LocationMatch(%d)", int(lm))
+	}
+}
+
+// Matches returns whether loc matches the pattern.
+func (lm LocationMatch) Matches(pattern, loc string) bool {
+	pattern, loc = NormalizeLocation(pattern), NormalizeLocation(loc)
+
+	switch lm {
+	case LocationExact:
+		return pattern == loc
+	case LocationHost:
+		return locationHost(pattern) == locationHost(loc)
+	case LocationPrefix:
+		return loc == pattern || strings.HasPrefix(loc, pattern+"/")
+	default:
+		return false
+	}
+}
+
+// locationHost returns the host of a normalized URL location, or the location
+// itself if it isn't a URL.
+func locationHost(loc string) string {
+	if u, err := url.Parse(loc); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return loc
+}
+
+// lookupLocation finds the value for the location in a map keyed by
+// location, falling back to comparing normalized locations.
+func lookupLocation[T any](m map[string]T, loc string) (T, bool) {
+	if v, ok := m[loc]; ok {
+		return v, true
+	}
+
+	norm := NormalizeLocation(loc)
+	for k, v := range m {
+		if NormalizeLocation(k) == norm {
+			return v, true
+		}
+	}
+
+	var zero T
+	return zero, false
+}
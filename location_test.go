@@ -0,0 +1,100 @@
+package macaroon
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestNormalizeLocation(t *testing.T) {
+	for in, out := range map[string]string{
+		"https://auth.fly.io":            "https://auth.fly.io",
+		"https://auth.fly.io/":           "https://auth.fly.io",
+		"HTTPS://Auth.Fly.IO/":           "https://auth.fly.io",
+		"https://auth.fly.io:443/":       "https://auth.fly.io",
+		"https://auth.fly.io:8443/":      "https://auth.fly.io:8443",
+		"http://auth.fly.io:80":          "http://auth.fly.io",
+		"https://auth.fly.io/Orgs/1/":    "https://auth.fly.io/Orgs/1",
+		"https://auth.fly.io/orgs?x=1":   "https://auth.fly.io/orgs?x=1",
+		"auth/":                          "auth",
+		"root":                           "root",
+		"https://auth.fly.io//":          "https://auth.fly.io",
+		"https://user@auth.fly.io/a/b//": "https://user@auth.fly.io/a/b",
+	} {
+		assert.Equal(t, out, NormalizeLocation(in), in)
+	}
+}
+
+func TestLocationMatch(t *testing.T) {
+	yes := func(lm LocationMatch, pattern, loc string) {
+		t.Helper()
+		assert.True(t, lm.Matches(pattern, loc), "%s %s %s", lm, pattern, loc)
+	}
+	no := func(lm LocationMatch, pattern, loc string) {
+		t.Helper()
+		assert.False(t, lm.Matches(pattern, loc), "%s %s %s", lm, pattern, loc)
+	}
+
+	yes(LocationExact, "https://auth", "https://AUTH/")
+	no(LocationExact, "https://auth", "https://auth/x")
+
+	yes(LocationHost, "https://auth", "http://auth/x")
+	no(LocationHost, "https://auth", "https://auth:8443")
+
+	yes(LocationPrefix, "https://auth/orgs", "https://auth/orgs/1")
+	yes(LocationPrefix, "https://auth/orgs/", "https://auth/orgs")
+	no(LocationPrefix, "https://auth/orgs", "https://auth/orgsx")
+}
+
+func TestThirdPartyCIDLocations(t *testing.T) {
+	var (
+		key = NewSigningKey()
+		ka  = NewEncryptionKey()
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add3P(ka, "https://auth.example/orgs/1"))
+	assert.Error(t, m.Add3P(ka, "https://auth.example/orgs/1/"))
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	// trailing slash mismatch used to yield no ticket
+	cid, err := m.ThirdPartyCID("https://auth.example/orgs/1/")
+	assert.NoError(t, err)
+	assert.NotZero(t, cid)
+
+	cid, err = m.ThirdPartyCIDMatching(LocationHost, "https://AUTH.example")
+	assert.NoError(t, err)
+	assert.NotZero(t, cid)
+
+	cid, err = m.ThirdPartyCIDMatching(LocationPrefix, "https://auth.example/orgs")
+	assert.NoError(t, err)
+	assert.NotZero(t, cid)
+
+	cid, err = m.ThirdPartyCIDMatching(LocationPrefix, "https://auth.example/users")
+	assert.NoError(t, err)
+	assert.Zero(t, cid)
+
+	assert.NoError(t, m.Add3P(ka, "https://auth.example/orgs/2"))
+	_, err = m.ThirdPartyCIDMatching(LocationHost, "https://auth.example")
+	assert.Error(t, err)
+
+	// trusted 3P lookups are normalized
+	_, _, dm, err := dischargeMacaroon(ka, "https://auth.example/orgs/1", buf)
+	assert.NoError(t, err)
+	dbuf, err := dm.Encode()
+	assert.NoError(t, err)
+
+	decoded, err := Decode(buf)
+	assert.NoError(t, err)
+	_, err = decoded.Verify(key, [][]byte{dbuf}, map[string]EncryptionKey{"https://auth.example/orgs/1/": NewEncryptionKey()})
+	assert.Error(t, err)
+	_, err = decoded.Verify(key, [][]byte{dbuf}, map[string]EncryptionKey{"https://auth.example/orgs/1/": ka})
+	assert.NoError(t, err)
+
+	// finding the permission token is normalized
+	b, err := ParseBundle(ToAuthorizationHeader(buf, dbuf), "https://API/")
+	assert.NoError(t, err)
+	assert.Equal(t, buf, b.Permission)
+}
@@ -97,6 +97,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"time"
 
 	msgpack "github.com/vmihailenco/msgpack/v5"
@@ -121,22 +122,36 @@ type Macaroon struct {
 	Tail          []byte    `json:"-"`
 
 	newProof bool
+	pooled   bool
+	display  bool
 }
 
 func encode(v interface{}) ([]byte, error) {
 	buf := &bytes.Buffer{}
+	if err := encodeTo(buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
 
+func encodeTo(w io.Writer, v interface{}) error {
 	enc := msgpack.GetEncoder()
 	defer msgpack.PutEncoder(enc)
 
-	enc.Reset(buf)
+	enc.Reset(w)
 	enc.UseArrayEncodedStructs(true)
 	enc.UseCompactInts(true)
 
-	if err := enc.Encode(v); err != nil {
-		return nil, err
-	}
-	return buf.Bytes(), nil
+	return enc.Encode(v)
+}
+
+func decodeFrom(r io.Reader, v interface{}) error {
+	dec := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(dec)
+
+	dec.Reset(r)
+
+	return dec.Decode(v)
 }
 
 // New creates a new token given a key-id string (which can
@@ -183,21 +198,47 @@ func Decode(buf []byte) (*Macaroon, error) {
 	return m, nil
 }
 
+// DecodeFrom is like [Decode], but reads the token from r. If r doesn't
+// implement [io.ByteScanner], DecodeFrom may read past the end of the token.
+func DecodeFrom(r io.Reader) (*Macaroon, error) {
+	m := &Macaroon{}
+	if err := decodeFrom(r, m); err != nil {
+		return nil, fmt.Errorf("macaroon decode: %w", err)
+	}
+
+	return m, nil
+}
+
 // DecodeNonce parses just the [Nonce] from an encoded [Macaroon].
 // You'd want to do this, for instance, to look metadata up by the
 // keyid of the [Macaroon], which is encoded in the [Nonce].
 func DecodeNonce(buf []byte) (Nonce, error) {
 	var (
-		nonceOnly = struct{ Nonce Nonce }{}
-		err       = msgpack.Unmarshal(buf, &nonceOnly)
+		nonce Nonce
+		dec   = msgpack.NewDecoder(bytes.NewReader(buf))
 	)
-	return nonceOnly.Nonce, err
+
+	// macaroons are encoded as arrays, with the nonce first
+	n, err := dec.DecodeArrayLen()
+	switch {
+	case err != nil:
+		return nonce, err
+	case n < 1:
+		return nonce, errors.New("decode nonce: empty macaroon")
+	}
+
+	err = dec.Decode(&nonce)
+	return nonce, err
 }
 
 // Add adds a caveat to a Macaroon, adjusting the tail signature in
 // the process. This is how you'd "attenuate" a token, taking a
 // read-write token and turning it into a read-only token, for instance.
 func (m *Macaroon) Add(caveats ...Caveat) error {
+	if m.display {
+		return errDisplayCopy
+	}
+
 	if m.Nonce.Proof && !m.newProof {
 		return errors.New("can't add caveats to finalized proof")
 	}
@@ -209,22 +250,27 @@ func (m *Macaroon) Add(caveats ...Caveat) error {
 
 	seen3P := map[string]bool{}
 	for _, cav := range GetCaveats[*Caveat3P](&m.UnsafeCaveats) {
-		seen3P[cav.Location] = true
+		seen3P[NormalizeLocation(cav.Location)] = true
 	}
 
+	now := time.Now()
 	for _, caveat := range caveats {
 		if caveat.IsAttestation() && !m.Nonce.Proof {
 			return errors.New("cannot add attestations to non-proof macaroons")
 		}
 
+		if err := checkDeprecation(caveat, now); err != nil {
+			return err
+		}
+
 		if c3p, ok := caveat.(*Caveat3P); ok {
 			// encrypt RN under the tail hmac so we can recover it during verification
 			c3p.VID = seal(EncryptionKey(m.Tail), c3p.rn)
 
-			if seen3P[c3p.Location] {
+			if seen3P[NormalizeLocation(c3p.Location)] {
 				return fmt.Errorf("m.add: attempting to add multiple 3ps for %s", c3p.Location)
 			}
-			seen3P[c3p.Location] = true
+			seen3P[NormalizeLocation(c3p.Location)] = true
 		}
 
 		m.UnsafeCaveats.Caveats = append(m.UnsafeCaveats.Caveats, caveat)
@@ -248,8 +294,8 @@ func (m *Macaroon) Add(caveats ...Caveat) error {
 func (m *Macaroon) dedup(caveats []Caveat) ([]Caveat, error) {
 	seen := make(map[string]bool, len(m.UnsafeCaveats.Caveats))
 
-	for _, cav := range m.UnsafeCaveats.Caveats {
-		packed, err := NewCaveatSet(cav).MarshalMsgpack()
+	for i := range m.UnsafeCaveats.Caveats {
+		packed, err := m.UnsafeCaveats.caveatBytes(i)
 		if err != nil {
 			return nil, err
 		}
@@ -277,6 +323,10 @@ func (m *Macaroon) dedup(caveats []Caveat) ([]Caveat, error) {
 // Encode encodes a Macaroon to bytes after creating it
 // or decoding it and adding more caveats.
 func (m *Macaroon) Encode() ([]byte, error) {
+	if m.display {
+		return nil, errDisplayCopy
+	}
+
 	if m.Nonce.Proof && m.newProof {
 		m.Tail = finalizeSignature(m.Tail)
 		m.newProof = false
@@ -285,6 +335,21 @@ func (m *Macaroon) Encode() ([]byte, error) {
 	return encode(m)
 }
 
+// EncodeTo is like [Macaroon.Encode], but writes the encoded token directly
+// to w, e.g. an HTTP response.
+func (m *Macaroon) EncodeTo(w io.Writer) error {
+	if m.display {
+		return errDisplayCopy
+	}
+
+	if m.Nonce.Proof && m.newProof {
+		m.Tail = finalizeSignature(m.Tail)
+		m.newProof = false
+	}
+
+	return encodeTo(w, m)
+}
+
 // Verify checks the signature on a [Macaroon.Decode] 'ed Macaroon and returns the
 // the set of caveats that require validation against the user's request.
 //
@@ -299,11 +364,32 @@ func (m *Macaroon) Encode() ([]byte, error) {
 // a token that says "yes, this person is logged in as bob@victim.com, but
 // only allow this request to perform reads, not writes"). Those added
 // ordinary caveats WILL be returned from Verify.
-func (m *Macaroon) Verify(k SigningKey, discharges [][]byte, trusted3Ps map[string]EncryptionKey) (*CaveatSet, error) {
-	return m.verify(k, discharges, nil, true, trusted3Ps)
+//
+// Discharges are needed for each of the token's third-party caveats, but
+// attestations in a discharge (e.g. "this request is made by bob") are only
+// trusted, and returned, if the discharge's ticket can be unsealed with the
+// key of a trusted third party at the discharge's location. trusted3Ps maps
+// the locations of trusted third parties to their keys, and may be nil. See
+// [Trusted3Ps] and [WithTrusted3Ps] for more flexible configuration.
+//
+// Verify's behavior can be adjusted with [VerifyOption]s.
+func (m *Macaroon) Verify(k SigningKey, discharges [][]byte, trusted3Ps map[string]EncryptionKey, opts ...VerifyOption) (*CaveatSet, error) {
+	return m.verify(k, discharges, nil, true, trusted3Ps, opts...)
 }
 
-func (m *Macaroon) verify(k SigningKey, discharges [][]byte, parentTokenBindingIds [][]byte, trustAttestations bool, trusted3Ps map[string]EncryptionKey) (*CaveatSet, error) {
+func (m *Macaroon) verify(k SigningKey, discharges [][]byte, parentTokenBindingIds [][]byte, trustAttestations bool, trusted3Ps map[string]EncryptionKey, opts ...VerifyOption) (*CaveatSet, error) {
+	if m.display {
+		return nil, errDisplayCopy
+	}
+
+	o := newVerifyOptions(opts...)
+	if o.err != nil {
+		return nil, fmt.Errorf("macaroon verify: %w", o.err)
+	}
+	if err := o.checkCaveatCount(len(m.UnsafeCaveats.Caveats)); err != nil {
+		return nil, err
+	}
+
 	if m.Nonce.Proof && m.newProof {
 		return nil, errors.New("can't verify unfinalized proof")
 	}
@@ -312,14 +398,19 @@ func (m *Macaroon) verify(k SigningKey, discharges [][]byte, parentTokenBindingI
 		trusted3Ps = map[string]EncryptionKey{}
 	}
 
-	dischargeByCID := make(map[string]*Macaroon, len(discharges))
-	for _, dBuf := range discharges {
+	type indexedDischarge struct {
+		m     *Macaroon
+		index int
+	}
+
+	dischargeByCID := make(map[string]indexedDischarge, len(discharges))
+	for i, dBuf := range discharges {
 		decoded, err := Decode(dBuf)
 		if err != nil {
 			continue // ignore malformed discharges
 		}
 
-		dischargeByCID[string(decoded.Nonce.KID)] = decoded
+		dischargeByCID[string(decoded.Nonce.KID)] = indexedDischarge{decoded, i}
 	}
 
 	curMac := sign(k, m.Nonce.MustEncode())
@@ -327,19 +418,24 @@ func (m *Macaroon) verify(k SigningKey, discharges [][]byte, parentTokenBindingI
 	ret := NewCaveatSet()
 
 	type verifyParams struct {
-		m *Macaroon
-		k SigningKey
+		m        *Macaroon
+		k        SigningKey
+		index    int
+		location string
 	}
 
 	dischargesToVerify := make([]*verifyParams, 0, len(dischargeByCID))
 	thisTokenBindingIds := [][]byte{digest(curMac)}
+	bound := false
 
-	for _, c := range m.UnsafeCaveats.Caveats {
+	// caveats before the last valid IssuerSeal were added by the issuer
+	sealed := 0
+
+	for i, c := range m.UnsafeCaveats.Caveats {
 		switch cav := c.(type) {
 		case *Caveat3P:
-			discharge, ok := dischargeByCID[string(cav.CID)]
-			if !ok {
-				return nil, errors.New("no matching discharge token")
+			if err := o.check3PLocation(cav.Location); err != nil {
+				return nil, err
 			}
 
 			dischargeKey, err := unseal(EncryptionKey(curMac), cav.VID)
@@ -347,7 +443,13 @@ func (m *Macaroon) verify(k SigningKey, discharges [][]byte, parentTokenBindingI
 				return nil, fmt.Errorf("macaroon verify: unseal VID for third-party caveat: %w", err)
 			}
 
-			dischargesToVerify = append(dischargesToVerify, &verifyParams{discharge, dischargeKey})
+			// missing discharges are reported along with failed ones below
+			discharge, ok := dischargeByCID[string(cav.CID)]
+			if !ok {
+				discharge.index = -1
+			}
+
+			dischargesToVerify = append(dischargesToVerify, &verifyParams{discharge.m, dischargeKey, discharge.index, cav.Location})
 		case *BindToParentToken:
 			// TODO @bento: this could be optimized
 			found := false
@@ -360,6 +462,12 @@ func (m *Macaroon) verify(k SigningKey, discharges [][]byte, parentTokenBindingI
 			if !found {
 				return nil, fmt.Errorf("discharge bound to different parent token: %x", cav)
 			}
+			bound = true
+		case *IssuerSeal:
+			if err := cav.check(k, curMac); err != nil {
+				return nil, err
+			}
+			sealed = len(ret.Caveats)
 		default:
 			if cav.IsAttestation() && !m.Nonce.Proof {
 				return nil, errors.New("attestation in non-proof macaroon")
@@ -370,7 +478,7 @@ func (m *Macaroon) verify(k SigningKey, discharges [][]byte, parentTokenBindingI
 			}
 		}
 
-		opc, err := NewCaveatSet(c).MarshalMsgpack()
+		opc, err := m.UnsafeCaveats.caveatBytes(i)
 		if err != nil {
 			return nil, err
 		}
@@ -379,41 +487,40 @@ func (m *Macaroon) verify(k SigningKey, discharges [][]byte, parentTokenBindingI
 		thisTokenBindingIds = append(thisTokenBindingIds, digest(curMac))
 	}
 
-	for _, d := range dischargesToVerify {
-		// If the discharge was actually created by a known third party we can
-		// trust its attestations. Verify this by comparing signing key from
-		// VID/CID.
-		var trustedDischarge bool
-		if ka, ok := trusted3Ps[d.m.Location]; ok {
-			cidr, err := unseal(ka, d.m.Nonce.KID)
-			if err != nil {
-				return ret, fmt.Errorf("discharge cid decrypt: %w", err)
-			}
-
-			var cid wireCID
-			if err = msgpack.Unmarshal(cidr, &cid); err != nil {
-				return ret, fmt.Errorf("bad cid in discharge: %w", err)
+	ret.provenance = make([]CaveatProvenance, len(ret.Caveats))
+	if sealed > 0 {
+		for i := range ret.provenance {
+			if i < sealed {
+				ret.provenance[i].Provenance = ProvenanceIssuer
+			} else {
+				ret.provenance[i].Provenance = ProvenanceAttenuator
 			}
+		}
+	}
 
-			if subtle.ConstantTimeCompare(d.k, cid.RN) != 1 {
-				return ret, errors.New("discharge key from CID/VID mismatch")
-			}
+	// discharge failures are collected, so they can all be reported
+	var dischargeErrs error
 
-			trustedDischarge = true
+	for _, d := range dischargesToVerify {
+		if d.m == nil {
+			dischargeErrs = appendErrs(dischargeErrs, &DischargeError{Index: -1, Location: d.location, Err: ErrNoDischarge})
+			continue
 		}
 
-		dcavs, err := d.m.verify(
-			d.k,
-			nil, /* don't let them nest yet */
-			thisTokenBindingIds,
-			trustAttestations && trustedDischarge,
-			trusted3Ps,
-		)
+		dcavs, err := verifyDischarge(d.m, d.k, thisTokenBindingIds, trustAttestations, trusted3Ps, o, opts...)
 		if err != nil {
-			return nil, fmt.Errorf("macaroon verify: verify discharge: %w", err)
+			dischargeErrs = appendErrs(dischargeErrs, &DischargeError{Index: d.index, Location: d.location, Err: err})
+			continue
 		}
 
 		ret.Caveats = append(ret.Caveats, dcavs.Caveats...)
+		for range dcavs.Caveats {
+			ret.provenance = append(ret.provenance, CaveatProvenance{ProvenanceDischarge, d.location})
+		}
+	}
+
+	if len(parentTokenBindingIds) != 0 && o.requireParentBinding && !bound {
+		return nil, errors.New("discharge not bound to parent token")
 	}
 
 	if m.Nonce.Proof {
@@ -424,9 +531,86 @@ func (m *Macaroon) verify(k SigningKey, discharges [][]byte, parentTokenBindingI
 		return nil, fmt.Errorf("macaroon verify: invalid")
 	}
 
+	if dischargeErrs != nil {
+		return nil, fmt.Errorf("macaroon verify: %w", dischargeErrs)
+	}
+
+	if len(parentTokenBindingIds) == 0 {
+		if err := o.checkCaveatCount(len(ret.Caveats)); err != nil {
+			return nil, err
+		}
+		if err := o.checkChannelBinding(ret); err != nil {
+			return nil, err
+		}
+		if err := o.checkAttestationAge(ret, o.clock()); err != nil {
+			return nil, err
+		}
+		if o.stats != nil {
+			o.stats.ReportTokenStats(CollectTokenStats(m, ret, o.clock()))
+		}
+	}
+
+	ret.verified = true
 	return ret, nil
 }
 
+// verifyDischarge verifies a discharge for one of the token's third-party
+// caveats, whose key is dk.
+func verifyDischarge(d *Macaroon, dk SigningKey, bindingIds [][]byte, trustAttestations bool, trusted3Ps map[string]EncryptionKey, o *verifyOptions, opts ...VerifyOption) (*CaveatSet, error) {
+	// If the discharge was actually created by a known third party we can
+	// trust its attestations. Verify this by comparing signing key from
+	// VID/CID.
+	var trustedKey Sealer
+	if keys := o.trusted3PKeys(trusted3Ps, d.Location); len(keys) != 0 {
+		var (
+			cidr []byte
+			err  error
+		)
+		for _, ka := range keys {
+			if cidr, err = ka.Unseal(d.Nonce.KID); err == nil {
+				trustedKey = ka
+				break
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("discharge cid decrypt: %w", err)
+		}
+
+		var cid wireCID
+		if err = msgpack.Unmarshal(cidr, &cid); err != nil {
+			return nil, fmt.Errorf("bad cid in discharge: %w", err)
+		}
+
+		if subtle.ConstantTimeCompare(dk, cid.RN) != 1 {
+			return nil, errors.New("discharge key from CID/VID mismatch")
+		}
+	}
+
+	dcavs, err := d.verify(
+		dk,
+		nil, /* don't let them nest yet */
+		bindingIds,
+		trustAttestations && trustedKey != nil,
+		trusted3Ps,
+		opts...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := o.checkDischargeAge(dcavs, o.clock()); err != nil {
+		return nil, err
+	}
+
+	if trustedKey != nil {
+		if err := unsealAttestations(dcavs, trustedKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return dcavs, nil
+}
+
 // finalizeSignature could conceptually just hash the macaroon tail. We're
 // already using the truncated tail hash for token binding though. It wouldn't
 // actually be bad to use the hash here, but HMAC feels better.
@@ -487,14 +671,33 @@ func (m *Macaroon) Add3P(ka EncryptionKey, loc string, cs ...Caveat) error {
 		return fmt.Errorf("bad key size: have %d, need %d", len(ka), EncryptionKeySize)
 	}
 
+	return m.Add3PWithSealer(ka, loc, cs...)
+}
+
+// Add3PWithSealer is like [Macaroon.Add3P], but seals the third-party
+// caveat's ticket with an arbitrary [Sealer] rather than an [EncryptionKey].
+// The third party must unseal the ticket with a compatible Sealer, using
+// [DischargeCIDWithSealer].
+func (m *Macaroon) Add3PWithSealer(s Sealer, loc string, cs ...Caveat) error {
+	return m.Add3PTicket(s, loc, TicketInfo{}, cs...)
+}
+
+// Add3PTicket is like [Macaroon.Add3PWithSealer], but includes metadata in
+// the third-party caveat's ticket, such as an expiry after which the third
+// party will refuse to discharge it.
+func (m *Macaroon) Add3PTicket(s Sealer, loc string, info TicketInfo, cs ...Caveat) error {
 	// make a new root hmac key for the 3p discharge macaroon
 	rn := NewSigningKey()
 
 	// make the CID, which is consumed by the 3p service; then
 	// encode and encrypt it
 	cid := &wireCID{
-		RN:      rn,
-		Caveats: *NewCaveatSet(cs...),
+		RN:         rn,
+		Caveats:    *NewCaveatSet(cs...),
+		IssuerHint: info.IssuerHint,
+	}
+	if !info.Expiry.IsZero() {
+		cid.Expiry = info.Expiry.Unix()
 	}
 
 	cidBytes, err := encode(cid)
@@ -502,13 +705,16 @@ func (m *Macaroon) Add3P(ka EncryptionKey, loc string, cs ...Caveat) error {
 		return fmt.Errorf("encoding CID: %w", err)
 	}
 
-	m.Add(&Caveat3P{
+	sealed, err := s.Seal(cidBytes)
+	if err != nil {
+		return fmt.Errorf("sealing CID: %w", err)
+	}
+
+	return m.Add(&Caveat3P{
 		Location: loc,
-		CID:      seal(ka, cidBytes),
+		CID:      sealed,
 		rn:       rn,
 	})
-
-	return nil
 }
 
 // ThirdPartyCIDs extracts the encrypted CIDs from a token's third party
@@ -538,11 +744,11 @@ func (m *Macaroon) ThirdPartyCIDs(existingDischarges ...[]byte) (map[string][]by
 	}
 
 	for _, cav := range GetCaveats[*Caveat3P](&m.UnsafeCaveats) {
-		if _, exists := ret[cav.Location]; exists {
+		if _, exists := lookupLocation(ret, cav.Location); exists {
 			return nil, fmt.Errorf("extract third party caveats: duplicate locations: %s", cav.Location)
 		}
 
-		if _, discharged := dischargeCIDs[hex.EncodeToString(m.Nonce.KID)]; !discharged {
+		if _, discharged := dischargeCIDs[hex.EncodeToString(cav.CID)]; !discharged {
 			ret[cav.Location] = cav.CID
 		}
 	}
@@ -551,14 +757,39 @@ func (m *Macaroon) ThirdPartyCIDs(existingDischarges ...[]byte) (map[string][]by
 }
 
 // ThirdPartyCID returns the CID (see [Macaron.ThirdPartyCIDs]) associated
-// with a URL location, if possible.
+// with a URL location, if possible. Locations are compared after
+// normalization (see [NormalizeLocation]).
 func (m *Macaroon) ThirdPartyCID(location string, existingDischarges ...[]byte) ([]byte, error) {
+	return m.ThirdPartyCIDMatching(LocationExact, location, existingDischarges...)
+}
+
+// ThirdPartyCIDMatching is like [Macaroon.ThirdPartyCID], but finds the CID
+// for the third-party caveat whose location matches the pattern according to
+// the match rule. It's an error for more than one undischarged caveat to
+// match.
+func (m *Macaroon) ThirdPartyCIDMatching(match LocationMatch, pattern string, existingDischarges ...[]byte) ([]byte, error) {
 	cids, err := m.ThirdPartyCIDs(existingDischarges...)
 	if err != nil {
 		return nil, err
 	}
 
-	return cids[location], nil
+	var (
+		ret   []byte
+		found string
+	)
+
+	for loc, cid := range cids {
+		if !match.Matches(pattern, loc) {
+			continue
+		}
+
+		if ret != nil {
+			return nil, fmt.Errorf("extract third party caveats: %s and %s both match %s", found, loc, pattern)
+		}
+		ret, found = cid, loc
+	}
+
+	return ret, nil
 }
 
 // https://stackoverflow.com/questions/25065055/what-is-the-maximum-time-time-in-go
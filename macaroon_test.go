@@ -2,6 +2,7 @@ package macaroon
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"math/rand"
 	"testing"
@@ -9,6 +10,8 @@ import (
 
 	"github.com/alecthomas/assert/v2"
 	"github.com/stretchr/testify/require"
+	"github.com/superfly/macaroon/access"
+	"github.com/superfly/macaroon/internal/rnd"
 	msgpack "github.com/vmihailenco/msgpack/v5"
 )
 
@@ -22,6 +25,13 @@ func cavExpiry(d time.Duration) Caveat {
 const (
 	cavTestParentResource = iota + CavMinUserDefined
 	cavTestChildResource
+	cavTestWidgetV1
+	cavTestWidgetV2
+	cavTestRenamed
+	cavTestAttestation
+	cavTestDeprecated
+	cavTestWire
+	cavTestTimestamped
 )
 
 type testCaveatParentResource struct {
@@ -40,7 +50,7 @@ func (c *testCaveatParentResource) CaveatType() CaveatType {
 }
 
 func (c *testCaveatParentResource) Prohibits(f Access) error {
-	tf, isTestAccess := f.(*testAccess)
+	tf, isTestAccess := access.As[*testAccess](f)
 
 	switch {
 	case !isTestAccess:
@@ -76,7 +86,7 @@ func (c *testCaveatChildResource) CaveatType() CaveatType {
 }
 
 func (c *testCaveatChildResource) Prohibits(f Access) error {
-	tf, isTestAccess := f.(*testAccess)
+	tf, isTestAccess := access.As[*testAccess](f)
 
 	switch {
 	case !isTestAccess:
@@ -238,7 +248,9 @@ func TestMacaroons(t *testing.T) {
 		defer reset(t)
 		requireVerify(t)
 
-		assert.Equal(t, mac.UnsafeCaveats, *verifiedCavs)
+		assert.Equal(t, mac.UnsafeCaveats.Caveats, verifiedCavs.Caveats)
+		assert.True(t, verifiedCavs.Verified())
+		assert.False(t, mac.UnsafeCaveats.Verified())
 	})
 
 	t.Run("verify - with 1p caveat", func(t *testing.T) {
@@ -458,7 +470,9 @@ func Test3pe2e(t *testing.T) {
 
 			_, _, err = dischargeCID(ka, authLoc, cid, isProof)
 			assert.NoError(t, err)
-			cid[10] = 0
+			// flip the byte, rather than zeroing it, so it's corrupted
+			// even when it's already zero (1 in 256 runs)
+			cid[10] ^= 0xff
 			_, _, err = dischargeCID(ka, authLoc, cid, isProof)
 			assert.Error(t, err)
 
@@ -498,7 +512,31 @@ func TestAttenuate(t *testing.T) {
 	m2, err := decoded.Verify(key, nil, nil)
 	assert.NoError(t, err)
 
-	t.Logf("%s", m2)
+	t.Logf("%v", m2)
+}
+
+func TestDeterministic(t *testing.T) {
+	mint := func() []byte {
+		t.Helper()
+		defer rnd.SetReaderForTesting(rnd.NewSeededReader([]byte("seed")))()
+
+		var (
+			key = NewSigningKey()
+			ka  = NewEncryptionKey()
+		)
+
+		m, err := New([]byte("kid"), "https://api.fly.io", key)
+		require.NoError(t, err)
+		require.NoError(t, m.Add(cavParent(ActionRead, 123)))
+		require.NoError(t, m.Add3P(ka, "https://auth.fly.io"))
+
+		buf, err := m.Encode()
+		require.NoError(t, err)
+
+		return buf
+	}
+
+	assert.Equal(t, mint(), mint())
 }
 
 func TestSimple3P(t *testing.T) {
@@ -649,3 +687,128 @@ func dischargeMacaroon(ka EncryptionKey, location string, encodedMacaroon []byte
 	dcavs, dm, err := DischargeCID(ka, location, cid)
 	return true, dcavs, dm, err
 }
+
+func TestStreaming(t *testing.T) {
+	key := NewSigningKey()
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavParent(ActionRead, 1)))
+
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	// tokens written back to back can be read back one at a time
+	var w bytes.Buffer
+	assert.NoError(t, m.EncodeTo(&w))
+	assert.Equal(t, buf, w.Bytes())
+	assert.NoError(t, m.EncodeTo(&w))
+
+	for i := 0; i < 2; i++ {
+		m2, err := DecodeFrom(&w)
+		assert.NoError(t, err)
+		_, err = m2.Verify(key, nil, nil)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 0, w.Len())
+
+	_, err = DecodeFrom(&w)
+	assert.Error(t, err)
+
+	cs := NewCaveatSet(cavParent(ActionRead, 1), cavChild(ActionWrite, 2))
+	assert.NoError(t, cs.EncodeTo(&w))
+
+	csBuf, err := cs.MarshalMsgpack()
+	assert.NoError(t, err)
+	assert.Equal(t, csBuf, w.Bytes())
+
+	cs2, err := DecodeCaveatsFrom(&w)
+	assert.NoError(t, err)
+	assert.Equal(t, cs, cs2)
+}
+
+func TestDischargeErrors(t *testing.T) {
+	var (
+		key = NewSigningKey()
+		ka  = NewEncryptionKey()
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	for _, loc := range []string{"https://a", "https://b", "https://c"} {
+		assert.NoError(t, m.Add3P(ka, loc))
+	}
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	discharge := func(loc string) *Macaroon {
+		_, _, dm, err := dischargeMacaroon(ka, loc, buf)
+		assert.NoError(t, err)
+		return dm
+	}
+
+	da, err := discharge("https://a").Encode()
+	assert.NoError(t, err)
+
+	// tampered with
+	dbm := discharge("https://b")
+	dbm.Tail[0] ^= 1
+	db, err := dbm.Encode()
+	assert.NoError(t, err)
+
+	_, err = m.Verify(key, [][]byte{[]byte("garbage"), da, db}, nil)
+	assert.True(t, errors.Is(err, ErrNoDischarge))
+
+	derrs := DischargeErrors(err)
+	assert.Equal(t, 2, len(derrs))
+	assert.Equal(t, 2, derrs[0].Index)
+	assert.Equal(t, "https://b", derrs[0].Location)
+	assert.Equal(t, -1, derrs[1].Index)
+	assert.Equal(t, "https://c", derrs[1].Location)
+	assert.EqualError(t, err, "macaroon verify: verify discharge 2 for https://b: macaroon verify: invalid; no matching discharge token for https://c")
+
+	var derr *DischargeError
+	assert.True(t, errors.As(err, &derr))
+	assert.Equal(t, "https://b", derr.Location)
+}
+
+func TestDecodeNonce(t *testing.T) {
+	m, err := New([]byte("kid"), "https://api", NewSigningKey())
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavParent(ActionRead, 1)))
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	n, err := DecodeNonce(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, m.Nonce, n)
+
+	_, err = DecodeNonce([]byte{0x90})
+	assert.Error(t, err)
+}
+
+func TestThirdPartyCIDsExistingDischarges(t *testing.T) {
+	var (
+		ka1 = NewEncryptionKey()
+		ka2 = NewEncryptionKey()
+	)
+
+	m, err := New([]byte("kid"), "https://api", NewSigningKey())
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add3P(ka1, "https://one"))
+	assert.NoError(t, m.Add3P(ka2, "https://two"))
+
+	cids, err := m.ThirdPartyCIDs()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(cids))
+
+	_, dm, err := DischargeCID(ka1, "https://one", cids["https://one"])
+	assert.NoError(t, err)
+	dbuf, err := dm.Encode()
+	assert.NoError(t, err)
+
+	// discharged caveats are matched by their CIDs
+	remaining, err := m.ThirdPartyCIDs(dbuf)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"https://two": cids["https://two"]}, remaining)
+}
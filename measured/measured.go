@@ -0,0 +1,164 @@
+// Package measured lets workload tokens prove they were discharged to code
+// running in a measured environment: a TPM-backed host or a trusted execution
+// environment (TEE) like SEV-SNP, TDX or Nitro Enclaves.
+//
+// The token carries a third-party caveat for an attestation service. The
+// workload has the caveat discharged by presenting a quote from its TPM or
+// TEE, whose report data commits to the caveat's ticket. The attestation
+// service checks the quote with a [QuoteVerifier] and adds a [Quote]
+// attestation to the discharge (see [Discharge]). A [Require] caveat in the
+// token restricts which measurements are acceptable.
+//
+// Attestations are only considered if the discharge comes from a trusted
+// third party (see [macaroon.Macaroon.Verify]).
+package measured
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/superfly/macaroon"
+)
+
+// Well-known platforms.
+const (
+	PlatformTPM    = "tpm"
+	PlatformSEVSNP = "sev-snp"
+	PlatformTDX    = "tdx"
+	PlatformNitro  = "nitro"
+)
+
+// Quote is an attestation, added by an attestation service to its discharge
+// tokens, stating that the workload presented a valid quote from a measured
+// environment. Digest is the SHA256 digest of the raw quote, so the quote can
+// be audited later. Measurement is the platform's measurement of the
+// workload (e.g. a PCR digest or launch measurement).
+type Quote struct {
+	Platform    string `json:"platform"`
+	Digest      []byte `json:"digest"`
+	Measurement []byte `json:"measurement"`
+}
+
+func init() {
+	macaroon.RegisterCaveatType("MeasuredEnvironmentAttestation", macaroon.CavMeasuredEnvironmentAttestation, &Quote{})
+}
+
+func (c *Quote) CaveatType() macaroon.CaveatType {
+	return macaroon.CavMeasuredEnvironmentAttestation
+}
+
+func (c *Quote) Prohibits(macaroon.Access) error {
+	// attestations play no role in access validation
+	return nil
+}
+
+func (c *Quote) IsAttestation() bool { return true }
+
+// Require requires that the token was discharged to a workload in a measured
+// environment with one of the listed measurements, as attested by a trusted
+// attestation service. If Platforms isn't empty, the environment must also be
+// one of the listed platforms.
+type Require struct {
+	Platforms    []string `json:"platforms,omitempty"`
+	Measurements [][]byte `json:"measurements"`
+}
+
+func init() {
+	macaroon.RegisterCaveatType("MeasuredEnvironment", macaroon.CavMeasuredEnvironment, &Require{})
+}
+
+func (c *Require) CaveatType() macaroon.CaveatType {
+	return macaroon.CavMeasuredEnvironment
+}
+
+func (c *Require) Prohibits(a macaroon.Access) error {
+	return c.ProhibitsWithContext(new(macaroon.ValidationContext), a)
+}
+
+func (c *Require) ProhibitsWithContext(vc *macaroon.ValidationContext, a macaroon.Access) error {
+	var quotes []*Quote
+	if cs := vc.Caveats(); cs != nil {
+		quotes = macaroon.GetCaveats[*Quote](cs)
+	}
+
+	if len(quotes) == 0 {
+		return fmt.Errorf("%w: no measured environment attestation", macaroon.ErrUnauthorized)
+	}
+
+	for _, q := range quotes {
+		if !c.allows(q) {
+			return fmt.Errorf("%w: measurement %s on %s not allowed", macaroon.ErrUnauthorized, hex.EncodeToString(q.Measurement), q.Platform)
+		}
+	}
+
+	return nil
+}
+
+func (c *Require) allows(q *Quote) bool {
+	if len(c.Platforms) != 0 {
+		var found bool
+		for _, p := range c.Platforms {
+			if p == q.Platform {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, m := range c.Measurements {
+		if bytes.Equal(m, q.Measurement) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Require) IsAttestation() bool { return false }
+
+// QuoteVerifier checks quotes from a TPM or TEE platform. VerifyQuote checks
+// the quote's signature chain and that its report data commits to
+// reportData, returning the workload's measurement.
+type QuoteVerifier interface {
+	VerifyQuote(platform string, quote, reportData []byte) (measurement []byte, err error)
+}
+
+// ReportData returns the value a workload's quote must commit to (e.g. in
+// the TEE report data or TPM quote nonce) to discharge the ticket. Binding the
+// quote to the ticket prevents quotes from being replayed for other tokens.
+func ReportData(ticket []byte) []byte {
+	h := sha256.Sum256(ticket)
+	return h[:]
+}
+
+// Discharge is used by an attestation service to discharge a ticket for a
+// workload presenting a quote. The quote is checked with v and the returned
+// discharge token carries a [Quote] attestation.
+func Discharge(ka macaroon.EncryptionKey, location string, ticket []byte, platform string, quote []byte, v QuoteVerifier) (*macaroon.Macaroon, error) {
+	if len(quote) == 0 {
+		return nil, errors.New("measured: empty quote")
+	}
+
+	_, dm, err := macaroon.DischargeCID(ka, location, ticket)
+	if err != nil {
+		return nil, err
+	}
+
+	measurement, err := v.VerifyQuote(platform, quote, ReportData(ticket))
+	if err != nil {
+		return nil, fmt.Errorf("measured: verify %s quote: %w", platform, err)
+	}
+
+	digest := sha256.Sum256(quote)
+	if err := dm.Add(&Quote{Platform: platform, Digest: digest[:], Measurement: measurement}); err != nil {
+		return nil, err
+	}
+
+	return dm, nil
+}
@@ -0,0 +1,72 @@
+package measured
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+	"github.com/superfly/macaroon/flyio"
+)
+
+// fakeVerifier accepts quotes of the form reportData || measurement.
+type fakeVerifier struct{}
+
+func (fakeVerifier) VerifyQuote(platform string, quote, reportData []byte) ([]byte, error) {
+	if !bytes.HasPrefix(quote, reportData) {
+		return nil, errors.New("report data mismatch")
+	}
+	return quote[len(reportData):], nil
+}
+
+func TestMeasured(t *testing.T) {
+	var (
+		key = macaroon.NewSigningKey()
+		ka  = macaroon.NewEncryptionKey()
+		loc = "https://attest"
+	)
+
+	m, err := macaroon.New([]byte("kid"), flyio.LocationPermission, key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(
+		&flyio.Organization{ID: 1, Mask: macaroon.ActionAll},
+		&Require{Platforms: []string{PlatformSEVSNP}, Measurements: [][]byte{[]byte("good")}},
+	))
+	assert.NoError(t, m.Add3P(ka, loc))
+
+	ticket, err := m.ThirdPartyCID(loc)
+	assert.NoError(t, err)
+
+	verify := func(platform string, measurement []byte) (*macaroon.CaveatSet, error) {
+		quote := append(ReportData(ticket), measurement...)
+		dm, err := Discharge(ka, loc, ticket, platform, quote, fakeVerifier{})
+		if err != nil {
+			return nil, err
+		}
+		tok, err := dm.Encode()
+		assert.NoError(t, err)
+		return m.Verify(key, [][]byte{tok}, map[string]macaroon.EncryptionKey{loc: ka})
+	}
+
+	access := &flyio.Access{OrgID: 1, Action: macaroon.ActionRead}
+
+	cs, err := verify(PlatformSEVSNP, []byte("good"))
+	assert.NoError(t, err)
+	assert.NoError(t, cs.Validate(access))
+
+	cs, err = verify(PlatformSEVSNP, []byte("bad"))
+	assert.NoError(t, err)
+	assert.Error(t, cs.Validate(access))
+
+	cs, err = verify(PlatformTDX, []byte("good"))
+	assert.NoError(t, err)
+	assert.Error(t, cs.Validate(access))
+
+	// quotes must commit to the ticket
+	_, err = Discharge(ka, loc, ticket, PlatformSEVSNP, append(ReportData([]byte("other")), "good"...), fakeVerifier{})
+	assert.Error(t, err)
+
+	// no attestation
+	assert.Error(t, macaroon.NewCaveatSet(&Require{Measurements: [][]byte{[]byte("good")}}).Validate(access))
+}
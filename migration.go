@@ -0,0 +1,64 @@
+package macaroon
+
+import (
+	"fmt"
+
+	msgpack "github.com/vmihailenco/msgpack/v5"
+)
+
+// CaveatMigration converts a decoded caveat of a superseded type into its
+// replacement.
+type CaveatMigration func(Caveat) (Caveat, error)
+
+var migrations = map[CaveatType]CaveatMigration{}
+
+// RegisterCaveatMigration declares that caveats of type `from` are superseded
+// by another caveat type. Caveats of type `from` are converted with the
+// provided function as they're decoded, so code evaluating caveats only ever
+// sees the replacement type. Migrations are applied repeatedly, so a type may
+// be migrated to a type that is itself superseded.
+//
+// The superseded type must remain registered so that it can be decoded. The
+// original encoding of a migrated caveat is retained, so that signatures
+// over existing tokens still verify and re-encoding those tokens is lossless.
+func RegisterCaveatMigration(from CaveatType, migrate CaveatMigration) {
+	if _, dup := migrations[from]; dup {
+		panic("duplicate caveat migration")
+	}
+	if _, registered := t2c[from]; !registered {
+		panic("migration from unregistered caveat type")
+	}
+
+	migrations[from] = migrate
+}
+
+// migrate applies any registered migrations to the caveat.
+func migrate(cav Caveat) (Caveat, error) {
+	seen := map[CaveatType]bool{}
+
+	for {
+		t := cav.CaveatType()
+
+		m, ok := migrations[t]
+		if !ok {
+			return cav, nil
+		}
+
+		if seen[t] {
+			return nil, fmt.Errorf("caveat migration cycle at type %d", t)
+		}
+		seen[t] = true
+
+		var err error
+		if cav, err = m(cav); err != nil {
+			return nil, fmt.Errorf("migrate caveat type %d: %w", t, err)
+		}
+	}
+}
+
+// rawCaveat is the original wire encoding of a caveat that was migrated when
+// it was decoded.
+type rawCaveat struct {
+	typ  CaveatType
+	body msgpack.RawMessage
+}
@@ -0,0 +1,81 @@
+package macaroon
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+// testCaveatWidgetV1 is superseded by testCaveatWidgetV2
+type testCaveatWidgetV1 struct {
+	Name string
+}
+
+func init() {
+	RegisterCaveatType("WidgetV1", cavTestWidgetV1, &testCaveatWidgetV1{})
+	RegisterCaveatMigration(cavTestWidgetV1, func(c Caveat) (Caveat, error) {
+		return &testCaveatWidgetV2{Names: []string{c.(*testCaveatWidgetV1).Name}}, nil
+	})
+}
+
+func (c *testCaveatWidgetV1) CaveatType() CaveatType   { return cavTestWidgetV1 }
+func (c *testCaveatWidgetV1) IsAttestation() bool      { return false }
+func (c *testCaveatWidgetV1) Prohibits(f Access) error { return ErrBadCaveat }
+
+type testCaveatWidgetV2 struct {
+	Names []string
+}
+
+func init() { RegisterCaveatType("WidgetV2", cavTestWidgetV2, &testCaveatWidgetV2{}) }
+
+func (c *testCaveatWidgetV2) CaveatType() CaveatType { return cavTestWidgetV2 }
+func (c *testCaveatWidgetV2) IsAttestation() bool    { return false }
+
+func (c *testCaveatWidgetV2) Prohibits(f Access) error {
+	if len(c.Names) == 0 {
+		return fmt.Errorf("%w widget", ErrUnauthorizedForResource)
+	}
+	return nil
+}
+
+func TestCaveatMigration(t *testing.T) {
+	key := NewSigningKey()
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavParent(ActionRead, 1), &testCaveatWidgetV1{Name: "foo"}, cavChild(ActionRead, 2)))
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	decoded, err := Decode(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, &testCaveatWidgetV2{Names: []string{"foo"}}, decoded.UnsafeCaveats.Caveats[1].(*testCaveatWidgetV2))
+
+	// old token still verifies
+	cavs, err := decoded.Verify(key, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(GetCaveats[*testCaveatWidgetV2](cavs)))
+
+	// re-encoding is lossless
+	buf2, err := decoded.Encode()
+	assert.NoError(t, err)
+	assert.Equal(t, buf, buf2)
+
+	// further attenuation works
+	assert.NoError(t, decoded.Add(cavChild(ActionRead, 3)))
+	buf3, err := decoded.Encode()
+	assert.NoError(t, err)
+	decoded, err = Decode(buf3)
+	assert.NoError(t, err)
+	_, err = decoded.Verify(key, nil, nil)
+	assert.NoError(t, err)
+
+	// json
+	jbuf, err := json.Marshal(NewCaveatSet(&testCaveatWidgetV1{Name: "foo"}))
+	assert.NoError(t, err)
+	cs := NewCaveatSet()
+	assert.NoError(t, json.Unmarshal(jbuf, cs))
+	assert.Equal(t, NewCaveatSet(&testCaveatWidgetV2{Names: []string{"foo"}}), cs)
+}
@@ -0,0 +1,105 @@
+package macaroon
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// MultiRoot makes a token verifiable under any one of several root keys,
+// e.g. those of regional issuers, so each region can verify tokens locally
+// without the regions sharing a key. Multi-root tokens are signed with a
+// random key, which MultiRoot carries sealed under each root's key. It must
+// be the token's first caveat. See [NewMultiRoot] and
+// [Macaroon.VerifyMultiRoot].
+//
+// Anyone holding a root key can recover the signing key of multi-root tokens
+// naming that root, so can mint tokens the other roots will accept. Only
+// list roots that are trusted equally.
+type MultiRoot struct {
+	Roots []SealedRoot `json:"roots"`
+}
+
+// SealedRoot is the signing key of a multi-root token, sealed under the key
+// of the root identified by KID.
+type SealedRoot struct {
+	KID []byte `json:"kid"`
+	Key []byte `json:"key"`
+}
+
+func init() { RegisterCaveatType("MultiRoot", CavMultiRoot, &MultiRoot{}) }
+
+func (c *MultiRoot) CaveatType() CaveatType { return CavMultiRoot }
+func (c *MultiRoot) IsAttestation() bool    { return false }
+
+func (c *MultiRoot) Prohibits(f Access) error {
+	// MultiRoot is part of token verification and has no role in access
+	// validation.
+	return nil
+}
+
+// NewMultiRoot mints a token verifiable under any of the roots, whose keys
+// are indexed by root KID. kid and loc are the token's, as with [New].
+func NewMultiRoot(kid []byte, loc string, roots map[string]EncryptionKey) (*Macaroon, error) {
+	if len(roots) == 0 {
+		return nil, errors.New("multi-root: no roots")
+	}
+
+	kids := make([]string, 0, len(roots))
+	for rootKID := range roots {
+		kids = append(kids, rootKID)
+	}
+	sort.Strings(kids)
+
+	key := NewSigningKey()
+
+	cav := &MultiRoot{Roots: make([]SealedRoot, 0, len(kids))}
+	for _, rootKID := range kids {
+		sealed, err := roots[rootKID].Seal(key)
+		if err != nil {
+			return nil, fmt.Errorf("multi-root: root %x: %w", rootKID, err)
+		}
+		cav.Roots = append(cav.Roots, SealedRoot{KID: []byte(rootKID), Key: sealed})
+	}
+
+	m, err := New(kid, loc, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Add(cav); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// VerifyMultiRoot verifies a token minted with [NewMultiRoot], using the key
+// ka of the root identified by rootKID. Otherwise, it's like
+// [Macaroon.Verify]. ErrUnknownKID is returned for tokens that can't be
+// verified under the root.
+func (m *Macaroon) VerifyMultiRoot(rootKID []byte, ka EncryptionKey, discharges [][]byte, trusted3Ps map[string]EncryptionKey, opts ...VerifyOption) (*CaveatSet, error) {
+	if len(m.UnsafeCaveats.Caveats) == 0 {
+		return nil, errors.New("multi-root verify: not a multi-root token")
+	}
+
+	// later MultiRoot caveats may have been added by anyone
+	cav, ok := m.UnsafeCaveats.Caveats[0].(*MultiRoot)
+	if !ok {
+		return nil, errors.New("multi-root verify: not a multi-root token")
+	}
+
+	for _, root := range cav.Roots {
+		if string(root.KID) != string(rootKID) {
+			continue
+		}
+
+		key, err := ka.Unseal(root.Key)
+		if err != nil {
+			return nil, fmt.Errorf("multi-root verify: unseal key for root %x: %w", rootKID, err)
+		}
+
+		return m.Verify(key, discharges, trusted3Ps, opts...)
+	}
+
+	return nil, fmt.Errorf("multi-root verify: %w: %x", ErrUnknownKID, rootKID)
+}
@@ -0,0 +1,62 @@
+package macaroon
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestMultiRoot(t *testing.T) {
+	var (
+		iad = NewEncryptionKey()
+		ams = NewEncryptionKey()
+		syd = NewEncryptionKey()
+	)
+
+	m, err := NewMultiRoot([]byte("tok"), "loc", map[string]EncryptionKey{"iad": iad, "ams": ams})
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavParent(ActionRead, 123), cavExpiry(time.Hour)))
+
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	decoded, err := Decode(buf)
+	assert.NoError(t, err)
+
+	for kid, ka := range map[string]EncryptionKey{"iad": iad, "ams": ams} {
+		cs, err := decoded.VerifyMultiRoot([]byte(kid), ka, nil, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, cs.Validate(&testAccess{action: ActionRead, parentResource: ptr(uint64(123))}))
+		assert.Error(t, cs.Validate(&testAccess{action: ActionRead, parentResource: ptr(uint64(234))}))
+	}
+
+	// wrong key for the root
+	_, err = decoded.VerifyMultiRoot([]byte("iad"), ams, nil, nil)
+	assert.Error(t, err)
+
+	// root not listed
+	_, err = decoded.VerifyMultiRoot([]byte("syd"), syd, nil, nil)
+	assert.True(t, errors.Is(err, ErrUnknownKID))
+
+	// attenuation works as usual
+	assert.NoError(t, decoded.Add(cavParent(ActionRead, 123)))
+	_, err = decoded.VerifyMultiRoot([]byte("ams"), ams, nil, nil)
+	assert.NoError(t, err)
+
+	// tampering breaks the signature
+	decoded.UnsafeCaveats.Caveats = decoded.UnsafeCaveats.Caveats[:2]
+	_, err = decoded.VerifyMultiRoot([]byte("ams"), ams, nil, nil)
+	assert.Error(t, err)
+
+	// MultiRoot is only honored as the first caveat
+	plain, err := New([]byte("tok"), "loc", NewSigningKey())
+	assert.NoError(t, err)
+	assert.NoError(t, plain.Add(cavParent(ActionRead, 123), m.UnsafeCaveats.Caveats[0]))
+	_, err = plain.VerifyMultiRoot([]byte("iad"), iad, nil, nil)
+	assert.Error(t, err)
+
+	_, err = NewMultiRoot([]byte("tok"), "loc", nil)
+	assert.Error(t, err)
+}
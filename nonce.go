@@ -5,6 +5,7 @@ import (
 
 	"github.com/google/uuid"
 	msgpack "github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
 )
 
 const nonceRndSize = 16
@@ -28,6 +29,11 @@ type Nonce struct {
 	nonceV0Fields
 	nonceV1Fields
 	version int
+
+	// raw is the original encoding of a nonce decoded from a legacy layout
+	// (see decodeLegacy). The token's signature covers the nonce's encoding,
+	// so it's re-encoded verbatim.
+	raw []byte
 }
 
 var (
@@ -55,6 +61,17 @@ func (n *Nonce) DecodeMsgpack(d *msgpack.Decoder) error {
 	// we encode structs as arrays, so adding new fields is tricky...
 	// The Closed field was a later addition, so we handle 2 or 3 fields.
 
+	code, err := d.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	if msgpcode.IsFixedMap(code) || code == msgpcode.Map16 || code == msgpcode.Map32 {
+		return n.decodeLegacy(d)
+	}
+
+	n.raw = nil
+
 	nFields, err := d.DecodeArrayLen()
 	if err != nil {
 		return err
@@ -86,6 +103,10 @@ func (n *Nonce) DecodeMsgpack(d *msgpack.Decoder) error {
 
 // DecodeMsgpack implements [msgpack.CustomDecoder]
 func (n *Nonce) EncodeMsgpack(e *msgpack.Encoder) error {
+	if n.raw != nil {
+		return e.Encode(msgpack.RawMessage(n.raw))
+	}
+
 	var fields []any
 
 	if n.version >= 0 {
@@ -126,5 +147,6 @@ func newNonce(kid []byte, isProof bool) Nonce {
 			Proof: isProof,
 		},
 		nonceVInvalid - 1,
+		nil,
 	}
 }
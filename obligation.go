@@ -0,0 +1,160 @@
+package macaroon
+
+import "fmt"
+
+// Well-known obligation types. Services may define their own.
+const (
+	// ObligationLog requires the request to be logged to the audit channel
+	// named by the obligation's value.
+	ObligationLog = "log"
+
+	// ObligationNotify requires the party named by the obligation's value to
+	// be notified of the request.
+	ObligationNotify = "notify"
+
+	// ObligationRequireTag requires the session making the request to carry
+	// the tag named by the obligation's value (e.g. "mfa").
+	ObligationRequireTag = "require-tag"
+)
+
+// Obligation is a caveat that doesn't restrict access, but instead obliges
+// the service handling a request to do something (e.g. log the request to a
+// particular audit channel). Obligations are returned by
+// [Validator.ValidateWithObligations], which callers must use to learn about
+// them. Validating a caveat set with obligations any other way fails with
+// ErrUnhandledObligation, so that they can't be silently dropped. Only
+// top-level obligations are allowed; caveat sets with obligations nested
+// within other caveats (e.g. [IfPresent]) fail to validate.
+type Obligation struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+func init() { RegisterCaveatType("Obligation", CavObligation, &Obligation{}) }
+
+func (c *Obligation) CaveatType() CaveatType {
+	return CavObligation
+}
+
+func (c *Obligation) Prohibits(f Access) error {
+	return c.ProhibitsWithContext(nil, f)
+}
+
+// obligationsAccepted is the context key set by ValidateWithObligations.
+type obligationsAccepted struct{}
+
+func (c *Obligation) ProhibitsWithContext(vc *ValidationContext, f Access) error {
+	if vc != nil {
+		if accepted, _ := vc.Get(obligationsAccepted{}); accepted == true {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w %s; use ValidateWithObligations", ErrUnhandledObligation, c)
+}
+
+func (c *Obligation) IsAttestation() bool { return false }
+
+func (c *Obligation) String() string {
+	return fmt.Sprintf("%s:%s", c.Type, c.Value)
+}
+
+// Obligations is a set of obligations imposed on a request.
+type Obligations []*Obligation
+
+// Require checks that every obligation is of one of the handled types,
+// returning ErrUnhandledObligation otherwise. Callers should list the types
+// they know how to fulfill, so tokens carrying obligations they don't
+// understand are rejected rather than having their obligations ignored.
+func (o Obligations) Require(handled ...string) error {
+	var merr error
+
+outer:
+	for _, ob := range o {
+		for _, typ := range handled {
+			if ob.Type == typ {
+				continue outer
+			}
+		}
+		merr = appendErrs(merr, fmt.Errorf("%w %s", ErrUnhandledObligation, ob))
+	}
+
+	return merr
+}
+
+// OfType returns the obligations of the specified type.
+func (o Obligations) OfType(typ string) Obligations {
+	var ret Obligations
+	for _, ob := range o {
+		if ob.Type == typ {
+			ret = append(ret, ob)
+		}
+	}
+	return ret
+}
+
+// ValidateWithObligations is like [Validator.Validate], but also returns the
+// obligations imposed by the caveat set. Obligations are only returned if
+// validation succeeds. Duplicate obligations are returned once. Caveat sets
+// with obligations nested within other caveats are rejected with
+// ErrUnhandledObligation.
+func (v *Validator) ValidateWithObligations(cs *CaveatSet, accesses ...Access) (Obligations, error) {
+	for _, cav := range cs.Caveats {
+		if ifp, ok := cav.(*IfPresent); ok && hasObligation(ifp.Ifs) {
+			return nil, fmt.Errorf("%w: obligations can't be nested", ErrUnhandledObligation)
+		}
+	}
+
+	accepting := *v
+	accepting.values = make(map[any]any, len(v.values)+1)
+	for k, val := range v.values {
+		accepting.values[k] = val
+	}
+	accepting.values[obligationsAccepted{}] = true
+
+	if err := accepting.Validate(cs, accesses...); err != nil {
+		return nil, err
+	}
+
+	return collectObligations(cs), nil
+}
+
+func hasObligation(cs *CaveatSet) bool {
+	if cs == nil {
+		return false
+	}
+
+	for _, cav := range cs.Caveats {
+		switch c := cav.(type) {
+		case *Obligation:
+			return true
+		case *IfPresent:
+			if hasObligation(c.Ifs) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidateWithObligations is like [CaveatSet.Validate], but also returns the
+// obligations imposed by the caveat set. See
+// [Validator.ValidateWithObligations].
+func (c *CaveatSet) ValidateWithObligations(accesses ...Access) (Obligations, error) {
+	return new(Validator).ValidateWithObligations(c, accesses...)
+}
+
+func collectObligations(cs *CaveatSet) Obligations {
+	var (
+		ret  Obligations
+		seen = map[Obligation]bool{}
+	)
+
+	for _, cav := range cs.Caveats {
+		if ob, ok := cav.(*Obligation); ok && !seen[*ob] {
+			seen[*ob] = true
+			ret = append(ret, ob)
+		}
+	}
+
+	return ret
+}
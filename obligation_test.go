@@ -0,0 +1,53 @@
+package macaroon
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestObligations(t *testing.T) {
+	var (
+		logAudit = &Obligation{Type: ObligationLog, Value: "audit"}
+		mfa      = &Obligation{Type: ObligationRequireTag, Value: "mfa"}
+		access   = &testAccess{action: ActionRead, parentResource: ptr(uint64(1))}
+	)
+
+	cs := NewCaveatSet(
+		cavParent(ActionRead, 1),
+		logAudit,
+		mfa,
+		&Obligation{Type: ObligationLog, Value: "audit"},
+	)
+
+	obs, err := cs.ValidateWithObligations(access)
+	assert.NoError(t, err)
+	assert.Equal(t, Obligations{logAudit, mfa}, obs)
+	assert.Equal(t, Obligations{mfa}, obs.OfType(ObligationRequireTag))
+
+	assert.NoError(t, obs.Require(ObligationLog, ObligationRequireTag))
+	err = obs.Require(ObligationLog)
+	assert.True(t, errors.Is(err, ErrUnhandledObligation))
+
+	// obligations don't restrict access, but aren't returned for prohibited
+	// accesses
+	obs, err = cs.ValidateWithObligations(&testAccess{action: ActionWrite, parentResource: ptr(uint64(1))})
+	assert.Error(t, err)
+	assert.Zero(t, obs)
+
+	// other ways of validating fail closed rather than dropping obligations
+	assert.True(t, errors.Is(NewCaveatSet(logAudit).Validate(access), ErrUnhandledObligation))
+	assert.True(t, errors.Is(NewValidator().ValidateAll(NewCaveatSet(mfa), access), ErrUnhandledObligation))
+	assert.True(t, errors.Is(mfa.Prohibits(access), ErrUnhandledObligation))
+
+	// nested obligations are rejected, and don't count as present
+	nested := NewCaveatSet(
+		cavParent(ActionRead, 1),
+		&IfPresent{Ifs: NewCaveatSet(&Obligation{Type: ObligationNotify, Value: "owner"}), Else: ActionNone},
+	)
+	obs, err = nested.ValidateWithObligations(access)
+	assert.True(t, errors.Is(err, ErrUnhandledObligation))
+	assert.Zero(t, obs)
+	assert.True(t, errors.Is(nested.Validate(access), ErrUnhandledObligation))
+}
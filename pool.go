@@ -0,0 +1,105 @@
+package macaroon
+
+import (
+	"fmt"
+	"sync"
+
+	msgpack "github.com/vmihailenco/msgpack/v5"
+)
+
+// Services that decode, verify and discard large numbers of tokens can reuse
+// Macaroons and CaveatSets to reduce allocations. Objects are acquired from a
+// pool with [AcquireMacaroon], [DecodePooled] or [AcquireCaveatSet] and
+// returned with Release. An object must not be used, and no references into
+// it (e.g. to its caveats) may be retained, after it's been released.
+
+var (
+	macaroonPool  = sync.Pool{New: func() any { return new(Macaroon) }}
+	caveatSetPool = sync.Pool{New: func() any { return new(CaveatSet) }}
+)
+
+// AcquireMacaroon gets an empty Macaroon from the pool. Call
+// [Macaroon.Release] when done with it.
+func AcquireMacaroon() *Macaroon {
+	m := macaroonPool.Get().(*Macaroon)
+	m.pooled = true
+	return m
+}
+
+// DecodePooled is like [Decode], but decodes into a Macaroon from the pool.
+// Call [Macaroon.Release] when done with it.
+func DecodePooled(buf []byte) (*Macaroon, error) {
+	m := AcquireMacaroon()
+	if err := msgpack.Unmarshal(buf, m); err != nil {
+		m.Release()
+		return nil, fmt.Errorf("macaroon decode: %w", err)
+	}
+
+	return m, nil
+}
+
+// Release wipes the secrets held by the Macaroon (its tail signature and
+// any third-party caveat keys) and, if the Macaroon was acquired from the
+// pool, returns it to the pool.
+func (m *Macaroon) Release() {
+	pooled := m.pooled
+
+	wipe(m.Tail)
+	m.UnsafeCaveats.reset()
+	*m = Macaroon{
+		Nonce: Nonce{
+			nonceV0Fields: nonceV0Fields{
+				KID: m.Nonce.KID[:0],
+				Rnd: m.Nonce.Rnd[:0],
+			},
+		},
+		UnsafeCaveats: m.UnsafeCaveats,
+		Tail:          m.Tail[:0],
+	}
+
+	if pooled {
+		macaroonPool.Put(m)
+	}
+}
+
+// AcquireCaveatSet gets an empty CaveatSet from the pool. Call
+// [CaveatSet.Release] when done with it.
+func AcquireCaveatSet() *CaveatSet {
+	c := caveatSetPool.Get().(*CaveatSet)
+	c.pooled = true
+	return c
+}
+
+// Release wipes any third-party caveat keys held by the CaveatSet and, if
+// the CaveatSet was acquired from the pool, returns it to the pool.
+func (c *CaveatSet) Release() {
+	pooled := c.pooled
+
+	c.reset()
+
+	if pooled {
+		caveatSetPool.Put(c)
+	}
+}
+
+// reset empties the set, retaining the capacity of its caveat slice.
+func (c *CaveatSet) reset() {
+	for i, cav := range c.Caveats {
+		if tp, ok := cav.(*Caveat3P); ok {
+			wipe(tp.rn)
+		}
+		c.Caveats[i] = nil
+	}
+
+	c.Caveats = c.Caveats[:0]
+	c.raw = nil
+	c.verified = false
+	c.provenance = nil
+	c.pooled = false
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,58 @@
+package macaroon
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestPool(t *testing.T) {
+	var (
+		key     = NewSigningKey()
+		ka      = NewEncryptionKey()
+		authLoc = "https://auth"
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavParent(ActionRead, 1)))
+	assert.NoError(t, m.Add3P(ka, authLoc))
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	_, _, dm, err := dischargeMacaroon(ka, authLoc, buf)
+	assert.NoError(t, err)
+	dbuf, err := dm.Encode()
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		pm, err := DecodePooled(buf)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("kid"), pm.Nonce.KID)
+		assert.Equal(t, 2, len(pm.UnsafeCaveats.Caveats))
+
+		cavs, err := pm.Verify(key, [][]byte{dbuf}, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, cavs.Validate(&testAccess{action: ActionRead, parentResource: ptr(uint64(1))}))
+
+		tail := pm.Tail
+		pm.Release()
+		assert.Equal(t, make([]byte, len(tail)), tail)
+		assert.Equal(t, 0, len(pm.UnsafeCaveats.Caveats))
+		assert.Equal(t, "", pm.Location)
+	}
+
+	// releasing a macaroon that wasn't pooled just wipes it
+	tail := m.Tail
+	m.Release()
+	assert.Equal(t, make([]byte, len(tail)), tail)
+
+	_, err = DecodePooled([]byte("bogus"))
+	assert.Error(t, err)
+
+	cs := AcquireCaveatSet()
+	cs.Caveats = append(cs.Caveats, cavParent(ActionRead, 1))
+	assert.NoError(t, cs.Validate(&testAccess{action: ActionRead, parentResource: ptr(uint64(1))}))
+	cs.Release()
+	assert.Equal(t, 0, len(cs.Caveats))
+}
@@ -0,0 +1,238 @@
+// Package posture implements device-posture requirements for tokens. A
+// [Require] caveat lists conditions on a device's posture (disk encryption,
+// OS version, etc.) that must hold for the token to be used. Posture is
+// reported by an MDM service acting as a third party: it discharges a
+// third-party caveat for the device and adds a [Claims] attestation to the
+// discharge with [Attest].
+//
+// Attestations are only considered if the discharge comes from a trusted
+// third party (see [macaroon.Macaroon.Verify]).
+package posture
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/superfly/macaroon"
+)
+
+// Claims is an attestation, added by an MDM service to its discharge tokens,
+// describing the posture of the device the token is being used from. At is
+// when the posture was reported (seconds since the Unix epoch), if known.
+type Claims struct {
+	DeviceID      string `json:"device_id"`
+	DiskEncrypted bool   `json:"disk_encrypted"`
+	ScreenLock    bool   `json:"screen_lock"`
+	OSName        string `json:"os_name"`
+	OSVersion     string `json:"os_version"`
+	At            int64  `json:"at,omitempty"`
+}
+
+func init() {
+	macaroon.RegisterCaveatType("DevicePostureAttestation", macaroon.CavDevicePostureAttestation, &Claims{})
+}
+
+func (c *Claims) CaveatType() macaroon.CaveatType {
+	return macaroon.CavDevicePostureAttestation
+}
+
+func (c *Claims) Prohibits(macaroon.Access) error {
+	// attestations play no role in access validation
+	return nil
+}
+
+func (c *Claims) IsAttestation() bool { return true }
+
+// AttestedAt implements [macaroon.TimestampedAttestation].
+func (c *Claims) AttestedAt() time.Time {
+	if c.At == 0 {
+		return time.Time{}
+	}
+	return time.Unix(c.At, 0)
+}
+
+// Attest is used by an MDM service to add a [Claims] attestation to a
+// discharge token (as returned by [macaroon.DischargeCID]). Claims without a
+// timestamp are stamped with the current time.
+func Attest(dm *macaroon.Macaroon, claims *Claims) error {
+	if claims.At == 0 {
+		claims.At = time.Now().Unix()
+	}
+	return dm.Add(claims)
+}
+
+// Claim names, for use in a [Condition].
+const (
+	ClaimDiskEncrypted = "disk_encrypted"
+	ClaimScreenLock    = "screen_lock"
+	ClaimOSName        = "os_name"
+	ClaimOSVersion     = "os_version"
+)
+
+// Operators, for use in a [Condition]. Boolean and string claims support
+// OpEqual and OpNotEqual. Version claims support all the operators and are
+// compared numerically, segment by segment (e.g. "14.2" < "14.10").
+const (
+	OpEqual        = "=="
+	OpNotEqual     = "!="
+	OpLess         = "<"
+	OpLessEqual    = "<="
+	OpGreater      = ">"
+	OpGreaterEqual = ">="
+)
+
+// Condition compares a posture claim with a value, e.g.
+// {ClaimOSVersion, OpGreaterEqual, "14.2"}.
+type Condition struct {
+	Claim string `json:"claim"`
+	Op    string `json:"op"`
+	Value string `json:"value"`
+}
+
+func (c Condition) String() string {
+	return fmt.Sprintf("%s %s %s", c.Claim, c.Op, c.Value)
+}
+
+// check checks whether the claims satisfy the condition.
+func (c Condition) check(claims *Claims) (bool, error) {
+	switch c.Claim {
+	case ClaimDiskEncrypted:
+		return compareBool(claims.DiskEncrypted, c.Op, c.Value)
+	case ClaimScreenLock:
+		return compareBool(claims.ScreenLock, c.Op, c.Value)
+	case ClaimOSName:
+		return compareOrdering(strings.Compare(claims.OSName, c.Value), c.Op, false)
+	case ClaimOSVersion:
+		cmp, err := compareVersions(claims.OSVersion, c.Value)
+		if err != nil {
+			return false, err
+		}
+		return compareOrdering(cmp, c.Op, true)
+	default:
+		return false, fmt.Errorf("unknown claim %q", c.Claim)
+	}
+}
+
+// Require requires that the device the token is used from satisfies each of
+// the conditions, as attested by a trusted MDM discharge. If more than one
+// discharge attests to the device's posture, they must all satisfy the
+// conditions.
+type Require struct {
+	Conditions []Condition `json:"conditions"`
+}
+
+func init() {
+	macaroon.RegisterCaveatType("DevicePosture", macaroon.CavDevicePosture, &Require{})
+}
+
+func (c *Require) CaveatType() macaroon.CaveatType {
+	return macaroon.CavDevicePosture
+}
+
+func (c *Require) Prohibits(a macaroon.Access) error {
+	return c.ProhibitsWithContext(new(macaroon.ValidationContext), a)
+}
+
+func (c *Require) ProhibitsWithContext(vc *macaroon.ValidationContext, a macaroon.Access) error {
+	var attestations []*Claims
+	if cs := vc.Caveats(); cs != nil {
+		attestations = macaroon.GetCaveats[*Claims](cs)
+	}
+
+	if len(attestations) == 0 {
+		return fmt.Errorf("%w: no device posture attestation", macaroon.ErrUnauthorized)
+	}
+
+	for _, claims := range attestations {
+		for _, cond := range c.Conditions {
+			ok, err := cond.check(claims)
+			switch {
+			case err != nil:
+				return fmt.Errorf("%w: device posture condition %s: %s", macaroon.ErrBadCaveat, cond, err)
+			case !ok:
+				return fmt.Errorf("%w: device %s doesn't satisfy %s", macaroon.ErrUnauthorized, claims.DeviceID, cond)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *Require) IsAttestation() bool { return false }
+
+func compareBool(claim bool, op, value string) (bool, error) {
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, err
+	}
+
+	switch op {
+	case OpEqual:
+		return claim == v, nil
+	case OpNotEqual:
+		return claim != v, nil
+	default:
+		return false, fmt.Errorf("bad operator %q for boolean claim", op)
+	}
+}
+
+// compareOrdering checks the result of a three-way comparison against op.
+// Ordering operators are only allowed if ordered is true.
+func compareOrdering(cmp int, op string, ordered bool) (bool, error) {
+	switch op {
+	case OpEqual:
+		return cmp == 0, nil
+	case OpNotEqual:
+		return cmp != 0, nil
+	}
+
+	if !ordered {
+		return false, fmt.Errorf("bad operator %q for unordered claim", op)
+	}
+
+	switch op {
+	case OpLess:
+		return cmp < 0, nil
+	case OpLessEqual:
+		return cmp <= 0, nil
+	case OpGreater:
+		return cmp > 0, nil
+	case OpGreaterEqual:
+		return cmp >= 0, nil
+	default:
+		return false, fmt.Errorf("bad operator %q", op)
+	}
+}
+
+// compareVersions compares dotted numeric versions. Missing segments are
+// treated as zero, so "14" == "14.0".
+func compareVersions(a, b string) (int, error) {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var av, bv uint64
+		var err error
+
+		if i < len(as) {
+			if av, err = strconv.ParseUint(as[i], 10, 64); err != nil {
+				return 0, fmt.Errorf("bad version %q", a)
+			}
+		}
+		if i < len(bs) {
+			if bv, err = strconv.ParseUint(bs[i], 10, 64); err != nil {
+				return 0, fmt.Errorf("bad version %q", b)
+			}
+		}
+
+		switch {
+		case av < bv:
+			return -1, nil
+		case av > bv:
+			return 1, nil
+		}
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,93 @@
+package posture
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+	"github.com/superfly/macaroon/flyio"
+)
+
+func TestPosture(t *testing.T) {
+	var (
+		key = macaroon.NewSigningKey()
+		ka  = macaroon.NewEncryptionKey()
+		mdm = "https://mdm"
+	)
+
+	m, err := macaroon.New([]byte("kid"), flyio.LocationPermission, key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(
+		&flyio.Organization{ID: 1, Mask: macaroon.ActionAll},
+		&Require{Conditions: []Condition{
+			{ClaimDiskEncrypted, OpEqual, "true"},
+			{ClaimOSName, OpEqual, "macos"},
+			{ClaimOSVersion, OpGreaterEqual, "14.2"},
+		}},
+	))
+	assert.NoError(t, m.Add3P(ka, mdm))
+
+	verify := func(claims *Claims, trusted bool) (*macaroon.CaveatSet, error) {
+		cid, err := m.ThirdPartyCID(mdm)
+		assert.NoError(t, err)
+		_, dm, err := macaroon.DischargeCID(ka, mdm, cid)
+		assert.NoError(t, err)
+		if claims != nil {
+			assert.NoError(t, Attest(dm, claims))
+		}
+		tok, err := dm.Encode()
+		assert.NoError(t, err)
+
+		var trusted3Ps map[string]macaroon.EncryptionKey
+		if trusted {
+			trusted3Ps = map[string]macaroon.EncryptionKey{mdm: ka}
+		}
+		return m.Verify(key, [][]byte{tok}, trusted3Ps)
+	}
+
+	access := &flyio.Access{OrgID: 1, Action: macaroon.ActionRead}
+	good := &Claims{DeviceID: "d1", DiskEncrypted: true, OSName: "macos", OSVersion: "14.10"}
+
+	cs, err := verify(good, true)
+	assert.NoError(t, err)
+	assert.NoError(t, cs.Validate(access))
+
+	for _, bad := range []*Claims{
+		{DeviceID: "d1", DiskEncrypted: false, OSName: "macos", OSVersion: "14.10"},
+		{DeviceID: "d1", DiskEncrypted: true, OSName: "linux", OSVersion: "14.10"},
+		{DeviceID: "d1", DiskEncrypted: true, OSName: "macos", OSVersion: "14.1"},
+		nil,
+	} {
+		cs, err := verify(bad, true)
+		assert.NoError(t, err)
+		assert.Error(t, cs.Validate(access))
+	}
+
+	// attestations from untrusted discharges are ignored
+	cs, err = verify(good, false)
+	assert.NoError(t, err)
+	assert.Error(t, cs.Validate(access))
+
+	// malformed conditions
+	cs = macaroon.NewCaveatSet(&Require{Conditions: []Condition{{ClaimOSName, OpLess, "macos"}}}, good)
+	assert.True(t, errors.Is(cs.Validate(access), macaroon.ErrBadCaveat))
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		cmp  int
+	}{
+		{"14.2", "14.10", -1},
+		{"14", "14.0", 0},
+		{"15", "14.9.9", 1},
+	} {
+		cmp, err := compareVersions(tc.a, tc.b)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.cmp, cmp, "%s vs %s", tc.a, tc.b)
+	}
+
+	_, err := compareVersions("14.x", "14")
+	assert.Error(t, err)
+}
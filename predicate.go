@@ -0,0 +1,177 @@
+package macaroon
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/superfly/macaroon/access"
+)
+
+// Predicate is a first-party caveat expressed as a string, in the style of
+// classic macaroon deployments: `<key> <operator> <value>`, e.g.
+// `time < 2025-01-01` or `user == 42`. The key selects a [PredicateEvaluator]
+// registered with [RegisterPredicate], which interprets the operator and value
+// against the Access. Predicates with unknown keys or malformed expressions
+// prohibit all access.
+//
+// Predicates are handy for compatibility and for prototyping. Typed caveats
+// should be preferred for anything long-lived.
+type Predicate struct {
+	Expression string `json:"expression"`
+}
+
+func init() { RegisterCaveatType("Predicate", CavPredicate, &Predicate{}) }
+
+func (c *Predicate) CaveatType() CaveatType {
+	return CavPredicate
+}
+
+func (c *Predicate) Prohibits(f Access) error {
+	return c.ProhibitsWithContext(newValidationContext(f), f)
+}
+
+func (c *Predicate) ProhibitsWithContext(vc *ValidationContext, f Access) error {
+	key, op, value, err := c.parse()
+	if err != nil {
+		return err
+	}
+
+	p, ok := predicates[key]
+	if !ok {
+		return fmt.Errorf("%w: unknown predicate %q", ErrBadCaveat, key)
+	}
+
+	return p.eval(vc, f, op, value)
+}
+
+func (c *Predicate) IsAttestation() bool { return false }
+
+// EvaluationCost implements [CostlyCaveat], returning the cost declared for
+// the predicate's key.
+func (c *Predicate) EvaluationCost() int {
+	key, _, _, err := c.parse()
+	if err != nil {
+		return DefaultCaveatCost
+	}
+
+	if p, ok := predicates[key]; ok {
+		return p.cost
+	}
+
+	return DefaultCaveatCost
+}
+
+func (c *Predicate) parse() (key, op, value string, err error) {
+	fields := strings.Fields(c.Expression)
+	if len(fields) < 3 {
+		return "", "", "", fmt.Errorf("%w: malformed predicate %q", ErrBadCaveat, c.Expression)
+	}
+
+	return fields[0], fields[1], strings.Join(fields[2:], " "), nil
+}
+
+// PredicateEvaluator evaluates a [Predicate] for a single key. It returns an
+// error if the predicate prohibits the access. Evaluators should return an
+// error wrapping ErrBadCaveat for operators or values they don't understand.
+type PredicateEvaluator func(vc *ValidationContext, f Access, op, value string) error
+
+// DefaultPredicateCost is the evaluation cost of predicates registered with
+// [RegisterPredicate]. See [CostlyCaveat].
+const DefaultPredicateCost = 5
+
+type predicate struct {
+	eval PredicateEvaluator
+	cost int
+}
+
+var predicates = map[string]predicate{}
+
+// RegisterPredicate registers the evaluator for predicates with the specified
+// key.
+func RegisterPredicate(key string, eval PredicateEvaluator) {
+	RegisterPredicateWithCost(key, DefaultPredicateCost, eval)
+}
+
+// RegisterPredicateWithCost is like [RegisterPredicate], but declares the cost
+// of evaluating predicates with the specified key. See [CostlyCaveat].
+func RegisterPredicateWithCost(key string, cost int, eval PredicateEvaluator) {
+	if _, dup := predicates[key]; dup {
+		panic("duplicate predicate")
+	}
+	if strings.ContainsAny(key, " \t\n") {
+		panic("predicate key contains whitespace")
+	}
+
+	predicates[key] = predicate{eval, cost}
+}
+
+func init() {
+	RegisterPredicate("time", timePredicate)
+	RegisterPredicate("user", userPredicate)
+}
+
+// timePredicate compares the validation time against an RFC3339 timestamp or
+// a date (YYYY-MM-DD, in UTC).
+func timePredicate(vc *ValidationContext, f Access, op, value string) error {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		if t, err = time.Parse(time.DateOnly, value); err != nil {
+			return fmt.Errorf("%w: bad time %q", ErrBadCaveat, value)
+		}
+	}
+
+	var (
+		now = vc.Now(f)
+		ok  bool
+	)
+
+	switch op {
+	case "<":
+		ok = now.Before(t)
+	case "<=":
+		ok = !now.After(t)
+	case ">":
+		ok = now.After(t)
+	case ">=":
+		ok = !now.Before(t)
+	default:
+		return fmt.Errorf("%w: bad time operator %q", ErrBadCaveat, op)
+	}
+
+	if !ok {
+		return fmt.Errorf("%w: time %s %s", ErrUnauthorized, op, value)
+	}
+
+	return nil
+}
+
+// userPredicate compares the user ID from an Access implementing
+// [access.UserID].
+func userPredicate(vc *ValidationContext, f Access, op, value string) error {
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: bad user id %q", ErrBadCaveat, value)
+	}
+
+	ua, ok := access.As[access.UserID](f)
+	if !ok {
+		return fmt.Errorf("%w user", ErrResourceUnspecified)
+	}
+
+	switch op {
+	case "==":
+		ok = ua.GetUserID() == id
+	case "!=":
+		ok = ua.GetUserID() != id
+	default:
+		return fmt.Errorf("%w: bad user operator %q", ErrBadCaveat, op)
+	}
+
+	if !ok {
+		return fmt.Errorf("%w user %d", ErrUnauthorizedForResource, ua.GetUserID())
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package macaroon
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+type testUserAccess struct {
+	testAccess
+	userID uint64
+}
+
+func (a *testUserAccess) GetUserID() uint64 { return a.userID }
+
+func TestPredicate(t *testing.T) {
+	var (
+		now = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+		a   = &testUserAccess{testAccess{action: ActionRead, now: now}, 42}
+	)
+
+	yes := func(expr string) {
+		t.Helper()
+		assert.NoError(t, NewCaveatSet(&Predicate{expr}).Validate(a))
+	}
+
+	no := func(expr string, target error) {
+		t.Helper()
+		err := NewCaveatSet(&Predicate{expr}).Validate(a)
+		assert.True(t, errors.Is(err, target), "%s: %v", expr, err)
+	}
+
+	yes("time < 2025-01-01")
+	yes("time <= 2024-06-01T00:00:00Z")
+	yes("time >= 2024-06-01")
+	no("time > 2024-06-01", ErrUnauthorized)
+	no("time < 2024-01-01", ErrUnauthorized)
+	no("time ~ 2024-01-01", ErrBadCaveat)
+	no("time < soon", ErrBadCaveat)
+
+	yes("user == 42")
+	yes("user != 7")
+	no("user == 7", ErrUnauthorizedForResource)
+	no("user < 7", ErrBadCaveat)
+	no("user == bob", ErrBadCaveat)
+	assert.True(t, errors.Is(NewCaveatSet(&Predicate{"user == 42"}).Validate(&a.testAccess), ErrResourceUnspecified))
+
+	no("bogus == 1", ErrBadCaveat)
+	no("time <", ErrBadCaveat)
+	no("", ErrBadCaveat)
+
+	// pinned time
+	assert.NoError(t, NewValidator(WithNow(now.AddDate(-1, 0, 0))).Validate(NewCaveatSet(&Predicate{"time < 2024-01-01"}), a))
+
+	// custom evaluators
+	RegisterPredicate("action", func(vc *ValidationContext, f Access, op, value string) error {
+		if op != "in" {
+			return ErrBadCaveat
+		}
+		if !f.GetAction().IsSubsetOf(ActionFromString(value)) {
+			return ErrUnauthorizedForAction
+		}
+		return nil
+	})
+	defer delete(predicates, "action")
+
+	yes("action in rw")
+	no("action in w", ErrUnauthorizedForAction)
+	assert.Panics(t, func() { RegisterPredicate("action", nil) })
+}
@@ -0,0 +1,68 @@
+package macaroon
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestProvenance(t *testing.T) {
+	var (
+		key = NewSigningKey()
+		ka  = NewEncryptionKey()
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavParent(ActionRead|ActionWrite, 1)))
+	assert.NoError(t, m.Add3P(ka, "https://auth"))
+
+	provenance := func(cs *CaveatSet) []CaveatProvenance {
+		ret := make([]CaveatProvenance, len(cs.Caveats))
+		for i := range cs.Caveats {
+			ret[i] = cs.Provenance(i)
+		}
+		return ret
+	}
+
+	assert.NoError(t, m.SealIssuerCaveats(key))
+	assert.NoError(t, m.Add(cavParent(ActionRead, 1)))
+
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+	_, _, dm, err := dischargeMacaroon(ka, "https://auth", buf)
+	assert.NoError(t, err)
+	assert.NoError(t, dm.Add(cavChild(ActionRead, 2)))
+	dbuf, err := dm.Encode()
+	assert.NoError(t, err)
+
+	cs, err := m.Verify(key, [][]byte{dbuf}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(cs.Caveats))
+	assert.Equal(t, 0, len(GetCaveats[*IssuerSeal](cs)))
+	assert.Equal(t, []CaveatProvenance{
+		{Provenance: ProvenanceIssuer},
+		{Provenance: ProvenanceAttenuator},
+		{Provenance: ProvenanceDischarge, Location: "https://auth"},
+	}, provenance(cs))
+
+	// modified and unverified sets have unknown provenance
+	cs.Caveats = cs.Caveats[1:]
+	assert.Equal(t, CaveatProvenance{}, cs.Provenance(0))
+	assert.Equal(t, CaveatProvenance{}, NewCaveatSet(cavParent(ActionRead, 1)).Provenance(0))
+
+	// attenuators can't forge seals
+	m2, err := Decode(buf)
+	assert.NoError(t, err)
+	assert.NoError(t, m2.SealIssuerCaveats(NewSigningKey()))
+	_, err = m2.Verify(key, [][]byte{dbuf}, nil)
+	assert.Error(t, err)
+
+	// tokens without seals verify as before
+	m3, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m3.Add(cavParent(ActionRead, 1)))
+	cs, err = m3.Verify(key, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []CaveatProvenance{{}}, provenance(cs))
+}
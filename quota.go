@@ -0,0 +1,51 @@
+package macaroon
+
+import (
+	"fmt"
+
+	"github.com/superfly/macaroon/access"
+)
+
+// QuotaCost is the evaluation cost of a [Quota] caveat. Quotas are usually
+// checked by querying a usage service, so they're costlier than most caveats.
+const QuotaCost = 10
+
+// Quota limits the usage of a metered resource (e.g. "machines" or
+// "gb-hours"). Rather than granting or denying an action outright, a quota
+// allows requests as long as they don't take the resource's usage over Limit.
+// Usage is reported by Accesses implementing [access.Usage]; other Accesses
+// are prohibited. Requests that don't add to the resource's usage are always
+// allowed.
+type Quota struct {
+	Resource string `json:"resource"`
+	Limit    uint64 `json:"limit"`
+}
+
+func init() { RegisterCaveatType("Quota", CavQuota, &Quota{}) }
+
+func (c *Quota) CaveatType() CaveatType {
+	return CavQuota
+}
+
+func (c *Quota) Prohibits(f Access) error {
+	ua, ok := access.As[access.Usage](f)
+	if !ok {
+		return fmt.Errorf("%w: usage for quota %s not reported", ErrInvalidAccess, c.Resource)
+	}
+
+	current, requested, err := ua.GetUsage(c.Resource)
+	switch {
+	case err != nil:
+		return fmt.Errorf("%w: get usage for quota %s: %w", ErrUnauthorized, c.Resource, err)
+	case requested == 0:
+		return nil
+	case current > c.Limit || requested > c.Limit-current:
+		return fmt.Errorf("%w: quota %s exceeded (%d used, %d requested, limit %d)", ErrUnauthorized, c.Resource, current, requested, c.Limit)
+	default:
+		return nil
+	}
+}
+
+func (c *Quota) IsAttestation() bool { return false }
+
+func (c *Quota) EvaluationCost() int { return QuotaCost }
@@ -0,0 +1,54 @@
+package macaroon
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+type usageAccess struct {
+	testAccess
+	usage map[string][2]uint64
+	err   error
+}
+
+func (a *usageAccess) GetUsage(resource string) (uint64, uint64, error) {
+	u := a.usage[resource]
+	return u[0], u[1], a.err
+}
+
+func TestQuota(t *testing.T) {
+	cs := NewCaveatSet(&Quota{Resource: "machines", Limit: 10})
+
+	access := func(current, requested uint64) *usageAccess {
+		return &usageAccess{
+			testAccess: testAccess{action: ActionWrite, parentResource: ptr(uint64(1))},
+			usage:      map[string][2]uint64{"machines": {current, requested}},
+		}
+	}
+
+	assert.NoError(t, cs.Validate(access(0, 10)))
+	assert.NoError(t, cs.Validate(access(9, 1)))
+	assert.Error(t, cs.Validate(access(9, 2)))
+	assert.Error(t, cs.Validate(access(11, 1)))
+
+	// requests that don't use the resource are allowed
+	assert.NoError(t, cs.Validate(access(11, 0)))
+
+	// no overflow
+	assert.Error(t, cs.Validate(access(5, ^uint64(0))))
+
+	// usage must be reported
+	err := cs.Validate(&testAccess{action: ActionWrite, parentResource: ptr(uint64(1))})
+	assert.True(t, errors.Is(err, ErrInvalidAccess))
+
+	usageErr := errors.New("usage service down")
+	a := access(0, 1)
+	a.err = usageErr
+	err = cs.Validate(a)
+	assert.True(t, errors.Is(err, usageErr))
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+
+	assert.Equal(t, QuotaCost, CaveatCost(&Quota{}))
+}
@@ -0,0 +1,308 @@
+package macaroon
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"sort"
+	"sync"
+	"time"
+)
+
+// VerifyFunc verifies a decoded permission token against its discharges,
+// typically by looking up the signing key by the token's KID and calling
+// [Macaroon.Verify] with the options.
+type VerifyFunc func(m *Macaroon, discharges [][]byte, opts ...VerifyOption) (*CaveatSet, error)
+
+// VerifiedCache caches the results of successful verifications, keyed by a
+// digest of the encoded permission token, discharges and verify options.
+// Entries must not be returned after they expire, unless expires is zero.
+// Implementations must be safe for concurrent use.
+type VerifiedCache interface {
+	Get(key [sha256.Size]byte) (*CaveatSet, bool)
+	Add(key [sha256.Size]byte, cs *CaveatSet, expires time.Time)
+}
+
+// ReadThroughVerifier is a verification front-end for gateways that see
+// bursts of identical credentials. Concurrent verifications of the same
+// (token, discharges) pair are deduplicated, so that only one of them does the
+// work, and successful verifications are stored in an optional
+// [VerifiedCache].
+//
+// Verified caveat sets are shared between callers and must not be modified.
+// Cache hits skip the checks Verify does with the current time (e.g.
+// [WithMaxDischargeAge] and [WithAttestationMaxAge]), so cached verifications
+// should expire no later than the tightest of those bounds (see MaxAge).
+// Caveats, including ValidityWindows and ChannelBindings, are still checked
+// by validation, which isn't cached. A cached verification will outlive
+// revocation of the signing key.
+type ReadThroughVerifier struct {
+	// Verify is called to verify tokens that aren't cached.
+	Verify VerifyFunc
+
+	// Cache, if non-nil, is consulted before verifying and updated after
+	// successful verifications.
+	Cache VerifiedCache
+
+	// MaxAge, if positive, limits how long verifications are cached.
+	MaxAge time.Duration
+
+	mu     sync.Mutex
+	flying map[[sha256.Size]byte]*verifyCall
+}
+
+type verifyCall struct {
+	wg  sync.WaitGroup
+	cs  *CaveatSet
+	err error
+}
+
+// VerifyToken decodes and verifies the encoded permission token with its
+// discharges, passing the options to Verify. Verifications with different
+// options are cached separately.
+func (v *ReadThroughVerifier) VerifyToken(token []byte, discharges [][]byte, opts ...VerifyOption) (*CaveatSet, error) {
+	key, cacheable := verifyCacheKey(token, discharges, newVerifyOptions(opts...))
+	if !cacheable {
+		m, err := Decode(token)
+		if err != nil {
+			return nil, err
+		}
+		return v.Verify(m, discharges, opts...)
+	}
+
+	if v.Cache != nil {
+		if cs, ok := v.Cache.Get(key); ok {
+			return cs, nil
+		}
+	}
+
+	v.mu.Lock()
+	if call, ok := v.flying[key]; ok {
+		v.mu.Unlock()
+		call.wg.Wait()
+		return call.cs, call.err
+	}
+
+	call := new(verifyCall)
+	call.wg.Add(1)
+	if v.flying == nil {
+		v.flying = map[[sha256.Size]byte]*verifyCall{}
+	}
+	v.flying[key] = call
+	v.mu.Unlock()
+
+	defer func() {
+		v.mu.Lock()
+		delete(v.flying, key)
+		v.mu.Unlock()
+		call.wg.Done()
+	}()
+
+	m, err := Decode(token)
+	if err != nil {
+		call.err = err
+		return nil, err
+	}
+
+	var expires time.Time
+	if v.MaxAge > 0 {
+		expires = time.Now().Add(v.MaxAge)
+	}
+
+	if call.cs, call.err = v.Verify(m, discharges, opts...); call.err == nil && v.Cache != nil {
+		v.Cache.Add(key, call.cs, expires)
+	}
+
+	return call.cs, call.err
+}
+
+// verifyCacheKey digests the token, discharges and options. Each is
+// length-prefixed so that different splits of the same bytes produce
+// different keys. Verifications can't be cached if the options can't be
+// fingerprinted, e.g. because they trust Sealers without stable IDs.
+func verifyCacheKey(token []byte, discharges [][]byte, o *verifyOptions) (key [sha256.Size]byte, ok bool) {
+	h := sha256.New()
+
+	writeLenPrefixed(h, token)
+	writeUvarint(h, uint64(len(discharges)))
+	for _, buf := range discharges {
+		writeLenPrefixed(h, buf)
+	}
+	if !o.fingerprint(h) {
+		return key, false
+	}
+
+	h.Sum(key[:0])
+	return key, true
+}
+
+// fingerprint writes the options affecting verification results to h. Map
+// entries are sorted, so equivalent options have the same fingerprint. It
+// returns false if the options can't be fingerprinted.
+func (o *verifyOptions) fingerprint(h hash.Hash) bool {
+	identified := true
+	writeSortedMap(h, o.aliases, func(canonical string) []byte { return []byte(canonical) })
+	writeLenPrefixed(h, o.channelBinding)
+	writeUvarint(h, uint64(o.maxCaveats))
+	writeUvarint(h, uint64(o.maxDischargeAge))
+	writeSortedMap(h, o.allowed3Ps, func(bool) []byte { return nil })
+	writeUvarint(h, uint64(o.clockSkew))
+
+	if o.requireParentBinding {
+		writeUvarint(h, 1)
+	} else {
+		writeUvarint(h, 0)
+	}
+
+	typs := make([]CaveatType, 0, len(o.attestationMaxAge))
+	for typ := range o.attestationMaxAge {
+		typs = append(typs, typ)
+	}
+	sort.Slice(typs, func(i, j int) bool { return typs[i] < typs[j] })
+	writeUvarint(h, uint64(len(typs)))
+	for _, typ := range typs {
+		writeUvarint(h, uint64(typ))
+		writeUvarint(h, uint64(o.attestationMaxAge[typ]))
+	}
+
+	writeSortedMap(h, o.trusted, func(keys []Sealer) []byte {
+		var buf []byte
+		for _, k := range keys {
+			id, ok := sealerID(k)
+			if !ok {
+				identified = false
+			}
+			buf = binary.AppendUvarint(buf, uint64(len(id)))
+			buf = append(buf, id...)
+		}
+		return buf
+	})
+
+	if o.now.IsZero() {
+		writeUvarint(h, 0)
+	} else {
+		writeUvarint(h, uint64(o.now.UnixNano()))
+	}
+
+	if o.err != nil {
+		writeLenPrefixed(h, []byte(o.err.Error()))
+	} else {
+		writeLenPrefixed(h, nil)
+	}
+
+	return identified
+}
+
+// sealerID identifies a trusted third party's key: by value for
+// EncryptionKeys, and by ID for IdentifiedSealers. Other Sealers can't be
+// identified.
+func sealerID(s Sealer) ([]byte, bool) {
+	switch k := s.(type) {
+	case EncryptionKey:
+		return append([]byte("key:"), k...), true
+	case IdentifiedSealer:
+		return append([]byte("id:"), k.ID()...), true
+	default:
+		return nil, false
+	}
+}
+
+func writeSortedMap[V any](h hash.Hash, m map[string]V, value func(V) []byte) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	writeUvarint(h, uint64(len(keys)))
+	for _, k := range keys {
+		writeLenPrefixed(h, []byte(k))
+		writeLenPrefixed(h, value(m[k]))
+	}
+}
+
+func writeLenPrefixed(h hash.Hash, buf []byte) {
+	writeUvarint(h, uint64(len(buf)))
+	h.Write(buf)
+}
+
+func writeUvarint(h hash.Hash, n uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	h.Write(buf[:binary.PutUvarint(buf[:], n)])
+}
+
+// LRUVerifiedCache is a [VerifiedCache] holding a bounded number of the most
+// recently used verifications.
+type LRUVerifiedCache struct {
+	size    int
+	now     func() time.Time
+	mu      sync.Mutex
+	order   *list.List
+	entries map[[sha256.Size]byte]*list.Element
+}
+
+var _ VerifiedCache = (*LRUVerifiedCache)(nil)
+
+type lruEntry struct {
+	key     [sha256.Size]byte
+	cs      *CaveatSet
+	expires time.Time
+}
+
+// NewLRUVerifiedCache creates a cache holding up to size verifications.
+func NewLRUVerifiedCache(size int) *LRUVerifiedCache {
+	if size < 1 {
+		panic(fmt.Sprintf("bad verified cache size %d", size))
+	}
+
+	return &LRUVerifiedCache{
+		size:    size,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[[sha256.Size]byte]*list.Element, size),
+	}
+}
+
+// Get implements VerifiedCache.
+func (c *LRUVerifiedCache) Get(key [sha256.Size]byte) (*CaveatSet, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := e.Value.(*lruEntry)
+	if !entry.expires.IsZero() && !c.now().Before(entry.expires) {
+		c.order.Remove(e)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(e)
+	return entry.cs, true
+}
+
+// Add implements VerifiedCache.
+func (c *LRUVerifiedCache) Add(key [sha256.Size]byte, cs *CaveatSet, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		entry := e.Value.(*lruEntry)
+		entry.cs, entry.expires = cs, expires
+		c.order.MoveToFront(e)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry{key, cs, expires})
+
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
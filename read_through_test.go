@@ -0,0 +1,209 @@
+package macaroon
+
+import (
+	"crypto/sha256"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestReadThroughVerifier(t *testing.T) {
+	var (
+		key   = NewSigningKey()
+		calls int32
+		gate  = make(chan struct{})
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavParent(ActionRead, 1)))
+	tok, err := m.Encode()
+	assert.NoError(t, err)
+
+	v := &ReadThroughVerifier{
+		Verify: func(m *Macaroon, discharges [][]byte, opts ...VerifyOption) (*CaveatSet, error) {
+			atomic.AddInt32(&calls, 1)
+			<-gate
+			return m.Verify(key, discharges, nil, opts...)
+		},
+		Cache: NewLRUVerifiedCache(1),
+	}
+
+	// concurrent verifications are deduplicated
+	var wg sync.WaitGroup
+	results := make([]*CaveatSet, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cs, err := v.VerifyToken(tok, nil)
+			assert.NoError(t, err)
+			results[i] = cs
+		}(i)
+	}
+
+	// give the goroutines a chance to pile up behind the first
+	time.Sleep(50 * time.Millisecond)
+	close(gate)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	for _, cs := range results {
+		assert.Equal(t, 1, len(cs.Caveats))
+	}
+
+	// subsequent verifications are cached
+	before := atomic.LoadInt32(&calls)
+	_, err = v.VerifyToken(tok, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, before, atomic.LoadInt32(&calls))
+
+	// discharges are part of the key
+	_, err = v.VerifyToken(tok, [][]byte{[]byte("bogus")})
+	assert.NoError(t, err)
+	assert.Equal(t, before+1, atomic.LoadInt32(&calls))
+
+	// which evicted the first verification
+	_, err = v.VerifyToken(tok, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, before+2, atomic.LoadInt32(&calls))
+
+	// failures aren't cached
+	_, err = v.VerifyToken([]byte("bogus"), nil)
+	assert.Error(t, err)
+
+	m2, err := New([]byte("kid"), "https://api", NewSigningKey())
+	assert.NoError(t, err)
+	tok2, err := m2.Encode()
+	assert.NoError(t, err)
+
+	before = atomic.LoadInt32(&calls)
+	_, err = v.VerifyToken(tok2, nil)
+	assert.Error(t, err)
+	_, err = v.VerifyToken(tok2, nil)
+	assert.Error(t, err)
+	assert.Equal(t, before+2, atomic.LoadInt32(&calls))
+}
+
+func TestReadThroughVerifierExpiry(t *testing.T) {
+	key := NewSigningKey()
+	calls := 0
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	tok, err := m.Encode()
+	assert.NoError(t, err)
+
+	cache := NewLRUVerifiedCache(2)
+	v := &ReadThroughVerifier{
+		Verify: func(m *Macaroon, discharges [][]byte, opts ...VerifyOption) (*CaveatSet, error) {
+			calls++
+			return m.Verify(key, discharges, nil, opts...)
+		},
+		Cache:  cache,
+		MaxAge: time.Minute,
+	}
+
+	_, err = v.VerifyToken(tok, nil)
+	assert.NoError(t, err)
+	_, err = v.VerifyToken(tok, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+
+	// options are part of the key
+	_, err = v.VerifyToken(tok, nil, WithMaxCaveats(10))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	// entries expire after MaxAge
+	cache.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	_, err = v.VerifyToken(tok, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestVerifyCacheKey(t *testing.T) {
+	key := func(token []byte, discharges [][]byte, opts ...VerifyOption) [sha256.Size]byte {
+		k, ok := verifyCacheKey(token, discharges, newVerifyOptions(opts...))
+		assert.True(t, ok)
+		return k
+	}
+
+	assert.NotEqual(t,
+		key([]byte("ab"), [][]byte{[]byte("c")}),
+		key([]byte("a"), [][]byte{[]byte("bc")}),
+	)
+	assert.Equal(t,
+		key([]byte("a"), [][]byte{[]byte("b")}),
+		key([]byte("a"), [][]byte{[]byte("b")}),
+	)
+
+	assert.NotEqual(t,
+		key([]byte("a"), nil, WithChannelBinding([]byte("x"))),
+		key([]byte("a"), nil, WithChannelBinding([]byte("y"))),
+	)
+	assert.NotEqual(t,
+		key([]byte("a"), nil),
+		key([]byte("a"), nil, WithMaxDischargeAge(time.Minute)),
+	)
+	assert.Equal(t,
+		key([]byte("a"), nil, WithAllowed3PLocations("https://a", "https://b")),
+		key([]byte("a"), nil, WithAllowed3PLocations("https://b", "https://a")),
+	)
+	assert.NotEqual(t,
+		key([]byte("a"), nil),
+		key([]byte("a"), nil, WithVerifyNow(time.Unix(1, 0))),
+	)
+
+	// trusted Sealers are identified by their IDs, and verifications
+	// trusting Sealers without IDs aren't cached
+	trusting := func(s Sealer) VerifyOption { return WithTrusted3Ps(Trusted3Ps{}.Add("https://auth", s)) }
+	assert.Equal(t,
+		key([]byte("a"), nil, trusting(&idSealer{id: "kms:a"})),
+		key([]byte("a"), nil, trusting(&idSealer{id: "kms:a"})),
+	)
+	assert.NotEqual(t,
+		key([]byte("a"), nil, trusting(&idSealer{id: "kms:a"})),
+		key([]byte("a"), nil, trusting(&idSealer{id: "kms:b"})),
+	)
+	_, ok := verifyCacheKey([]byte("a"), nil, newVerifyOptions(trusting(&testSealer{NewEncryptionKey()})))
+	assert.False(t, ok)
+}
+
+// idSealer is an IdentifiedSealer.
+type idSealer struct {
+	testSealer
+	id string
+}
+
+func (s *idSealer) ID() []byte { return []byte(s.id) }
+
+func TestReadThroughVerifierUnidentifiedSealer(t *testing.T) {
+	var (
+		key   = NewSigningKey()
+		calls = 0
+		opt   = WithTrusted3Ps(Trusted3Ps{}.Add("https://auth", &testSealer{NewEncryptionKey()}))
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	tok, err := m.Encode()
+	assert.NoError(t, err)
+
+	v := &ReadThroughVerifier{
+		Verify: func(m *Macaroon, discharges [][]byte, opts ...VerifyOption) (*CaveatSet, error) {
+			calls++
+			return m.Verify(key, discharges, nil, opts...)
+		},
+		Cache: NewLRUVerifiedCache(1),
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = v.VerifyToken(tok, nil, opt)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,140 @@
+package macaroon
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	msgpack "github.com/vmihailenco/msgpack/v5"
+)
+
+// ErrBadReceipt is returned for receipts that are malformed, or not signed by
+// a trusted witness.
+var ErrBadReceipt = errors.New("bad verification receipt")
+
+const receiptVersion = 1
+
+// receiptContext is prepended to receipts' encodings when signing them, so
+// the signatures can't be confused with others made with the witness's key.
+const receiptContext = "macaroon-verification-receipt"
+
+// Receipt records a successful verification of a token by a witness (the
+// verifying service), so downstream systems, like audit pipelines or other
+// services, can trust the result without the token's key or re-verifying it.
+// Receipts are signed with the witness's Ed25519 key; see [IssueReceipt] and
+// [VerifyReceipt].
+//
+// Receipts record that the token verified, not that any particular access
+// was allowed. The caveats themselves aren't included, only their digest
+// (see [CaveatSet.Digest]), so consumers that need them must have them from
+// elsewhere, e.g. from the token.
+type Receipt struct {
+	// Witness identifies the witness, and the key its receipts are signed
+	// with.
+	Witness string
+
+	// TokenDigest is the SHA256 digest of the verified token.
+	TokenDigest []byte
+
+	// CaveatsDigest is the digest of the verified caveats, including those
+	// from discharges.
+	CaveatsDigest []byte
+
+	// VerifiedAt is when the token was verified.
+	VerifiedAt time.Time
+}
+
+type wireReceipt struct {
+	_msgpack      struct{} `msgpack:",as_array"`
+	Version       uint
+	Witness       string
+	TokenDigest   []byte
+	CaveatsDigest []byte
+	VerifiedAt    int64
+	Signature     []byte
+}
+
+// Covers returns whether the receipt is for the token.
+func (r *Receipt) Covers(token []byte) bool {
+	digest := sha256.Sum256(token)
+	return string(r.TokenDigest) == string(digest[:])
+}
+
+// IssueReceipt issues a receipt, signed with key, for the verification of
+// token, which yielded cs. cs must be the result of a successful
+// [Macaroon.Verify].
+func IssueReceipt(key ed25519.PrivateKey, witness string, token []byte, cs *CaveatSet, verifiedAt time.Time) ([]byte, error) {
+	if !cs.Verified() {
+		return nil, errors.New("issue receipt: caveats weren't verified")
+	}
+
+	cavsDigest, err := cs.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("issue receipt: %w", err)
+	}
+
+	tokDigest := sha256.Sum256(token)
+
+	w := wireReceipt{
+		Version:       receiptVersion,
+		Witness:       witness,
+		TokenDigest:   tokDigest[:],
+		CaveatsDigest: cavsDigest,
+		VerifiedAt:    verifiedAt.Unix(),
+	}
+
+	msg, err := w.signedBytes()
+	if err != nil {
+		return nil, fmt.Errorf("issue receipt: %w", err)
+	}
+	w.Signature = ed25519.Sign(key, msg)
+
+	return encode(w)
+}
+
+// VerifyReceipt decodes a receipt, checking that it was signed by its
+// witness, whose public key is looked up in witnesses.
+func VerifyReceipt(buf []byte, witnesses map[string]ed25519.PublicKey) (*Receipt, error) {
+	var w wireReceipt
+	if err := msgpack.Unmarshal(buf, &w); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBadReceipt, err)
+	}
+
+	if w.Version != receiptVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrBadReceipt, w.Version)
+	}
+
+	key, ok := witnesses[w.Witness]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown witness %q", ErrBadReceipt, w.Witness)
+	}
+
+	sig := w.Signature
+	w.Signature = nil
+
+	msg, err := w.signedBytes()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBadReceipt, err)
+	}
+
+	if len(key) != ed25519.PublicKeySize || !ed25519.Verify(key, msg, sig) {
+		return nil, fmt.Errorf("%w: invalid signature", ErrBadReceipt)
+	}
+
+	return &Receipt{
+		Witness:       w.Witness,
+		TokenDigest:   w.TokenDigest,
+		CaveatsDigest: w.CaveatsDigest,
+		VerifiedAt:    time.Unix(w.VerifiedAt, 0),
+	}, nil
+}
+
+func (w wireReceipt) signedBytes() ([]byte, error) {
+	buf, err := encode(w)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(receiptContext), buf...), nil
+}
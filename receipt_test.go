@@ -0,0 +1,63 @@
+package macaroon
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestReceipt(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+
+	key := NewSigningKey()
+	m, err := New([]byte("kid"), "loc", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavParent(ActionRead, 123)))
+
+	tok, err := m.Encode()
+	assert.NoError(t, err)
+
+	decoded, err := Decode(tok)
+	assert.NoError(t, err)
+	cs, err := decoded.Verify(key, nil, nil)
+	assert.NoError(t, err)
+
+	now := time.Unix(time.Now().Unix(), 0)
+	buf, err := IssueReceipt(priv, "api", tok, cs, now)
+	assert.NoError(t, err)
+
+	r, err := VerifyReceipt(buf, map[string]ed25519.PublicKey{"api": pub})
+	assert.NoError(t, err)
+	assert.Equal(t, "api", r.Witness)
+	assert.True(t, r.VerifiedAt.Equal(now))
+	assert.True(t, r.Covers(tok))
+	assert.False(t, r.Covers(append(tok, 0)))
+
+	digest, err := cs.Digest()
+	assert.NoError(t, err)
+	assert.Equal(t, digest, r.CaveatsDigest)
+
+	// unknown witness
+	_, err = VerifyReceipt(buf, map[string]ed25519.PublicKey{"other": pub})
+	assert.True(t, errors.Is(err, ErrBadReceipt))
+
+	// wrong key
+	_, err = VerifyReceipt(buf, map[string]ed25519.PublicKey{"api": otherPub})
+	assert.True(t, errors.Is(err, ErrBadReceipt))
+
+	// tampered
+	tampered := append([]byte{}, buf...)
+	tampered[len(tampered)-70] ^= 1
+	_, err = VerifyReceipt(tampered, map[string]ed25519.PublicKey{"api": pub})
+	assert.True(t, errors.Is(err, ErrBadReceipt))
+
+	// only verified caveats get receipts
+	_, err = IssueReceipt(priv, "api", tok, &m.UnsafeCaveats, now)
+	assert.Error(t, err)
+}
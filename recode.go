@@ -0,0 +1,35 @@
+package macaroon
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Recode decodes and re-encodes the token, returning the re-encoded token
+// only if it's identical to the original. Otherwise, including when the
+// token uses a legacy layout (which is retained when re-encoding so that
+// signatures remain valid), it fails with [ErrNonCanonical].
+//
+// Use Recode at ingestion points that store tokens, so that equivalent
+// tokens are stored identically and trailing or smuggled bytes are rejected.
+func Recode(buf []byte) ([]byte, error) {
+	m, err := Decode(buf)
+	if err != nil {
+		return nil, fmt.Errorf("recode: %w", err)
+	}
+
+	if m.Nonce.raw != nil {
+		return nil, fmt.Errorf("recode: %w: legacy nonce layout", ErrNonCanonical)
+	}
+
+	rebuf, err := m.Encode()
+	if err != nil {
+		return nil, fmt.Errorf("recode: %w", err)
+	}
+
+	if !bytes.Equal(buf, rebuf) {
+		return nil, fmt.Errorf("recode: %w", ErrNonCanonical)
+	}
+
+	return rebuf, nil
+}
@@ -0,0 +1,60 @@
+package macaroon
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	msgpack "github.com/vmihailenco/msgpack/v5"
+)
+
+func TestRecode(t *testing.T) {
+	key := NewSigningKey()
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavParent(ActionRead, 1), &ValidityWindow{NotBefore: 1, NotAfter: 1 << 40}))
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	rebuf, err := Recode(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, buf, rebuf)
+
+	// trailing bytes
+	_, err = Recode(append(buf[:len(buf):len(buf)], 0))
+	assert.True(t, errors.Is(err, ErrNonCanonical))
+
+	// legacy map layout
+	mapBuf, err := msgpack.Marshal(map[string]any{
+		"Nonce":         msgpack.RawMessage(m.Nonce.MustEncode()),
+		"Location":      m.Location,
+		"UnsafeCaveats": &m.UnsafeCaveats,
+		"Tail":          m.Tail,
+	})
+	assert.NoError(t, err)
+	_, err = Recode(mapBuf)
+	assert.True(t, errors.Is(err, ErrNonCanonical))
+
+	// legacy nonce layout
+	nonce, err := msgpack.Marshal(map[string]any{"kid": []byte("kid"), "rnd": rbuf(nonceRndSize)})
+	assert.NoError(t, err)
+	legacyBuf, err := legacyMacaroon(t, nonce, "https://api", key).Encode()
+	assert.NoError(t, err)
+	_, err = Recode(legacyBuf)
+	assert.True(t, errors.Is(err, ErrNonCanonical))
+
+	// non-compact integers
+	var wide bytes.Buffer
+	enc := msgpack.NewEncoder(&wide)
+	enc.UseArrayEncodedStructs(true)
+	assert.NoError(t, enc.Encode(m))
+	assert.NotEqual(t, buf, wide.Bytes())
+	_, err = Recode(wide.Bytes())
+	assert.True(t, errors.Is(err, ErrNonCanonical))
+
+	_, err = Recode([]byte("garbage"))
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrNonCanonical))
+}
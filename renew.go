@@ -0,0 +1,164 @@
+package macaroon
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RenewPolicy controls when [Renew] is willing to mint a successor token.
+type RenewPolicy struct {
+	// MaxAge is the maximum age of a token that may be renewed. A token's age
+	// is measured from the earliest NotBefore of its validity windows, which
+	// Renew carries forward to the successor, so repeated renewals can't
+	// extend a session past MaxAge. Zero means no limit.
+	MaxAge time.Duration
+
+	// GracePeriod is how long after a token has expired it may still be
+	// renewed. Zero means only unexpired tokens may be renewed.
+	GracePeriod time.Duration
+
+	// Now is the time at which the policy is evaluated. Zero means the
+	// current time.
+	Now time.Time
+}
+
+var (
+	ErrRenewExpired = errors.New("renew: token expired")
+	ErrRenewTooOld  = errors.New("renew: token too old")
+)
+
+// Renew mints a successor to m with a fresh [ValidityWindow] ending extend
+// after the current time, preserving all the other caveats, including
+// third-party caveats. This supports sliding-expiry sessions. The key must be
+// the key m was minted with; m's signature is checked before renewal.
+//
+// Top-level validity windows are replaced by a single window whose NotBefore
+// is the earliest of the original windows. Windows nested within other
+// caveats (e.g. [IfPresent]) are preserved as-is. Discharges bound to m won't
+// be valid for the successor. [IssuerSeal]s are made afresh, and the new
+// window is added before the last of them, so it's reported as the
+// issuer's.
+func Renew(m *Macaroon, key SigningKey, extend time.Duration, policy RenewPolicy) (*Macaroon, error) {
+	if m.Nonce.Proof {
+		return nil, errors.New("renew: can't renew proof")
+	}
+
+	tpKeys, err := m.recover3PKeys(key)
+	if err != nil {
+		return nil, fmt.Errorf("renew: %w", err)
+	}
+
+	now := policy.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	var (
+		notBefore = now
+		notAfter  = maxTime
+	)
+
+	for _, c := range m.UnsafeCaveats.Caveats {
+		if vw, ok := c.(*ValidityWindow); ok {
+			if nb := time.Unix(vw.NotBefore, 0); nb.Before(notBefore) {
+				notBefore = nb
+			}
+			if na := time.Unix(vw.NotAfter, 0); na.Before(notAfter) {
+				notAfter = na
+			}
+		}
+	}
+
+	if now.After(notAfter.Add(policy.GracePeriod)) {
+		return nil, fmt.Errorf("%w at %s", ErrRenewExpired, notAfter)
+	}
+
+	if policy.MaxAge != 0 && now.Sub(notBefore) > policy.MaxAge {
+		return nil, fmt.Errorf("%w: issued at %s", ErrRenewTooOld, notBefore)
+	}
+
+	successor, err := New(m.Nonce.KID, m.Location, key)
+	if err != nil {
+		return nil, fmt.Errorf("renew: %w", err)
+	}
+
+	window := &ValidityWindow{
+		NotBefore: notBefore.Unix(),
+		NotAfter:  now.Add(extend).Unix(),
+	}
+
+	lastSeal := -1
+	for i, c := range m.UnsafeCaveats.Caveats {
+		if _, ok := c.(*IssuerSeal); ok {
+			lastSeal = i
+		}
+	}
+
+	for i, c := range m.UnsafeCaveats.Caveats {
+		switch cav := c.(type) {
+		case *ValidityWindow:
+			continue
+		case *IssuerSeal:
+			// seals are MACs of the token's signature, so must be made
+			// afresh. The window is the issuer's, so goes before the last.
+			if i == lastSeal {
+				if err := successor.Add(window); err != nil {
+					return nil, fmt.Errorf("renew: %w", err)
+				}
+			}
+			if err := successor.SealIssuerCaveats(key); err != nil {
+				return nil, fmt.Errorf("renew: %w", err)
+			}
+			continue
+		case *Caveat3P:
+			c = &Caveat3P{Location: cav.Location, CID: cav.CID, rn: tpKeys[i]}
+		}
+
+		if err := successor.Add(c); err != nil {
+			return nil, fmt.Errorf("renew: %w", err)
+		}
+	}
+
+	if lastSeal == -1 {
+		if err := successor.Add(window); err != nil {
+			return nil, fmt.Errorf("renew: %w", err)
+		}
+	}
+
+	return successor, nil
+}
+
+// recover3PKeys checks the signature on the macaroon, recovering the discharge
+// keys for its third-party caveats along the way. The returned map is keyed
+// by the index of the 3P caveat.
+func (m *Macaroon) recover3PKeys(k SigningKey) (map[int][]byte, error) {
+	var (
+		curMac = sign(k, m.Nonce.MustEncode())
+		ret    = map[int][]byte{}
+	)
+
+	for i, c := range m.UnsafeCaveats.Caveats {
+		if cav, ok := c.(*Caveat3P); ok {
+			rn, err := unseal(EncryptionKey(curMac), cav.VID)
+			if err != nil {
+				return nil, fmt.Errorf("unseal VID for third-party caveat: %w", err)
+			}
+			ret[i] = rn
+		}
+
+		opc, err := m.UnsafeCaveats.caveatBytes(i)
+		if err != nil {
+			return nil, err
+		}
+
+		curMac = sign(SigningKey(curMac), opc)
+	}
+
+	if subtle.ConstantTimeCompare(curMac, m.Tail) != 1 {
+		return nil, errors.New("invalid signature")
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,97 @@
+package macaroon
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestRenew(t *testing.T) {
+	var (
+		key     = NewSigningKey()
+		ka      = NewEncryptionKey()
+		authLoc = "https://auth"
+		issued  = time.Now().Add(-2 * time.Hour)
+		access  = &testAccess{action: ActionRead, parentResource: ptr(uint64(123))}
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavParent(ActionRead, 123)))
+	assert.NoError(t, m.Add(&ValidityWindow{NotBefore: issued.Unix(), NotAfter: issued.Add(time.Hour).Unix()}))
+	assert.NoError(t, m.Add3P(ka, authLoc))
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	_, _, dm, err := dischargeMacaroon(ka, authLoc, buf)
+	assert.NoError(t, err)
+	dbuf, err := dm.Encode()
+	assert.NoError(t, err)
+
+	decoded, err := Decode(buf)
+	assert.NoError(t, err)
+
+	// expired an hour ago
+	_, err = Renew(decoded, key, time.Hour, RenewPolicy{})
+	assert.True(t, errors.Is(err, ErrRenewExpired))
+
+	// too old
+	_, err = Renew(decoded, key, time.Hour, RenewPolicy{GracePeriod: 2 * time.Hour, MaxAge: time.Hour})
+	assert.True(t, errors.Is(err, ErrRenewTooOld))
+
+	// wrong key
+	_, err = Renew(decoded, NewSigningKey(), time.Hour, RenewPolicy{GracePeriod: 2 * time.Hour})
+	assert.Error(t, err)
+
+	renewed, err := Renew(decoded, key, time.Hour, RenewPolicy{GracePeriod: 2 * time.Hour})
+	assert.NoError(t, err)
+	assert.Equal(t, decoded.Nonce.KID, renewed.Nonce.KID)
+	assert.Equal(t, decoded.Location, renewed.Location)
+
+	windows := GetCaveats[*ValidityWindow](&renewed.UnsafeCaveats)
+	assert.Equal(t, 1, len(windows))
+	assert.Equal(t, issued.Unix(), windows[0].NotBefore)
+	assert.True(t, renewed.Expiration().After(time.Now()))
+
+	rbuf, err := renewed.Encode()
+	assert.NoError(t, err)
+	renewed, err = Decode(rbuf)
+	assert.NoError(t, err)
+
+	// existing unbound discharge still satisfies the 3P caveat
+	cavs, err := renewed.Verify(key, [][]byte{dbuf}, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, cavs.Validate(access))
+	assert.Error(t, cavs.Validate(&testAccess{action: ActionWrite, parentResource: ptr(uint64(123))}))
+}
+
+func TestRenewSealed(t *testing.T) {
+	var (
+		key    = NewSigningKey()
+		access = &testAccess{action: ActionRead, parentResource: ptr(uint64(123))}
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavParent(ActionRead, 123), cavExpiry(time.Hour)))
+	assert.NoError(t, m.SealIssuerCaveats(key))
+	assert.NoError(t, m.Add(cavParent(ActionRead|ActionWrite, 123)))
+
+	renewed, err := Renew(m, key, 2*time.Hour, RenewPolicy{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(GetCaveats[*IssuerSeal](&renewed.UnsafeCaveats)))
+
+	cs, err := renewed.Verify(key, nil, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, cs.Validate(access))
+
+	// the new window is the issuer's, the attenuator's caveat isn't
+	assert.Equal(t, 3, len(cs.Caveats))
+	for i, want := range []Provenance{ProvenanceIssuer, ProvenanceIssuer, ProvenanceAttenuator} {
+		assert.Equal(t, want, cs.Provenance(i).Provenance)
+	}
+	_, isVW := cs.Caveats[1].(*ValidityWindow)
+	assert.True(t, isVW)
+}
@@ -0,0 +1,112 @@
+package macaroon
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResourceRules are the constraints on which resources an Access may specify,
+// used to implement [Access.Validate] for Accesses describing a hierarchy of
+// resources (e.g. org → app → machine). They ensure an Access represents a
+// single action taken on a single object: that child resources are only
+// specified along with their parents, and that sibling resources aren't
+// specified together.
+//
+// Resources are registered by name with [ResourceRules.Resource], and rules
+// referring to them are checked in the order they're added. Rules should be
+// added during initialization; ResourceRules aren't safe for concurrent
+// modification.
+type ResourceRules[A any] struct {
+	specified map[string]func(A) bool
+	rules     []func(A) error
+}
+
+// Resource registers a kind of resource. specified reports whether an access
+// specifies the resource.
+func (r *ResourceRules[A]) Resource(name string, specified func(A) bool) *ResourceRules[A] {
+	if _, dup := r.specified[name]; dup {
+		panic(fmt.Sprintf("duplicate resource %q", name))
+	}
+	if r.specified == nil {
+		r.specified = map[string]func(A) bool{}
+	}
+
+	r.specified[name] = specified
+	return r
+}
+
+func (r *ResourceRules[A]) lookup(name string) func(A) bool {
+	specified, ok := r.specified[name]
+	if !ok {
+		panic(fmt.Sprintf("unregistered resource %q", name))
+	}
+	return specified
+}
+
+// Required requires that accesses specify the resource (e.g. the root of the
+// hierarchy).
+func (r *ResourceRules[A]) Required(name string) *ResourceRules[A] {
+	specified := r.lookup(name)
+
+	return r.Check(func(a A) error {
+		if !specified(a) {
+			return fmt.Errorf("%w %s", ErrResourceUnspecified, name)
+		}
+		return nil
+	})
+}
+
+// Requires requires that accesses specifying the child resource also specify
+// the parent resource.
+func (r *ResourceRules[A]) Requires(child, parent string) *ResourceRules[A] {
+	var (
+		childSpecified  = r.lookup(child)
+		parentSpecified = r.lookup(parent)
+	)
+
+	return r.Check(func(a A) error {
+		if childSpecified(a) && !parentSpecified(a) {
+			return fmt.Errorf("%w %s", ErrResourceUnspecified, parent)
+		}
+		return nil
+	})
+}
+
+// Exclusive requires that accesses specify at most one of the resources.
+func (r *ResourceRules[A]) Exclusive(names ...string) *ResourceRules[A] {
+	specified := make([]func(A) bool, len(names))
+	for i, name := range names {
+		specified[i] = r.lookup(name)
+	}
+
+	return r.Check(func(a A) error {
+		var n int
+		for _, s := range specified {
+			if s(a) {
+				n++
+			}
+		}
+
+		if n > 1 {
+			return fmt.Errorf("%w: %s", ErrResourcesMutuallyExclusive, strings.Join(names, ", "))
+		}
+		return nil
+	})
+}
+
+// Check adds an arbitrary rule, e.g. one validating a resource's identifier.
+func (r *ResourceRules[A]) Check(rule func(A) error) *ResourceRules[A] {
+	r.rules = append(r.rules, rule)
+	return r
+}
+
+// Validate checks the access against the rules, returning the first
+// violation.
+func (r *ResourceRules[A]) Validate(a A) error {
+	for _, rule := range r.rules {
+		if err := rule(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package macaroon
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestResourceRules(t *testing.T) {
+	rules := new(ResourceRules[*testAccess]).
+		Resource("parent", func(a *testAccess) bool { return a.parentResource != nil }).
+		Resource("child", func(a *testAccess) bool { return a.childResource != nil }).
+		Resource("other", func(a *testAccess) bool { return a.action == ActionControl })
+
+	rules.
+		Required("parent").
+		Requires("child", "parent").
+		Exclusive("child", "other")
+
+	assert.NoError(t, rules.Validate(&testAccess{parentResource: ptr[uint64](1)}))
+	assert.NoError(t, rules.Validate(&testAccess{parentResource: ptr[uint64](1), childResource: ptr[uint64](2)}))
+
+	err := rules.Validate(&testAccess{childResource: ptr[uint64](2)})
+	assert.True(t, errors.Is(err, ErrResourceUnspecified))
+	assert.Contains(t, err.Error(), "parent")
+
+	err = rules.Validate(&testAccess{action: ActionControl, parentResource: ptr[uint64](1), childResource: ptr[uint64](2)})
+	assert.True(t, errors.Is(err, ErrResourcesMutuallyExclusive))
+	assert.Contains(t, err.Error(), "child, other")
+
+	assert.Panics(t, func() { rules.Resource("parent", nil) })
+	assert.Panics(t, func() { rules.Requires("child", "bogus") })
+}
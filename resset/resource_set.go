@@ -41,7 +41,7 @@ func (rs ResourceSet[ID]) Prohibits(id *ID, action macaroon.Action) error {
 
 	var (
 		foundPerm = false
-		perm      = macaroon.ActionAll
+		perm      = ^macaroon.ActionNone
 		zeroID    ID
 	)
 
@@ -62,7 +62,7 @@ func (rs ResourceSet[ID]) Prohibits(id *ID, action macaroon.Action) error {
 	}
 
 	if !action.IsSubsetOf(perm) {
-		return fmt.Errorf("%w access %s (%s not allowed)", macaroon.ErrUnauthorizedForAction, action, action.Remove(perm))
+		return &macaroon.ActionError{Requested: action, Permitted: perm, Resource: fmt.Sprint(*id)}
 	}
 
 	return nil
@@ -0,0 +1,110 @@
+package macaroon
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SealedAttestation is an attestation whose contents are encrypted to the
+// verifier. Third parties use it to attach attestations to discharges that
+// only the token's issuer should read, e.g. internal user IDs, which the
+// client and any intermediaries handling the discharge can't. It's created
+// with [NewSealedAttestation], using the key the third party shares with the
+// issuer.
+//
+// When the discharge is trusted, Verify unseals it with the third party's key
+// and returns the attestations it contains in its place. Like other
+// attestations, it's dropped from untrusted discharges.
+type SealedAttestation struct {
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func init() {
+	RegisterCaveatType("SealedAttestation", CavSealedAttestation, &SealedAttestation{})
+}
+
+// NewSealedAttestation seals attestations with s, which must be the key the
+// third party shares with the token's issuer (i.e. the one third-party
+// caveats' tickets are sealed with), for adding to a discharge token.
+func NewSealedAttestation(s Sealer, attestations ...Caveat) (*SealedAttestation, error) {
+	if len(attestations) == 0 {
+		return nil, errors.New("seal attestation: no attestations")
+	}
+
+	for _, a := range attestations {
+		if !a.IsAttestation() {
+			return nil, fmt.Errorf("seal attestation: %s isn't an attestation", CaveatTypeName(a.CaveatType()))
+		}
+		if _, ok := a.(*SealedAttestation); ok {
+			return nil, errors.New("seal attestation: can't nest sealed attestations")
+		}
+	}
+
+	pt, err := NewCaveatSet(attestations...).MarshalMsgpack()
+	if err != nil {
+		return nil, fmt.Errorf("seal attestation: %w", err)
+	}
+
+	ct, err := s.Seal(pt)
+	if err != nil {
+		return nil, fmt.Errorf("seal attestation: %w", err)
+	}
+
+	return &SealedAttestation{Ciphertext: ct}, nil
+}
+
+func (c *SealedAttestation) CaveatType() CaveatType { return CavSealedAttestation }
+func (c *SealedAttestation) IsAttestation() bool    { return true }
+
+func (c *SealedAttestation) Prohibits(f Access) error {
+	// attestations play no role in access validation
+	return nil
+}
+
+// unseal decrypts the attestations, checking that they are attestations.
+func (c *SealedAttestation) unseal(s Sealer) ([]Caveat, error) {
+	pt, err := s.Unseal(c.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("unseal attestation: %w", err)
+	}
+
+	cs, err := DecodeCaveats(pt)
+	if err != nil {
+		return nil, fmt.Errorf("unseal attestation: %w", err)
+	}
+
+	for _, a := range cs.Caveats {
+		if _, sealed := a.(*SealedAttestation); sealed || !a.IsAttestation() {
+			return nil, fmt.Errorf("unseal attestation: %s isn't an attestation", CaveatTypeName(a.CaveatType()))
+		}
+	}
+
+	return cs.Caveats, nil
+}
+
+// unsealAttestations replaces the SealedAttestations among a trusted
+// discharge's caveats with their contents.
+func unsealAttestations(cs *CaveatSet, s Sealer) error {
+	if len(GetCaveats[*SealedAttestation](cs)) == 0 {
+		return nil
+	}
+
+	cavs := make([]Caveat, 0, len(cs.Caveats))
+	for _, c := range cs.Caveats {
+		sa, ok := c.(*SealedAttestation)
+		if !ok {
+			cavs = append(cavs, c)
+			continue
+		}
+
+		unsealed, err := sa.unseal(s)
+		if err != nil {
+			return err
+		}
+		cavs = append(cavs, unsealed...)
+	}
+
+	cs.Caveats = cavs
+	cs.provenance = nil
+	return nil
+}
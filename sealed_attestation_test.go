@@ -0,0 +1,59 @@
+package macaroon
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestSealedAttestation(t *testing.T) {
+	var (
+		key = NewSigningKey()
+		ka  = NewEncryptionKey()
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add3P(ka, "https://auth"))
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	_, err = NewSealedAttestation(ka, cavParent(ActionRead, 1))
+	assert.Error(t, err)
+
+	sa, err := NewSealedAttestation(ka, &testCaveatAttestation{"internal-123"})
+	assert.NoError(t, err)
+
+	_, _, dm, err := dischargeMacaroon(ka, "https://auth", buf)
+	assert.NoError(t, err)
+	assert.NoError(t, dm.Add(&testCaveatAttestation{"bob"}, sa))
+	dbuf, err := dm.Encode()
+	assert.NoError(t, err)
+
+	// the client can't read the sealed attestation
+	_, err = sa.unseal(NewEncryptionKey())
+	assert.Error(t, err)
+
+	// the verifier trusting the third party gets its contents
+	cs, err := m.Verify(key, [][]byte{dbuf}, map[string]EncryptionKey{"https://auth": ka})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(GetCaveats[*SealedAttestation](cs)))
+	assert.Equal(t, []*testCaveatAttestation{{"bob"}, {"internal-123"}}, GetCaveats[*testCaveatAttestation](cs))
+
+	// without trust, it's dropped along with other attestations
+	cs, err = m.Verify(key, [][]byte{dbuf}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(GetCaveats[*SealedAttestation](cs)))
+	assert.Equal(t, 0, len(GetCaveats[*testCaveatAttestation](cs)))
+
+	// attestations sealed with another key fail verification
+	sa, err = NewSealedAttestation(NewEncryptionKey(), &testCaveatAttestation{"mallory"})
+	assert.NoError(t, err)
+	_, _, dm, err = dischargeMacaroon(ka, "https://auth", buf)
+	assert.NoError(t, err)
+	assert.NoError(t, dm.Add(sa))
+	dbuf, err = dm.Encode()
+	assert.NoError(t, err)
+	_, err = m.Verify(key, [][]byte{dbuf}, map[string]EncryptionKey{"https://auth": ka})
+	assert.Error(t, err)
+}
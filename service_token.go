@@ -0,0 +1,64 @@
+package macaroon
+
+import (
+	"fmt"
+	"time"
+)
+
+// ParentToken is an attestation, added to service tokens by
+// [Bundle.IssueServiceToken], identifying the token they were issued from by
+// the UUID of its nonce (see [Nonce.UUID]). It allows services receiving a
+// service token to attribute requests to the user's token.
+type ParentToken struct {
+	UUID string `json:"uuid"`
+}
+
+func init() { RegisterCaveatType("ParentToken", CavParentToken, &ParentToken{}) }
+
+func (c *ParentToken) CaveatType() CaveatType { return CavParentToken }
+func (c *ParentToken) IsAttestation() bool    { return true }
+
+func (c *ParentToken) Prohibits(f Access) error {
+	// attestations play no role in access validation
+	return nil
+}
+
+// IssueServiceToken verifies the bundle with key and mints a short-lived
+// first-party token, with the same key, KID and location as the permission
+// token, for fan-out to internal services that shouldn't hold the user's
+// full bundle. The service token carries the verified caveats, a
+// [ValidityWindow] of ttl, a [ParentToken] attestation and any extra caveats,
+// so it never allows more than the bundle did, and it needs no discharges.
+//
+// The bundle is verified without trusted third parties, so attestations from
+// discharges aren't carried over. Service tokens are proofs (they carry an
+// attestation), so they can't be further attenuated.
+func (b *Bundle) IssueServiceToken(key SigningKey, ttl time.Duration, extraCavs ...Caveat) ([]byte, error) {
+	parent, err := Decode(b.Permission)
+	if err != nil {
+		return nil, fmt.Errorf("issue service token: %w", err)
+	}
+
+	cs, err := parent.Verify(key, b.Discharges, nil)
+	if err != nil {
+		return nil, fmt.Errorf("issue service token: %w", err)
+	}
+
+	m, err := newMacaroon(parent.Nonce.KID, parent.Location, key, true)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	cavs := append(cs.Caveats,
+		&ValidityWindow{NotBefore: now.Unix(), NotAfter: now.Add(ttl).Unix()},
+		&ParentToken{UUID: parent.Nonce.UUID().String()},
+	)
+
+	if err := m.Add(append(cavs, extraCavs...)...); err != nil {
+		return nil, fmt.Errorf("issue service token: %w", err)
+	}
+
+	return m.Encode()
+}
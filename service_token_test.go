@@ -0,0 +1,69 @@
+package macaroon
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestIssueServiceToken(t *testing.T) {
+	var (
+		authLoc = "https://auth"
+		key     = NewSigningKey()
+		ka      = NewEncryptionKey()
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavParent(ActionRead|ActionWrite, 1)))
+	assert.NoError(t, m.Add3P(ka, authLoc))
+	perm, err := m.Encode()
+	assert.NoError(t, err)
+
+	_, _, dm, err := dischargeMacaroon(ka, authLoc, perm)
+	assert.NoError(t, err)
+	assert.NoError(t, dm.Add(cavChild(ActionRead, 2)))
+	dis, err := dm.Encode()
+	assert.NoError(t, err)
+
+	b := &Bundle{Permission: perm, Discharges: [][]byte{dis}}
+
+	tok, err := b.IssueServiceToken(key, time.Minute, cavParent(ActionRead, 1))
+	assert.NoError(t, err)
+
+	st, err := Decode(tok)
+	assert.NoError(t, err)
+	assert.Equal(t, m.Nonce.KID, st.Nonce.KID)
+	assert.Equal(t, "https://api", st.Location)
+	assert.True(t, st.Nonce.Proof)
+
+	// no discharges needed
+	cs, err := st.Verify(key, nil, nil)
+	assert.NoError(t, err)
+
+	parents := GetCaveats[*ParentToken](cs)
+	assert.Equal(t, 1, len(parents))
+	assert.Equal(t, m.Nonce.UUID().String(), parents[0].UUID)
+
+	a := &testAccess{action: ActionRead, parentResource: ptr(uint64(1)), childResource: ptr(uint64(2))}
+	assert.NoError(t, cs.Validate(a))
+
+	// inherits the bundle's caveats, plus the extras
+	a.action = ActionWrite
+	assert.True(t, errors.Is(cs.Validate(a), ErrUnauthorizedForAction))
+
+	// short-lived
+	a.action = ActionRead
+	assert.Error(t, NewValidator(WithNow(time.Now().Add(2*time.Minute))).Validate(cs, a))
+
+	// can't be attenuated
+	assert.Error(t, st.Add(cavChild(ActionRead, 2)))
+
+	// the bundle must verify
+	_, err = b.IssueServiceToken(NewSigningKey(), time.Minute)
+	assert.Error(t, err)
+	_, err = (&Bundle{Permission: perm}).IssueServiceToken(key, time.Minute)
+	assert.Error(t, err)
+}
@@ -0,0 +1,275 @@
+// Package session stores macaroon [macaroon.Bundle]s in encrypted browser
+// cookies, for web frontends built directly on macaroons.
+//
+// Bundles are sealed with the current cookie encryption key and split across
+// several cookies if they don't fit in one. Cookies sealed with previous keys
+// are still accepted and are re-sealed with the current key the next time
+// they're loaded, allowing the cookie encryption key to be rotated. Bundles
+// that are close to expiring can be automatically re-minted on load.
+package session
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/superfly/macaroon"
+	msgpack "github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	defaultMaxCookieSize = 4000
+
+	// the first cookie is prefixed with the chunk count and a ".", leaving
+	// room for up to 999 chunks.
+	chunkCountSize = 4
+	maxChunks      = 999
+)
+
+var (
+	ErrNoSession = errors.New("session: no session cookie")
+	ErrMalformed = errors.New("session: malformed session cookie")
+)
+
+// Codec reads and writes bundles as session cookies.
+type Codec struct {
+	// Name is the name of the session cookie. Additional cookies, if needed,
+	// are named Name.1, Name.2, etc.
+	Name string
+
+	// Keys are the cookie encryption keys. Cookies are sealed with the first
+	// key and may be unsealed with any of them.
+	Keys []macaroon.EncryptionKey
+
+	// Cookie, if set, is the template for the attributes (Path, Domain,
+	// Secure, SameSite, etc.) of the cookies that are written. Name, Value,
+	// Expires and MaxAge are ignored.
+	Cookie *http.Cookie
+
+	// MaxCookieSize is the largest cookie value written. Defaults to 4000
+	// bytes. It must be larger than the 4 byte chunk count prefix.
+	MaxCookieSize int
+
+	// Renew, if set, is called by Load to re-mint bundles expiring within
+	// RenewWithin.
+	Renew       func(*macaroon.Bundle) (*macaroon.Bundle, error)
+	RenewWithin time.Duration
+
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Load reads the bundle from the request's session cookies. If the cookies
+// were sealed with an old key or the bundle was renewed, the session cookies
+// are rewritten to w.
+func (c *Codec) Load(w http.ResponseWriter, r *http.Request) (*macaroon.Bundle, error) {
+	b, current, err := c.decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	rewrite := !current
+
+	if c.Renew != nil {
+		exp, err := b.Expiration()
+		if err != nil {
+			return nil, err
+		}
+
+		if exp.Sub(c.now()) < c.RenewWithin {
+			if b, err = c.Renew(b); err != nil {
+				return nil, fmt.Errorf("session renew: %w", err)
+			}
+			rewrite = true
+		}
+	}
+
+	if rewrite {
+		if err := c.Save(w, r, b); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// Save writes the bundle to w as session cookies. If r is non-nil, any
+// session cookies it carries that are no longer needed are cleared.
+func (c *Codec) Save(w http.ResponseWriter, r *http.Request, b *macaroon.Bundle) error {
+	if len(c.Keys) == 0 {
+		return errors.New("session: no encryption keys")
+	}
+
+	exp, err := b.Expiration()
+	if err != nil {
+		return err
+	}
+
+	pt, err := b.EncodeEnvelope()
+	if err != nil {
+		return fmt.Errorf("session encode: %w", err)
+	}
+
+	ct, err := c.Keys[0].Seal(pt)
+	if err != nil {
+		return fmt.Errorf("session seal: %w", err)
+	}
+
+	chunks, err := c.split(base64.RawURLEncoding.EncodeToString(ct))
+	if err != nil {
+		return err
+	}
+
+	for i, chunk := range chunks {
+		if i == 0 {
+			chunk = strconv.Itoa(len(chunks)) + "." + chunk
+		}
+
+		cookie := c.cookie(c.chunkName(i), chunk)
+		if exp.Before(maxCookieExpiry) {
+			cookie.Expires = exp
+		}
+		http.SetCookie(w, cookie)
+	}
+
+	if r != nil {
+		c.clear(w, r, len(chunks))
+	}
+
+	return nil
+}
+
+// Clear expires all of the request's session cookies, e.g. on logout.
+func (c *Codec) Clear(w http.ResponseWriter, r *http.Request) {
+	c.clear(w, r, 0)
+}
+
+func (c *Codec) clear(w http.ResponseWriter, r *http.Request, keep int) {
+	for i := keep; ; i++ {
+		if _, err := r.Cookie(c.chunkName(i)); err != nil {
+			return
+		}
+
+		cookie := c.cookie(c.chunkName(i), "")
+		cookie.MaxAge = -1
+		http.SetCookie(w, cookie)
+	}
+}
+
+// decode reads and unseals the bundle, reporting whether it was sealed with
+// the current key.
+func (c *Codec) decode(r *http.Request) (*macaroon.Bundle, bool, error) {
+	first, err := r.Cookie(c.chunkName(0))
+	if err != nil {
+		return nil, false, ErrNoSession
+	}
+
+	nStr, value, ok := strings.Cut(first.Value, ".")
+	if !ok {
+		return nil, false, ErrMalformed
+	}
+
+	n, err := strconv.Atoi(nStr)
+	if err != nil || n < 1 {
+		return nil, false, ErrMalformed
+	}
+
+	var sb strings.Builder
+	sb.WriteString(value)
+
+	for i := 1; i < n; i++ {
+		chunk, err := r.Cookie(c.chunkName(i))
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: missing chunk %d", ErrMalformed, i)
+		}
+		sb.WriteString(chunk.Value)
+	}
+
+	ct, err := base64.RawURLEncoding.DecodeString(sb.String())
+	if err != nil {
+		return nil, false, ErrMalformed
+	}
+
+	for i, key := range c.Keys {
+		pt, err := key.Unseal(ct)
+		if err != nil {
+			continue
+		}
+
+		if b, err := macaroon.DecodeBundle(pt); err == nil {
+			return b, i == 0, nil
+		}
+
+		// cookies written before bundles were encoded as envelopes hold a
+		// list of tokens, starting with the permission token. Rewrite them.
+		var toks [][]byte
+		if err := msgpack.Unmarshal(pt, &toks); err != nil || len(toks) == 0 {
+			return nil, false, ErrMalformed
+		}
+
+		return &macaroon.Bundle{Permission: toks[0], Discharges: toks[1:]}, false, nil
+	}
+
+	return nil, false, fmt.Errorf("%w: no key unseals cookie", ErrMalformed)
+}
+
+func (c *Codec) split(value string) ([]string, error) {
+	size := c.MaxCookieSize
+	if size == 0 {
+		size = defaultMaxCookieSize
+	}
+	if size <= chunkCountSize {
+		return nil, fmt.Errorf("session: MaxCookieSize %d must be larger than %d", size, chunkCountSize)
+	}
+	// leave room for the chunk count in the first cookie
+	size -= chunkCountSize
+
+	var chunks []string
+	for len(value) > size {
+		chunks = append(chunks, value[:size])
+		value = value[size:]
+	}
+	chunks = append(chunks, value)
+
+	if len(chunks) > maxChunks {
+		return nil, fmt.Errorf("session: %d cookies needed, max is %d", len(chunks), maxChunks)
+	}
+
+	return chunks, nil
+}
+
+func (c *Codec) chunkName(i int) string {
+	if i == 0 {
+		return c.Name
+	}
+	return c.Name + "." + strconv.Itoa(i)
+}
+
+func (c *Codec) cookie(name, value string) *http.Cookie {
+	var cookie http.Cookie
+	if c.Cookie != nil {
+		cookie = *c.Cookie
+	}
+
+	cookie.Name = name
+	cookie.Value = value
+	cookie.Expires = time.Time{}
+	cookie.MaxAge = 0
+
+	return &cookie
+}
+
+func (c *Codec) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// tokens without validity windows "expire" at the end of time, which doesn't
+// make for a sensible cookie expiry. Such cookies are session cookies.
+var maxCookieExpiry = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
@@ -0,0 +1,147 @@
+package session
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+	msgpack "github.com/vmihailenco/msgpack/v5"
+)
+
+func TestCodec(t *testing.T) {
+	var (
+		key    = macaroon.NewSigningKey()
+		now    = time.Now()
+		oldKey = macaroon.NewEncryptionKey()
+		curKey = macaroon.NewEncryptionKey()
+	)
+
+	mint := func(ttl time.Duration) *macaroon.Bundle {
+		m, err := macaroon.New([]byte("kid"), "https://api", key)
+		assert.NoError(t, err)
+		assert.NoError(t, m.Add(&macaroon.ValidityWindow{NotBefore: now.Unix(), NotAfter: now.Add(ttl).Unix()}))
+		tok, err := m.Encode()
+		assert.NoError(t, err)
+
+		dm, err := macaroon.New([]byte("dkid"), "https://auth", macaroon.NewSigningKey())
+		assert.NoError(t, err)
+		dtok, err := dm.Encode()
+		assert.NoError(t, err)
+
+		return &macaroon.Bundle{Permission: tok, Discharges: [][]byte{dtok}}
+	}
+
+	// request carrying the cookies set on the recorder
+	roundTrip := func(rec *httptest.ResponseRecorder) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, c := range rec.Result().Cookies() {
+			if c.MaxAge >= 0 {
+				r.AddCookie(c)
+			}
+		}
+		return r
+	}
+
+	var renewed int
+	c := &Codec{
+		Name:          "session",
+		Keys:          []macaroon.EncryptionKey{oldKey},
+		Cookie:        &http.Cookie{Path: "/", Secure: true, HttpOnly: true},
+		MaxCookieSize: 100,
+		Renew: func(b *macaroon.Bundle) (*macaroon.Bundle, error) {
+			renewed++
+			return mint(time.Hour), nil
+		},
+		RenewWithin: 5 * time.Minute,
+		Now:         func() time.Time { return now },
+	}
+
+	b := mint(time.Hour)
+
+	rec := httptest.NewRecorder()
+	assert.NoError(t, c.Save(rec, nil, b))
+	cookies := rec.Result().Cookies()
+	assert.True(t, len(cookies) > 1)
+	assert.Equal(t, "session", cookies[0].Name)
+	assert.Equal(t, "session.1", cookies[1].Name)
+	assert.True(t, cookies[0].Secure)
+	assert.Equal(t, now.Add(time.Hour).Unix(), cookies[0].Expires.Unix())
+
+	r := roundTrip(rec)
+	rec = httptest.NewRecorder()
+	got, err := c.Load(rec, r)
+	assert.NoError(t, err)
+	assert.Equal(t, b, got)
+	assert.Equal(t, 0, len(rec.Result().Cookies()))
+
+	// rotate the cookie key: old cookies are still accepted and re-sealed
+	c.Keys = []macaroon.EncryptionKey{curKey, oldKey}
+	rec = httptest.NewRecorder()
+	got, err = c.Load(rec, r)
+	assert.NoError(t, err)
+	assert.Equal(t, b, got)
+	assert.NotEqual(t, 0, len(rec.Result().Cookies()))
+
+	c.Keys = []macaroon.EncryptionKey{curKey}
+	_, err = c.Load(httptest.NewRecorder(), roundTrip(rec))
+	assert.NoError(t, err)
+
+	_, err = c.Load(httptest.NewRecorder(), r)
+	assert.True(t, errors.Is(err, ErrMalformed))
+
+	// bundles near expiry are renewed
+	rec = httptest.NewRecorder()
+	assert.NoError(t, c.Save(rec, nil, mint(time.Minute)))
+	rec2 := httptest.NewRecorder()
+	got, err = c.Load(rec2, roundTrip(rec))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, renewed)
+	exp, err := got.Expiration()
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(time.Hour).Unix(), exp.Unix())
+
+	// cookies must have room for more than the chunk count
+	for _, size := range []int{-1, 1, 3, 4} {
+		c2 := *c
+		c2.MaxCookieSize = size
+		assert.Error(t, c2.Save(httptest.NewRecorder(), nil, got))
+	}
+
+	// shrinking a session clears chunks that are no longer needed
+	r = roundTrip(rec2)
+	c.MaxCookieSize = 0
+	rec = httptest.NewRecorder()
+	assert.NoError(t, c.Save(rec, r, got))
+	cookies = rec.Result().Cookies()
+	assert.Equal(t, "session", cookies[0].Name)
+	for _, cookie := range cookies[1:] {
+		assert.Equal(t, -1, cookie.MaxAge)
+	}
+
+	rec = httptest.NewRecorder()
+	c.Clear(rec, r)
+	for _, cookie := range rec.Result().Cookies() {
+		assert.Equal(t, -1, cookie.MaxAge)
+	}
+
+	// cookies holding a plain list of tokens are still accepted and rewritten
+	legacy, err := msgpack.Marshal(b.Tokens())
+	assert.NoError(t, err)
+	ct, err := curKey.Seal(legacy)
+	assert.NoError(t, err)
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: "1." + base64.RawURLEncoding.EncodeToString(ct)})
+	rec = httptest.NewRecorder()
+	got, err = c.Load(rec, r)
+	assert.NoError(t, err)
+	assert.Equal(t, b, got)
+	assert.NotEqual(t, 0, len(rec.Result().Cookies()))
+
+	_, err = c.Load(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.True(t, errors.Is(err, ErrNoSession))
+}
@@ -0,0 +1,53 @@
+package macaroon
+
+import (
+	"errors"
+	"fmt"
+)
+
+// WithShadowMode evaluates caveats of the specified types in "shadow" mode:
+// their failures are reported, but don't deny access. This lets new caveat
+// types be rolled out against production traffic before being enforced.
+//
+// Failures of shadowed caveats are raised as warnings (see [WithWarnings]) and
+// reported to subscriptions as events with Shadowed set (see
+// [WithSubscription]). Shadowed caveats reporting that the access doesn't
+// specify their resource (ErrResourceUnspecified) are treated as usual, so
+// they don't change the behavior of IfPresent caveats containing them. The
+// option may be used more than once.
+//
+// With [WithParallelism], warnings may be raised concurrently.
+func WithShadowMode(types ...CaveatType) ValidationOption {
+	return func(v *Validator) {
+		if v.shadow == nil {
+			v.shadow = make(map[CaveatType]bool, len(types))
+		}
+		for _, t := range types {
+			v.shadow[t] = true
+		}
+	}
+}
+
+// shadows returns whether the caveat's failure should be reported rather than
+// enforced.
+func (r *validationRun) shadows(c Caveat, err error) bool {
+	switch {
+	case err == nil || !r.shadow[c.CaveatType()]:
+		return false
+	case errors.Is(err, ErrResourceUnspecified), errors.Is(err, ErrBudgetExceeded):
+		return false
+	default:
+		return true
+	}
+}
+
+func (r *validationRun) warnShadowed(c Caveat, err error) {
+	if r.warn == nil {
+		return
+	}
+
+	r.warn(Warning{
+		Caveat:  c,
+		Message: fmt.Sprintf("shadowed caveat %s would deny access: %s", caveatTypeToString(c.CaveatType()), err),
+	})
+}
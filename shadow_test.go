@@ -0,0 +1,56 @@
+package macaroon
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestShadowMode(t *testing.T) {
+	cs := NewCaveatSet(
+		cavParent(ActionAll, 1),
+		&VerifierPin{Hostnames: []string{"mgmt1.internal"}},
+		&IfPresent{Ifs: NewCaveatSet(cavChild(ActionRead, 2)), Else: ActionRead},
+	)
+
+	var (
+		warnings []string
+		events   []CaveatEvent
+		v        = NewValidator(
+			WithShadowMode(CavVerifierPin, cavTestChildResource),
+			WithWarnings(func(w Warning) { warnings = append(warnings, w.Message) }),
+			WithSubscription(func(e CaveatEvent) { events = append(events, e) }, CavVerifierPin),
+		)
+	)
+
+	read := &testAccess{action: ActionRead, parentResource: ptr(uint64(1))}
+
+	// enforced, the pin denies access
+	assert.True(t, errors.Is(cs.Validate(Compose(read, verifierPart{"other", ""})), ErrUnauthorized))
+
+	// shadowed, it's reported
+	assert.NoError(t, v.Validate(cs, Compose(read, verifierPart{"other", ""})))
+	assert.Equal(t, []string{"shadowed caveat VerifierPin would deny access: unauthorized: token not valid on this host"}, warnings)
+	assert.Equal(t, 1, len(events))
+	assert.True(t, events[0].Shadowed)
+	assert.Error(t, events[0].Err)
+
+	// passing shadowed caveats aren't reported as shadowed
+	warnings, events = nil, nil
+	assert.NoError(t, v.Validate(cs, Compose(read, verifierPart{"mgmt1.internal", ""})))
+	assert.Zero(t, warnings)
+	assert.False(t, events[0].Shadowed)
+
+	// unshadowed caveats are still enforced, and shadowed caveats reporting
+	// unspecified resources don't change IfPresent's behavior
+	warnings = nil
+	write := &testAccess{action: ActionWrite, parentResource: ptr(uint64(1))}
+	assert.True(t, errors.Is(v.Validate(cs, Compose(write, verifierPart{"mgmt1.internal", ""})), ErrUnauthorized))
+	assert.Zero(t, warnings)
+
+	// shadowed caveats in IfPresent are reported
+	child := &testAccess{action: ActionWrite, parentResource: ptr(uint64(1)), childResource: ptr(uint64(2))}
+	assert.NoError(t, v.Validate(cs, Compose(child, verifierPart{"mgmt1.internal", ""})))
+	assert.Equal(t, 1, len(warnings))
+}
@@ -0,0 +1,258 @@
+// Package siem encodes token verification decisions in formats ingested by
+// security tooling: OCSF (the Open Cybersecurity Schema Framework) and ECS
+// (the Elastic Common Schema), both as JSON. Services report each decision
+// as an [Event], built with [NewEvent] after verifying a token or converted
+// from the audit events of other packages (e.g. [FromBreakGlass]), and ship
+// the encoded events to their SIEM's collector.
+package siem
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/superfly/macaroon"
+	"github.com/superfly/macaroon/breakglass"
+)
+
+// Schema versions the encoders target.
+const (
+	OCSFVersion = "1.1.0"
+	ECSVersion  = "8.11.0"
+)
+
+// Event is a token verification decision.
+type Event struct {
+	Time time.Time
+
+	// Location, KID and TokenUUID identify the token (see
+	// [macaroon.Nonce.UUID]).
+	Location  string
+	KID       []byte
+	TokenUUID string
+
+	// Subject is who the token was used by, if known (e.g. a user ID or
+	// email).
+	Subject string
+
+	// Action is what the token was used for, if known.
+	Action string
+
+	// Message optionally describes the event in more detail.
+	Message string
+
+	// Err is why the token was rejected, or nil if it was accepted.
+	Err error
+}
+
+// NewEvent creates an event for the verification of m, which failed with
+// err if it's not nil.
+func NewEvent(m *macaroon.Macaroon, err error) Event {
+	return Event{
+		Time:      time.Now(),
+		Location:  m.Location,
+		KID:       m.Nonce.KID,
+		TokenUUID: m.Nonce.UUID().String(),
+		Err:       err,
+	}
+}
+
+// FromBreakGlass converts an audit event from a [breakglass.Verifier].
+func FromBreakGlass(e breakglass.Event) Event {
+	ret := Event{
+		Time:      e.Time,
+		TokenUUID: e.TokenUUID,
+		Action:    "break-glass",
+		Err:       e.Err,
+	}
+
+	if e.Request != nil {
+		ret.Subject = e.Request.Requester
+		ret.Message = fmt.Sprintf("break-glass token requested by %s: %s", e.Request.Requester, e.Request.Reason)
+	}
+	if e.Approval != nil {
+		ret.Message += fmt.Sprintf(" (approved by %s)", e.Approval.Approver)
+	}
+
+	return ret
+}
+
+// Allowed returns whether the token was accepted.
+func (e Event) Allowed() bool { return e.Err == nil }
+
+func (e Event) reason() string {
+	if e.Err == nil {
+		return ""
+	}
+	return e.Err.Error()
+}
+
+func (e Event) message() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Allowed() {
+		return "token accepted"
+	}
+	return "token rejected: " + e.reason()
+}
+
+// OCSF classification of events: Authentication events in the Identity &
+// Access Management category, with the "other" activity.
+const (
+	ocsfCategoryUID = 3
+	ocsfClassUID    = 3002
+	ocsfActivityID  = 99
+
+	ocsfSeverityInformational = 1
+	ocsfSeverityMedium        = 3
+
+	ocsfStatusSuccess = 1
+	ocsfStatusFailure = 2
+)
+
+// named is an object with just a name, e.g. a user.
+type named struct {
+	Name string `json:"name"`
+}
+
+type ocsfEvent struct {
+	Time         int64  `json:"time"`
+	CategoryUID  int    `json:"category_uid"`
+	CategoryName string `json:"category_name"`
+	ClassUID     int    `json:"class_uid"`
+	ClassName    string `json:"class_name"`
+	ActivityID   int    `json:"activity_id"`
+	ActivityName string `json:"activity_name"`
+	TypeUID      int    `json:"type_uid"`
+	SeverityID   int    `json:"severity_id"`
+	StatusID     int    `json:"status_id"`
+	Status       string `json:"status"`
+	StatusDetail string `json:"status_detail,omitempty"`
+	Message      string `json:"message"`
+
+	Metadata struct {
+		Version string `json:"version"`
+		Product struct {
+			Name       string `json:"name"`
+			VendorName string `json:"vendor_name"`
+		} `json:"product"`
+		UID string `json:"uid,omitempty"`
+	} `json:"metadata"`
+
+	User *named `json:"user,omitempty"`
+
+	Service *named `json:"service,omitempty"`
+
+	Unmapped map[string]string `json:"unmapped,omitempty"`
+}
+
+// OCSF encodes the event as an OCSF Authentication event.
+func OCSF(e Event) ([]byte, error) {
+	oe := ocsfEvent{
+		Time:         e.Time.UnixMilli(),
+		CategoryUID:  ocsfCategoryUID,
+		CategoryName: "Identity & Access Management",
+		ClassUID:     ocsfClassUID,
+		ClassName:    "Authentication",
+		ActivityID:   ocsfActivityID,
+		ActivityName: "Verify Token",
+		TypeUID:      ocsfClassUID*100 + ocsfActivityID,
+		SeverityID:   ocsfSeverityInformational,
+		StatusID:     ocsfStatusSuccess,
+		Status:       "Success",
+		Message:      e.message(),
+		Unmapped:     unmapped(e),
+	}
+
+	if !e.Allowed() {
+		oe.SeverityID = ocsfSeverityMedium
+		oe.StatusID = ocsfStatusFailure
+		oe.Status = "Failure"
+		oe.StatusDetail = e.reason()
+	}
+
+	oe.Metadata.Version = OCSFVersion
+	oe.Metadata.Product.Name = "macaroon"
+	oe.Metadata.Product.VendorName = "Fly.io"
+	oe.Metadata.UID = e.TokenUUID
+
+	if e.Subject != "" {
+		oe.User = &named{Name: e.Subject}
+	}
+	if e.Location != "" {
+		oe.Service = &named{Name: e.Location}
+	}
+
+	return json.Marshal(oe)
+}
+
+type ecsEvent struct {
+	Timestamp string `json:"@timestamp"`
+	Message   string `json:"message"`
+
+	ECS struct {
+		Version string `json:"version"`
+	} `json:"ecs"`
+
+	Event struct {
+		Kind     string   `json:"kind"`
+		Category []string `json:"category"`
+		Type     []string `json:"type"`
+		Action   string   `json:"action"`
+		Outcome  string   `json:"outcome"`
+		Reason   string   `json:"reason,omitempty"`
+	} `json:"event"`
+
+	User *named `json:"user,omitempty"`
+
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// ECS encodes the event as an ECS authentication event.
+func ECS(e Event) ([]byte, error) {
+	ee := ecsEvent{
+		Timestamp: e.Time.UTC().Format(time.RFC3339Nano),
+		Message:   e.message(),
+		Labels:    unmapped(e),
+	}
+
+	ee.ECS.Version = ECSVersion
+	ee.Event.Kind = "event"
+	ee.Event.Category = []string{"authentication"}
+	ee.Event.Type = []string{"info"}
+	ee.Event.Action = "token-verification"
+	ee.Event.Outcome = "success"
+	if !e.Allowed() {
+		ee.Event.Outcome = "failure"
+		ee.Event.Reason = e.reason()
+	}
+
+	if e.Subject != "" {
+		ee.User = &named{Name: e.Subject}
+	}
+
+	return json.Marshal(ee)
+}
+
+// unmapped returns the event's fields without a place in the schemas.
+func unmapped(e Event) map[string]string {
+	ret := map[string]string{}
+	if e.TokenUUID != "" {
+		ret["token_uuid"] = e.TokenUUID
+	}
+	if len(e.KID) != 0 {
+		ret["token_kid"] = hex.EncodeToString(e.KID)
+	}
+	if e.Location != "" {
+		ret["token_location"] = e.Location
+	}
+	if e.Action != "" {
+		ret["token_action"] = e.Action
+	}
+	if len(ret) == 0 {
+		return nil
+	}
+	return ret
+}
@@ -0,0 +1,78 @@
+package siem
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+	"github.com/superfly/macaroon/breakglass"
+)
+
+func TestEncoders(t *testing.T) {
+	key := macaroon.NewSigningKey()
+	m, err := macaroon.New([]byte{0xab, 0xcd}, "https://api.fly.io", key)
+	assert.NoError(t, err)
+
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	e := NewEvent(m, nil)
+	e.Time, e.Subject = at, "user@example.com"
+
+	decode := func(buf []byte, err error) map[string]any {
+		t.Helper()
+		assert.NoError(t, err)
+		var ret map[string]any
+		assert.NoError(t, json.Unmarshal(buf, &ret))
+		return ret
+	}
+
+	ocsf := decode(OCSF(e))
+	assert.Equal[any](t, float64(at.UnixMilli()), ocsf["time"])
+	assert.Equal[any](t, float64(300299), ocsf["type_uid"])
+	assert.Equal[any](t, "Success", ocsf["status"])
+	assert.Equal[any](t, map[string]any{"name": "user@example.com"}, ocsf["user"])
+	assert.Equal[any](t, map[string]any{"name": "https://api.fly.io"}, ocsf["service"])
+	assert.Equal[any](t, m.Nonce.UUID().String(), ocsf["metadata"].(map[string]any)["uid"])
+	assert.Equal[any](t, "abcd", ocsf["unmapped"].(map[string]any)["token_kid"])
+
+	ecs := decode(ECS(e))
+	assert.Equal[any](t, "2024-05-01T12:00:00Z", ecs["@timestamp"])
+	assert.Equal[any](t, "success", ecs["event"].(map[string]any)["outcome"])
+	assert.Equal[any](t, "token accepted", ecs["message"])
+	assert.Equal[any](t, "https://api.fly.io", ecs["labels"].(map[string]any)["token_location"])
+
+	e.Err = macaroon.ErrUnauthorized
+
+	ocsf = decode(OCSF(e))
+	assert.Equal[any](t, "Failure", ocsf["status"])
+	assert.Equal[any](t, "unauthorized", ocsf["status_detail"])
+	assert.Equal[any](t, float64(3), ocsf["severity_id"])
+
+	ecs = decode(ECS(e))
+	assert.Equal[any](t, "failure", ecs["event"].(map[string]any)["outcome"])
+	assert.Equal[any](t, "unauthorized", ecs["event"].(map[string]any)["reason"])
+	assert.Equal[any](t, "token rejected: unauthorized", ecs["message"])
+}
+
+func TestFromBreakGlass(t *testing.T) {
+	e := FromBreakGlass(breakglass.Event{
+		Time:      time.Now(),
+		TokenUUID: "uuid",
+		Request:   &breakglass.Request{Requester: "alice", Reason: "incident 42"},
+		Approval:  &breakglass.Approval{Approver: "bob"},
+	})
+	assert.True(t, e.Allowed())
+	assert.Equal(t, "alice", e.Subject)
+	assert.Equal(t, "break-glass token requested by alice: incident 42 (approved by bob)", e.Message)
+
+	e = FromBreakGlass(breakglass.Event{Err: breakglass.ErrNotApproved})
+	assert.False(t, e.Allowed())
+	assert.True(t, errors.Is(e.Err, macaroon.ErrUnauthorized))
+
+	ecs, err := ECS(e)
+	assert.NoError(t, err)
+	assert.Contains(t, string(ecs), `"action":"token-verification"`)
+}
@@ -0,0 +1,61 @@
+package macaroon
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/superfly/macaroon/access"
+)
+
+// SpendLimit caps the estimated monetary cost of the operations a token may
+// authorize, e.g. for provisioning tokens handed to CI. Amount is in the
+// currency's minor unit (e.g. cents) and Currency is an ISO 4217 code.
+//
+// The cost of an operation is reported by Accesses implementing
+// [access.EstimatedCost]; other Accesses are prohibited, as are operations
+// priced in a different currency. When accesses are validated together with
+// [ValidateAll], their combined cost is capped.
+type SpendLimit struct {
+	Amount   uint64 `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func init() { RegisterCaveatType("SpendLimit", CavSpendLimit, &SpendLimit{}) }
+
+func (c *SpendLimit) CaveatType() CaveatType {
+	return CavSpendLimit
+}
+
+func (c *SpendLimit) Prohibits(f Access) error {
+	return c.ProhibitsWithContext(newValidationContext(f), f)
+}
+
+// spendKey is the ValidationContext key under which a SpendLimit tracks the
+// amount spent by earlier accesses in the validation call.
+type spendKey struct{ c *SpendLimit }
+
+func (c *SpendLimit) ProhibitsWithContext(vc *ValidationContext, f Access) error {
+	ca, ok := access.As[access.EstimatedCost](f)
+	if !ok {
+		return fmt.Errorf("%w: estimated cost not reported", ErrInvalidAccess)
+	}
+
+	amount, currency := ca.GetEstimatedCost()
+	if !strings.EqualFold(currency, c.Currency) {
+		return fmt.Errorf("%w: spend limit in %s, but cost in %s", ErrUnauthorized, c.Currency, currency)
+	}
+
+	var err error
+	vc.Update(spendKey{c}, func(v any, _ bool) any {
+		spent, _ := v.(uint64)
+		if spent > c.Amount || amount > c.Amount-spent {
+			err = fmt.Errorf("%w: spend limit of %d %s exceeded (%d spent, %d requested)", ErrUnauthorized, c.Amount, c.Currency, spent, amount)
+			return spent
+		}
+		return spent + amount
+	})
+
+	return err
+}
+
+func (c *SpendLimit) IsAttestation() bool { return false }
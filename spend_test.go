@@ -0,0 +1,43 @@
+package macaroon
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+type costAccess struct {
+	testAccess
+	amount   uint64
+	currency string
+}
+
+func (a *costAccess) GetEstimatedCost() (uint64, string) { return a.amount, a.currency }
+
+func TestSpendLimit(t *testing.T) {
+	cs := NewCaveatSet(&SpendLimit{Amount: 1000, Currency: "USD"})
+
+	access := func(amount uint64, currency string) *costAccess {
+		return &costAccess{
+			testAccess: testAccess{action: ActionWrite, parentResource: ptr(uint64(1))},
+			amount:     amount,
+			currency:   currency,
+		}
+	}
+
+	assert.NoError(t, cs.Validate(access(1000, "USD")))
+	assert.NoError(t, cs.Validate(access(500, "usd")))
+	assert.Error(t, cs.Validate(access(1001, "USD")))
+	assert.Error(t, cs.Validate(access(10, "EUR")))
+
+	// separately validated accesses are capped individually
+	assert.NoError(t, cs.Validate(access(600, "USD"), access(600, "USD")))
+
+	// accesses validated together are capped in total
+	assert.NoError(t, cs.ValidateAll(access(400, "USD"), access(600, "USD")))
+	assert.Error(t, cs.ValidateAll(access(600, "USD"), access(600, "USD")))
+
+	err := cs.Validate(&testAccess{action: ActionWrite, parentResource: ptr(uint64(1))})
+	assert.True(t, errors.Is(err, ErrInvalidAccess))
+}
@@ -0,0 +1,165 @@
+package macaroon
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// TokenStats are anonymized statistics about a verified token: what kinds of
+// caveats it carries, but nothing identifying the token, its holder or the
+// resources it grants access to. See [WithStatsReporter].
+type TokenStats struct {
+	// CaveatTypes counts the verified caveats of each type, by registered
+	// name, including those nested within IfPresent caveats.
+	CaveatTypes map[string]int
+
+	// ThirdParties are the normalized locations of the token's third-party
+	// caveats.
+	ThirdParties []string
+
+	// Expiry is the token's remaining validity, per its earliest-expiring
+	// ValidityWindow, when it was verified. It's only meaningful if
+	// HasExpiry is set.
+	Expiry    time.Duration
+	HasExpiry bool
+}
+
+// CollectTokenStats extracts statistics from a token and the caveats
+// returned by verifying it.
+func CollectTokenStats(m *Macaroon, verified *CaveatSet, now time.Time) TokenStats {
+	ret := TokenStats{CaveatTypes: map[string]int{}}
+	countCaveatTypes(verified, ret.CaveatTypes)
+
+	for _, c3p := range GetCaveats[*Caveat3P](&m.UnsafeCaveats) {
+		ret.ThirdParties = append(ret.ThirdParties, NormalizeLocation(c3p.Location))
+	}
+
+	for _, vw := range GetCaveats[*ValidityWindow](verified) {
+		if exp := time.Unix(vw.NotAfter, 0).Sub(now); !ret.HasExpiry || exp < ret.Expiry {
+			ret.Expiry, ret.HasExpiry = exp, true
+		}
+	}
+
+	return ret
+}
+
+func countCaveatTypes(cs *CaveatSet, counts map[string]int) {
+	for _, cav := range cs.Caveats {
+		counts[caveatTypeToString(cav.CaveatType())]++
+
+		if ifp, ok := cav.(*IfPresent); ok && ifp.Ifs != nil {
+			countCaveatTypes(ifp.Ifs, counts)
+		}
+	}
+}
+
+// StatsReporter receives statistics about verified tokens. Reporters are
+// called synchronously by Verify, so should be fast and safe for concurrent
+// use.
+type StatsReporter interface {
+	ReportTokenStats(TokenStats)
+}
+
+// WithStatsReporter reports statistics about each successfully verified
+// token to r.
+func WithStatsReporter(r StatsReporter) VerifyOption {
+	return func(o *verifyOptions) { o.stats = r }
+}
+
+// ExpiryBuckets are the upper bounds of the buckets [StatsAggregator] sorts
+// token expiries into.
+var ExpiryBuckets = []time.Duration{
+	time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+	30 * 24 * time.Hour,
+	365 * 24 * time.Hour,
+}
+
+// StatsAggregator is a StatsReporter that aggregates statistics across
+// tokens, e.g. for periodic export to a metrics system.
+type StatsAggregator struct {
+	mu sync.Mutex
+	s  StatsSnapshot
+}
+
+// StatsSnapshot is the aggregated statistics of a [StatsAggregator].
+type StatsSnapshot struct {
+	// Tokens is the number of tokens reported.
+	Tokens int
+
+	// CaveatTypes counts caveats by type, across all tokens.
+	CaveatTypes map[string]int
+
+	// ThirdParties counts third-party caveats by location, across all
+	// tokens.
+	ThirdParties map[string]int
+
+	// Expiries counts tokens by remaining validity. The nth count is of
+	// tokens expiring within ExpiryBuckets[n] (and not an earlier bucket);
+	// the last count is of tokens expiring later or not at all.
+	Expiries []int
+}
+
+var _ StatsReporter = (*StatsAggregator)(nil)
+
+// ReportTokenStats implements [StatsReporter].
+func (a *StatsAggregator) ReportTokenStats(ts TokenStats) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.s.Tokens == 0 {
+		a.s = newStatsSnapshot()
+	}
+
+	a.s.Tokens++
+
+	for typ, n := range ts.CaveatTypes {
+		a.s.CaveatTypes[typ] += n
+	}
+
+	for _, loc := range ts.ThirdParties {
+		a.s.ThirdParties[loc]++
+	}
+
+	bucket := len(ExpiryBuckets)
+	if ts.HasExpiry {
+		bucket = sort.Search(len(ExpiryBuckets), func(i int) bool { return ts.Expiry <= ExpiryBuckets[i] })
+	}
+	a.s.Expiries[bucket]++
+}
+
+// Snapshot returns the statistics aggregated since the last call to Reset.
+func (a *StatsAggregator) Snapshot() StatsSnapshot {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ret := newStatsSnapshot()
+	ret.Tokens = a.s.Tokens
+	for typ, n := range a.s.CaveatTypes {
+		ret.CaveatTypes[typ] = n
+	}
+	for loc, n := range a.s.ThirdParties {
+		ret.ThirdParties[loc] = n
+	}
+	copy(ret.Expiries, a.s.Expiries)
+
+	return ret
+}
+
+// Reset clears the aggregated statistics.
+func (a *StatsAggregator) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.s = StatsSnapshot{}
+}
+
+func newStatsSnapshot() StatsSnapshot {
+	return StatsSnapshot{
+		CaveatTypes:  map[string]int{},
+		ThirdParties: map[string]int{},
+		Expiries:     make([]int, len(ExpiryBuckets)+1),
+	}
+}
@@ -0,0 +1,64 @@
+package macaroon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestTokenStats(t *testing.T) {
+	var (
+		key = NewSigningKey()
+		ka  = NewEncryptionKey()
+		now = time.Now().Truncate(time.Second)
+		agg = new(StatsAggregator)
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(
+		cavParent(ActionRead, 1),
+		&IfPresent{Ifs: NewCaveatSet(cavChild(ActionRead, 2)), Else: ActionRead},
+		&ValidityWindow{NotBefore: now.Unix(), NotAfter: now.Add(48 * time.Hour).Unix()},
+	))
+	assert.NoError(t, m.Add3P(ka, "https://Auth/"))
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	_, _, dm, err := dischargeMacaroon(ka, "https://auth", buf)
+	assert.NoError(t, err)
+	assert.NoError(t, dm.Add(&ValidityWindow{NotBefore: now.Unix(), NotAfter: now.Add(30 * time.Minute).Unix()}))
+	dbuf, err := dm.Encode()
+	assert.NoError(t, err)
+
+	cs, err := m.Verify(key, [][]byte{dbuf}, nil, WithStatsReporter(agg))
+	assert.NoError(t, err)
+
+	ts := CollectTokenStats(m, cs, now)
+	assert.Equal(t, map[string]int{
+		caveatTypeToString(cavTestParentResource): 1,
+		caveatTypeToString(cavTestChildResource):  1,
+		"IfPresent":                               1,
+		"ValidityWindow":                          2,
+	}, ts.CaveatTypes)
+	assert.Equal(t, []string{"https://auth"}, ts.ThirdParties)
+	assert.True(t, ts.HasExpiry)
+	assert.Equal(t, 30*time.Minute, ts.Expiry)
+
+	// failed verifications aren't reported
+	_, err = m.Verify(key, nil, nil, WithStatsReporter(agg))
+	assert.Error(t, err)
+
+	agg.ReportTokenStats(TokenStats{CaveatTypes: map[string]int{"ValidityWindow": 1}, Expiry: 72 * time.Hour, HasExpiry: true})
+	agg.ReportTokenStats(TokenStats{})
+
+	snap := agg.Snapshot()
+	assert.Equal(t, 3, snap.Tokens)
+	assert.Equal(t, 3, snap.CaveatTypes["ValidityWindow"])
+	assert.Equal(t, map[string]int{"https://auth": 1}, snap.ThirdParties)
+	assert.Equal(t, []int{1, 0, 1, 0, 0, 1}, snap.Expiries)
+
+	agg.Reset()
+	assert.Equal(t, 0, agg.Snapshot().Tokens)
+}
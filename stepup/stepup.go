@@ -0,0 +1,133 @@
+// Package stepup implements step-up authentication: caveats requiring that
+// sensitive actions be authorized by a recent strong authentication (e.g.
+// MFA), while other actions need only the token itself. This allows "read
+// with a password, delete with MFA" policies in a single token.
+//
+// Evidence of strong authentication comes from one of two places:
+//
+//   - An [Authenticated] attestation, added with [Attest] by a third-party
+//     authentication service to its discharge tokens. Attestations are only
+//     considered if the discharge comes from a trusted third party (see
+//     [macaroon.Macaroon.Verify]).
+//   - An Access implementing [access.StrongAuth], for services that track
+//     strong authentication in their own sessions.
+package stepup
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/superfly/macaroon"
+	"github.com/superfly/macaroon/access"
+)
+
+// Well-known strong authentication methods.
+const (
+	MethodTOTP     = "totp"
+	MethodWebAuthn = "webauthn"
+)
+
+// Require requires a strong authentication within the last MaxAge seconds
+// for accesses involving any of Actions. If Methods isn't empty, the
+// authentication must have used one of them. Accesses not involving Actions
+// are unaffected.
+type Require struct {
+	Actions macaroon.Action `json:"actions"`
+	MaxAge  int64           `json:"max_age"`
+	Methods []string        `json:"methods,omitempty"`
+}
+
+func init() {
+	macaroon.RegisterCaveatType("StepUp", macaroon.CavStepUp, &Require{})
+}
+
+// NewRequire creates a Require caveat.
+func NewRequire(actions macaroon.Action, maxAge time.Duration, methods ...string) *Require {
+	return &Require{Actions: actions, MaxAge: int64(maxAge / time.Second), Methods: methods}
+}
+
+func (c *Require) CaveatType() macaroon.CaveatType {
+	return macaroon.CavStepUp
+}
+
+func (c *Require) Prohibits(a macaroon.Access) error {
+	return c.ProhibitsWithContext(new(macaroon.ValidationContext), a)
+}
+
+func (c *Require) ProhibitsWithContext(vc *macaroon.ValidationContext, a macaroon.Access) error {
+	if a.GetAction()&c.Actions == 0 {
+		return nil
+	}
+
+	now := vc.Now(a)
+
+	if cs := vc.Caveats(); cs != nil {
+		for _, att := range macaroon.GetCaveats[*Authenticated](cs) {
+			if c.satisfiedBy(now, att.Method, time.Unix(att.At, 0)) {
+				return nil
+			}
+		}
+	}
+
+	if sa, ok := access.As[access.StrongAuth](a); ok {
+		if method, at, ok := sa.GetStrongAuth(); ok && c.satisfiedBy(now, method, at) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s requires strong authentication within %s", macaroon.ErrUnauthorized, a.GetAction()&c.Actions, time.Duration(c.MaxAge)*time.Second)
+}
+
+func (c *Require) satisfiedBy(now time.Time, method string, at time.Time) bool {
+	if at.After(now) || now.Sub(at) > time.Duration(c.MaxAge)*time.Second {
+		return false
+	}
+
+	if len(c.Methods) == 0 {
+		return true
+	}
+
+	for _, m := range c.Methods {
+		if m == method {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Require) IsAttestation() bool { return false }
+
+// Authenticated is an attestation, added by an authentication service to its
+// discharge tokens, stating that the user strongly authenticated with Method
+// at At (seconds since the Unix epoch).
+type Authenticated struct {
+	Method string `json:"method"`
+	At     int64  `json:"at"`
+}
+
+func init() {
+	macaroon.RegisterCaveatType("StepUpAttestation", macaroon.CavStepUpAttestation, &Authenticated{})
+}
+
+func (c *Authenticated) CaveatType() macaroon.CaveatType {
+	return macaroon.CavStepUpAttestation
+}
+
+func (c *Authenticated) Prohibits(macaroon.Access) error {
+	// attestations play no role in access validation
+	return nil
+}
+
+func (c *Authenticated) IsAttestation() bool { return true }
+
+// AttestedAt implements [macaroon.TimestampedAttestation].
+func (c *Authenticated) AttestedAt() time.Time { return time.Unix(c.At, 0) }
+
+// Attest is used by an authentication service to add an [Authenticated]
+// attestation to a discharge token (as returned by [macaroon.DischargeCID]),
+// stating that the user strongly authenticated with method at the specified
+// time.
+func Attest(dm *macaroon.Macaroon, method string, at time.Time) error {
+	return dm.Add(&Authenticated{Method: method, At: at.Unix()})
+}
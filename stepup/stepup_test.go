@@ -0,0 +1,81 @@
+package stepup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+	"github.com/superfly/macaroon/flyio"
+)
+
+type mfaAccess struct {
+	flyio.Access
+	method string
+	at     time.Time
+}
+
+func (a *mfaAccess) GetStrongAuth() (string, time.Time, bool) {
+	return a.method, a.at, !a.at.IsZero()
+}
+
+func TestStepUp(t *testing.T) {
+	var (
+		key = macaroon.NewSigningKey()
+		ka  = macaroon.NewEncryptionKey()
+		now = time.Now()
+	)
+
+	m, err := macaroon.New([]byte("kid"), flyio.LocationPermission, key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(
+		&flyio.Organization{ID: 1, Mask: macaroon.ActionAll},
+		NewRequire(macaroon.ActionDelete, 10*time.Minute, MethodTOTP, MethodWebAuthn),
+	))
+	assert.NoError(t, m.Add3P(ka, flyio.LocationAuthentication))
+
+	discharge := func(at time.Time) [][]byte {
+		cid, err := m.ThirdPartyCID(flyio.LocationAuthentication)
+		assert.NoError(t, err)
+		_, dm, err := macaroon.DischargeCID(ka, flyio.LocationAuthentication, cid)
+		assert.NoError(t, err)
+		if !at.IsZero() {
+			assert.NoError(t, Attest(dm, MethodTOTP, at))
+		}
+		tok, err := dm.Encode()
+		assert.NoError(t, err)
+		return [][]byte{tok}
+	}
+
+	trusted := map[string]macaroon.EncryptionKey{flyio.LocationAuthentication: ka}
+	read := &flyio.Access{OrgID: 1, Action: macaroon.ActionRead}
+	del := &flyio.Access{OrgID: 1, Action: macaroon.ActionDelete}
+
+	// no strong auth: read, but not delete
+	cs, err := m.Verify(key, discharge(time.Time{}), trusted)
+	assert.NoError(t, err)
+	assert.NoError(t, cs.Validate(read))
+	assert.Error(t, cs.Validate(del))
+
+	// fresh strong auth
+	cs, err = m.Verify(key, discharge(now.Add(-time.Minute)), trusted)
+	assert.NoError(t, err)
+	assert.NoError(t, cs.Validate(del))
+	assert.Error(t, macaroon.NewValidator(macaroon.WithNow(now.Add(time.Hour))).Validate(cs, del))
+
+	// stale strong auth
+	cs, err = m.Verify(key, discharge(now.Add(-time.Hour)), trusted)
+	assert.NoError(t, err)
+	assert.Error(t, cs.Validate(del))
+
+	// attestations from untrusted discharges are ignored
+	cs, err = m.Verify(key, discharge(now), nil)
+	assert.NoError(t, err)
+	assert.Error(t, cs.Validate(del))
+
+	// strong auth reported by the access
+	cs = macaroon.NewCaveatSet(NewRequire(macaroon.ActionDelete, 10*time.Minute, MethodTOTP, MethodWebAuthn))
+	assert.NoError(t, cs.Validate(&mfaAccess{Access: *del, method: MethodWebAuthn, at: now}))
+	assert.Error(t, cs.Validate(&mfaAccess{Access: *del, method: "sms", at: now}))
+	assert.Error(t, cs.Validate(&mfaAccess{Access: *del}))
+}
@@ -0,0 +1,51 @@
+package macaroon
+
+// CaveatEvent describes the evaluation of a caveat against an access during
+// validation. Err is the caveat's result: nil if it permitted the access.
+// Shadowed is set if the caveat failed, but wasn't enforced because its type
+// is evaluated in shadow mode (see [WithShadowMode]).
+type CaveatEvent struct {
+	Caveat   Caveat
+	Access   Access
+	Err      error
+	Shadowed bool
+}
+
+// Passed returns whether the caveat permitted the access.
+func (e CaveatEvent) Passed() bool { return e.Err == nil }
+
+type subscription struct {
+	types map[CaveatType]bool
+	f     func(CaveatEvent)
+}
+
+// WithSubscription calls f each time the Validator evaluates a caveat of one
+// of the specified types, or of any type if none are specified, whether it
+// passes or fails. This lets policy tooling observe how often particular
+// caveats gate real traffic, e.g. before tightening them. Caveats nested in
+// IfPresent caveats are included; caveats skipped because the validation
+// budget was exhausted aren't. The option may be used more than once.
+//
+// f is called synchronously, and concurrently if [WithParallelism] is used,
+// so it should be fast and safe for concurrent use.
+func WithSubscription(f func(CaveatEvent), types ...CaveatType) ValidationOption {
+	return func(v *Validator) {
+		sub := subscription{f: f}
+		if len(types) != 0 {
+			sub.types = make(map[CaveatType]bool, len(types))
+			for _, t := range types {
+				sub.types[t] = true
+			}
+		}
+		v.subs = append(v.subs, sub)
+	}
+}
+
+// notify calls the subscriptions interested in the event's caveat.
+func (r *validationRun) notify(e CaveatEvent) {
+	for _, sub := range r.subs {
+		if sub.types == nil || sub.types[e.Caveat.CaveatType()] {
+			sub.f(e)
+		}
+	}
+}
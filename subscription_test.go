@@ -0,0 +1,43 @@
+package macaroon
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestWithSubscription(t *testing.T) {
+	cs := NewCaveatSet(
+		cavParent(ActionRead|ActionWrite, 1),
+		&IfPresent{Ifs: NewCaveatSet(cavChild(ActionRead, 2)), Else: ActionRead},
+		&testCaveatAttestation{"bob"},
+	)
+
+	var (
+		parents []CaveatEvent
+		all     []CaveatType
+	)
+
+	v := NewValidator(
+		WithSubscription(func(e CaveatEvent) { parents = append(parents, e) }, cavTestParentResource),
+		WithSubscription(func(e CaveatEvent) { all = append(all, e.Caveat.CaveatType()) }),
+	)
+
+	read := &testAccess{action: ActionRead, parentResource: ptr(uint64(1)), childResource: ptr(uint64(2))}
+	write := &testAccess{action: ActionWrite, parentResource: ptr(uint64(2))}
+
+	assert.NoError(t, v.Validate(cs, read))
+	assert.Error(t, v.Validate(cs, write))
+
+	assert.Equal(t, 2, len(parents))
+	assert.True(t, parents[0].Passed())
+	assert.Equal[Access](t, read, parents[0].Access)
+	assert.False(t, parents[1].Passed())
+	assert.Equal[Access](t, write, parents[1].Access)
+
+	// nested caveats are included, attestations aren't evaluated
+	assert.Equal(t, []CaveatType{
+		cavTestParentResource, cavTestChildResource, CavIfPresent,
+		cavTestParentResource, cavTestChildResource, CavIfPresent,
+	}, all)
+}
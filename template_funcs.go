@@ -0,0 +1,102 @@
+package macaroon
+
+import (
+	"fmt"
+	"text/template"
+	"time"
+)
+
+// TemplateFuncs returns helpers for rendering tokens in templates, e.g. "you've
+// been granted..." notifications sent by issuance services. The FuncMap works
+// with text/template and, converted, html/template:
+//
+//	scope        (*CaveatSet) []string  — see ScopeSummary
+//	expiry       (*CaveatSet) time.Time — see CaveatSetExpiry
+//	expiresIn    (*CaveatSet) string    — see HumanizeExpiry
+//	thirdParties (*Macaroon) []string   — see ThirdPartyLocations
+//
+// For example:
+//
+//	You've been granted access, expiring {{ expiresIn .Caveats }}:
+//	{{ range scope .Caveats }}
+//	  - {{ . }}
+//	{{- end }}
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"scope":  ScopeSummary,
+		"expiry": CaveatSetExpiry,
+		"expiresIn": func(cs *CaveatSet) string {
+			return HumanizeExpiry(CaveatSetExpiry(cs), time.Now())
+		},
+		"thirdParties": ThirdPartyLocations,
+	}
+}
+
+// ScopeSummary describes each of the caveats restricting the token, using the
+// caveat's String method if it has one and its type name otherwise.
+// Attestations and validity windows (see [CaveatSetExpiry]) are omitted.
+func ScopeSummary(cs *CaveatSet) []string {
+	ret := make([]string, 0, len(cs.Caveats))
+	for _, cav := range cs.Caveats {
+		if _, isVW := cav.(*ValidityWindow); isVW || cav.IsAttestation() {
+			continue
+		}
+
+		if s, ok := cav.(fmt.Stringer); ok {
+			ret = append(ret, s.String())
+		} else {
+			ret = append(ret, CaveatTypeName(cav.CaveatType()))
+		}
+	}
+	return ret
+}
+
+// CaveatSetExpiry returns the earliest expiry of the caveat set's validity
+// windows, or the zero time if it has none.
+func CaveatSetExpiry(cs *CaveatSet) time.Time {
+	var ret time.Time
+	for _, vw := range GetCaveats[*ValidityWindow](cs) {
+		if na := time.Unix(vw.NotAfter, 0); ret.IsZero() || na.Before(ret) {
+			ret = na
+		}
+	}
+	return ret
+}
+
+// HumanizeExpiry describes when exp is, relative to now, e.g. "in 3 hours".
+// The zero time never expires.
+func HumanizeExpiry(exp, now time.Time) string {
+	if exp.IsZero() {
+		return "never"
+	}
+
+	plural := func(n int64, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("in 1 %s", unit)
+		}
+		return fmt.Sprintf("in %d %ss", n, unit)
+	}
+
+	switch d := exp.Sub(now); {
+	case d <= 0:
+		return "expired"
+	case d < time.Minute:
+		return "in less than a minute"
+	case d < time.Hour:
+		return plural(int64(d/time.Minute), "minute")
+	case d < 48*time.Hour:
+		return plural(int64(d/time.Hour), "hour")
+	default:
+		return plural(int64(d/(24*time.Hour)), "day")
+	}
+}
+
+// ThirdPartyLocations returns the locations of the token's third-party
+// caveats, i.e. the services that must discharge it.
+func ThirdPartyLocations(m *Macaroon) []string {
+	var ret []string
+	for _, c3p := range GetCaveats[*Caveat3P](&m.UnsafeCaveats) {
+		ret = append(ret, c3p.Location)
+	}
+	return ret
+}
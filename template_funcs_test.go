@@ -0,0 +1,55 @@
+package macaroon
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	var (
+		key = NewSigningKey()
+		now = time.Now()
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(
+		cavParent(ActionRead, 1),
+		&ValidityWindow{NotBefore: now.Unix(), NotAfter: now.Add(3*time.Hour + time.Minute).Unix()},
+		&VerifierPin{Hostnames: []string{"mgmt1"}},
+	))
+	assert.NoError(t, m.Add3P(NewEncryptionKey(), "https://auth"))
+
+	cs := NewCaveatSet(m.UnsafeCaveats.Caveats[:3]...)
+
+	tmpl := template.Must(template.New("").Funcs(TemplateFuncs()).Parse(
+		`{{ range scope .Caveats }}{{ . }};{{ end }} expires {{ expiresIn .Caveats }} via {{ thirdParties .Token }}`,
+	))
+
+	var buf bytes.Buffer
+	assert.NoError(t, tmpl.Execute(&buf, map[string]any{"Caveats": cs, "Token": m}))
+	assert.Equal(t, "ParentResource;VerifierPin; expires in 3 hours via [https://auth]", buf.String())
+
+	// usable with html/template too
+	htmltemplate.Must(htmltemplate.New("").Funcs(htmltemplate.FuncMap(TemplateFuncs())).Parse(`{{ scope . }}`))
+
+	assert.Equal(t, now.Add(3*time.Hour+time.Minute).Unix(), CaveatSetExpiry(cs).Unix())
+	assert.Zero(t, CaveatSetExpiry(NewCaveatSet()))
+
+	for exp, want := range map[time.Time]string{
+		{}:                        "never",
+		now.Add(-time.Second):     "expired",
+		now.Add(30 * time.Second): "in less than a minute",
+		now.Add(90 * time.Second): "in 1 minute",
+		now.Add(47 * time.Hour):   "in 47 hours",
+		now.Add(72 * time.Hour):   "in 3 days",
+		now.Add(24*time.Hour + 1): "in 24 hours",
+	} {
+		assert.Equal(t, want, HumanizeExpiry(exp, now))
+	}
+}
@@ -0,0 +1,195 @@
+// Package tp contains helpers for third-party discharge services.
+//
+// A [Template] describes the caveats a discharge service adds to its
+// discharge tokens, with [Param]s standing in for values only known once the
+// caller has been authenticated (user IDs, session IDs, expiries). Discharge
+// handlers define their templates once and fill in the parameters per
+// request, rather than constructing caveats by hand:
+//
+//	var (
+//		userID   = tp.NewParam[uint64]("user_id")
+//		template = tp.NewTemplate(
+//			tp.ValidFor(time.Hour),
+//			tp.With(userID, func(id uint64) macaroon.Caveat { return &flyio.IsUser{ID: id} }),
+//		)
+//	)
+//
+//	func handleDischarge(w http.ResponseWriter, r *http.Request) {
+//		user := authenticate(r)
+//		ticketCavs, dm, err := template.Discharge(key, location, cid, userID.Bind(user.ID))
+//		// ...
+//	}
+package tp
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/superfly/macaroon"
+)
+
+// Param is a typed template parameter, identified by its name.
+type Param[T any] struct {
+	name string
+}
+
+// NewParam creates a template parameter. Names must be unique among the
+// parameters used with a template.
+func NewParam[T any](name string) Param[T] {
+	return Param[T]{name: name}
+}
+
+// Name returns the parameter's name.
+func (p Param[T]) Name() string { return p.name }
+
+// Bind supplies the parameter's value for rendering a template.
+func (p Param[T]) Bind(v T) Value {
+	return Value{name: p.name, v: v}
+}
+
+// Get returns the parameter's value from vals.
+func (p Param[T]) Get(vals Values) (T, error) {
+	var zero T
+
+	v, ok := vals[p.name]
+	if !ok {
+		return zero, fmt.Errorf("template: missing parameter %s", p.name)
+	}
+
+	t, ok := v.(T)
+	if !ok {
+		return zero, fmt.Errorf("template: parameter %s is %T, not %T", p.name, v, zero)
+	}
+
+	return t, nil
+}
+
+// Value is a parameter's value. See [Param.Bind].
+type Value struct {
+	name string
+	v    any
+}
+
+// Values are the parameter values a template is rendered with.
+type Values map[string]any
+
+// CaveatTemplate renders caveats from parameter values.
+type CaveatTemplate interface {
+	Render(vals Values, now time.Time) ([]macaroon.Caveat, error)
+}
+
+// CaveatTemplateFunc adapts a function to a [CaveatTemplate].
+type CaveatTemplateFunc func(vals Values, now time.Time) ([]macaroon.Caveat, error)
+
+// Render implements [CaveatTemplate].
+func (f CaveatTemplateFunc) Render(vals Values, now time.Time) ([]macaroon.Caveat, error) {
+	return f(vals, now)
+}
+
+// Static renders the specified caveats, regardless of parameters.
+func Static(cavs ...macaroon.Caveat) CaveatTemplate {
+	return CaveatTemplateFunc(func(Values, time.Time) ([]macaroon.Caveat, error) {
+		return cavs, nil
+	})
+}
+
+// With renders a caveat from the value of p.
+func With[T any](p Param[T], fn func(T) macaroon.Caveat) CaveatTemplate {
+	return CaveatTemplateFunc(func(vals Values, _ time.Time) ([]macaroon.Caveat, error) {
+		v, err := p.Get(vals)
+		if err != nil {
+			return nil, err
+		}
+		return []macaroon.Caveat{fn(v)}, nil
+	})
+}
+
+// ValidFor renders a [macaroon.ValidityWindow] starting when the template is
+// rendered and lasting ttl.
+func ValidFor(ttl time.Duration) CaveatTemplate {
+	return CaveatTemplateFunc(func(_ Values, now time.Time) ([]macaroon.Caveat, error) {
+		return []macaroon.Caveat{&macaroon.ValidityWindow{
+			NotBefore: now.Unix(),
+			NotAfter:  now.Add(ttl).Unix(),
+		}}, nil
+	})
+}
+
+// ValidUntil renders a [macaroon.ValidityWindow] starting when the template is
+// rendered and ending at the value of p, e.g. the expiry of the caller's
+// session.
+func ValidUntil(p Param[time.Time]) CaveatTemplate {
+	return CaveatTemplateFunc(func(vals Values, now time.Time) ([]macaroon.Caveat, error) {
+		exp, err := p.Get(vals)
+		if err != nil {
+			return nil, err
+		}
+		if !exp.After(now) {
+			return nil, fmt.Errorf("template: %s is in the past", p.name)
+		}
+		return []macaroon.Caveat{&macaroon.ValidityWindow{
+			NotBefore: now.Unix(),
+			NotAfter:  exp.Unix(),
+		}}, nil
+	})
+}
+
+// Template is a set of caveat templates that discharge services render into
+// the caveats for their discharge tokens.
+type Template struct {
+	cavs []CaveatTemplate
+}
+
+// NewTemplate creates a template from caveat templates, which are rendered
+// in order.
+func NewTemplate(cavs ...CaveatTemplate) *Template {
+	return &Template{cavs: append([]CaveatTemplate{}, cavs...)}
+}
+
+// Render renders the template's caveats with the specified parameter values.
+func (t *Template) Render(vals ...Value) ([]macaroon.Caveat, error) {
+	return t.render(time.Now(), vals...)
+}
+
+func (t *Template) render(now time.Time, vals ...Value) ([]macaroon.Caveat, error) {
+	byName := make(Values, len(vals))
+	for _, v := range vals {
+		if _, dup := byName[v.name]; dup {
+			return nil, fmt.Errorf("template: parameter %s bound more than once", v.name)
+		}
+		byName[v.name] = v.v
+	}
+
+	var ret []macaroon.Caveat
+	for _, ct := range t.cavs {
+		cavs, err := ct.Render(byName, now)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, cavs...)
+	}
+
+	return ret, nil
+}
+
+// Discharge discharges a third-party caveat's ticket (see
+// [macaroon.DischargeCIDWithSealer]), adding the rendered template's caveats
+// to the discharge token. As with DischargeCID, the ticket's caveats are
+// returned and must be validated before issuing the discharge token.
+func (t *Template) Discharge(s macaroon.Sealer, location string, cid []byte, vals ...Value) ([]macaroon.Caveat, *macaroon.Macaroon, error) {
+	cavs, err := t.Render(vals...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ticketCavs, dm, err := macaroon.DischargeCIDWithSealer(s, location, cid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := dm.Add(cavs...); err != nil {
+		return nil, nil, fmt.Errorf("template: %w", err)
+	}
+
+	return ticketCavs, dm, nil
+}
@@ -0,0 +1,80 @@
+package tp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+	"github.com/superfly/macaroon/flyio"
+)
+
+func TestTemplate(t *testing.T) {
+	var (
+		userID  = NewParam[uint64]("user_id")
+		expires = NewParam[time.Time]("expires")
+		now     = time.Unix(1700000000, 0)
+
+		template = NewTemplate(
+			ValidUntil(expires),
+			With(userID, func(id uint64) macaroon.Caveat { return &flyio.IsUser{ID: id} }),
+			Static(&flyio.Organization{ID: 1, Mask: macaroon.ActionRead}),
+		)
+	)
+
+	cavs, err := template.render(now, userID.Bind(123), expires.Bind(now.Add(time.Hour)))
+	assert.NoError(t, err)
+	assert.Equal(t, []macaroon.Caveat{
+		&macaroon.ValidityWindow{NotBefore: now.Unix(), NotAfter: now.Add(time.Hour).Unix()},
+		&flyio.IsUser{ID: 123},
+		&flyio.Organization{ID: 1, Mask: macaroon.ActionRead},
+	}, cavs)
+
+	_, err = template.render(now, userID.Bind(123))
+	assert.EqualError(t, err, "template: missing parameter expires")
+
+	_, err = template.render(now, userID.Bind(123), expires.Bind(now.Add(-time.Hour)))
+	assert.EqualError(t, err, "template: expires is in the past")
+
+	_, err = template.render(now, userID.Bind(123), userID.Bind(456), expires.Bind(now.Add(time.Hour)))
+	assert.EqualError(t, err, "template: parameter user_id bound more than once")
+
+	// parameters with the same name and different types don't mix
+	_, err = NewTemplate(With(NewParam[string]("user_id"), func(string) macaroon.Caveat { return nil })).render(now, userID.Bind(123))
+	assert.EqualError(t, err, "template: parameter user_id is uint64, not string")
+}
+
+func TestTemplateDischarge(t *testing.T) {
+	var (
+		key = macaroon.NewSigningKey()
+		ka  = macaroon.NewEncryptionKey()
+
+		userID   = NewParam[uint64]("user_id")
+		template = NewTemplate(
+			ValidFor(time.Hour),
+			With(userID, func(id uint64) macaroon.Caveat { return &flyio.IsUser{ID: id} }),
+		)
+	)
+
+	m, err := macaroon.New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add3P(ka, "https://auth", &flyio.Organization{ID: 1, Mask: macaroon.ActionRead}))
+
+	cid, err := m.ThirdPartyCID("https://auth")
+	assert.NoError(t, err)
+
+	ticketCavs, dm, err := template.Discharge(ka, "https://auth", cid, userID.Bind(123))
+	assert.NoError(t, err)
+	assert.Equal(t, []macaroon.Caveat{&flyio.Organization{ID: 1, Mask: macaroon.ActionRead}}, ticketCavs)
+
+	dbuf, err := dm.Encode()
+	assert.NoError(t, err)
+
+	cs, err := m.Verify(key, [][]byte{dbuf}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []*flyio.IsUser{{ID: 123}}, macaroon.GetCaveats[*flyio.IsUser](cs))
+	assert.Equal(t, 1, len(macaroon.GetCaveats[*macaroon.ValidityWindow](cs)))
+
+	_, _, err = template.Discharge(ka, "https://auth", cid)
+	assert.Error(t, err)
+}
@@ -0,0 +1,36 @@
+package macaroon
+
+// Trusted3Ps are the third parties whose discharge tokens are trusted to make
+// attestations, mapping their locations to their keys. A location may have
+// several keys, e.g. while a third party rotates its key. Keys are usually
+// [EncryptionKey]s, but may be any [Sealer] the third party's tickets are
+// sealed with (see [Macaroon.Add3PWithSealer]), e.g. one backed by a key
+// management system.
+//
+// Trust only affects attestations. A discharge token is valid if it was
+// signed with the key sealed in the ticket of the corresponding third-party
+// caveat, which anyone who can unseal the ticket can do, and the caveats it
+// adds are always returned by Verify. The attestations in a discharge are
+// only returned if its ticket can be unsealed with one of the keys for the
+// discharge's location, proving the discharge was issued by that third party.
+type Trusted3Ps map[string][]Sealer
+
+// Add adds keys for the third party at the location.
+func (t Trusted3Ps) Add(loc string, keys ...Sealer) Trusted3Ps {
+	loc = NormalizeLocation(loc)
+	t[loc] = append(t[loc], keys...)
+	return t
+}
+
+// WithTrusted3Ps trusts the attestations of discharges issued by the third
+// parties, in addition to any passed to Verify. See [Trusted3Ps].
+func WithTrusted3Ps(t Trusted3Ps) VerifyOption {
+	return func(o *verifyOptions) {
+		if o.trusted == nil {
+			o.trusted = Trusted3Ps{}
+		}
+		for loc, keys := range t {
+			o.trusted.Add(loc, keys...)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package macaroon
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestTrusted3Ps(t *testing.T) {
+	var (
+		key    = NewSigningKey()
+		oldKey = NewEncryptionKey()
+		newKey = NewEncryptionKey()
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add3P(newKey, "https://auth"))
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	_, _, dm, err := dischargeMacaroon(newKey, "https://auth", buf)
+	assert.NoError(t, err)
+	assert.NoError(t, dm.Add(&testCaveatAttestation{"bob"}))
+	dbuf, err := dm.Encode()
+	assert.NoError(t, err)
+
+	attestations := func(cs *CaveatSet) []*testCaveatAttestation {
+		return GetCaveats[*testCaveatAttestation](cs)
+	}
+
+	// untrusted discharges are valid, but their attestations are dropped
+	cs, err := m.Verify(key, [][]byte{dbuf}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(attestations(cs)))
+
+	// any of a location's keys will do
+	trusted := Trusted3Ps{}.Add("https://auth/", oldKey, newKey)
+	cs, err = m.Verify(key, [][]byte{dbuf}, nil, WithTrusted3Ps(trusted))
+	assert.NoError(t, err)
+	assert.Equal(t, []*testCaveatAttestation{{"bob"}}, attestations(cs))
+
+	// trusting a location with the wrong keys is an error
+	_, err = m.Verify(key, [][]byte{dbuf}, nil, WithTrusted3Ps(Trusted3Ps{}.Add("https://auth", oldKey)))
+	assert.Error(t, err)
+
+	kr := NewKeyring()
+	assert.NoError(t, kr.Add([]byte("kid"), "key", key))
+	cs, err = kr.Verify(m, [][]byte{dbuf})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(attestations(cs)))
+
+	kr.Trust3P("https://auth", newKey)
+	assert.Equal(t, Trusted3Ps{"https://auth": {newKey}}, kr.Trusted3Ps())
+	cs, err = kr.Verify(m, [][]byte{dbuf})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(attestations(cs)))
+}
+
+// testSealer is a Sealer that isn't an EncryptionKey, like those backed by
+// key management systems.
+type testSealer struct{ key EncryptionKey }
+
+func (s *testSealer) Seal(pt []byte) ([]byte, error)   { return s.key.Seal(pt) }
+func (s *testSealer) Unseal(ct []byte) ([]byte, error) { return s.key.Unseal(ct) }
+
+func TestTrusted3PSealer(t *testing.T) {
+	var (
+		key    = NewSigningKey()
+		sealer = &testSealer{NewEncryptionKey()}
+		other  = &testSealer{NewEncryptionKey()}
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add3PWithSealer(sealer, "https://auth"))
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	cid, err := m.ThirdPartyCID("https://auth")
+	assert.NoError(t, err)
+	_, dm, err := DischargeCIDWithSealer(sealer, "https://auth", cid)
+	assert.NoError(t, err)
+
+	sealed, err := NewSealedAttestation(sealer, &testCaveatAttestation{"sealed"})
+	assert.NoError(t, err)
+	assert.NoError(t, dm.Add(&testCaveatAttestation{"bob"}, sealed))
+	assert.NoError(t, dm.Bind(buf))
+	dbuf, err := dm.Encode()
+	assert.NoError(t, err)
+
+	cs, err := m.Verify(key, [][]byte{dbuf}, nil, WithTrusted3Ps(Trusted3Ps{}.Add("https://auth", sealer)))
+	assert.NoError(t, err)
+	assert.Equal(t, []*testCaveatAttestation{{"bob"}, {"sealed"}}, GetCaveats[*testCaveatAttestation](cs))
+
+	_, err = m.Verify(key, [][]byte{dbuf}, nil, WithTrusted3Ps(Trusted3Ps{}.Add("https://auth", other)))
+	assert.Error(t, err)
+
+	kr := NewKeyring()
+	assert.NoError(t, kr.Add([]byte("kid"), "key", key))
+	kr.Trust3P("https://auth", sealer)
+	cs, err = kr.Verify(m, [][]byte{dbuf})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(GetCaveats[*testCaveatAttestation](cs)))
+}
@@ -0,0 +1,286 @@
+package macaroon
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ValidationContext carries state across the evaluation of caveats within a
+// single validation call. When validating with [ValidateAll], one context is
+// shared by every access in the call, so caveats that need to see the whole
+// operation (rate limits, batches of mutations, etc.) can accumulate state
+// between accesses. When validating with [Validate], each access gets its own
+// context.
+type ValidationContext struct {
+	// Accesses is the full list of accesses being validated in this call.
+	Accesses []Access
+
+	caveats *CaveatSet
+	now     time.Time
+	state   map[any]any
+	memo    map[memoKey]any
+	run     *validationRun
+	mu      sync.Mutex
+
+	// set for the contexts of caveats evaluated with a timeout, which share
+	// their parent's state until they're abandoned
+	parent    *ValidationContext
+	done      chan struct{}
+	abandoned bool
+}
+
+func newValidationContext(accesses ...Access) *ValidationContext {
+	return &ValidationContext{Accesses: accesses, run: new(validationRun)}
+}
+
+// Now returns the time at which the access should be evaluated. This is the
+// time pinned with [WithNow], if any, or else the result of [Access.Now].
+// Time-sensitive caveats should implement [ContextualCaveat] and use this
+// rather than calling Access.Now directly.
+func (vc *ValidationContext) Now(a Access) time.Time {
+	if !vc.now.IsZero() {
+		return vc.now
+	}
+	return a.Now()
+}
+
+// Caveats returns the caveat set being validated. This includes attestations
+// from trusted discharges, for caveats whose evaluation depends on them. It's
+// nil when a caveat is evaluated outside of a [Validator], e.g. by calling
+// its Prohibits method directly.
+func (vc *ValidationContext) Caveats() *CaveatSet {
+	return vc.caveats
+}
+
+// Done returns a channel that's closed when the caveat's evaluation is
+// abandoned, e.g. because it exceeded the [WithCaveatTimeout] timeout.
+// Caveats doing I/O should stop when it's closed. Once it's closed, the
+// caveat's changes to the context are discarded. It's nil, never closing,
+// when the evaluation can't be abandoned.
+func (vc *ValidationContext) Done() <-chan struct{} {
+	return vc.done
+}
+
+// Get retrieves a value previously stored in the context with Set. Keys
+// should be of an unexported type defined by the caveat implementation, to
+// avoid collisions between caveat types.
+func (vc *ValidationContext) Get(key any) (any, bool) {
+	if vc.parent != nil {
+		return vc.parent.Get(key)
+	}
+
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	v, ok := vc.state[key]
+	return v, ok
+}
+
+// Set stores a value in the context, to be retrieved by a subsequent caveat
+// evaluation in the same validation call. Get and Set are safe for concurrent
+// use (see [WithParallelism]), but a caveat that reads and then updates a value
+// should do so with Update.
+func (vc *ValidationContext) Set(key, value any) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	if vc.parent != nil {
+		if !vc.abandoned {
+			vc.parent.Set(key, value)
+		}
+		return
+	}
+
+	if vc.state == nil {
+		vc.state = map[any]any{}
+	}
+	vc.state[key] = value
+}
+
+// Update atomically replaces the value stored in the context under key with
+// the result of calling fn with the current value, if any.
+func (vc *ValidationContext) Update(key any, fn func(value any, ok bool) any) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	if vc.parent != nil {
+		if !vc.abandoned {
+			vc.parent.Update(key, fn)
+		}
+		return
+	}
+
+	if vc.state == nil {
+		vc.state = map[any]any{}
+	}
+	v, ok := vc.state[key]
+	vc.state[key] = fn(v, ok)
+}
+
+type memoKey struct {
+	access Access
+	key    any
+}
+
+// Memoize returns the result of fn, calling it only the first time it's
+// needed for the access in the validation call. Caveats use it for
+// extractions from Accesses that many caveats in a set repeat, e.g. finding a
+// product's Access in a composite Access or looking up a resource's labels.
+// Like Get and Set, keys should be of an unexported type defined by the
+// caveat implementation. Results are only memoized for Accesses that are
+// pointers, which are identified by address; Access values are never hashed,
+// since they may hold uncomparable values. fn is called every time if vc is
+// nil (e.g. when caveats are evaluated outside of a [Validator]) or if the
+// access isn't a pointer. Under [WithParallelism], fn may be called more than
+// once.
+func Memoize[T any](vc *ValidationContext, a Access, key any, fn func() T) T {
+	if vc == nil || a == nil || reflect.ValueOf(a).Kind() != reflect.Pointer {
+		return fn()
+	}
+
+	mk := memoKey{a, key}
+	if v, ok := vc.memoized(mk); ok {
+		return v.(T)
+	}
+
+	ret := fn()
+	vc.memoize(mk, ret)
+	return ret
+}
+
+func (vc *ValidationContext) memoized(mk memoKey) (any, bool) {
+	if vc.parent != nil {
+		return vc.parent.memoized(mk)
+	}
+
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	v, ok := vc.memo[mk]
+	return v, ok
+}
+
+func (vc *ValidationContext) memoize(mk memoKey, v any) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	if vc.parent != nil {
+		if !vc.abandoned {
+			vc.parent.memoize(mk, v)
+		}
+		return
+	}
+
+	if vc.memo == nil {
+		vc.memo = map[memoKey]any{}
+	}
+	vc.memo[mk] = v
+}
+
+// Prohibits checks whether the caveat prohibits the access, passing the
+// context along to caveats implementing [ContextualCaveat]. Caveats that
+// contain other caveats (e.g. [IfPresent]) should use this to evaluate their
+// children.
+func (vc *ValidationContext) Prohibits(c Caveat, a Access) error {
+	if err := vc.run.charge(c); err != nil {
+		return err
+	}
+
+	var err error
+	if vc.run.timeout > 0 {
+		err = vc.prohibitsWithTimeout(c, a, vc.run.timeout)
+	} else {
+		err = vc.prohibits(c, a)
+	}
+
+	shadowed := vc.run.shadows(c, err)
+	vc.run.notify(CaveatEvent{Caveat: c, Access: a, Err: err, Shadowed: shadowed})
+
+	if shadowed {
+		vc.run.warnShadowed(c, err)
+		return nil
+	}
+
+	return err
+}
+
+func (vc *ValidationContext) prohibits(c Caveat, a Access) error {
+	if cc, ok := c.(ContextualCaveat); ok {
+		return cc.ProhibitsWithContext(vc, a)
+	}
+	return c.Prohibits(a)
+}
+
+// prohibitsWithTimeout evaluates the caveat in another goroutine, giving up on
+// it after timeout. See WithCaveatTimeout. The caveat is evaluated with a
+// child context, which is abandoned on timeout. Panics are propagated to the
+// caller.
+func (vc *ValidationContext) prohibitsWithTimeout(c Caveat, a Access, timeout time.Duration) error {
+	type outcome struct {
+		err      error
+		panicked bool
+		p        any
+	}
+
+	child := &ValidationContext{
+		Accesses: vc.Accesses,
+		caveats:  vc.caveats,
+		now:      vc.now,
+		run:      vc.run,
+		parent:   vc,
+		done:     make(chan struct{}),
+	}
+
+	result := make(chan outcome, 1)
+	go func() {
+		panicked := true
+		defer func() {
+			if panicked {
+				result <- outcome{panicked: true, p: recover()}
+			}
+		}()
+
+		err := child.prohibits(c, a)
+		panicked = false
+		result <- outcome{err: err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case o := <-result:
+		if o.panicked {
+			panic(o.p)
+		}
+		return o.err
+	case <-timer.C:
+		child.abandon()
+		return fmt.Errorf("%w: %s after %s", ErrCaveatTimeout, caveatTypeToString(c.CaveatType()), timeout)
+	case <-vc.done:
+		// our own evaluation was abandoned
+		child.abandon()
+		return fmt.Errorf("%w: %s abandoned", ErrCaveatTimeout, caveatTypeToString(c.CaveatType()))
+	}
+}
+
+// abandon discards the context's subsequent changes and closes its Done
+// channel.
+func (vc *ValidationContext) abandon() {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	vc.abandoned = true
+	close(vc.done)
+}
+
+// ContextualCaveat is implemented by caveats that need access to the
+// [ValidationContext] during evaluation. When validating, ProhibitsWithContext
+// is called in place of Prohibits.
+type ContextualCaveat interface {
+	Caveat
+
+	ProhibitsWithContext(vc *ValidationContext, a Access) error
+}
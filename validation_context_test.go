@@ -0,0 +1,130 @@
+package macaroon
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+type batchLimitKey struct{}
+
+// testCaveatBatchLimit limits the number of accesses allowed in a single
+// validation call.
+type testCaveatBatchLimit struct {
+	Max int
+}
+
+func (c *testCaveatBatchLimit) CaveatType() CaveatType { return CavUnregistered }
+func (c *testCaveatBatchLimit) IsAttestation() bool    { return false }
+
+func (c *testCaveatBatchLimit) Prohibits(f Access) error {
+	return c.ProhibitsWithContext(newValidationContext(f), f)
+}
+
+func (c *testCaveatBatchLimit) ProhibitsWithContext(vc *ValidationContext, f Access) error {
+	n, _ := vc.Get(batchLimitKey{})
+	count, _ := n.(int)
+	count++
+	vc.Set(batchLimitKey{}, count)
+
+	if count > c.Max {
+		return fmt.Errorf("%w: batch limited to %d accesses", ErrUnauthorized, c.Max)
+	}
+	return nil
+}
+
+func TestValidateAll(t *testing.T) {
+	var (
+		cs       = NewCaveatSet(&testCaveatBatchLimit{Max: 2})
+		accesses = []*testAccess{
+			{action: ActionRead},
+			{action: ActionRead},
+			{action: ActionRead},
+		}
+	)
+
+	// each access is evaluated in isolation
+	assert.NoError(t, Validate(cs, accesses...))
+
+	// accesses share state
+	assert.NoError(t, ValidateAll(cs, accesses[:2]...))
+	assert.True(t, errors.Is(ValidateAll(cs, accesses...), ErrUnauthorized))
+
+	// nested caveats also get the shared context
+	cs = NewCaveatSet(&IfPresent{Ifs: NewCaveatSet(&testCaveatBatchLimit{Max: 1})})
+	assert.NoError(t, cs.ValidateAll(accesses[0]))
+	assert.True(t, errors.Is(cs.ValidateAll(accesses[0], accesses[1]), ErrUnauthorized))
+}
+
+func TestValidationContext(t *testing.T) {
+	vc := newValidationContext()
+
+	_, ok := vc.Get("foo")
+	assert.False(t, ok)
+
+	vc.Set("foo", 1)
+	v, ok := vc.Get("foo")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+}
+
+type countingLabelsPart struct {
+	labels map[string]string
+	calls  *int
+}
+
+func (p countingLabelsPart) GetLabels() (map[string]string, bool) {
+	*p.calls++
+	return p.labels, true
+}
+
+// valueAccess is a comparable Access type that may hold uncomparable values.
+type valueAccess struct{ X any }
+
+func (valueAccess) GetAction() Action { return ActionRead }
+func (valueAccess) Now() time.Time    { return time.Now() }
+func (valueAccess) Validate() error   { return nil }
+
+func TestMemoize(t *testing.T) {
+	var (
+		calls = 0
+		a     = &testAccess{action: ActionRead}
+		b     = &testAccess{action: ActionRead}
+		vc    = newValidationContext(a, b)
+		fn    = func() int { calls++; return calls }
+	)
+
+	assert.Equal(t, 1, Memoize(vc, a, "k", fn))
+	assert.Equal(t, 1, Memoize(vc, a, "k", fn))
+	assert.Equal(t, 2, Memoize(vc, b, "k", fn))
+	assert.Equal(t, 3, Memoize(vc, a, "other", fn))
+
+	// without a context, nothing is memoized
+	assert.Equal(t, 4, Memoize(nil, a, "k", fn))
+	assert.Equal(t, 5, Memoize(nil, a, "k", fn))
+
+	// nor for Access values, which may not be hashable
+	v := valueAccess{X: []int{1}}
+	assert.Equal(t, 6, Memoize(vc, v, "k", fn))
+	assert.Equal(t, 7, Memoize(vc, v, "k", fn))
+
+	// several Labels caveats share a lookup of the access's labels
+	calls = 0
+	cs := NewCaveatSet(
+		&Labels{Selectors: []string{"env=prod"}, Action: ActionAll},
+		&Labels{Selectors: []string{"team=web"}, Action: ActionAll},
+		&Labels{Selectors: []string{"env=prod,team=web"}, Action: ActionRead},
+	)
+	labeled := func() Access {
+		return Compose(&testAccess{action: ActionRead}, countingLabelsPart{map[string]string{"env": "prod", "team": "web"}, &calls})
+	}
+
+	assert.NoError(t, cs.Validate(labeled()))
+	assert.Equal(t, 1, calls)
+
+	assert.NoError(t, ValidateAll(cs, labeled(), labeled()))
+	assert.Equal(t, 3, calls)
+}
@@ -0,0 +1,206 @@
+package macaroon
+
+import (
+	"sync"
+	"time"
+)
+
+// Validator validates caveat sets against accesses, applying a set of
+// options. The zero value is usable and behaves like [Validate].
+type Validator struct {
+	now         time.Time
+	budget      int
+	parallelism int
+	warn        func(Warning)
+	values      map[any]any
+	timeout     time.Duration
+	subs        []subscription
+	shadow      map[CaveatType]bool
+}
+
+// ValidationOption configures a [Validator].
+type ValidationOption func(*Validator)
+
+// NewValidator creates a Validator with the specified options.
+func NewValidator(opts ...ValidationOption) *Validator {
+	v := new(Validator)
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// WithNow pins the evaluation time used by time-sensitive caveats (e.g.
+// [ValidityWindow]), overriding [Access.Now]. This is useful for replaying
+// historical requests during audits and for deterministic tests. Because
+// [Macaroon.Verify] returns the caveats from discharge tokens along with those
+// from the permission token, the pinned time applies to discharges' validity
+// windows as well.
+func WithNow(t time.Time) ValidationOption {
+	return func(v *Validator) { v.now = t }
+}
+
+// WithBudget limits the total cost of the caveat evaluations performed by a
+// single call to the Validator, across all the accesses in the call. Once the
+// budget is exhausted, validation fails with ErrBudgetExceeded. This prevents
+// untrusted attenuators from stuffing tokens with pathologically expensive
+// caveats. See [CaveatCost].
+func WithBudget(budget int) ValidationOption {
+	return func(v *Validator) { v.budget = budget }
+}
+
+// WithParallelism evaluates the caveats in large caveat sets concurrently,
+// using up to n goroutines per access. Errors are aggregated in the same order
+// as they would be by sequential evaluation. This is meant for tokens with
+// hundreds of caveats on latency-sensitive paths; smaller caveat sets are
+// still evaluated sequentially. Caveats that store state in the
+// [ValidationContext] must tolerate being evaluated in any order when this is
+// enabled.
+func WithParallelism(n int) ValidationOption {
+	return func(v *Validator) { v.parallelism = n }
+}
+
+// WithCaveatTimeout limits the wall-clock time spent evaluating each caveat,
+// for caveats that do I/O (e.g. checking revocation lists or rate limits).
+// Caveats that take longer fail with ErrCaveatTimeout. Their evaluation
+// isn't interrupted, but continues in the background with its result and any
+// further changes to the [ValidationContext] discarded. Caveats doing I/O
+// should stop when [ValidationContext.Done] is closed. Panics in caveats are
+// propagated to the caller as usual.
+func WithCaveatTimeout(timeout time.Duration) ValidationOption {
+	return func(v *Validator) { v.timeout = timeout }
+}
+
+// WithContextValue stores a value in each [ValidationContext] created by the
+// Validator, for caveats that take configuration from the verifier (e.g. a
+// hook for checking membership in a set stored elsewhere). As with
+// [ValidationContext.Set], keys should be of an unexported type defined by
+// the caveat implementation, which should provide its own option wrapping
+// this one.
+func WithContextValue(key, value any) ValidationOption {
+	return func(v *Validator) {
+		if v.values == nil {
+			v.values = map[any]any{}
+		}
+		v.values[key] = value
+	}
+}
+
+// Validate checks that the caveat set permits each of the accesses,
+// evaluating each access in isolation. See [Validate].
+func (v *Validator) Validate(cs *CaveatSet, accesses ...Access) error {
+	var (
+		merr error
+		run  = v.newRun()
+	)
+
+	v.warnDeprecated(cs)
+
+	for _, access := range accesses {
+		if ferr := access.Validate(); ferr != nil {
+			merr = appendErrs(merr, ferr)
+			continue
+		}
+
+		merr = appendErrs(merr, cs.validateAccess(v.newContext(cs, run, access), access))
+
+		if run.exhausted() {
+			break
+		}
+	}
+
+	return merr
+}
+
+// ValidateAll checks that the caveat set permits the accesses, sharing a
+// single [ValidationContext] between them. See [ValidateAll].
+func (v *Validator) ValidateAll(cs *CaveatSet, accesses ...Access) error {
+	var (
+		merr error
+		run  = v.newRun()
+		vc   = v.newContext(cs, run, accesses...)
+	)
+
+	v.warnDeprecated(cs)
+
+	for _, access := range accesses {
+		if ferr := access.Validate(); ferr != nil {
+			merr = appendErrs(merr, ferr)
+			continue
+		}
+
+		merr = appendErrs(merr, cs.validateAccess(vc, access))
+
+		if run.exhausted() {
+			break
+		}
+	}
+
+	return merr
+}
+
+func (v *Validator) newRun() *validationRun {
+	return &validationRun{
+		budget:      v.budget,
+		limited:     v.budget > 0,
+		parallelism: v.parallelism,
+		timeout:     v.timeout,
+		subs:        v.subs,
+		shadow:      v.shadow,
+		warn:        v.warn,
+	}
+}
+
+func (v *Validator) newContext(cs *CaveatSet, run *validationRun, accesses ...Access) *ValidationContext {
+	vc := newValidationContext(accesses...)
+	vc.caveats = cs
+	vc.now = v.now
+	vc.run = run
+
+	for k, val := range v.values {
+		vc.Set(k, val)
+	}
+
+	return vc
+}
+
+// validationRun holds state shared by all the contexts created for a single
+// call to a Validator.
+type validationRun struct {
+	budget      int
+	limited     bool
+	parallelism int
+	timeout     time.Duration
+	subs        []subscription
+	shadow      map[CaveatType]bool
+	warn        func(Warning)
+
+	mu sync.Mutex
+}
+
+// charge deducts the cost of evaluating the caveat from the budget.
+func (r *validationRun) charge(c Caveat) error {
+	if !r.limited {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.budget -= CaveatCost(c); r.budget < 0 {
+		return ErrBudgetExceeded
+	}
+
+	return nil
+}
+
+func (r *validationRun) exhausted() bool {
+	if !r.limited {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.budget < 0
+}
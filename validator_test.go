@@ -0,0 +1,156 @@
+package macaroon
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestValidatorWithNow(t *testing.T) {
+	var (
+		key     = NewSigningKey()
+		ka      = NewEncryptionKey()
+		authLoc = "https://auth"
+		then    = time.Now().Add(-24 * time.Hour)
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(&ValidityWindow{NotBefore: then.Add(-time.Minute).Unix(), NotAfter: then.Add(time.Minute).Unix()}))
+	assert.NoError(t, m.Add3P(ka, authLoc))
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	found, _, dm, err := dischargeMacaroon(ka, authLoc, buf)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.NoError(t, dm.Add(&ValidityWindow{NotBefore: then.Add(-time.Hour).Unix(), NotAfter: then.Add(time.Hour).Unix()}))
+	dbuf, err := dm.Encode()
+	assert.NoError(t, err)
+
+	cavs, err := m.Verify(key, [][]byte{dbuf}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(GetCaveats[*ValidityWindow](cavs)))
+
+	access := &testAccess{action: ActionRead}
+
+	// token expired a day ago
+	assert.Error(t, cavs.Validate(access))
+
+	// valid at pinned time
+	v := NewValidator(WithNow(then))
+	assert.NoError(t, v.Validate(cavs, access))
+	assert.NoError(t, v.ValidateAll(cavs, access, access))
+
+	// discharge window is also evaluated at pinned time
+	var (
+		rootWindow      = NewCaveatSet(cavs.Caveats[0])
+		dischargeWindow = NewCaveatSet(cavs.Caveats[1])
+	)
+
+	v = NewValidator(WithNow(then.Add(30 * time.Minute)))
+	assert.Error(t, v.Validate(rootWindow, access))
+	assert.NoError(t, v.Validate(dischargeWindow, access))
+
+	v = NewValidator(WithNow(then.Add(2 * time.Hour)))
+	assert.Error(t, v.Validate(dischargeWindow, access))
+}
+
+func TestValidatorWithParallelism(t *testing.T) {
+	var (
+		seq = NewValidator()
+		par = NewValidator(WithParallelism(8))
+		cs  = new(CaveatSet)
+	)
+
+	for i := uint64(0); i < 2*minParallelCaveats; i++ {
+		cs.Caveats = append(cs.Caveats, cavParent(ActionRead|ActionWrite, i%4), &ValidityWindow{NotAfter: maxTime.Unix()})
+	}
+
+	access := &testAccess{action: ActionRead, parentResource: ptr(uint64(1))}
+	assert.True(t, errors.Is(par.Validate(cs, access), ErrUnauthorized))
+
+	// errors are aggregated in caveat order
+	assert.Equal(t, seq.Validate(cs, access).Error(), par.Validate(cs, access).Error())
+
+	cs = new(CaveatSet)
+	for i := 0; i < 2*minParallelCaveats; i++ {
+		cs.Caveats = append(cs.Caveats, cavParent(ActionRead, 1))
+	}
+	assert.NoError(t, par.Validate(cs, access))
+	assert.NoError(t, par.ValidateAll(cs, access, access))
+
+	// budgets still apply
+	par = NewValidator(WithParallelism(8), WithBudget(minParallelCaveats))
+	assert.True(t, errors.Is(par.Validate(cs, access), ErrBudgetExceeded))
+}
+
+// slowCaveat takes d to evaluate, allowing everything.
+type slowCaveat struct{ d time.Duration }
+
+func (c *slowCaveat) CaveatType() CaveatType   { return CavUnregistered }
+func (c *slowCaveat) IsAttestation() bool      { return false }
+func (c *slowCaveat) Prohibits(a Access) error { time.Sleep(c.d); return nil }
+
+func TestValidatorWithCaveatTimeout(t *testing.T) {
+	var (
+		v      = NewValidator(WithCaveatTimeout(50 * time.Millisecond))
+		access = &testAccess{action: ActionRead, parentResource: ptr(uint64(1))}
+	)
+
+	assert.NoError(t, v.Validate(NewCaveatSet(cavParent(ActionRead, 1), &slowCaveat{time.Millisecond}), access))
+	assert.Error(t, v.Validate(NewCaveatSet(cavParent(ActionRead, 2), &slowCaveat{time.Millisecond}), access))
+
+	start := time.Now()
+	err := v.Validate(NewCaveatSet(cavParent(ActionRead, 1), &slowCaveat{time.Second}), access)
+	assert.True(t, errors.Is(err, ErrCaveatTimeout))
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+// blockingCaveat waits for its evaluation to be abandoned, then tries to
+// store a value in the context.
+type blockingCaveat struct{ stopped chan struct{} }
+
+type blockingKey struct{}
+
+func (c *blockingCaveat) CaveatType() CaveatType   { return CavUnregistered }
+func (c *blockingCaveat) IsAttestation() bool      { return false }
+func (c *blockingCaveat) Prohibits(a Access) error { return nil }
+
+func (c *blockingCaveat) ProhibitsWithContext(vc *ValidationContext, a Access) error {
+	<-vc.Done()
+	vc.Set(blockingKey{}, true)
+	Memoize(vc, a, blockingKey{}, func() bool { return true })
+	close(c.stopped)
+	return nil
+}
+
+// panickingCaveat panics when evaluated.
+type panickingCaveat struct{}
+
+func (c *panickingCaveat) CaveatType() CaveatType   { return CavUnregistered }
+func (c *panickingCaveat) IsAttestation() bool      { return false }
+func (c *panickingCaveat) Prohibits(a Access) error { panic("boom") }
+
+func TestCaveatTimeoutAbandonment(t *testing.T) {
+	access := &testAccess{action: ActionRead, parentResource: ptr(uint64(1))}
+
+	vc := newValidationContext(access)
+	vc.run.timeout = 10 * time.Millisecond
+
+	c := &blockingCaveat{stopped: make(chan struct{})}
+	assert.True(t, errors.Is(vc.Prohibits(c, access), ErrCaveatTimeout))
+
+	// the abandoned caveat sees Done closed, and its changes are discarded
+	<-c.stopped
+	_, ok := vc.Get(blockingKey{})
+	assert.False(t, ok)
+	assert.Zero(t, len(vc.memo))
+
+	// panics reach the caller rather than crashing the process
+	v := NewValidator(WithCaveatTimeout(time.Second))
+	assert.Panics(t, func() { _ = v.Validate(NewCaveatSet(&panickingCaveat{}), access) })
+}
@@ -0,0 +1,150 @@
+// Package vaulttransit implements a [macaroon.Sealer] backed by HashiCorp
+// Vault's transit secrets engine, so that the keys shared between a token
+// issuer and a third-party discharge service never leave Vault.
+//
+// The issuer adds third-party caveats with [macaroon.Macaroon.Add3PWithSealer]
+// and the discharge service recovers tickets with
+// [macaroon.DischargeCIDWithSealer], both using a Sealer configured with the
+// same transit key.
+package vaulttransit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/superfly/macaroon"
+)
+
+const defaultMount = "transit"
+
+// Sealer seals and unseals data with a named Vault transit key.
+type Sealer struct {
+	// Addr is the base address of the Vault server, e.g.
+	// "https://vault.internal:8200".
+	Addr string
+
+	// Token is the Vault token used to authenticate requests.
+	Token string
+
+	// Mount is the path at which the transit engine is mounted. Defaults to
+	// "transit".
+	Mount string
+
+	// KeyName is the name of the transit key.
+	KeyName string
+
+	// Client is the HTTP client used to talk to Vault. Defaults to
+	// http.DefaultClient.
+	Client *http.Client
+}
+
+var _ macaroon.IdentifiedSealer = (*Sealer)(nil)
+
+// ID implements [macaroon.IdentifiedSealer], identifying the transit key by
+// the server, mount and key name.
+func (s *Sealer) ID() []byte {
+	return []byte("vault-transit:" + s.url("keys"))
+}
+
+// Seal implements [macaroon.Sealer].
+func (s *Sealer) Seal(plaintext []byte) ([]byte, error) {
+	var resp struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+
+	req := map[string]string{"plaintext": base64.StdEncoding.EncodeToString(plaintext)}
+	if err := s.do("encrypt", req, &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Ciphertext == "" {
+		return nil, fmt.Errorf("vault transit encrypt: missing ciphertext")
+	}
+
+	return []byte(resp.Ciphertext), nil
+}
+
+// Unseal implements [macaroon.Sealer].
+func (s *Sealer) Unseal(ciphertext []byte) ([]byte, error) {
+	var resp struct {
+		Plaintext string `json:"plaintext"`
+	}
+
+	req := map[string]string{"ciphertext": string(ciphertext)}
+	if err := s.do("decrypt", req, &resp); err != nil {
+		return nil, err
+	}
+
+	pt, err := base64.StdEncoding.DecodeString(resp.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("vault transit decrypt: %w", err)
+	}
+
+	return pt, nil
+}
+
+// url returns the URL of the transit endpoint for the operation on the key.
+func (s *Sealer) url(op string) string {
+	mount := s.Mount
+	if mount == "" {
+		mount = defaultMount
+	}
+
+	return fmt.Sprintf("%s/v1/%s/%s/%s",
+		strings.TrimSuffix(s.Addr, "/"),
+		strings.Trim(mount, "/"),
+		op,
+		url.PathEscape(s.KeyName),
+	)
+}
+
+func (s *Sealer) do(op string, reqBody any, respData any) error {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	u := s.url(op)
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return fmt.Errorf("vault transit %s: %w", op, err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("vault transit %s: %w", op, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", s.Token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault transit %s: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	var wrapper struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []string        `json:"errors"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&wrapper); err != nil {
+		return fmt.Errorf("vault transit %s: status %d: %w", op, resp.StatusCode, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("vault transit %s: status %d: %s", op, resp.StatusCode, strings.Join(wrapper.Errors, "; "))
+	}
+
+	if err := json.Unmarshal(wrapper.Data, respData); err != nil {
+		return fmt.Errorf("vault transit %s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package vaulttransit
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+)
+
+// fakeTransit emulates the encrypt/decrypt endpoints of Vault's transit
+// engine for a single key.
+func fakeTransit(t *testing.T, token, keyName string) *httptest.Server {
+	key := macaroon.NewEncryptionKey()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != token {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]any{"errors": []string{"permission denied"}})
+			return
+		}
+
+		var req map[string]string
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		switch r.URL.Path {
+		case "/v1/transit/encrypt/" + keyName:
+			pt, err := base64.StdEncoding.DecodeString(req["plaintext"])
+			assert.NoError(t, err)
+			ct, err := key.Seal(pt)
+			assert.NoError(t, err)
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{
+				"ciphertext": "vault:v1:" + base64.StdEncoding.EncodeToString(ct),
+			}})
+		case "/v1/transit/decrypt/" + keyName:
+			ct, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(req["ciphertext"], "vault:v1:"))
+			assert.NoError(t, err)
+			pt, err := key.Unseal(ct)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]any{"errors": []string{err.Error()}})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{
+				"plaintext": base64.StdEncoding.EncodeToString(pt),
+			}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]any{"errors": []string{}})
+		}
+	}))
+}
+
+func TestSealer(t *testing.T) {
+	srv := fakeTransit(t, "tok", "auth")
+	defer srv.Close()
+
+	var (
+		sealer  = &Sealer{Addr: srv.URL, Token: "tok", KeyName: "auth"}
+		key     = macaroon.NewSigningKey()
+		authLoc = "https://auth"
+	)
+
+	ct, err := sealer.Seal([]byte("hello"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(ct), "vault:v1:"))
+
+	pt, err := sealer.Unseal(ct)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello"), pt)
+
+	assert.Equal(t, "vault-transit:"+srv.URL+"/v1/transit/keys/auth", string(sealer.ID()))
+	assert.NotEqual(t, sealer.ID(), (&Sealer{Addr: srv.URL, KeyName: "other"}).ID())
+
+	_, err = (&Sealer{Addr: srv.URL, Token: "bad", KeyName: "auth"}).Seal([]byte("hello"))
+	assert.Error(t, err)
+
+	_, err = sealer.Unseal([]byte("vault:v1:AAAAAAAAAAAAAAAAAAAAAAAAAAAA"))
+	assert.Error(t, err)
+
+	m, err := macaroon.New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add3PWithSealer(sealer, authLoc))
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	cid, err := macaroon.ThirdPartyCID(buf, authLoc)
+	assert.NoError(t, err)
+
+	_, dm, err := macaroon.DischargeCIDWithSealer(sealer, authLoc, cid)
+	assert.NoError(t, err)
+	dBuf, err := dm.Encode()
+	assert.NoError(t, err)
+
+	_, err = m.Verify(key, [][]byte{dBuf}, nil)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,189 @@
+package macaroon
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+// testdata/vectors.json is shared with the edge package, which checks that
+// its verifier agrees with this one. Tokens have random nonces, so the
+// vectors are regenerated only on request.
+var updateVectors = flag.Bool("update-vectors", false, "regenerate testdata/vectors.json")
+
+const vectorsPath = "testdata/vectors.json"
+
+type testVector struct {
+	Name       string             `json:"name"`
+	Key        string             `json:"key"`
+	Token      string             `json:"token"`
+	Discharges []string           `json:"discharges,omitempty"`
+	Valid      bool               `json:"valid"`
+	Caveats    []testVectorCaveat `json:"caveats,omitempty"`
+}
+
+// testVectorCaveat is a verified caveat, with Encoded being the encoding of a
+// caveat set containing only it.
+type testVectorCaveat struct {
+	Type    CaveatType `json:"type"`
+	Encoded string     `json:"encoded"`
+}
+
+func TestVectors(t *testing.T) {
+	if *updateVectors {
+		buf, err := json.MarshalIndent(makeTestVectors(t), "", "\t")
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(vectorsPath, append(buf, '\n'), 0o644))
+	}
+
+	buf, err := os.ReadFile(vectorsPath)
+	assert.NoError(t, err)
+
+	var vectors []testVector
+	assert.NoError(t, json.Unmarshal(buf, &vectors))
+	assert.NotEqual(t, 0, len(vectors))
+
+	for _, v := range vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			key, err := hex.DecodeString(v.Key)
+			assert.NoError(t, err)
+
+			tok, err := base64.StdEncoding.DecodeString(v.Token)
+			assert.NoError(t, err)
+
+			var discharges [][]byte
+			for _, d := range v.Discharges {
+				buf, err := base64.StdEncoding.DecodeString(d)
+				assert.NoError(t, err)
+				discharges = append(discharges, buf)
+			}
+
+			cavs, err := verifyTestVector(key, tok, discharges)
+			if !v.Valid {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, v.Caveats, cavs)
+		})
+	}
+}
+
+func verifyTestVector(key, tok []byte, discharges [][]byte) ([]testVectorCaveat, error) {
+	m, err := Decode(tok)
+	if err != nil {
+		return nil, err
+	}
+
+	cs, err := m.Verify(key, discharges, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []testVectorCaveat
+	for _, cav := range cs.Caveats {
+		buf, err := NewCaveatSet(cav).MarshalMsgpack()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, testVectorCaveat{cav.CaveatType(), hex.EncodeToString(buf)})
+	}
+	return ret, nil
+}
+
+func makeTestVectors(t *testing.T) []testVector {
+	t.Helper()
+
+	var (
+		kid      = []byte("vector-kid")
+		key      = SigningKey(bytes.Repeat([]byte{0x11}, 32))
+		otherKey = SigningKey(bytes.Repeat([]byte{0x22}, 32))
+		ka       = EncryptionKey(bytes.Repeat([]byte{0x33}, EncryptionKeySize))
+		vw       = &ValidityWindow{NotBefore: 1700000000, NotAfter: 4100000000}
+	)
+
+	labels, err := NewLabels(ActionRead, "env=prod,tier!=db")
+	assert.NoError(t, err)
+
+	encode := func(m *Macaroon) []byte {
+		buf, err := m.Encode()
+		assert.NoError(t, err)
+		return buf
+	}
+
+	mint := func(cavs ...Caveat) *Macaroon {
+		m, err := New(kid, "https://vectors.example", key)
+		assert.NoError(t, err)
+		assert.NoError(t, m.Add(cavs...))
+		return m
+	}
+
+	discharge := func(m *Macaroon, parent []byte, cavs ...Caveat) []byte {
+		cid, err := m.ThirdPartyCID("https://3p.example")
+		assert.NoError(t, err)
+		_, dm, err := DischargeCID(ka, "https://3p.example", cid)
+		assert.NoError(t, err)
+		assert.NoError(t, dm.Add(cavs...))
+		assert.NoError(t, dm.Bind(parent))
+		return encode(dm)
+	}
+
+	var vectors []testVector
+	add := func(name string, k SigningKey, tok []byte, discharges ...[]byte) {
+		v := testVector{
+			Name:  name,
+			Key:   hex.EncodeToString(k),
+			Token: base64.StdEncoding.EncodeToString(tok),
+		}
+		for _, d := range discharges {
+			v.Discharges = append(v.Discharges, base64.StdEncoding.EncodeToString(d))
+		}
+
+		cavs, err := verifyTestVector(k, tok, discharges)
+		v.Valid, v.Caveats = err == nil, cavs
+		vectors = append(vectors, v)
+	}
+
+	plain := encode(mint(vw, labels, &VerifierPin{Hostnames: []string{"api.example"}}))
+	add("first-party", key, plain)
+	add("wrong key", otherKey, plain)
+
+	tampered := append([]byte{}, plain...)
+	tampered[len(tampered)-1] ^= 1
+	add("tampered tail", key, tampered)
+
+	attenuated, err := Decode(plain)
+	assert.NoError(t, err)
+	assert.NoError(t, attenuated.Add(&ValidityWindow{NotBefore: 1700000000, NotAfter: 4000000000}))
+	add("attenuated", key, encode(attenuated))
+
+	sealed := mint(vw)
+	assert.NoError(t, sealed.SealIssuerCaveats(key))
+	assert.NoError(t, sealed.Add(labels))
+	add("issuer seal", key, encode(sealed))
+
+	forged := mint(vw)
+	assert.NoError(t, forged.SealIssuerCaveats(otherKey))
+	add("forged issuer seal", key, encode(forged))
+
+	m3p := mint(vw)
+	assert.NoError(t, m3p.Add3P(ka, "https://3p.example"))
+	tok3p := encode(m3p)
+	add("third-party", key, tok3p, discharge(m3p, tok3p, labels))
+	add("missing discharge", key, tok3p)
+	add("discharge bound to other token", key, tok3p, discharge(m3p, plain, labels))
+	add("untrusted discharge attestation", key, tok3p, discharge(m3p, tok3p, &ParentToken{UUID: "vector"}))
+
+	proof, err := newMacaroon(kid, "https://vectors.example", key, true)
+	assert.NoError(t, err)
+	assert.NoError(t, proof.Add(vw, &ParentToken{UUID: "vector"}))
+	add("proof attestation", key, encode(proof))
+
+	return vectors
+}
@@ -0,0 +1,55 @@
+package macaroon
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/superfly/macaroon/access"
+)
+
+// VerifierPin is a caveat restricting which instances of the service may
+// accept the token, e.g. for break-glass credentials that must only be
+// honored by a specific management host. The verifying instance, as reported
+// by an Access implementing [access.Verifier], must match one of Hostnames
+// (compared case-insensitively) or one of MachineIDs.
+type VerifierPin struct {
+	Hostnames  []string `json:"hostnames,omitempty"`
+	MachineIDs []string `json:"machine_ids,omitempty"`
+}
+
+func init() { RegisterCaveatType("VerifierPin", CavVerifierPin, &VerifierPin{}) }
+
+func (c *VerifierPin) CaveatType() CaveatType { return CavVerifierPin }
+func (c *VerifierPin) IsAttestation() bool    { return false }
+
+func (c *VerifierPin) Prohibits(f Access) error {
+	v, ok := access.As[access.Verifier](f)
+	if !ok {
+		return fmt.Errorf("%w: verifier unknown", ErrInvalidAccess)
+	}
+
+	hostname, machineID := v.GetVerifier()
+	hostname = normalizeHostname(hostname)
+
+	if hostname != "" {
+		for _, h := range c.Hostnames {
+			if normalizeHostname(h) == hostname {
+				return nil
+			}
+		}
+	}
+
+	if machineID != "" {
+		for _, id := range c.MachineIDs {
+			if id == machineID {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: token not valid on this host", ErrUnauthorized)
+}
+
+func normalizeHostname(h string) string {
+	return strings.ToLower(strings.TrimSuffix(h, "."))
+}
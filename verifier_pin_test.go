@@ -0,0 +1,40 @@
+package macaroon
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+type verifierPart [2]string
+
+func (p verifierPart) GetVerifier() (string, string) { return p[0], p[1] }
+
+func TestVerifierPin(t *testing.T) {
+	key := NewSigningKey()
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(cavParent(ActionRead, 1), &VerifierPin{
+		Hostnames:  []string{"mgmt1.internal"},
+		MachineIDs: []string{"e2865013"},
+	}))
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	decoded, err := Decode(buf)
+	assert.NoError(t, err)
+	cs, err := decoded.Verify(key, nil, nil)
+	assert.NoError(t, err)
+
+	a := &testAccess{action: ActionRead, parentResource: ptr(uint64(1))}
+
+	assert.NoError(t, cs.Validate(Compose(a, verifierPart{"mgmt1.internal", ""})))
+	assert.NoError(t, cs.Validate(Compose(a, verifierPart{"MGMT1.internal.", ""})))
+	assert.NoError(t, cs.Validate(Compose(a, verifierPart{"other", "e2865013"})))
+
+	assert.True(t, errors.Is(cs.Validate(Compose(a, verifierPart{"mgmt2.internal", "3d8d9e7c"})), ErrUnauthorized))
+	assert.True(t, errors.Is(cs.Validate(Compose(a, verifierPart{"", ""})), ErrUnauthorized))
+	assert.True(t, errors.Is(cs.Validate(a), ErrInvalidAccess))
+}
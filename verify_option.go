@@ -0,0 +1,218 @@
+package macaroon
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// VerifyOption configures [Macaroon.Verify].
+type VerifyOption func(*verifyOptions)
+
+type verifyOptions struct {
+	// normalized alias location -> normalized canonical location
+	aliases map[string]string
+
+	// see WithChannelBinding
+	channelBinding []byte
+
+	// see WithMaxCaveats, WithMaxDischargeAge, WithAllowed3PLocations and
+	// WithClockSkew
+	maxCaveats      int
+	maxDischargeAge time.Duration
+	allowed3Ps      map[string]bool
+	clockSkew       time.Duration
+
+	// see WithRequireParentBinding
+	requireParentBinding bool
+
+	// see WithAttestationMaxAge
+	attestationMaxAge map[CaveatType]time.Duration
+
+	// see WithStatsReporter
+	stats StatsReporter
+
+	// see WithTrusted3Ps
+	trusted Trusted3Ps
+
+	// see WithVerifyNow
+	now time.Time
+
+	// set by options that can't be applied, e.g. unknown profiles
+	err error
+}
+
+func newVerifyOptions(opts ...VerifyOption) *verifyOptions {
+	o := new(verifyOptions)
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithLocationAliases declares sets of equivalent third-party locations, to
+// support service renames and split-horizon deployments. The map is keyed by
+// canonical location, with the alternate locations for each.
+//
+// Discharges are matched to third-party caveats by their tickets, but a
+// discharge's attestations are only trusted if the key for its location is
+// among the trusted third parties passed to Verify. With aliases, a
+// discharge minted under any of the equivalent locations (e.g.
+// "https://auth.internal") is trusted using the key configured for any other
+// (e.g. "https://auth.fly.io").
+func WithLocationAliases(aliases map[string][]string) VerifyOption {
+	return func(o *verifyOptions) {
+		if o.aliases == nil {
+			o.aliases = map[string]string{}
+		}
+
+		for canonical, alts := range aliases {
+			canonical = NormalizeLocation(canonical)
+			o.aliases[canonical] = canonical
+			for _, alt := range alts {
+				o.aliases[NormalizeLocation(alt)] = canonical
+			}
+		}
+	}
+}
+
+// WithMaxCaveats limits the number of caveats in the token and its
+// discharges, bounding the work done verifying and validating hostile tokens.
+func WithMaxCaveats(n int) VerifyOption {
+	return func(o *verifyOptions) { o.maxCaveats = n }
+}
+
+// WithMaxDischargeAge requires discharges to have been issued within maxAge,
+// as determined by the latest NotBefore of their ValidityWindow caveats.
+// Discharges without a ValidityWindow are rejected.
+func WithMaxDischargeAge(maxAge time.Duration) VerifyOption {
+	return func(o *verifyOptions) { o.maxDischargeAge = maxAge }
+}
+
+// WithAllowed3PLocations rejects tokens with third-party caveats for
+// locations other than those specified, taking location aliases into
+// account.
+func WithAllowed3PLocations(locations ...string) VerifyOption {
+	return func(o *verifyOptions) {
+		o.allowed3Ps = make(map[string]bool, len(locations))
+		for _, loc := range locations {
+			o.allowed3Ps[NormalizeLocation(loc)] = true
+		}
+	}
+}
+
+// WithClockSkew tolerates differences of up to skew between our clock and
+// those of the third parties issuing discharges when checking discharge age.
+// See [WithMaxDischargeAge].
+func WithClockSkew(skew time.Duration) VerifyOption {
+	return func(o *verifyOptions) { o.clockSkew = skew }
+}
+
+// WithRequireParentBinding rejects discharges that aren't bound to the token
+// being verified with a [BindToParentToken] caveat (see [Macaroon.Bind]).
+// Without it, unbound discharges are accepted for compatibility, though they
+// can be used with any token having a matching third-party caveat.
+func WithRequireParentBinding() VerifyOption {
+	return func(o *verifyOptions) { o.requireParentBinding = true }
+}
+
+// WithVerifyNow pins the time used by the time-sensitive checks done during
+// verification (e.g. [WithMaxDischargeAge] and [WithAttestationMaxAge]),
+// overriding the current time. Use it along with [WithNow] when replaying
+// historical requests, so that verification and validation agree.
+func WithVerifyNow(t time.Time) VerifyOption {
+	return func(o *verifyOptions) { o.now = t }
+}
+
+// clock returns the time pinned with WithVerifyNow, or the current time.
+func (o *verifyOptions) clock() time.Time {
+	if o.now.IsZero() {
+		return time.Now()
+	}
+	return o.now
+}
+
+func (o *verifyOptions) checkCaveatCount(n int) error {
+	if o.maxCaveats > 0 && n > o.maxCaveats {
+		return fmt.Errorf("macaroon verify: too many caveats (%d > %d)", n, o.maxCaveats)
+	}
+	return nil
+}
+
+func (o *verifyOptions) check3PLocation(loc string) error {
+	if o.allowed3Ps == nil {
+		return nil
+	}
+
+	canonical := o.canonicalLocation(loc)
+	for allowed := range o.allowed3Ps {
+		if o.canonicalLocation(allowed) == canonical {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("macaroon verify: third-party location %s not allowed", loc)
+}
+
+func (o *verifyOptions) checkDischargeAge(dcavs *CaveatSet, now time.Time) error {
+	if o.maxDischargeAge <= 0 {
+		return nil
+	}
+
+	var issued int64
+	for _, vw := range GetCaveats[*ValidityWindow](dcavs) {
+		if vw.NotBefore > issued {
+			issued = vw.NotBefore
+		}
+	}
+
+	switch age := now.Sub(time.Unix(issued, 0)); {
+	case issued == 0:
+		return errors.New("discharge has no issue time")
+	case age < -o.clockSkew:
+		return errors.New("discharge issued in the future")
+	case age > o.maxDischargeAge+o.clockSkew:
+		return fmt.Errorf("discharge too old (issued %s ago)", age.Truncate(time.Second))
+	default:
+		return nil
+	}
+}
+
+// canonicalLocation resolves location aliases.
+func (o *verifyOptions) canonicalLocation(loc string) string {
+	norm := NormalizeLocation(loc)
+	if canonical, ok := o.aliases[norm]; ok {
+		return canonical
+	}
+	return norm
+}
+
+// trusted3PKeys finds the keys for a discharge's location among the trusted
+// third parties passed to Verify and WithTrusted3Ps, taking aliases into
+// account.
+func (o *verifyOptions) trusted3PKeys(trusted3Ps map[string]EncryptionKey, loc string) []Sealer {
+	var ret []Sealer
+	if ka, ok := lookupTrusted3P(o, trusted3Ps, loc); ok {
+		ret = append(ret, ka)
+	}
+	if keys, ok := lookupTrusted3P(o, o.trusted, loc); ok {
+		ret = append(ret, keys...)
+	}
+	return ret
+}
+
+func lookupTrusted3P[T any](o *verifyOptions, trusted map[string]T, loc string) (T, bool) {
+	if v, ok := lookupLocation(trusted, loc); ok || len(o.aliases) == 0 {
+		return v, ok
+	}
+
+	canonical := o.canonicalLocation(loc)
+	for tloc, v := range trusted {
+		if o.canonicalLocation(tloc) == canonical {
+			return v, true
+		}
+	}
+
+	var zero T
+	return zero, false
+}
@@ -0,0 +1,109 @@
+package macaroon
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+type testCaveatAttestation struct{ Name string }
+
+func init() { RegisterCaveatType("TestAttestation", cavTestAttestation, &testCaveatAttestation{}) }
+
+func (c *testCaveatAttestation) CaveatType() CaveatType   { return cavTestAttestation }
+func (c *testCaveatAttestation) Prohibits(f Access) error { return nil }
+func (c *testCaveatAttestation) IsAttestation() bool      { return true }
+
+func TestWithLocationAliases(t *testing.T) {
+	var (
+		key = NewSigningKey()
+		ka  = NewEncryptionKey()
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add3P(ka, "https://auth.fly.io"))
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	// discharge minted by the renamed service
+	cid, err := m.ThirdPartyCID("https://auth.fly.io")
+	assert.NoError(t, err)
+	_, dm, err := DischargeCID(ka, "https://auth.internal", cid)
+	assert.NoError(t, err)
+	assert.NoError(t, dm.Add(&testCaveatAttestation{"bob"}))
+	dbuf, err := dm.Encode()
+	assert.NoError(t, err)
+
+	var (
+		trusted = map[string]EncryptionKey{"https://auth.fly.io": ka}
+		aliases = map[string][]string{"https://auth.fly.io": {"https://auth.internal/"}}
+	)
+
+	decoded, err := Decode(buf)
+	assert.NoError(t, err)
+
+	// without aliases, the attestation isn't trusted
+	cavs, err := decoded.Verify(key, [][]byte{dbuf}, trusted)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(GetCaveats[*testCaveatAttestation](cavs)))
+
+	cavs, err = decoded.Verify(key, [][]byte{dbuf}, trusted, WithLocationAliases(aliases))
+	assert.NoError(t, err)
+	assert.Equal(t, []*testCaveatAttestation{{"bob"}}, GetCaveats[*testCaveatAttestation](cavs))
+
+	// aliases work in either direction
+	cavs, err = decoded.Verify(key, [][]byte{dbuf}, map[string]EncryptionKey{"https://auth.internal": ka}, WithLocationAliases(aliases))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(GetCaveats[*testCaveatAttestation](cavs)))
+
+	// the aliased key still has to match
+	_, err = decoded.Verify(key, [][]byte{dbuf}, map[string]EncryptionKey{"https://auth.fly.io": NewEncryptionKey()}, WithLocationAliases(aliases))
+	assert.Error(t, err)
+}
+
+func TestWithRequireParentBinding(t *testing.T) {
+	var (
+		key = NewSigningKey()
+		ka  = NewEncryptionKey()
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add3P(ka, "https://auth"))
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	_, _, unbound, err := dischargeMacaroon(ka, "https://auth", buf)
+	assert.NoError(t, err)
+	unboundBuf, err := unbound.Encode()
+	assert.NoError(t, err)
+
+	_, _, bound, err := dischargeMacaroon(ka, "https://auth", buf)
+	assert.NoError(t, err)
+	assert.NoError(t, bound.Bind(buf))
+	boundBuf, err := bound.Encode()
+	assert.NoError(t, err)
+
+	_, err = m.Verify(key, [][]byte{unboundBuf}, nil)
+	assert.NoError(t, err)
+	_, err = m.Verify(key, [][]byte{unboundBuf}, nil, WithRequireParentBinding())
+	assert.EqualError(t, err, "macaroon verify: verify discharge 0 for https://auth: discharge not bound to parent token")
+
+	_, err = m.Verify(key, [][]byte{boundBuf}, nil, WithRequireParentBinding())
+	assert.NoError(t, err)
+
+	// binding to another token doesn't count
+	other, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, other.Add3P(ka, "https://auth"))
+	otherBuf, err := other.Encode()
+	assert.NoError(t, err)
+	_, _, misbound, err := dischargeMacaroon(ka, "https://auth", buf)
+	assert.NoError(t, err)
+	assert.NoError(t, misbound.Bind(otherBuf))
+	misboundBuf, err := misbound.Encode()
+	assert.NoError(t, err)
+	_, err = m.Verify(key, [][]byte{misboundBuf}, nil, WithRequireParentBinding())
+	assert.Error(t, err)
+}
@@ -0,0 +1,226 @@
+package macaroon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// VerifyProfile is a named bundle of verification options, so a fleet's
+// verification posture can be managed centrally (see [LoadVerifyProfiles])
+// rather than at each call site (see [WithVerifyProfile]). Zero fields impose
+// no restriction.
+type VerifyProfile struct {
+	Name string
+
+	// MaxCaveats limits the number of caveats in a token and its discharges.
+	// See [WithMaxCaveats].
+	MaxCaveats int
+
+	// MaxDischargeAge limits how long ago discharges may have been issued.
+	// See [WithMaxDischargeAge].
+	MaxDischargeAge time.Duration
+
+	// Allowed3PLocations limits the third parties tokens may have caveats
+	// for. See [WithAllowed3PLocations].
+	Allowed3PLocations []string
+
+	// ClockSkew is the tolerated difference between our clock and third
+	// parties'. See [WithClockSkew].
+	ClockSkew time.Duration
+
+	// LocationAliases are passed to [WithLocationAliases].
+	LocationAliases map[string][]string
+
+	// RequireParentBinding rejects unbound discharges. See
+	// [WithRequireParentBinding].
+	RequireParentBinding bool
+}
+
+// Options returns the profile's verification options.
+func (p *VerifyProfile) Options() []VerifyOption {
+	var opts []VerifyOption
+	if p.MaxCaveats > 0 {
+		opts = append(opts, WithMaxCaveats(p.MaxCaveats))
+	}
+	if p.MaxDischargeAge > 0 {
+		opts = append(opts, WithMaxDischargeAge(p.MaxDischargeAge))
+	}
+	if len(p.Allowed3PLocations) != 0 {
+		opts = append(opts, WithAllowed3PLocations(p.Allowed3PLocations...))
+	}
+	if p.ClockSkew > 0 {
+		opts = append(opts, WithClockSkew(p.ClockSkew))
+	}
+	if len(p.LocationAliases) != 0 {
+		opts = append(opts, WithLocationAliases(p.LocationAliases))
+	}
+	if p.RequireParentBinding {
+		opts = append(opts, WithRequireParentBinding())
+	}
+	return opts
+}
+
+type verifyProfileJSON struct {
+	Name               string              `json:"name"`
+	MaxCaveats         int                 `json:"max_caveats,omitempty"`
+	MaxDischargeAge    string              `json:"max_discharge_age,omitempty"`
+	Allowed3PLocations []string            `json:"allowed_3p_locations,omitempty"`
+	ClockSkew          string              `json:"clock_skew,omitempty"`
+	LocationAliases    map[string][]string `json:"location_aliases,omitempty"`
+
+	RequireParentBinding bool `json:"require_parent_binding,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler, formatting durations like "1h30m".
+func (p VerifyProfile) MarshalJSON() ([]byte, error) {
+	pj := verifyProfileJSON{
+		Name:                 p.Name,
+		MaxCaveats:           p.MaxCaveats,
+		Allowed3PLocations:   p.Allowed3PLocations,
+		LocationAliases:      p.LocationAliases,
+		RequireParentBinding: p.RequireParentBinding,
+	}
+	if p.MaxDischargeAge != 0 {
+		pj.MaxDischargeAge = p.MaxDischargeAge.String()
+	}
+	if p.ClockSkew != 0 {
+		pj.ClockSkew = p.ClockSkew.String()
+	}
+	return json.Marshal(pj)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (p *VerifyProfile) UnmarshalJSON(b []byte) error {
+	var pj verifyProfileJSON
+	if err := json.Unmarshal(b, &pj); err != nil {
+		return err
+	}
+
+	ret := VerifyProfile{
+		Name:                 pj.Name,
+		MaxCaveats:           pj.MaxCaveats,
+		Allowed3PLocations:   pj.Allowed3PLocations,
+		LocationAliases:      pj.LocationAliases,
+		RequireParentBinding: pj.RequireParentBinding,
+	}
+
+	var err error
+	if pj.MaxDischargeAge != "" {
+		if ret.MaxDischargeAge, err = time.ParseDuration(pj.MaxDischargeAge); err != nil {
+			return fmt.Errorf("verify profile %q: max_discharge_age: %w", pj.Name, err)
+		}
+	}
+	if pj.ClockSkew != "" {
+		if ret.ClockSkew, err = time.ParseDuration(pj.ClockSkew); err != nil {
+			return fmt.Errorf("verify profile %q: clock_skew: %w", pj.Name, err)
+		}
+	}
+
+	*p = ret
+	return nil
+}
+
+// Built-in profiles. They can be replaced by [RegisterVerifyProfile] or
+// [LoadVerifyProfiles].
+var (
+	// ProfileStrict is for verifying tokens presented by untrusted clients.
+	ProfileStrict = VerifyProfile{
+		Name:                 "strict",
+		MaxCaveats:           64,
+		MaxDischargeAge:      time.Hour,
+		ClockSkew:            30 * time.Second,
+		RequireParentBinding: true,
+	}
+
+	// ProfileInternal is for verifying tokens presented by internal
+	// services, which may carry larger, longer-lived tokens.
+	ProfileInternal = VerifyProfile{
+		Name:            "internal",
+		MaxCaveats:      256,
+		MaxDischargeAge: 24 * time.Hour,
+		ClockSkew:       time.Minute,
+	}
+
+	// ProfileLegacy imposes no restrictions, matching Verify's behavior
+	// without options.
+	ProfileLegacy = VerifyProfile{
+		Name: "legacy",
+	}
+)
+
+var (
+	verifyProfiles   = map[string]VerifyProfile{}
+	verifyProfilesMu sync.RWMutex
+)
+
+func init() {
+	RegisterVerifyProfile(ProfileStrict)
+	RegisterVerifyProfile(ProfileInternal)
+	RegisterVerifyProfile(ProfileLegacy)
+}
+
+// RegisterVerifyProfile registers a profile, replacing any profile with the
+// same name. It's safe to call concurrently with verification, so profiles
+// can be reloaded at runtime.
+func RegisterVerifyProfile(p VerifyProfile) {
+	verifyProfilesMu.Lock()
+	defer verifyProfilesMu.Unlock()
+
+	verifyProfiles[p.Name] = p
+}
+
+// LookupVerifyProfile returns the named profile.
+func LookupVerifyProfile(name string) (VerifyProfile, bool) {
+	verifyProfilesMu.RLock()
+	defer verifyProfilesMu.RUnlock()
+
+	p, ok := verifyProfiles[name]
+	return p, ok
+}
+
+// LoadVerifyProfiles registers the profiles in a JSON array, like
+//
+//	[{"name": "strict", "max_caveats": 32, "max_discharge_age": "15m"}]
+//
+// Either all the profiles are registered or, on error, none are.
+func LoadVerifyProfiles(config []byte) error {
+	var profiles []VerifyProfile
+	if err := json.Unmarshal(config, &profiles); err != nil {
+		return fmt.Errorf("load verify profiles: %w", err)
+	}
+
+	for _, p := range profiles {
+		if p.Name == "" {
+			return errors.New("load verify profiles: profile missing name")
+		}
+	}
+
+	verifyProfilesMu.Lock()
+	defer verifyProfilesMu.Unlock()
+
+	for _, p := range profiles {
+		verifyProfiles[p.Name] = p
+	}
+
+	return nil
+}
+
+// WithVerifyProfile applies the options of the named profile, as registered
+// at the time of verification. Verification fails if there's no such
+// profile.
+func WithVerifyProfile(name string) VerifyOption {
+	return func(o *verifyOptions) {
+		p, ok := LookupVerifyProfile(name)
+		if !ok {
+			o.err = fmt.Errorf("unknown verify profile %q", name)
+			return
+		}
+
+		for _, opt := range p.Options() {
+			opt(o)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package macaroon
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestVerifyProfiles(t *testing.T) {
+	var (
+		key  = NewSigningKey()
+		ka   = NewEncryptionKey()
+		auth = "https://auth"
+	)
+
+	m, err := New([]byte("kid"), "https://api", key)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add(&ValidityWindow{NotBefore: time.Now().Unix(), NotAfter: time.Now().Add(time.Hour).Unix()}))
+	assert.NoError(t, m.Add3P(ka, auth))
+	buf, err := m.Encode()
+	assert.NoError(t, err)
+
+	discharge := func(t *testing.T, issued time.Time) []byte {
+		t.Helper()
+
+		_, _, dm, err := dischargeMacaroon(ka, auth, buf)
+		assert.NoError(t, err)
+		assert.NoError(t, dm.Add(&ValidityWindow{NotBefore: issued.Unix(), NotAfter: issued.Add(48 * time.Hour).Unix()}))
+		dbuf, err := dm.Encode()
+		assert.NoError(t, err)
+		return dbuf
+	}
+
+	var (
+		fresh = discharge(t, time.Now())
+		stale = discharge(t, time.Now().Add(-2*time.Hour))
+	)
+
+	t.Run("options", func(t *testing.T) {
+		_, err := m.Verify(key, [][]byte{fresh}, nil, WithMaxCaveats(2))
+		assert.NoError(t, err)
+		_, err = m.Verify(key, [][]byte{fresh}, nil, WithMaxCaveats(1))
+		assert.EqualError(t, err, "macaroon verify: too many caveats (2 > 1)")
+
+		_, err = m.Verify(key, [][]byte{stale}, nil, WithMaxDischargeAge(3*time.Hour))
+		assert.NoError(t, err)
+		_, err = m.Verify(key, [][]byte{stale}, nil, WithMaxDischargeAge(time.Hour))
+		assert.Error(t, err)
+		_, err = m.Verify(key, [][]byte{stale}, nil, WithMaxDischargeAge(time.Hour), WithClockSkew(2*time.Hour))
+		assert.NoError(t, err)
+		_, err = m.Verify(key, [][]byte{discharge(t, time.Now().Add(time.Hour))}, nil, WithMaxDischargeAge(time.Hour))
+		assert.EqualError(t, err, "macaroon verify: verify discharge 0 for https://auth: discharge issued in the future")
+		_, err = m.Verify(key, [][]byte{stale}, nil, WithMaxDischargeAge(time.Hour), WithVerifyNow(time.Now().Add(-90*time.Minute)))
+		assert.NoError(t, err)
+		_, err = m.Verify(key, [][]byte{fresh}, nil, WithMaxDischargeAge(time.Hour), WithVerifyNow(time.Now().Add(2*time.Hour)))
+		assert.Error(t, err)
+
+		_, err = m.Verify(key, [][]byte{fresh}, nil, WithAllowed3PLocations("https://auth/"))
+		assert.NoError(t, err)
+		_, err = m.Verify(key, [][]byte{fresh}, nil, WithAllowed3PLocations("https://other"))
+		assert.EqualError(t, err, "macaroon verify: third-party location https://auth not allowed")
+		_, err = m.Verify(key, [][]byte{fresh}, nil,
+			WithAllowed3PLocations("https://other"),
+			WithLocationAliases(map[string][]string{"https://other": {auth}}),
+		)
+		assert.NoError(t, err)
+	})
+
+	t.Run("profiles", func(t *testing.T) {
+		_, err := m.Verify(key, [][]byte{stale}, nil, WithVerifyProfile("legacy"))
+		assert.NoError(t, err)
+		_, err = m.Verify(key, [][]byte{stale}, nil, WithVerifyProfile("strict"))
+		assert.Error(t, err)
+		_, err = m.Verify(key, [][]byte{stale}, nil, WithVerifyProfile("internal"))
+		assert.NoError(t, err)
+		_, err = m.Verify(key, [][]byte{fresh}, nil, WithVerifyProfile("bogus"))
+		assert.EqualError(t, err, `macaroon verify: unknown verify profile "bogus"`)
+	})
+
+	t.Run("load", func(t *testing.T) {
+		t.Cleanup(func() { RegisterVerifyProfile(ProfileStrict) })
+
+		assert.NoError(t, LoadVerifyProfiles([]byte(`[
+			{"name": "strict", "max_discharge_age": "3h", "allowed_3p_locations": ["https://other"]},
+			{"name": "tiny", "max_caveats": 1}
+		]`)))
+
+		_, err := m.Verify(key, [][]byte{stale}, nil, WithVerifyProfile("strict"))
+		assert.EqualError(t, err, "macaroon verify: third-party location https://auth not allowed")
+		_, err = m.Verify(key, [][]byte{fresh}, nil, WithVerifyProfile("tiny"))
+		assert.EqualError(t, err, "macaroon verify: too many caveats (2 > 1)")
+
+		p, ok := LookupVerifyProfile("strict")
+		assert.True(t, ok)
+		assert.Equal(t, 3*time.Hour, p.MaxDischargeAge)
+
+		pj, err := json.Marshal(p)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"name":"strict","max_discharge_age":"3h0m0s","allowed_3p_locations":["https://other"]}`, string(pj))
+
+		assert.Error(t, LoadVerifyProfiles([]byte(`[{"name": "bad", "clock_skew": "soon"}]`)))
+		assert.Error(t, LoadVerifyProfiles([]byte(`[{"max_caveats": 1}]`)))
+	})
+}
@@ -0,0 +1,153 @@
+// Package webauthn binds tokens to WebAuthn credentials for high-assurance
+// interactive use. A token with a [Credential] caveat is only usable for
+// requests carrying a fresh WebAuthn assertion made with that credential.
+//
+// This package doesn't verify WebAuthn assertions itself. The service issues
+// single-use challenges (e.g. with [Challenges]) and verifies assertions over
+// them with the WebAuthn library of its choice, exposing the result through
+// an Access implementing [access.WebAuthn].
+package webauthn
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/superfly/macaroon"
+	"github.com/superfly/macaroon/access"
+	"github.com/superfly/macaroon/internal/rnd"
+)
+
+// Credential binds a token to the WebAuthn credential with the specified ID.
+type Credential struct {
+	ID []byte `json:"id"`
+}
+
+func init() {
+	macaroon.RegisterCaveatType("WebAuthn", macaroon.CavWebAuthn, &Credential{})
+}
+
+func (c *Credential) CaveatType() macaroon.CaveatType {
+	return macaroon.CavWebAuthn
+}
+
+func (c *Credential) Prohibits(a macaroon.Access) error {
+	wa, ok := access.As[access.WebAuthn](a)
+	if !ok {
+		return fmt.Errorf("%w: webauthn assertion required", macaroon.ErrInvalidAccess)
+	}
+
+	if err := wa.VerifyWebAuthnAssertion(c.ID); err != nil {
+		return fmt.Errorf("%w: webauthn credential %s: %w", macaroon.ErrUnauthorized, base64.RawURLEncoding.EncodeToString(c.ID), err)
+	}
+
+	return nil
+}
+
+func (c *Credential) IsAttestation() bool { return false }
+
+var (
+	ErrUnknownChallenge = errors.New("webauthn: unknown or reused challenge")
+	ErrExpiredChallenge = errors.New("webauthn: expired challenge")
+)
+
+// challengeSize is the size of challenges issued by Challenges. The WebAuthn
+// spec requires at least 16 bytes.
+const challengeSize = 32
+
+// Challenges issues single-use WebAuthn challenges and checks that the
+// challenges assertions are made over were issued recently and haven't been
+// used before. It's safe for concurrent use. Challenges are held in memory,
+// so services with several replicas should route the assertion to the
+// replica that issued the challenge or use their own shared store.
+type Challenges struct {
+	// TTL is how long a challenge remains valid after it's issued.
+	TTL time.Duration
+
+	// Now is used to get the current time. If nil, time.Now is used.
+	Now func() time.Time
+
+	mu     sync.Mutex
+	issued map[string]time.Time
+}
+
+// NewChallenges creates a Challenges whose challenges expire after ttl.
+func NewChallenges(ttl time.Duration) *Challenges {
+	return &Challenges{TTL: ttl}
+}
+
+// Issue creates a new challenge, to be sent to the client for signing.
+func (c *Challenges) Issue() ([]byte, error) {
+	challenge := make([]byte, challengeSize)
+	if err := rnd.Read(challenge); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+
+	if c.issued == nil {
+		c.issued = map[string]time.Time{}
+	}
+
+	// opportunistically forget expired challenges
+	for k, at := range c.issued {
+		if now.Sub(at) > c.TTL {
+			delete(c.issued, k)
+		}
+	}
+
+	c.issued[string(challenge)] = now
+
+	return challenge, nil
+}
+
+// Consume checks that the challenge was issued and hasn't expired, and
+// prevents it from being used again.
+func (c *Challenges) Consume(challenge []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	at, ok := c.issued[string(challenge)]
+	if !ok || len(challenge) != challengeSize {
+		return ErrUnknownChallenge
+	}
+	delete(c.issued, string(challenge))
+
+	if c.now().Sub(at) > c.TTL {
+		return ErrExpiredChallenge
+	}
+
+	return nil
+}
+
+func (c *Challenges) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// Assertion is a verified WebAuthn assertion. It's a helper for implementing
+// [access.WebAuthn]: services verify the assertion in a request, consume its
+// challenge, and include the result in their Access.
+type Assertion struct {
+	CredentialID []byte
+}
+
+// VerifyWebAuthnAssertion implements [access.WebAuthn].
+func (a *Assertion) VerifyWebAuthnAssertion(credentialID []byte) error {
+	switch {
+	case a == nil:
+		return errors.New("no assertion")
+	case !bytes.Equal(a.CredentialID, credentialID):
+		return errors.New("assertion made with different credential")
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,58 @@
+package webauthn
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/superfly/macaroon"
+	"github.com/superfly/macaroon/flyio"
+	"github.com/superfly/macaroon/internal/rnd"
+)
+
+type webauthnAccess struct {
+	flyio.Access
+	*Assertion
+}
+
+func TestCredential(t *testing.T) {
+	var (
+		cs  = macaroon.NewCaveatSet(&Credential{ID: []byte("cred-1")})
+		fa  = flyio.Access{OrgID: 1, Action: macaroon.ActionRead}
+		now = time.Now()
+	)
+
+	assert.NoError(t, cs.Validate(&webauthnAccess{fa, &Assertion{CredentialID: []byte("cred-1")}}))
+	assert.Error(t, cs.Validate(&webauthnAccess{fa, &Assertion{CredentialID: []byte("cred-2")}}))
+	assert.Error(t, cs.Validate(&webauthnAccess{fa, nil}))
+	assert.True(t, errors.Is(cs.Validate(&fa), macaroon.ErrInvalidAccess))
+
+	c := NewChallenges(time.Minute)
+	c.Now = func() time.Time { return now }
+
+	ch, err := c.Issue()
+	assert.NoError(t, err)
+	assert.Equal(t, challengeSize, len(ch))
+	assert.NoError(t, c.Consume(ch))
+	assert.True(t, errors.Is(c.Consume(ch), ErrUnknownChallenge))
+	assert.True(t, errors.Is(c.Consume([]byte("bogus")), ErrUnknownChallenge))
+
+	ch, err = c.Issue()
+	assert.NoError(t, err)
+	now = now.Add(2 * time.Minute)
+	assert.True(t, errors.Is(c.Consume(ch), ErrExpiredChallenge))
+}
+
+func TestSeededChallenges(t *testing.T) {
+	issue := func() []byte {
+		restore := rnd.SetReaderForTesting(rnd.NewSeededReader([]byte("seed")))
+		defer restore()
+
+		ch, err := NewChallenges(time.Minute).Issue()
+		assert.NoError(t, err)
+		return ch
+	}
+
+	assert.Equal(t, issue(), issue())
+}